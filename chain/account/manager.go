@@ -47,6 +47,15 @@ type Manager struct {
 
 // NewManager creates a new Manager. It is used to maintain account changes based on the block environment which specified by blockHash
 func NewManager(blockHash common.Hash, db protocol.ChainDB) *Manager {
+	if db == nil {
+		panic("account.NewManager is called without a database")
+	}
+	acctDb, _ := db.GetActDatabase(blockHash)
+	return NewManagerWithActDatabase(blockHash, db, acctDb)
+}
+
+// NewManagerWithActDatabase creates a new Manager like NewManager, but the accounts are loaded from acctDb. e.g. the history states from GetHistoryActDatabase
+func NewManagerWithActDatabase(blockHash common.Hash, db protocol.ChainDB, acctDb *store.AccountTrieDB) *Manager {
 	if db == nil {
 		panic("account.NewManager is called without a database")
 	}
@@ -61,16 +70,19 @@ func NewManager(blockHash common.Hash, db protocol.ChainDB) *Manager {
 		panic(err)
 	}
 
-	manager.acctDb, _ = db.GetActDatabase(blockHash)
+	manager.acctDb = acctDb
 	manager.processor = NewLogProcessor(manager)
 	return manager
 }
 
-// GetAccount loads account from cache or db, or creates a new one if it's not exist.
+// GetAccount loads account from cache or db, or creates a new one if it's not exist. It panics if the account is not available in the history states
 func (am *Manager) GetAccount(address common.Address) types.AccountAccessor {
 	cached := am.accountCache[address]
 	if cached == nil {
-		data, _ := am.acctDb.Get(address)
+		data, err := am.acctDb.Get(address)
+		if err == store.ErrActHistoryNotExist {
+			panic(err)
+		}
 		account := NewAccount(am.db, address, data)
 		cached = NewSafeAccount(am.processor, account)
 		// cache it
@@ -115,16 +127,19 @@ func (am *Manager) GetEvents() []*types.Event {
 	return events
 }
 
-// GetEvents returns all events since last reset
-// func (am *Manager) GetEventsByTx(txHash common.Hash) []*types.Event {
-// 	result := make([]*types.Event, 0)
-// 	for _, event := range am.processor.GetEvents() {
-// 		if event.TxHash == txHash {
-// 			result = append(result, event)
-// 		}
-// 	}
-// 	return result
-// }
+// GetEventsByTx returns the events of the transaction since last reset, in the order they are added
+func (am *Manager) GetEventsByTx(txHash common.Hash) []*types.Event {
+	result := make([]*types.Event, 0)
+	for _, changeLog := range am.processor.GetChangeLogs() {
+		if changeLog.LogType != AddEventLog {
+			continue
+		}
+		if event, ok := changeLog.NewVal.(*types.Event); ok && event.TxHash == txHash {
+			result = append(result, event)
+		}
+	}
+	return result
+}
 
 // GetChangeLogs returns all change logs since last reset
 func (am *Manager) GetChangeLogs() types.ChangeLogSlice {
@@ -224,7 +239,7 @@ func (am *Manager) updateVersion(logs types.ChangeLogSlice, account *SafeAccount
 		account.rawAccount.SetVersion(changeLog.LogType, nextVersion, currentHeight)
 
 		// update version trie
-		k := versionTrieKey(account.GetAddress(), changeLog.LogType)
+		k := VersionTrieKey(account.GetAddress(), changeLog.LogType)
 		if err := versionTrie.TryUpdate(k, big.NewInt(int64(changeLog.Version)).Bytes()); err != nil {
 			return err
 		}
@@ -293,7 +308,8 @@ func (am *Manager) Finalise() error {
 	return nil
 }
 
-func versionTrieKey(address common.Address, logType types.ChangeLogType) []byte {
+// VersionTrieKey returns the key of the change log version in version trie
+func VersionTrieKey(address common.Address, logType types.ChangeLogType) []byte {
 	return append(address.Bytes(), big.NewInt(int64(logType)).Bytes()...)
 }
 
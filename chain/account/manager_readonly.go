@@ -64,6 +64,17 @@ func (am *ReadOnlyManager) Reset(blockHash common.Hash) {
 	am.accountCache = make(map[common.Address]*ReadOnlyAccount)
 }
 
+// ResetHistory switches state to the block like Reset, but the states at the older stable blocks are loaded from account history. It returns error if the states at the block are not available
+func (am *ReadOnlyManager) ResetHistory(blockHash common.Hash) error {
+	acctDb, err := am.db.GetHistoryActDatabase(blockHash)
+	if err != nil {
+		return err
+	}
+	am.acctDb = acctDb
+	am.accountCache = make(map[common.Address]*ReadOnlyAccount)
+	return nil
+}
+
 // GetAccount
 func (am *ReadOnlyManager) GetAccount(address common.Address) types.AccountAccessor {
 	// 从缓存中读取account
@@ -0,0 +1,126 @@
+package chain
+
+import (
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/consensus"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"io"
+	"sync/atomic"
+)
+
+const (
+	archiveMagic   = "LEMOARCH"
+	archiveVersion = 1
+	// archiveLogInterval is the count of blocks between two progress logs
+	archiveLogInterval = 1000
+)
+
+var (
+	ErrInvalidArchive      = errors.New("invalid block archive")
+	ErrArchiveVersion      = errors.New("unsupported block archive version")
+	ErrArchiveChainID      = errors.New("block archive is from another chain")
+	ErrArchiveGenesis      = errors.New("block archive has different genesis block")
+	ErrInvalidExportRange  = errors.New("invalid export range")
+	ErrBlockChainStopped   = errors.New("block chain is stopped")
+	ErrArchiveBlockHeight  = errors.New("block archive is not continuous")
+	ErrArchiveBlockMissing = errors.New("block archive is not started from a known block")
+)
+
+// archiveHeader is the first item in block archive. The blocks encoded by RLP are followed it one by one
+type archiveHeader struct {
+	Magic       string
+	Version     uint32
+	ChainID     uint16
+	GenesisHash common.Hash
+	From        uint32
+	To          uint32
+}
+
+// ExportChain writes the stable blocks in [from, to] to the archive. The blocks contain confirms and change logs
+func ExportChain(w io.Writer, bc *BlockChain, from, to uint32) error {
+	if from == 0 || from > to || to > bc.StableBlock().Height() {
+		return ErrInvalidExportRange
+	}
+	header := &archiveHeader{
+		Magic:       archiveMagic,
+		Version:     archiveVersion,
+		ChainID:     bc.ChainID(),
+		GenesisHash: bc.Genesis().Hash(),
+		From:        from,
+		To:          to,
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return err
+	}
+	for height := from; height <= to; height++ {
+		block := bc.GetBlockByHeight(height)
+		if block == nil {
+			return ErrLoadBlock
+		}
+		if err := rlp.Encode(w, block); err != nil {
+			return err
+		}
+		if (height-from+1)%archiveLogInterval == 0 {
+			log.Infof("Exported %d blocks, height: %d", height-from+1, height)
+		}
+	}
+	log.Infof("Export blocks done. from: %d, to: %d", from, to)
+	return nil
+}
+
+// ImportChain reads blocks from the archive and inserts them to chain with full verification. The blocks which are already in chain are skipped.
+// It returns the count of inserted blocks
+func ImportChain(r io.Reader, bc *BlockChain) (int, error) {
+	stream := rlp.NewStream(r, 0)
+	header := new(archiveHeader)
+	if err := stream.Decode(header); err != nil || header.Magic != archiveMagic {
+		return 0, ErrInvalidArchive
+	}
+	if header.Version != archiveVersion {
+		return 0, ErrArchiveVersion
+	}
+	if header.ChainID != bc.ChainID() {
+		return 0, ErrArchiveChainID
+	}
+	if header.GenesisHash != bc.Genesis().Hash() {
+		return 0, ErrArchiveGenesis
+	}
+	if header.From == 0 || header.From > header.To {
+		return 0, ErrInvalidArchive
+	}
+	if header.From > bc.StableBlock().Height()+1 {
+		return 0, ErrArchiveBlockMissing
+	}
+
+	inserted := 0
+	for height := header.From; height <= header.To; height++ {
+		block := new(types.Block)
+		if err := stream.Decode(block); err != nil {
+			log.Errorf("Decode block %d from archive failed: %v", height, err)
+			return inserted, ErrInvalidArchive
+		}
+		if block.Height() != height {
+			return inserted, ErrArchiveBlockHeight
+		}
+		if atomic.LoadInt32(&bc.stopped) != 0 {
+			return inserted, ErrBlockChainStopped
+		}
+		err := bc.InsertBlock(block)
+		if err == consensus.ErrIgnoreBlock {
+			continue
+		}
+		if err != nil {
+			log.Errorf("Import block %s failed: %v", block.ShortString(), err)
+			return inserted, err
+		}
+		inserted++
+		if inserted%archiveLogInterval == 0 {
+			log.Infof("Imported %d blocks, height: %d", inserted, height)
+		}
+	}
+	log.Infof("Import blocks done. inserted: %d, stable height: %d", inserted, bc.StableBlock().Height())
+	return inserted, nil
+}
@@ -0,0 +1,112 @@
+package chain
+
+import (
+	"bytes"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
+	"github.com/LemoFoundationLtd/lemochain-core/common/flag"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func newArchiveTestChain(t *testing.T, dir string, genesis *Genesis, chainID uint16) *BlockChain {
+	_ = os.RemoveAll(dir)
+	db := store.NewChainDataBase(dir)
+	SetupGenesisBlock(db, genesis)
+	bc, err := NewBlockChain(Config{ChainID: chainID, MineTimeout: 10000}, deputynode.NewManager(5, db), db, flag.CmdFlags{}, txpool.NewTxPool())
+	assert.NoError(t, err)
+	return bc
+}
+
+func closeArchiveTestChain(bc *BlockChain, dir string) {
+	bc.Stop()
+	_ = bc.db.Close()
+	_ = os.RemoveAll(dir)
+}
+
+func TestExportChain_ImportChain(t *testing.T) {
+	srcDir, dstDir := GetStorePath()+"_src", GetStorePath()+"_dst"
+	genesis := &Genesis{
+		Time:            uint32(time.Now().Unix()) - 100,
+		GasLimit:        params.GenesisGasLimit,
+		Founder:         testDeputies[0].MinerAddress,
+		DeputyNodesInfo: testDeputies.ToDeputyNodesInfo()[:1],
+	}
+	deputynode.SetSelfNodeKey(testDeputies[0].PrivateKey)
+	src := newArchiveTestChain(t, srcDir, genesis, testChainID)
+	defer closeArchiveTestChain(src, srcDir)
+	// only one deputy, so every mined block is stable
+	for i := 0; i < 3; i++ {
+		src.MineBlock(1000)
+	}
+	assert.Equal(t, uint32(3), src.StableBlock().Height())
+
+	// invalid range
+	buf := new(bytes.Buffer)
+	assert.Equal(t, ErrInvalidExportRange, ExportChain(buf, src, 0, 3))
+	assert.Equal(t, ErrInvalidExportRange, ExportChain(buf, src, 2, 1))
+	assert.Equal(t, ErrInvalidExportRange, ExportChain(buf, src, 1, 4))
+
+	// export part of chain
+	buf.Reset()
+	assert.NoError(t, ExportChain(buf, src, 2, 3))
+	partArchive := buf.Bytes()
+	buf = new(bytes.Buffer)
+	assert.NoError(t, ExportChain(buf, src, 1, 3))
+	archive := buf.Bytes()
+
+	dst := newArchiveTestChain(t, dstDir, genesis, testChainID)
+	defer closeArchiveTestChain(dst, dstDir)
+	// not started from a known block
+	_, err := ImportChain(bytes.NewReader(partArchive), dst)
+	assert.Equal(t, ErrArchiveBlockMissing, err)
+	// invalid data
+	_, err = ImportChain(bytes.NewReader([]byte{1, 2, 3}), dst)
+	assert.Equal(t, ErrInvalidArchive, err)
+	// truncated
+	count, err := ImportChain(bytes.NewReader(archive[:len(archive)-10]), dst)
+	assert.Equal(t, ErrInvalidArchive, err)
+	assert.Equal(t, 2, count)
+
+	// success. The existed blocks are skipped
+	count, err = ImportChain(bytes.NewReader(archive), dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, src.StableBlock().Hash(), dst.StableBlock().Hash())
+	for height := uint32(1); height <= 3; height++ {
+		assert.Equal(t, src.GetBlockByHeight(height).Hash(), dst.GetBlockByHeight(height).Hash())
+	}
+}
+
+func TestImportChain_header(t *testing.T) {
+	dir := GetStorePath() + "_header"
+	bc := newArchiveTestChain(t, dir, DefaultGenesisConfig(), testChainID)
+	defer closeArchiveTestChain(bc, dir)
+
+	encode := func(header *archiveHeader) []byte {
+		data, err := rlp.EncodeToBytes(header)
+		assert.NoError(t, err)
+		return data
+	}
+	tests := []struct {
+		header *archiveHeader
+		err    error
+	}{
+		{&archiveHeader{Magic: "LEMO", Version: archiveVersion, ChainID: testChainID, GenesisHash: bc.Genesis().Hash(), From: 1, To: 1}, ErrInvalidArchive},
+		{&archiveHeader{Magic: archiveMagic, Version: 2, ChainID: testChainID, GenesisHash: bc.Genesis().Hash(), From: 1, To: 1}, ErrArchiveVersion},
+		{&archiveHeader{Magic: archiveMagic, Version: archiveVersion, ChainID: 1, GenesisHash: bc.Genesis().Hash(), From: 1, To: 1}, ErrArchiveChainID},
+		{&archiveHeader{Magic: archiveMagic, Version: archiveVersion, ChainID: testChainID, From: 1, To: 1}, ErrArchiveGenesis},
+		{&archiveHeader{Magic: archiveMagic, Version: archiveVersion, ChainID: testChainID, GenesisHash: bc.Genesis().Hash(), From: 2, To: 1}, ErrInvalidArchive},
+		// no block after header
+		{&archiveHeader{Magic: archiveMagic, Version: archiveVersion, ChainID: testChainID, GenesisHash: bc.Genesis().Hash(), From: 1, To: 1}, ErrInvalidArchive},
+	}
+	for i, test := range tests {
+		_, err := ImportChain(bytes.NewReader(encode(test.header)), bc)
+		assert.Equal(t, test.err, err, "index=%d", i)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/store"
 	db "github.com/LemoFoundationLtd/lemochain-core/store/protocol"
 	"sync/atomic"
+	"time"
 )
 
 var ErrNoGenesis = errors.New("can't get genesis block")
@@ -32,15 +33,25 @@ type BlockChain struct {
 	am     *account.Manager
 	dm     *deputynode.Manager
 	engine *consensus.DPoVP
+	route  *subscribe.CentralRouteSub
 
-	stopped int32
-	quitCh  chan struct{}
+	stopped   int32
+	quitCh    chan struct{}
+	pruneDone chan struct{} // closed after the prune loop exits. It is nil if the loop is not started
 }
 
 // Config holds chain options.
 type Config struct {
 	ChainID     uint16
 	MineTimeout uint64 // milliseconds
+	// Route is the event bus to send chain events. It is the global one if nil
+	Route *subscribe.CentralRouteSub
+	// Clock returns the current time. It is time.Now if nil
+	Clock func() time.Time
+	// PruneBlocks is the count of recent stable blocks whose states are kept. The states in term snapshot blocks are always kept. All states are kept if it is 0
+	PruneBlocks uint32
+	// CompactInterval is the interval of pruning states and compacting database files. It is disabled if it is 0
+	CompactInterval time.Duration
 }
 
 func NewBlockChain(config Config, dm *deputynode.Manager, db db.ChainDB, flags flag.CmdFlags, txPool *txpool.TxPool) (bc *BlockChain, err error) {
@@ -49,8 +60,12 @@ func NewBlockChain(config Config, dm *deputynode.Manager, db db.ChainDB, flags f
 		db:      db,
 		dm:      dm,
 		flags:   flags,
+		route:   config.Route,
 		quitCh:  make(chan struct{}),
 	}
+	if bc.route == nil {
+		bc.route = subscribe.DefaultRoute()
+	}
 	bc.genesisBlock, err = bc.db.GetBlockByHeight(0)
 	if err != nil {
 		return nil, ErrNoGenesis
@@ -70,12 +85,22 @@ func NewBlockChain(config Config, dm *deputynode.Manager, db db.ChainDB, flags f
 		ChainID:       bc.chainID,
 		MineTimeout:   config.MineTimeout,
 		MinerExtra:    params.MinerExtra,
+		Clock:         config.Clock,
 	}
 	txGuard := txpool.NewTxGuard(latestStableBlock.Time())
 	bc.engine = consensus.NewDPoVP(dpovpCfg, bc.db, bc.dm, bc.am, bc, txPool, txGuard)
 
 	bc.initTxPool(latestStableBlock, txPool, txGuard)
 	go bc.runFeedTranspondLoop()
+	if config.CompactInterval > 0 {
+		// the states in memory are not compacted
+		if chainDB, ok := db.(*store.ChainDatabase); ok && chainDB.Backend != store.BackendMemory {
+			bc.pruneDone = make(chan struct{})
+			go bc.runPruneLoop(newStatePruner(bc, chainDB, config.PruneBlocks), config.CompactInterval)
+		} else {
+			log.Warnf("The database doesn't support compaction")
+		}
+	}
 
 	log.Info("BlockChain is ready", "stableHeight", bc.StableBlock().Height(), "stableHash", bc.StableBlock().Hash(), "currentHeight", bc.CurrentBlock().Height(), "currentHash", bc.CurrentBlock().Hash())
 	return bc, nil
@@ -90,9 +115,11 @@ func (bc *BlockChain) initTxPool(block *types.Block, txPool *txpool.TxPool, txGu
 	stableTime := block.Time()
 	height := block.Height()
 	iter := block
+	start := block
 	// 需要初始化的block交易条件为，区块时间戳距离最新的稳定区块的时间戳不大于30分钟
 	for stableTime-iter.Time() <= uint32(params.MaxTxLifeTime) {
 		txGuard.SaveBlock(iter)
+		start = iter
 		if height <= 0 {
 			break
 		}
@@ -100,11 +127,12 @@ func (bc *BlockChain) initTxPool(block *types.Block, txPool *txpool.TxPool, txGu
 		height--
 		iter = bc.GetBlockByHeight(height)
 		if iter == nil {
-			log.Errorf("get block by height error when init tx pool. height: %d.", height)
-			panic(ErrLoadBlock)
+			// the blocks before state snapshot are not synchronized
+			log.Warnf("block is missing when init tx pool. height: %d. the chain may be synchronized from state snapshot", height)
+			break
 		}
 	}
-	log.Debugf("Finish init tx pool, start block height: %d timestamp: %d to end block height: %d timestamp: %d. ", iter.Height(), iter.Time(), block.Height(), block.Time())
+	log.Debugf("Finish init tx pool, start block height: %d timestamp: %d to end block height: %d timestamp: %d. ", start.Height(), start.Time(), block.Height(), block.Time())
 }
 
 func (bc *BlockChain) TxGuard() *txpool.TxGuard {
@@ -136,13 +164,13 @@ func (bc *BlockChain) runFeedTranspondLoop() {
 	for {
 		select {
 		case block := <-currentCh:
-			go subscribe.Send(subscribe.NewCurrentBlock, block)
+			go bc.route.Send(subscribe.NewCurrentBlock, block)
 		case block := <-stableCh:
-			go subscribe.Send(subscribe.NewStableBlock, block)
+			go bc.route.Send(subscribe.NewStableBlock, block)
 		case confirm := <-confirmCh:
-			go subscribe.Send(subscribe.NewConfirm, confirm)
+			go bc.route.Send(subscribe.NewConfirm, confirm)
 		case confirmsInfo := <-fetchConfirmCh:
-			go subscribe.Send(subscribe.FetchConfirms, confirmsInfo)
+			go bc.route.Send(subscribe.FetchConfirms, confirmsInfo)
 		case <-bc.quitCh:
 			currentSub.Unsubscribe()
 			stableSub.Unsubscribe()
@@ -153,6 +181,33 @@ func (bc *BlockChain) runFeedTranspondLoop() {
 	}
 }
 
+// runPruneLoop prunes states and compacts database files periodically
+func (bc *BlockChain) runPruneLoop(pruner *statePruner, interval time.Duration) {
+	defer close(bc.pruneDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := pruner.run(bc.quitCh); err != nil && err != store.ErrCompactAborted {
+				log.Errorf("Prune states failed: %v", err)
+			}
+		case <-bc.quitCh:
+			return
+		}
+	}
+}
+
+// SubscribeCurrent subscribe the current block update notification. The blocks may be not continuous
+func (bc *BlockChain) SubscribeCurrent(ch chan *types.Block) subscribe.Subscription {
+	return bc.engine.SubscribeCurrent(ch)
+}
+
+// SubscribeStable subscribe the stable block update notification
+func (bc *BlockChain) SubscribeStable(ch chan *types.Block) subscribe.Subscription {
+	return bc.engine.SubscribeStable(ch)
+}
+
 // Genesis genesis block
 func (bc *BlockChain) Genesis() *types.Block {
 	return bc.genesisBlock
@@ -229,7 +284,7 @@ func (bc *BlockChain) MineBlock(txProcessTimeout int64) {
 	block, err := bc.engine.MineBlock(txProcessTimeout)
 	// broadcast
 	if err == nil {
-		go subscribe.Send(subscribe.NewMinedBlock, block)
+		go bc.route.Send(subscribe.NewMinedBlock, block)
 	}
 }
 
@@ -243,6 +298,21 @@ func (bc *BlockChain) InsertBlock(block *types.Block) error {
 	return err
 }
 
+// InsertSnapshot sets the block with unverified synchronized state as the chain base, then the blocks after it could be inserted
+func (bc *BlockChain) InsertSnapshot(snapshot *store.Snapshot) error {
+	return bc.engine.InsertSnapshot(snapshot)
+}
+
+// GetSnapshotAccounts returns at most count accounts from the start address in the state of stable block
+func (bc *BlockChain) GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error) {
+	return bc.db.GetSnapshotAccounts(blockHash, start, count)
+}
+
+// GetNodeData returns the trie node or contract code by hash
+func (bc *BlockChain) GetNodeData(hash common.Hash) ([]byte, error) {
+	return bc.db.GetNodeData(hash)
+}
+
 // InsertConfirms receive confirm package from net connection
 func (bc *BlockChain) InsertConfirms(height uint32, blockHash common.Hash, sigList []types.SignData) {
 	if atomic.LoadInt32(&bc.stopped) != 0 {
@@ -264,7 +334,7 @@ func (bc *BlockChain) FetchConfirm(height uint32) error {
 	fetchList := []network.GetConfirmInfo{
 		{Height: height, Hash: block.Hash()},
 	}
-	go subscribe.Send(subscribe.FetchConfirms, fetchList)
+	go bc.route.Send(subscribe.FetchConfirms, fetchList)
 	return nil
 }
 
@@ -279,5 +349,9 @@ func (bc *BlockChain) Stop() {
 		return
 	}
 	close(bc.quitCh)
+	// the database can't be closed until compaction is stopped
+	if bc.pruneDone != nil {
+		<-bc.pruneDone
+	}
 	log.Info("BlockChain stop")
 }
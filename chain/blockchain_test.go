@@ -124,7 +124,7 @@ func newTestBlockChain(attendedDeputyCount int) *BlockChain {
 
 	// max deputy count is 5
 	dm := deputynode.NewManager(5, db)
-	blockChain, err := NewBlockChain(Config{testChainID, 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
+	blockChain, err := NewBlockChain(Config{ChainID: testChainID, MineTimeout: 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
 	if err != nil {
 		panic(err)
 	}
@@ -182,7 +182,7 @@ func newTestBlock(bc *BlockChain) *types.Block {
 	deputynode.SetSelfNodeKey(deputy.PrivateKey)
 
 	// testTx := createTestTx()
-	block, _, err := assembler.MineBlock(header, nil, 1000)
+	block, _, _, err := assembler.MineBlock(header, nil, 1000)
 	if err != nil {
 		panic(err)
 	}
@@ -196,12 +196,12 @@ func TestNewBlockChain(t *testing.T) {
 
 	// no genesis
 	dm := deputynode.NewManager(5, db)
-	_, err := NewBlockChain(Config{testChainID, 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
+	_, err := NewBlockChain(Config{ChainID: testChainID, MineTimeout: 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
 	assert.Equal(t, ErrNoGenesis, err)
 
 	// success
 	genesisBlock := SetupGenesisBlock(db, nil)
-	blockChain, err := NewBlockChain(Config{testChainID, 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
+	blockChain, err := NewBlockChain(Config{ChainID: testChainID, MineTimeout: 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
 	assert.NoError(t, err)
 	assert.Equal(t, genesisBlock, blockChain.engine.StableBlock())
 	assert.Equal(t, genesisBlock, blockChain.engine.CurrentBlock())
@@ -19,6 +19,7 @@ type BlockAssembler struct {
 	dm          *deputynode.Manager
 	txProcessor *transaction.TxProcessor
 	canLoader   CandidateLoader
+	clock       func() time.Time
 }
 
 func NewBlockAssembler(am *account.Manager, dm *deputynode.Manager, txProcessor *transaction.TxProcessor, canLoader CandidateLoader) *BlockAssembler {
@@ -27,48 +28,49 @@ func NewBlockAssembler(am *account.Manager, dm *deputynode.Manager, txProcessor
 		dm:          dm,
 		txProcessor: txProcessor,
 		canLoader:   canLoader,
+		clock:       time.Now,
 	}
 }
 
-// Seal packages all products into a block
-func (ba *BlockAssembler) RunBlock(block *types.Block) (*types.Block, error) {
+// Seal packages all products into a block. The transaction receipts are returned with the block
+func (ba *BlockAssembler) RunBlock(block *types.Block) (*types.Block, types.Receipts, error) {
 	// execute tx
-	gasUsed, err := ba.txProcessor.Process(block.Header, block.Txs)
+	gasUsed, receipts, err := ba.txProcessor.Process(block.Header, block.Txs)
 	if err != nil {
 		log.Errorf("processor internal error: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 	// Finalize accounts
 	if err = ba.Finalize(block.Header.Height); err != nil {
 		log.Errorf("Finalize accounts error: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 	// seal a new block
 	newBlock := ba.Seal(block.Header, ba.am.GetTxsProduct(block.Txs, gasUsed), block.Confirms)
-	return newBlock, nil
+	return newBlock, receipts, nil
 }
 
-// MineBlock packages all products into a block
-func (ba *BlockAssembler) MineBlock(header *types.Header, txs types.Transactions, applyTxTimeout int64) (*types.Block, types.Transactions, error) {
+// MineBlock packages all products into a block. The transaction receipts are returned with the block
+func (ba *BlockAssembler) MineBlock(header *types.Header, txs types.Transactions, applyTxTimeout int64) (*types.Block, types.Receipts, types.Transactions, error) {
 	// execute tx
-	packagedTxs, invalidTxs, gasUsed := ba.txProcessor.ApplyTxs(header, txs, applyTxTimeout)
+	packagedTxs, invalidTxs, receipts, gasUsed := ba.txProcessor.ApplyTxs(header, txs, applyTxTimeout)
 	log.Debug("ApplyTxs ok")
 	// Finalize accounts
 	if err := ba.Finalize(header.Height); err != nil {
 		log.Errorf("Finalize accounts error: %v", err)
-		return nil, invalidTxs, err
+		return nil, nil, invalidTxs, err
 	}
 	// seal block
 	newBlock := ba.Seal(header, ba.am.GetTxsProduct(packagedTxs, gasUsed), nil)
 	// sign block
-	signData, err := SignBlock(newBlock.Hash())
+	signData, err := SignBlock(newBlock.Hash(), ba.dm.SelfNodeKey())
 	if err != nil {
 		log.Errorf("Sign for block failed! block hash:%s", newBlock.Hash().Hex())
-		return nil, invalidTxs, err
+		return nil, nil, invalidTxs, err
 	}
 	newBlock.Header.SignData = signData
 
-	return newBlock, invalidTxs, nil
+	return newBlock, receipts, invalidTxs, nil
 }
 
 func (ba *BlockAssembler) PrepareHeader(parentHeader *types.Header, extra string) (*types.Header, error) {
@@ -88,7 +90,7 @@ func (ba *BlockAssembler) PrepareHeader(parentHeader *types.Header, extra string
 	// allow 1 second time error
 	// but next block's time can't be small than parent block
 	parTime := parentHeader.Time
-	blockTime := uint32(time.Now().Unix())
+	blockTime := uint32(ba.clock().Unix())
 	if parTime > blockTime {
 		blockTime = parTime
 	}
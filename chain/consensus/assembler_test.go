@@ -602,7 +602,7 @@ func TestBlockAssembler_RunBlock(t *testing.T) {
 	// genesis block
 	rawBlock := &types.Block{Header: &types.Header{Height: 0}, Txs: types.Transactions{}}
 	assert.PanicsWithValue(t, transaction.ErrInvalidGenesis, func() {
-		_, _ = ba.RunBlock(rawBlock)
+		_, _, _ = ba.RunBlock(rawBlock)
 	})
 
 	// prepare a genesis block (and balance) for test
@@ -610,13 +610,14 @@ func TestBlockAssembler_RunBlock(t *testing.T) {
 
 	// process block fail
 	rawBlock = &types.Block{Header: &types.Header{Height: 1}, Txs: types.Transactions{tx}}
-	_, err = ba.RunBlock(rawBlock)
+	_, _, err = ba.RunBlock(rawBlock)
 	assert.Equal(t, transaction.ErrInvalidTxInBlock, err)
 
 	// process block success
 	rawBlock = &types.Block{Header: &types.Header{Height: 1, ParentHash: genesisBlock.Hash(), GasLimit: 10000000, MinerAddress: firstTerm.Nodes[0].MinerAddress}, Txs: types.Transactions{tx}}
-	newBlock, err := ba.RunBlock(rawBlock)
+	newBlock, receipts, err := ba.RunBlock(rawBlock)
 	assert.NoError(t, err)
+	assert.Equal(t, 1, len(receipts))
 	assert.Equal(t, rawBlock.Txs, newBlock.Txs)
 	assert.Equal(t, rawBlock.MinerAddress(), newBlock.MinerAddress())
 	assert.Equal(t, uint64(21204), newBlock.GasUsed())
@@ -637,9 +638,10 @@ func TestBlockAssembler_MineBlock(t *testing.T) {
 
 	header := &types.Header{Height: 1, ParentHash: genesisBlock.Hash(), GasLimit: 10000000, MinerAddress: firstTerm.Nodes[0].MinerAddress}
 	txs := types.Transactions{tx, invalidTx}
-	newBlock, invalidTxs, err := ba.MineBlock(header, txs, 1000)
+	newBlock, receipts, invalidTxs, err := ba.MineBlock(header, txs, 1000)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(newBlock.Txs))
+	assert.Equal(t, 1, len(receipts))
 	assert.Equal(t, 1, len(invalidTxs))
 	assert.NotEqual(t, nil, newBlock.Header.SignData)
 }
@@ -1,30 +1,36 @@
 package consensus
 
 import (
-	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"crypto/ecdsa"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"sync"
 )
 
 // cache confirm to save CPU. This confirm may not be used at last
 var sigCache struct {
+	Key  *ecdsa.PrivateKey
 	Hash common.Hash
 	Sig  []byte
+	lock sync.Mutex
 }
 
 // SignBlock sign a block hash by node key
-func SignBlock(blockHash common.Hash) ([]byte, error) {
-	if sigCache.Hash == blockHash {
+func SignBlock(blockHash common.Hash, key *ecdsa.PrivateKey) ([]byte, error) {
+	sigCache.lock.Lock()
+	defer sigCache.lock.Unlock()
+	if sigCache.Key == key && sigCache.Hash == blockHash {
 		return sigCache.Sig, nil
 	}
 
 	// sign
-	sig, err := crypto.Sign(blockHash[:], deputynode.GetSelfNodeKey())
+	sig, err := crypto.Sign(blockHash[:], key)
 	if err != nil {
 		return []byte{}, err
 	}
 
 	// save to cache
+	sigCache.Key = key
 	sigCache.Hash = blockHash
 	sigCache.Sig = sig
 
@@ -1,7 +1,6 @@
 package consensus
 
 import (
-	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/stretchr/testify/assert"
@@ -10,12 +9,11 @@ import (
 
 func TestSignBlock(t *testing.T) {
 	key, _ := crypto.GenerateKey()
-	deputynode.SetSelfNodeKey(key)
 	block := types.Block{Header: &types.Header{}}
 	hash := block.Hash()
 
 	// sign and recover
-	sig, err := SignBlock(hash)
+	sig, err := SignBlock(hash, key)
 	assert.NoError(t, err)
 	block.Header.SignData = sig
 	nodeID, err := block.SignerNodeID()
@@ -24,21 +22,26 @@ func TestSignBlock(t *testing.T) {
 
 	// sign another hash
 	block.Header.Height++
-	sig2, err := SignBlock(block.Hash())
+	sig2, err := SignBlock(block.Hash(), key)
 	assert.NoError(t, err)
 	assert.NotEqual(t, sig, sig2)
+
+	// sign the same hash by another key
+	key2, _ := crypto.GenerateKey()
+	sig3, err := SignBlock(block.Hash(), key2)
+	assert.NoError(t, err)
+	assert.NotEqual(t, sig2, sig3)
 }
 
 func BenchmarkSignBlock(b *testing.B) {
 	key, _ := crypto.GenerateKey()
-	deputynode.SetSelfNodeKey(key)
 	block := types.Block{Header: &types.Header{}}
 	hash := block.Hash()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		for j := 0; j < 400; j++ {
-			_, err := SignBlock(hash)
+			_, err := SignBlock(hash, key)
 			assert.NoError(b, err)
 		}
 	}
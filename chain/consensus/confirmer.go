@@ -153,11 +153,16 @@ func (c *Confirmer) SetLastSig(block *types.Block) {
 }
 
 func IsMinedByself(block *types.Block) bool {
+	return isMinedBy(block, deputynode.GetSelfNodeID())
+}
+
+// isMinedBy tests if the block is signed by the node
+func isMinedBy(block *types.Block, selfNodeID []byte) bool {
 	nodeID, err := block.SignerNodeID()
 	if err != nil {
 		return false
 	}
-	return bytes.Compare(nodeID, deputynode.GetSelfNodeID()) == 0
+	return bytes.Compare(nodeID, selfNodeID) == 0
 }
 
 // TryConfirmStable try to sign and save a confirm into a stable block
@@ -197,7 +202,7 @@ func (c *Confirmer) SaveConfirm(block *types.Block, sigList []types.SignData) (*
 
 // confirmBlock sign a block and return signData
 func (c *Confirmer) confirmBlock(block *types.Block) (types.SignData, error) {
-	sig, err := SignBlock(block.Hash())
+	sig, err := SignBlock(block.Hash(), c.dm.SelfNodeKey())
 	if err != nil {
 		log.Error("sign for confirm data error", "err", err)
 		return types.SignData{}, err
@@ -12,6 +12,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
 	"github.com/LemoFoundationLtd/lemochain-core/metrics"
 	"github.com/LemoFoundationLtd/lemochain-core/network"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
 	"github.com/LemoFoundationLtd/lemochain-core/store/protocol"
 	"sync"
 	"time"
@@ -32,6 +33,7 @@ type DPoVP struct {
 	txPool     *txpool.TxPool
 	txGuard    *txpool.TxGuard
 	minerExtra string // Extra data in mined block header. It is shorter than 256bytes
+	clock      func() time.Time
 
 	stableManager *StableManager           // used to process stable logic
 	forkManager   *ForkManager             // forks manager
@@ -68,9 +70,15 @@ func NewDPoVP(config Config, db protocol.ChainDB, dm *deputynode.Manager, am *ac
 		confirmer:     NewConfirmer(dm, db, db, db),
 		minerExtra:    config.MinerExtra,
 		logForks:      config.LogForks,
+		clock:         config.Clock,
+	}
+	if dpovp.clock == nil {
+		dpovp.clock = time.Now
 	}
 	dpovp.validator = NewValidator(config.MineTimeout, db, dm, txGuard, dpovp)
+	dpovp.validator.clock = dpovp.clock
 	dpovp.assembler = NewBlockAssembler(am, dm, dpovp.processor, dpovp)
+	dpovp.assembler.clock = dpovp.clock
 	return dpovp
 }
 
@@ -128,7 +136,7 @@ func (dp *DPoVP) MineBlock(txProcessTimeout int64) (*types.Block, error) {
 	}
 
 	txs := dp.txPool.GetTxs(header.Time, params.MaxTxsForMiner)
-	block, invalidTxs, err := dp.assembler.MineBlock(header, txs, txProcessTimeout)
+	block, receipts, invalidTxs, err := dp.assembler.MineBlock(header, txs, txProcessTimeout)
 	if err != nil {
 		if err == deputynode.ErrNoStableTerm {
 			// fetch last snapshot block's confirm
@@ -142,7 +150,7 @@ func (dp *DPoVP) MineBlock(txProcessTimeout int64) (*types.Block, error) {
 	dp.txPool.DelTxs(invalidTxs)
 
 	// save
-	if err = dp.saveNewBlock(block); err != nil {
+	if err = dp.saveNewBlock(block, receipts); err != nil {
 		return nil, err
 	}
 	return block, nil
@@ -161,7 +169,7 @@ func (dp *DPoVP) InsertBlock(rawBlock *types.Block) (*types.Block, error) {
 	log.Debug("🎁 Start insert block to chain", "block", rawBlock.ShortString(), "parent", rawBlock.ParentHash())
 
 	// verify and create a new block witch filled by transaction products
-	block, err := dp.VerifyAndSeal(rawBlock)
+	block, receipts, err := dp.VerifyAndSeal(rawBlock)
 	if err != nil {
 		verifyBlockMeter.Mark(1) // 统计调用频率用
 		log.Errorf("block verify failed: %v", err)
@@ -175,7 +183,7 @@ func (dp *DPoVP) InsertBlock(rawBlock *types.Block) (*types.Block, error) {
 	}
 
 	// save
-	if err = dp.saveNewBlock(block); err != nil {
+	if err = dp.saveNewBlock(block, receipts); err != nil {
 		return nil, err
 	}
 
@@ -190,15 +198,15 @@ func (dp *DPoVP) InsertBlock(rawBlock *types.Block) (*types.Block, error) {
 }
 
 // saveNewBlock save block then update the current and stable block
-func (dp *DPoVP) saveNewBlock(block *types.Block) error {
+func (dp *DPoVP) saveNewBlock(block *types.Block, receipts types.Receipts) error {
 	// save
-	if err := dp.saveToStore(block); err != nil {
+	if err := dp.saveToStore(block, receipts); err != nil {
 		return err
 	}
 	dp.txGuard.SaveBlock(block)
 
 	// save last sig because we are the miner. If we clear db and restart, this will be useful
-	if IsMinedByself(block) {
+	if isMinedBy(block, dp.dm.SelfNodeID()) {
 		dp.confirmer.SetLastSig(block)
 	}
 	// try update stable block if there are enough confirms
@@ -236,6 +244,27 @@ func (dp *DPoVP) saveNewBlock(block *types.Block) error {
 	return nil
 }
 
+// InsertSnapshot sets the block with synchronized state as the chain base and current block. The deputy terms before the block should have been saved in deputy manager.
+// The account fields in state are not verified, so the block is not announced as a new stable block
+func (dp *DPoVP) InsertSnapshot(snapshot *store.Snapshot) error {
+	dp.chainLock.Lock()
+	defer dp.chainLock.Unlock()
+
+	block := snapshot.Block
+	if err := dp.db.SetSnapshotBlock(snapshot); err != nil {
+		log.Error("Save snapshot block fail", "block", block.ShortString(), "err", err)
+		return ErrSaveBlock
+	}
+	oldCurrent := dp.CurrentBlock()
+	dp.forkManager.SetHeadBlock(block)
+	dp.txGuard.SaveBlock(block)
+	dp.onStableChanged(block)
+	log.Info("Insert unverified state snapshot", "block", block.ShortString(), "oldCurrent", oldCurrent.ShortString())
+
+	go dp.currentFeed.Send(block)
+	return nil
+}
+
 // onCurrentChanged
 func (dp *DPoVP) onCurrentChanged(oldCurrent, newCurrent *types.Block) {
 	if newCurrent.ParentHash() == oldCurrent.Hash() {
@@ -265,7 +294,7 @@ func (dp *DPoVP) onStableChanged(newStable *types.Block) {
 }
 
 // saveToStore save block and account state to db. They are still unstable now
-func (dp *DPoVP) saveToStore(block *types.Block) error {
+func (dp *DPoVP) saveToStore(block *types.Block, receipts types.Receipts) error {
 	hash := block.Hash()
 	if err := dp.db.SetBlock(hash, block); err != nil {
 		log.Error("Insert block to cache fail", "block", block.ShortString())
@@ -273,6 +302,13 @@ func (dp *DPoVP) saveToStore(block *types.Block) error {
 	}
 	log.Info("Save block to store", "block", block.ShortString(), "time", block.Time(), "parent", block.ParentHash())
 
+	if receipts != nil {
+		if err := dp.db.SetReceipts(hash, receipts); err != nil {
+			log.Error("Save receipts error!", "block", block.ShortString(), "err", err)
+			return ErrSaveBlock
+		}
+	}
+
 	if err := dp.am.Save(hash); err != nil {
 		log.Error("Save account error!", "block", block.ShortString(), "err", err)
 		return ErrSaveAccount
@@ -282,7 +318,7 @@ func (dp *DPoVP) saveToStore(block *types.Block) error {
 
 func (dp *DPoVP) broadcastConfirm(block *types.Block, sig types.SignData) {
 	// only broadcast confirm info within 3 minutes
-	if time.Now().Unix()-int64(block.Time()) >= 3*60 {
+	if dp.clock().Unix()-int64(block.Time()) >= 3*60 {
 		return
 	}
 
@@ -381,7 +417,7 @@ func (dp *DPoVP) isIgnorableBlock(block *types.Block) bool {
 }
 
 // VerifyAndSeal verify block then create a new block
-func (dp *DPoVP) VerifyAndSeal(block *types.Block) (*types.Block, error) {
+func (dp *DPoVP) VerifyAndSeal(block *types.Block) (*types.Block, types.Receipts, error) {
 	// verify every things that can be verified before tx processing
 	if err := dp.validator.VerifyBeforeTxProcess(block, dp.processor.ChainID); err != nil {
 		if err == deputynode.ErrNoStableTerm {
@@ -389,7 +425,7 @@ func (dp *DPoVP) VerifyAndSeal(block *types.Block) (*types.Block, error) {
 			snapshotHeight := deputynode.GetLastSnapshotHeight(block.Height() - 1)
 			go dp.FetchRemoteConfirms(snapshotHeight, snapshotHeight, 0)
 		}
-		return nil, ErrInvalidBlock
+		return nil, nil, ErrInvalidBlock
 	}
 	// filter the valid confirms
 	confirms := block.Confirms
@@ -398,21 +434,21 @@ func (dp *DPoVP) VerifyAndSeal(block *types.Block) (*types.Block, error) {
 	log.Debug("Verify confirms done", "validCount", len(block.Confirms))
 
 	// parse block, change local state and seal a new block
-	newBlock, err := dp.assembler.RunBlock(block)
+	newBlock, receipts, err := dp.assembler.RunBlock(block)
 	if err != nil {
 		if err == transaction.ErrInvalidTxInBlock {
-			return nil, ErrInvalidBlock
+			return nil, nil, ErrInvalidBlock
 		}
 		log.Errorf("RunBlock internal error: %v", err)
 		// panic("processor internal error")
-		return nil, err
+		return nil, nil, err
 	}
 
 	// verify the things computed by tx
 	if err := dp.validator.VerifyAfterTxProcess(block, newBlock); err != nil {
-		return nil, ErrInvalidBlock
+		return nil, nil, ErrInvalidBlock
 	}
-	return newBlock, nil
+	return newBlock, receipts, nil
 }
 
 func (dp *DPoVP) InsertConfirms(height uint32, blockHash common.Hash, sigList []types.SignData) error {
@@ -142,7 +142,7 @@ func newTestBlock(dp *DPoVP, parentHeader *types.Header, deputyInfos deputyTestD
 	if err != nil {
 		panic(err)
 	}
-	block, invalidTxs, err := dp.assembler.MineBlock(header, txs, 3000)
+	block, _, invalidTxs, err := dp.assembler.MineBlock(header, txs, 3000)
 	if err != nil {
 		panic(err)
 	}
@@ -291,11 +291,15 @@ func TestDPoVP_saveNewBlock(t *testing.T) {
 	// let me be the miner of block1
 	deputynode.SetSelfNodeKey(deputyInfos.FindByMiner(block1.MinerAddress()).PrivateKey)
 	// 0───1 <-current
-	err = dp.saveNewBlock(block1)
+	receipts := types.Receipts{&types.Receipt{TxHash: tx1.Hash(), GasUsed: 21000}}
+	err = dp.saveNewBlock(block1, receipts)
 	assert.NoError(t, err)
 	blockInDb, err := dp.db.GetUnConfirmByHeight(block1.Height(), block1.Hash())
 	assert.NoError(t, err)
 	assert.Equal(t, block1, blockInDb)
+	receiptsInDb, err := dp.db.GetReceipts(block1.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, receipts, receiptsInDb)
 	assert.Equal(t, true, dp.TxGuard().ExistTx(block1.Hash(), tx1))
 	assert.Equal(t, false, dp.TxGuard().ExistTx(block1.Hash(), tx2))
 	assert.Equal(t, block1.Hash(), dp.confirmer.lastSig.Hash)
@@ -310,7 +314,7 @@ func TestDPoVP_saveNewBlock(t *testing.T) {
 	avoidMiner(block1q.MinerAddress(), deputyInfos)
 	// 0─┬─1 <-current
 	//   └─1'
-	err = dp.saveNewBlock(block1q)
+	err = dp.saveNewBlock(block1q, nil)
 	assert.NoError(t, err)
 	blockInDb, err = dp.db.GetUnConfirmByHeight(block1q.Height(), block1q.Hash())
 	assert.NoError(t, err)
@@ -328,12 +332,12 @@ func TestDPoVP_saveNewBlock(t *testing.T) {
 	block2q := newTestBlock(dp, block1q.Header, deputyInfos, nil)
 	// 0─┬─1 <-current
 	//   └─1'──2'
-	err = dp.saveNewBlock(block2q)
+	err = dp.saveNewBlock(block2q, nil)
 	assert.NoError(t, err)
 	block3q := newTestBlock(dp, block2q.Header, deputyInfos, nil)
 	// 0─┬─1
 	//   └─1'──2'──3' <-current
-	err = dp.saveNewBlock(block3q) // insert new block on other fork
+	err = dp.saveNewBlock(block3q, nil) // insert new block on other fork
 	assert.NoError(t, err)
 	txInPool = dp.txPool.GetTxs(block3q.Time(), 100)
 	assert.Equal(t, 1, len(txInPool))
@@ -345,12 +349,12 @@ func TestDPoVP_saveNewBlock(t *testing.T) {
 	sig, _ := dp.confirmer.TryConfirm(block4q)
 	block4q.Confirms = append(block4q.Confirms, sig)
 	// 4' <-current
-	err = dp.saveNewBlock(block4q)
+	err = dp.saveNewBlock(block4q, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, block4q.Hash(), dp.StableBlock().Hash())
 
 	// saveToStore error: block is exist
-	err = dp.saveNewBlock(block4q)
+	err = dp.saveNewBlock(block4q, nil)
 	assert.Equal(t, ErrSaveBlock, err)
 }
 
@@ -363,7 +367,7 @@ func TestDPoVP_onCurrentChanged(t *testing.T) {
 	tx3 := MakeTxFast(deputyInfos[0].PrivateKey, 103)
 	dp.txPool.AddTxs(types.Transactions{tx1, tx2, tx3})
 	block1 := newTestBlock(dp, dp.CurrentBlock().Header, deputyInfos, nil)
-	err := dp.saveToStore(block1)
+	err := dp.saveToStore(block1, nil)
 	assert.NoError(t, err)
 	time.Sleep(time.Duration(testDpovpCfg.MineTimeout) * time.Millisecond)
 	block2 := newTestBlock(dp, block1.Header, deputyInfos, types.Transactions{tx2})
@@ -391,7 +395,7 @@ func TestDPoVP_UpdateStable(t *testing.T) {
 
 	// not changed. confirms are not enough
 	block1 := newTestBlock(dp, dp.CurrentBlock().Header, deputyInfos, nil)
-	err := dp.saveToStore(block1)
+	err := dp.saveToStore(block1, nil)
 	assert.NoError(t, err)
 	changed, err := dp.UpdateStable(block1)
 	assert.NoError(t, err)
@@ -405,7 +409,7 @@ func TestDPoVP_UpdateStable(t *testing.T) {
 	assert.Equal(t, ErrSetStableBlockToDB, err)
 
 	// changed
-	err = dp.saveToStore(block2)
+	err = dp.saveToStore(block2, nil)
 	assert.NoError(t, err)
 	changed, err = dp.UpdateStable(block2)
 	assert.NoError(t, err)
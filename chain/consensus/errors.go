@@ -1,6 +1,9 @@
 package consensus
 
-import "errors"
+import (
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+)
 
 var (
 	ErrBlockNotExist            = errors.New("block not exist in local")
@@ -11,7 +14,7 @@ var (
 	ErrSaveBlock                = errors.New("save block to db error")
 	ErrSaveAccount              = errors.New("save account error")
 	ErrVerifyHeaderFailed       = errors.New("verify block's header error")
-	ErrVerifyBlockFailed        = errors.New("verify block error")
+	ErrVerifyBlockFailed        = types.ErrVerifyBlockFailed
 	ErrSnapshotIsNil            = errors.New("local deputy nodes snapshot is nil")
 	ErrInvalidConfirmSigner     = errors.New("invalid confirm signer")
 	ErrInvalidSignedConfirmInfo = errors.New("invalid signed data of confirm info")
@@ -3,6 +3,7 @@ package consensus
 import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"time"
 )
 
 // Config holds consensus options.
@@ -14,6 +15,8 @@ type Config struct {
 	ChainID       uint16
 	MineTimeout   uint64
 	MinerExtra    string // Extra data in mined block header. It is shorter than 256bytes
+	// Clock returns the current time. It is time.Now if nil
+	Clock func() time.Time
 }
 
 // BlockMaterial is used for mine a new block
@@ -274,13 +274,13 @@ func MakeTxFast(fromPrivate *ecdsa.PrivateKey, amount int64) *types.Transaction
 	from := crypto.PubkeyToAddress(fromPrivate.PublicKey)
 	r := rand.New(rand.NewSource(1234))
 	expiration := uint64(time.Now().Unix() + 100 + r.Int63n(200))
-	tx := types.NewTransaction(from, from, new(big.Int).Mul(common.OneLEMO, big.NewInt(amount)), 1000000, big.NewInt(100), []byte{}, params.OrdinaryTx, testChainID, expiration, "", string("aaa"))
+	tx := types.NewTransaction(from, from, new(big.Int).Mul(common.OneLEMO, big.NewInt(amount)), 1000000, params.MinGasPrice, []byte{}, params.OrdinaryTx, testChainID, expiration, "", string("aaa"))
 	return SignTx(tx, fromPrivate)
 }
 
 func MakeTx(fromPrivate *ecdsa.PrivateKey, to common.Address, amount *big.Int, expiration uint64) *types.Transaction {
 	from := crypto.PubkeyToAddress(fromPrivate.PublicKey)
-	tx := types.NewTransaction(from, to, amount, 1000000, big.NewInt(100), []byte{}, params.OrdinaryTx, testChainID, expiration, "", string("aaa"))
+	tx := types.NewTransaction(from, to, amount, 1000000, params.MinGasPrice, []byte{}, params.OrdinaryTx, testChainID, expiration, "", string("aaa"))
 	return SignTx(tx, fromPrivate)
 }
 
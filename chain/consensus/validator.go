@@ -17,6 +17,7 @@ type Validator struct {
 	dm          *deputynode.Manager
 	txGuard     TxGuard
 	canLoader   CandidateLoader
+	clock       func() time.Time
 }
 
 func NewValidator(mineTimeout uint64, blockLoader BlockLoader, dm *deputynode.Manager, txGuard TxGuard, canLoader CandidateLoader) *Validator {
@@ -26,6 +27,7 @@ func NewValidator(mineTimeout uint64, blockLoader BlockLoader, dm *deputynode.Ma
 		dm:          dm,
 		txGuard:     txGuard,
 		canLoader:   canLoader,
+		clock:       time.Now,
 	}
 }
 
@@ -56,6 +58,10 @@ func verifyTxs(block *types.Block, txGuard TxGuard, chainId uint16) error {
 		return ErrVerifyBlockFailed
 	}
 	for _, tx := range block.Txs {
+		if err := tx.VerifyVersion(block.Height()); err != nil {
+			log.Errorf("Consensus verify fail: tx version %d is not accepted at height %d", tx.Version(), block.Height())
+			return ErrVerifyBlockFailed
+		}
 		if err := tx.VerifyTxBody(chainId, uint64(block.Time()), true); err != nil {
 			return ErrVerifyBlockFailed
 		}
@@ -73,8 +79,8 @@ func verifyHeight(block *types.Block, parent *types.Block) error {
 }
 
 // verifyTime verify that the block timestamp is less than the current time
-func verifyTime(block *types.Block) error {
-	timeNow := time.Now().Unix()
+func verifyTime(block *types.Block, now time.Time) error {
+	timeNow := now.Unix()
 	if int64(block.Time())-timeNow > 1 { // Prevent validation failure due to time error
 		log.Error("Consensus verify fail: block is in the future", "time", block.Time(), "now", timeNow)
 		return ErrVerifyHeaderFailed
@@ -254,7 +260,7 @@ func (v *Validator) VerifyBeforeTxProcess(block *types.Block, chainId uint16) er
 	if err := verifyHeight(block, parent); err != nil {
 		return err
 	}
-	if err := verifyTime(block); err != nil {
+	if err := verifyTime(block, v.clock()); err != nil {
 		return err
 	}
 	if err := verifyExtraData(block); err != nil {
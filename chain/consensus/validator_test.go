@@ -196,13 +196,16 @@ func newBlockForVerifyTime(time uint32) *types.Block {
 }
 
 func Test_verifyTime(t *testing.T) {
-	now := uint32(time.Now().Unix())
+	timeNow := time.Now()
+	now := uint32(timeNow.Unix())
 	// 1. 正确情况
-	assert.NoError(t, verifyTime(newBlockForVerifyTime(now)))
+	assert.NoError(t, verifyTime(newBlockForVerifyTime(now), timeNow))
 	// 2. 验证误差为1s
-	assert.NoError(t, verifyTime(newBlockForVerifyTime(now+1)))
+	assert.NoError(t, verifyTime(newBlockForVerifyTime(now+1), timeNow))
 	// 3. 异常情况
-	assert.Equal(t, ErrVerifyHeaderFailed, verifyTime(newBlockForVerifyTime(now+2)))
+	assert.Equal(t, ErrVerifyHeaderFailed, verifyTime(newBlockForVerifyTime(now+2), timeNow))
+	// 4. 使用其它时钟
+	assert.NoError(t, verifyTime(newBlockForVerifyTime(now+100), timeNow.Add(100*time.Second)))
 }
 
 func newBlockForVerifyDeputy(height uint32, deputyNodes types.DeputyNodes, deputyRoot []byte) *types.Block {
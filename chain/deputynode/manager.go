@@ -2,10 +2,12 @@ package deputynode
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"errors"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/store"
 	"math"
@@ -37,6 +39,8 @@ type Manager struct {
 
 	evilDeputies map[common.Address]uint32 // key is minerAddress, value is release height(release height = block height + InterimDuration)
 	edLock       sync.Mutex
+
+	selfKey *ecdsa.PrivateKey // the node key of this manager. Use the global node key if it is nil
 }
 
 // NewManager creates a new Manager. It is used to maintain term record list
@@ -201,14 +205,35 @@ func (m *Manager) GetDeputyByNodeID(height uint32, nodeID []byte) *types.DeputyN
 	return nil
 }
 
+// SetSelfNodeKey sets the node key of this manager, so that several nodes can run in one process
+func (m *Manager) SetSelfNodeKey(key *ecdsa.PrivateKey) {
+	m.selfKey = key
+}
+
+// SelfNodeKey returns the node key of this manager
+func (m *Manager) SelfNodeKey() *ecdsa.PrivateKey {
+	if m.selfKey != nil {
+		return m.selfKey
+	}
+	return GetSelfNodeKey()
+}
+
+// SelfNodeID returns the node id of this manager
+func (m *Manager) SelfNodeID() []byte {
+	if m.selfKey != nil {
+		return crypto.PrivateKeyToNodeID(m.selfKey)
+	}
+	return GetSelfNodeID()
+}
+
 // GetMyDeputyInfo 获取自己在某一届高度的共识节点信息
 func (m *Manager) GetMyDeputyInfo(height uint32) *types.DeputyNode {
-	return m.GetDeputyByNodeID(height, GetSelfNodeID())
+	return m.GetDeputyByNodeID(height, m.SelfNodeID())
 }
 
 // GetMyMinerAddress 获取自己在某一届高度的矿工账号
 func (m *Manager) GetMyMinerAddress(height uint32) (common.Address, bool) {
-	deputy := m.GetDeputyByNodeID(height, GetSelfNodeID())
+	deputy := m.GetDeputyByNodeID(height, m.SelfNodeID())
 	if deputy != nil {
 		return deputy.MinerAddress, true
 	}
@@ -217,7 +242,7 @@ func (m *Manager) GetMyMinerAddress(height uint32) (common.Address, bool) {
 
 // IsSelfDeputyNode
 func (m *Manager) IsSelfDeputyNode(height uint32) bool {
-	return m.IsNodeDeputy(height, GetSelfNodeID())
+	return m.IsNodeDeputy(height, m.SelfNodeID())
 }
 
 // IsNodeDeputy
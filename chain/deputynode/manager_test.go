@@ -354,6 +354,35 @@ func TestManager_GetMyMinerAddress(t *testing.T) {
 	assert.Equal(t, false, success)
 }
 
+func TestManager_SetSelfNodeKey(t *testing.T) {
+	m := NewManager(5, testBlockLoader{})
+	nodes := pickNodes(0, 1, 2)
+	m.SaveSnapshot(0, nodes)
+
+	globalKey, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	SetSelfNodeKey(globalKey)
+	// use the global key by default
+	assert.Equal(t, globalKey, m.SelfNodeKey())
+	assert.Equal(t, GetSelfNodeID(), m.SelfNodeID())
+	assert.Equal(t, false, m.IsSelfDeputyNode(0))
+
+	private, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	m.SetSelfNodeKey(private)
+	assert.Equal(t, private, m.SelfNodeKey())
+	assert.Equal(t, crypto.PrivateKeyToNodeID(private), m.SelfNodeID())
+	// the global key is not changed
+	assert.Equal(t, globalKey, GetSelfNodeKey())
+
+	myNode := nodes[1].Copy()
+	myNode.NodeID = crypto.PrivateKeyToNodeID(private)
+	nodes[1] = myNode
+	m.SaveSnapshot(0, nodes)
+	assert.Equal(t, true, m.IsSelfDeputyNode(0))
+	assert.Equal(t, myNode, m.GetMyDeputyInfo(0))
+}
+
 func TestManager_GetDeputyByDistance_Error(t *testing.T) {
 	m := NewManager(5, testBlockLoader{})
 
@@ -0,0 +1,208 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"time"
+)
+
+const (
+	version = 1
+
+	keyHeaderKDF = "scrypt"
+	keyCipher    = "aes-128-ctr"
+
+	// StandardScryptN is the N parameter of scrypt using 256MB memory and taking approximately 1s CPU time on a modern processor
+	StandardScryptN = 1 << 18
+	// StandardScryptP is the P parameter of scrypt using 256MB memory and taking approximately 1s CPU time on a modern processor
+	StandardScryptP = 1
+	// LightScryptN is the N parameter of scrypt using 4MB memory and taking approximately 100ms CPU time on a modern processor
+	LightScryptN = 1 << 12
+	// LightScryptP is the P parameter of scrypt using 4MB memory and taking approximately 100ms CPU time on a modern processor
+	LightScryptP = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+var (
+	ErrDecrypt        = errors.New("could not decrypt key with given passphrase")
+	ErrKeyVersion     = errors.New("unsupported key file version")
+	ErrKeyCipher      = errors.New("unsupported key file cipher")
+	ErrKeyKDF         = errors.New("unsupported key file kdf")
+	ErrKDFParams      = errors.New("key file kdf params exceed the limits")
+	ErrAddressNotSame = errors.New("key file address is not match with the private key")
+)
+
+// encryptedKeyJSON is the content of a key file
+type encryptedKeyJSON struct {
+	Address common.Address `json:"address"`
+	Crypto  cryptoJSON     `json:"crypto"`
+	Id      string         `json:"id"`
+	Version int            `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams cipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    scryptParams `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type scryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	return buf
+}
+
+// keyFileName returns the file name of a key file like "UTC--2019-01-02T15-04-05.000000000Z--1d1fc2...". The address part is in hex so that the file name is case insensitive
+func keyFileName(addr common.Address) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", ts.Format("2006-01-02T15-04-05.000000000Z"), hex.EncodeToString(addr[:]))
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
+// encryptKey encrypts the private key with passphrase, and returns the json content of key file
+func encryptKey(key *ecdsa.PrivateKey, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	salt := randomBytes(32)
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	encryptKey := derivedKey[:16]
+	keyBytes := crypto.FromECDSA(key)
+
+	iv := randomBytes(aes.BlockSize)
+	cipherText, err := aesCTRXOR(encryptKey, keyBytes, iv)
+	if err != nil {
+		return nil, err
+	}
+	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	encrypted := encryptedKeyJSON{
+		Address: crypto.PubkeyToAddress(key.PublicKey),
+		Crypto: cryptoJSON{
+			Cipher:       keyCipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          keyHeaderKDF,
+			KDFParams: scryptParams{
+				N:     scryptN,
+				R:     scryptR,
+				P:     scryptP,
+				DKLen: scryptDKLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+		Id:      hex.EncodeToString(randomBytes(16)),
+		Version: version,
+	}
+	return json.MarshalIndent(encrypted, "", "  ")
+}
+
+// checkScryptParams rejects the params which are not generated by encryptKey, so that a crafted key file can't exhaust CPU and memory
+func checkScryptParams(params scryptParams) error {
+	if params.N <= 1 || params.N > StandardScryptN || params.N&(params.N-1) != 0 {
+		return ErrKDFParams
+	}
+	if params.R <= 0 || params.R > scryptR || params.P <= 0 || params.P > LightScryptP || params.DKLen != scryptDKLen {
+		return ErrKDFParams
+	}
+	return nil
+}
+
+// decryptKey decrypts the key file content with passphrase
+func decryptKey(keyJSON []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	k := new(encryptedKeyJSON)
+	if err := json.Unmarshal(keyJSON, k); err != nil {
+		return nil, err
+	}
+	if k.Version != version {
+		return nil, ErrKeyVersion
+	}
+	if k.Crypto.Cipher != keyCipher {
+		return nil, ErrKeyCipher
+	}
+	if k.Crypto.KDF != keyHeaderKDF {
+		return nil, ErrKeyKDF
+	}
+
+	mac, err := hex.DecodeString(k.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(k.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(k.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := hex.DecodeString(k.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, err
+	}
+	params := k.Crypto.KDFParams
+	if err := checkScryptParams(params); err != nil {
+		return nil, err
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(derivedKey) < 32 || !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+
+	keyBytes, err := aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != k.Address {
+		return nil, ErrAddressNotSame
+	}
+	return key, nil
+}
@@ -0,0 +1,258 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoMatch = errors.New("no key for given address")
+	ErrLocked  = errors.New("account is locked")
+)
+
+// unlocked is an unlocked private key which will be locked again after timeout
+type unlocked struct {
+	key   *ecdsa.PrivateKey
+	abort chan struct{}
+}
+
+// KeyStore manages the passphrase encrypted key files in a directory
+type KeyStore struct {
+	dir     string
+	scryptN int
+	scryptP int
+
+	unlocked map[common.Address]*unlocked
+	mu       sync.RWMutex
+}
+
+// NewKeyStore creates a keystore for the given directory
+func NewKeyStore(dir string, scryptN, scryptP int) *KeyStore {
+	dir, _ = filepath.Abs(dir)
+	return &KeyStore{
+		dir:      dir,
+		scryptN:  scryptN,
+		scryptP:  scryptP,
+		unlocked: make(map[common.Address]*unlocked),
+	}
+}
+
+// Dir returns the directory of key files
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+// NewAccount generates a new key and stores it into the key directory, encrypted with the passphrase
+func (ks *KeyStore) NewAccount(passphrase string) (common.Address, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return ks.storeKey(key, passphrase)
+}
+
+// ImportECDSA stores the given key into the key directory, encrypted with the passphrase
+func (ks *KeyStore) ImportECDSA(key *ecdsa.PrivateKey, passphrase string) (common.Address, error) {
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	if _, err := ks.find(addr); err == nil {
+		return addr, nil
+	}
+	return ks.storeKey(key, passphrase)
+}
+
+func (ks *KeyStore) storeKey(key *ecdsa.PrivateKey, passphrase string) (common.Address, error) {
+	content, err := encryptKey(key, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return common.Address{}, err
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return common.Address{}, err
+	}
+	// write to a temporary file first, so that a broken file never appears in the key directory
+	f, err := ioutil.TempFile(ks.dir, ".tmp-"+keyFileName(addr))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return common.Address{}, err
+	}
+	f.Close()
+	if err := os.Rename(f.Name(), filepath.Join(ks.dir, keyFileName(addr))); err != nil {
+		os.Remove(f.Name())
+		return common.Address{}, err
+	}
+	return addr, nil
+}
+
+// Accounts returns the addresses of all key files in the key directory
+func (ks *KeyStore) Accounts() ([]common.Address, error) {
+	files, err := ks.keyFiles()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]common.Address, 0, len(files))
+	for _, file := range files {
+		addr, err := readAddress(file)
+		if err != nil {
+			log.Debugf("Skip invalid key file %s: %v", file, err)
+			continue
+		}
+		result = append(result, addr)
+	}
+	return result, nil
+}
+
+// HasAddress reports whether a key for the given address is present
+func (ks *KeyStore) HasAddress(addr common.Address) bool {
+	_, err := ks.find(addr)
+	return err == nil
+}
+
+// Unlock decrypts the key of the given address and keeps it in memory. The key will be locked again after timeout. It will be unlocked until Lock is called if the timeout is 0
+func (ks *KeyStore) Unlock(addr common.Address, passphrase string, timeout time.Duration) error {
+	key, err := ks.getDecryptedKey(addr, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if u, ok := ks.unlocked[addr]; ok {
+		close(u.abort)
+	}
+	u := &unlocked{key: key, abort: make(chan struct{})}
+	ks.unlocked[addr] = u
+	if timeout > 0 {
+		go ks.expire(addr, u, timeout)
+	}
+	return nil
+}
+
+// Lock removes the decrypted key of the given address from memory
+func (ks *KeyStore) Lock(addr common.Address) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if u, ok := ks.unlocked[addr]; ok {
+		close(u.abort)
+		delete(ks.unlocked, addr)
+	}
+	return nil
+}
+
+// IsUnlocked reports whether the key of the given address is unlocked
+func (ks *KeyStore) IsUnlocked(addr common.Address) bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	_, ok := ks.unlocked[addr]
+	return ok
+}
+
+func (ks *KeyStore) expire(addr common.Address, u *unlocked, timeout time.Duration) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-u.abort:
+	case <-t.C:
+		ks.mu.Lock()
+		// only drop if it's still the same key instance that we started with
+		if ks.unlocked[addr] == u {
+			delete(ks.unlocked, addr)
+		}
+		ks.mu.Unlock()
+	}
+}
+
+// SignTx signs the transaction with the unlocked key of the given address
+func (ks *KeyStore) SignTx(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	u, ok := ks.unlocked[addr]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return types.MakeSigner().SignTx(tx, u.key)
+}
+
+// SignTxWithPassphrase decrypts the key of the given address and signs the transaction with it. The key is not kept in memory
+func (ks *KeyStore) SignTxWithPassphrase(addr common.Address, passphrase string, tx *types.Transaction) (*types.Transaction, error) {
+	key, err := ks.getDecryptedKey(addr, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return types.MakeSigner().SignTx(tx, key)
+}
+
+func (ks *KeyStore) getDecryptedKey(addr common.Address, passphrase string) (*ecdsa.PrivateKey, error) {
+	file, err := ks.find(addr)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return decryptKey(content, passphrase)
+}
+
+// find returns the path of the key file for the given address
+func (ks *KeyStore) find(addr common.Address) (string, error) {
+	files, err := ks.keyFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if fileAddr, err := readAddress(file); err == nil && fileAddr == addr {
+			return file, nil
+		}
+	}
+	return "", ErrNoMatch
+}
+
+// keyFiles returns the paths of all key files which are sorted by file name
+func (ks *KeyStore) keyFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := make([]string, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		// skip temporary files, editor backups and sub directories
+		if info.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		files = append(files, filepath.Join(ks.dir, name))
+	}
+	return files, nil
+}
+
+func readAddress(file string) (common.Address, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var k struct {
+		Address common.Address `json:"address"`
+	}
+	if err := json.Unmarshal(content, &k); err != nil {
+		return common.Address{}, err
+	}
+	return k.Address, nil
+}
@@ -0,0 +1,125 @@
+package keystore
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeyStore(t *testing.T) (*KeyStore, func()) {
+	dir, err := ioutil.TempDir("", "keystore-test")
+	assert.NoError(t, err)
+	return NewKeyStore(dir, LightScryptN, LightScryptP), func() { os.RemoveAll(dir) }
+}
+
+func TestEncryptDecryptKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	content, err := encryptKey(key, "123456", LightScryptN, LightScryptP)
+	assert.NoError(t, err)
+
+	// the private key must not be stored in plain text
+	assert.NotContains(t, string(content), common.ToHex(crypto.FromECDSA(key))[2:])
+
+	decrypted, err := decryptKey(content, "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.FromECDSA(key), crypto.FromECDSA(decrypted))
+
+	_, err = decryptKey(content, "654321")
+	assert.Equal(t, ErrDecrypt, err)
+
+	// crafted kdf params
+	for _, params := range []string{`"n":1073741824`, `"n":4095`, `"r":1024`, `"p":65536`, `"dklen":1048576`} {
+		name := strings.Split(params, ":")[0]
+		crafted := regexp.MustCompile(name+`:\s*\d+`).ReplaceAllString(string(content), params)
+		_, err = decryptKey([]byte(crafted), "123456")
+		assert.Equal(t, ErrKDFParams, err, params)
+	}
+}
+
+func TestKeyStore_NewAccount(t *testing.T) {
+	ks, clean := newTestKeyStore(t)
+	defer clean()
+
+	accounts, err := ks.Accounts()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(accounts))
+
+	addr1, err := ks.NewAccount("pass1")
+	assert.NoError(t, err)
+	addr2, err := ks.NewAccount("pass2")
+	assert.NoError(t, err)
+	assert.NotEqual(t, addr1, addr2)
+	assert.True(t, ks.HasAddress(addr1))
+	assert.False(t, ks.HasAddress(common.HexToAddress("0x1")))
+
+	accounts, err = ks.Accounts()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(accounts))
+	assert.Contains(t, accounts, addr1)
+	assert.Contains(t, accounts, addr2)
+
+	// the same key is imported only once
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	addr3, err := ks.ImportECDSA(key, "pass3")
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.PubkeyToAddress(key.PublicKey), addr3)
+	_, err = ks.ImportECDSA(key, "pass3")
+	assert.NoError(t, err)
+	accounts, err = ks.Accounts()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(accounts))
+}
+
+func TestKeyStore_SignTx(t *testing.T) {
+	ks, clean := newTestKeyStore(t)
+	defer clean()
+
+	addr, err := ks.NewAccount("pass")
+	assert.NoError(t, err)
+	tx := types.NewTransaction(addr, common.HexToAddress("0x1"), common.Big1, 100, big.NewInt(1000000000), nil, 0, 100, uint64(time.Now().Unix()+300), "", "")
+
+	// locked
+	_, err = ks.SignTx(addr, tx)
+	assert.Equal(t, ErrLocked, err)
+	// wrong passphrase
+	assert.Equal(t, ErrDecrypt, ks.Unlock(addr, "wrong", 0))
+	// not exist
+	assert.Equal(t, ErrNoMatch, ks.Unlock(common.HexToAddress("0x1"), "pass", 0))
+
+	assert.NoError(t, ks.Unlock(addr, "pass", 0))
+	assert.True(t, ks.IsUnlocked(addr))
+	signed, err := ks.SignTx(addr, tx)
+	assert.NoError(t, err)
+	signers, err := types.MakeSigner().GetSigners(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{addr}, signers)
+
+	assert.NoError(t, ks.Lock(addr))
+	assert.False(t, ks.IsUnlocked(addr))
+	_, err = ks.SignTx(addr, tx)
+	assert.Equal(t, ErrLocked, err)
+
+	// sign with passphrase
+	signed, err = ks.SignTxWithPassphrase(addr, "pass", tx)
+	assert.NoError(t, err)
+	signers, err = types.MakeSigner().GetSigners(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{addr}, signers)
+	assert.False(t, ks.IsUnlocked(addr))
+
+	// unlock with timeout
+	assert.NoError(t, ks.Unlock(addr, "pass", 50*time.Millisecond))
+	assert.True(t, ks.IsUnlocked(addr))
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, ks.IsUnlocked(addr))
+}
@@ -0,0 +1,98 @@
+package params
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+var ErrUnknownFork = errors.New("unknown fork")
+
+// Fork is the name of a consensus upgrade
+type Fork string
+
+const (
+	EVMUpgradeFork Fork = "evmUpgrade" // 启用CREATE2, EXTCODEHASH, CHAINID, SELFBALANCE指令
+)
+
+// KnownForks is all forks supported by this version of node
+var KnownForks = []Fork{EVMUpgradeFork}
+
+// ForkConfig is the activation heights of forks. The forks not in it are not activated
+type ForkConfig map[Fork]uint32
+
+var (
+	forkConfig = DefaultForkConfig()
+	forkLock   sync.RWMutex
+)
+
+// DefaultForkConfig returns the activation heights on main net. The upgrades which height is not decided are not in it
+func DefaultForkConfig() ForkConfig {
+	return ForkConfig{}
+}
+
+// Check returns an error if there is an unknown fork
+func (c ForkConfig) Check() error {
+	for fork := range c {
+		if !isKnownFork(fork) {
+			return ErrUnknownFork
+		}
+	}
+	return nil
+}
+
+func isKnownFork(fork Fork) bool {
+	for _, known := range KnownForks {
+		if known == fork {
+			return true
+		}
+	}
+	return false
+}
+
+// SetForkConfig replaces the activation heights of forks
+func SetForkConfig(config ForkConfig) error {
+	if err := config.Check(); err != nil {
+		return err
+	}
+	copied := make(ForkConfig, len(config))
+	for fork, height := range config {
+		copied[fork] = height
+	}
+	forkLock.Lock()
+	defer forkLock.Unlock()
+	forkConfig = copied
+	return nil
+}
+
+// ForkHeight returns the activation height of fork. It returns math.MaxUint32 if the fork is not scheduled
+func ForkHeight(fork Fork) uint32 {
+	forkLock.RLock()
+	defer forkLock.RUnlock()
+	if height, ok := forkConfig[fork]; ok {
+		return height
+	}
+	return math.MaxUint32
+}
+
+// IsActive returns true if the fork rules are enabled in the block at the height
+func IsActive(fork Fork, height uint32) bool {
+	return height >= ForkHeight(fork)
+}
+
+// TxVersion is the transaction version accepted since genesis. types.TxVersion is derived from it
+const TxVersion uint8 = 1
+
+// txVersionUpgrades is the forks which enable new transaction versions
+var txVersionUpgrades = map[uint8]Fork{}
+
+// MaxTxVersion returns the newest transaction version accepted in the block at the height
+func MaxTxVersion(height uint32) uint8 {
+	version := TxVersion
+	for v, fork := range txVersionUpgrades {
+		if v > version && IsActive(fork, height) {
+			version = v
+		}
+	}
+	return version
+}
@@ -3,6 +3,7 @@ package params
 type GasTable struct {
 	ExtcodeSize uint64
 	ExtcodeCopy uint64
+	ExtcodeHash uint64
 	Balance     uint64
 	SLoad       uint64
 	Calls       uint64
@@ -22,6 +23,7 @@ var (
 	DefaultGasTable = GasTable{
 		ExtcodeSize: 700,
 		ExtcodeCopy: 700,
+		ExtcodeHash: 400,
 		Balance:     400,
 		SLoad:       200,
 		Calls:       700,
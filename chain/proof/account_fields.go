@@ -0,0 +1,231 @@
+package proof
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// rootBinding describes a root in account which is changed by some types of change logs. The new root is recorded by a root log in the same block
+type rootBinding struct {
+	rootType types.ChangeLogType
+	sources  []types.ChangeLogType
+	root     func(data *types.AccountData) common.Hash
+}
+
+var rootBindings = []rootBinding{
+	{account.StorageRootLog, []types.ChangeLogType{account.StorageLog}, func(data *types.AccountData) common.Hash { return data.StorageRoot }},
+	{account.AssetCodeRootLog, []types.ChangeLogType{account.AssetCodeLog, account.AssetCodeStateLog, account.AssetCodeTotalSupplyLog}, func(data *types.AccountData) common.Hash { return data.AssetCodeRoot }},
+	{account.AssetIdRootLog, []types.ChangeLogType{account.AssetIdLog}, func(data *types.AccountData) common.Hash { return data.AssetIdRoot }},
+	{account.EquityRootLog, []types.ChangeLogType{account.EquityLog}, func(data *types.AccountData) common.Hash { return data.EquityRoot }},
+}
+
+// findLog returns the index of the change log in logs, or -1 if it is not found. The version is ignored if it is 0
+func findLog(logs types.ChangeLogSlice, address common.Address, logType types.ChangeLogType, version uint32) int {
+	for i := len(logs) - 1; i >= 0; i-- {
+		if logs[i].Address == address && logs[i].LogType == logType && (version == 0 || logs[i].Version == version) {
+			return i
+		}
+	}
+	return -1
+}
+
+// verifyAccountFields checks the account fields are same as the values in its newest change logs
+func verifyAccountFields(data *types.AccountData, logsByHeight map[uint32]types.ChangeLogSlice, historyVersions map[uint32]uint32) error {
+	if _, ok := data.NewestRecords[account.SuicideLog]; ok {
+		return ErrAccountUnprovable
+	}
+	newest := make(map[types.ChangeLogType]*types.ChangeLog)
+	for logType, record := range data.NewestRecords {
+		logs := logsByHeight[record.Height]
+		index := findLog(logs, data.Address, logType, record.Version)
+		if index < 0 {
+			return ErrChangeLogMissing
+		}
+		newest[logType] = logs[index]
+	}
+
+	// the value is zero if there is no change log
+	balance := new(big.Int)
+	votes := new(big.Int)
+	voteFor := common.Address{}
+	codeHash := common.Hash{}
+	signers := types.Signers{}
+	var ok bool
+	if changeLog, exist := newest[account.BalanceLog]; exist {
+		var value big.Int
+		if value, ok = changeLog.NewVal.(big.Int); !ok {
+			return ErrInvalidProof
+		}
+		balance = &value
+	}
+	if changeLog, exist := newest[account.VotesLog]; exist {
+		var value big.Int
+		if value, ok = changeLog.NewVal.(big.Int); !ok {
+			return ErrInvalidProof
+		}
+		votes = &value
+	}
+	if changeLog, exist := newest[account.VoteForLog]; exist {
+		if voteFor, ok = changeLog.NewVal.(common.Address); !ok {
+			return ErrInvalidProof
+		}
+	}
+	if changeLog, exist := newest[account.CodeLog]; exist {
+		code, ok := changeLog.NewVal.(types.Code)
+		if !ok {
+			return ErrInvalidProof
+		}
+		codeHash = crypto.Keccak256Hash(code)
+	}
+	if changeLog, exist := newest[account.SignerLog]; exist {
+		if signers, ok = changeLog.NewVal.(types.Signers); !ok {
+			return ErrInvalidProof
+		}
+	}
+
+	accountVotes := data.Candidate.Votes
+	if accountVotes == nil {
+		accountVotes = new(big.Int)
+	}
+	if data.Balance == nil || data.Balance.Cmp(balance) != 0 || accountVotes.Cmp(votes) != 0 || data.VoteFor != voteFor {
+		return ErrAccountMismatch
+	}
+	// empty code is saved as empty hash
+	if data.CodeHash != codeHash && !(data.CodeHash == (common.Hash{}) && codeHash == common.Sha3Nil) {
+		return ErrAccountMismatch
+	}
+	if len(data.Signers) != len(signers) || (len(signers) > 0 && !reflect.DeepEqual(data.Signers, signers)) {
+		return ErrAccountMismatch
+	}
+
+	for _, binding := range rootBindings {
+		root, err := bindingRoot(data, binding, logsByHeight)
+		if err != nil {
+			return err
+		}
+		if binding.root(data) != root {
+			return ErrAccountMismatch
+		}
+	}
+
+	profile, err := candidateProfile(data, logsByHeight, historyVersions)
+	if err != nil {
+		return err
+	}
+	if len(data.Candidate.Profile) != len(profile) || (len(profile) > 0 && !reflect.DeepEqual(data.Candidate.Profile, profile)) {
+		return ErrAccountMismatch
+	}
+	return nil
+}
+
+// bindingRoot returns the root in the root log which is in the block of the newest source change log
+func bindingRoot(data *types.AccountData, binding rootBinding, logsByHeight map[uint32]types.ChangeLogSlice) (common.Hash, error) {
+	changed := false
+	height := uint32(0)
+	for _, logType := range binding.sources {
+		if record, ok := data.NewestRecords[logType]; ok {
+			if !changed || record.Height > height {
+				height = record.Height
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return common.Hash{}, nil
+	}
+	logs := logsByHeight[height]
+	index := findLog(logs, data.Address, binding.rootType, 0)
+	if index < 0 {
+		// the root is not changed in the block, so it can't be found in the proof
+		return common.Hash{}, ErrAccountUnprovable
+	}
+	root, ok := logs[index].NewVal.(common.Hash)
+	if !ok {
+		return common.Hash{}, ErrInvalidProof
+	}
+	return root, nil
+}
+
+// candidateProfile rebuilds the candidate profile by the newest CandidateLog and the CandidateStateLogs after it
+func candidateProfile(data *types.AccountData, logsByHeight map[uint32]types.ChangeLogSlice, historyVersions map[uint32]uint32) (types.Profile, error) {
+	profile := make(types.Profile)
+	applyState := func(changeLog *types.ChangeLog) error {
+		key, ok := changeLog.Extra.(string)
+		if !ok {
+			return ErrInvalidProof
+		}
+		if profile[key], ok = changeLog.NewVal.(string); !ok {
+			return ErrInvalidProof
+		}
+		return nil
+	}
+
+	candidateRecord, hasCandidate := data.NewestRecords[account.CandidateLog]
+	stateRecord, hasState := data.NewestRecords[account.CandidateStateLog]
+	if hasCandidate {
+		logs := logsByHeight[candidateRecord.Height]
+		index := findLog(logs, data.Address, account.CandidateLog, candidateRecord.Version)
+		if index < 0 {
+			return nil, ErrChangeLogMissing
+		}
+		base, ok := logs[index].NewVal.(*types.Profile)
+		if !ok {
+			return nil, ErrInvalidProof
+		}
+		if base != nil {
+			for key, value := range *base {
+				profile[key] = value
+			}
+		}
+		for _, changeLog := range logs[index+1:] {
+			if changeLog.Address == data.Address && changeLog.LogType == account.CandidateStateLog {
+				if err := applyState(changeLog); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if !hasState || (hasCandidate && stateRecord.Height <= candidateRecord.Height) {
+		return profile, nil
+	}
+
+	// all CandidateStateLogs after the block of CandidateLog must be in proof
+	nextVersion := uint32(1)
+	if hasCandidate {
+		version, ok := historyVersions[candidateRecord.Height]
+		if !ok {
+			return nil, ErrVersionProofMissing
+		}
+		nextVersion = version + 1
+	}
+	heights := make([]uint32, 0, len(logsByHeight))
+	for height := range logsByHeight {
+		if !hasCandidate || height > candidateRecord.Height {
+			heights = append(heights, height)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	for _, height := range heights {
+		for _, changeLog := range logsByHeight[height] {
+			if changeLog.Address != data.Address || changeLog.LogType != account.CandidateStateLog {
+				continue
+			}
+			if changeLog.Version != nextVersion {
+				return nil, ErrChangeLogMissing
+			}
+			if err := applyState(changeLog); err != nil {
+				return nil, err
+			}
+			nextVersion++
+		}
+	}
+	if nextVersion != stateRecord.Version+1 {
+		return nil, ErrChangeLogMissing
+	}
+	return profile, nil
+}
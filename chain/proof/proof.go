@@ -0,0 +1,474 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+	"github.com/LemoFoundationLtd/lemochain-core/common/merkle"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/trie"
+	"math/big"
+	"sort"
+)
+
+//go:generate gencodec -type VersionProof --field-override versionProofMarshaling -out gen_version_proof_json.go
+//go:generate gencodec -type LogsProof --field-override logsProofMarshaling -out gen_logs_proof_json.go
+//go:generate gencodec -type AccountProof --field-override accountProofMarshaling -out gen_account_proof_json.go
+//go:generate gencodec -type TxProof --field-override txProofMarshaling -out gen_tx_proof_json.go
+
+var (
+	ErrInvalidProof        = errors.New("the proof is invalid")
+	ErrVersionMismatch     = errors.New("the version in proof is not match the account")
+	ErrVersionProofMissing = errors.New("the version proof of account is missing")
+	ErrChangeLogMissing    = errors.New("the change log of account is missing in proof")
+	ErrAccountMismatch     = errors.New("the account data is not match the change logs")
+	ErrAccountUnprovable   = errors.New("the account data can't be proved by change logs")
+	ErrTxNotInBlock        = errors.New("the transaction is not in block")
+	ErrTxNotInBox          = errors.New("the transaction is not in box")
+)
+
+// VersionProof proves the version of an account's change log type in the version trie. The Version is 0 if the type is not in the trie
+type VersionProof struct {
+	LogType types.ChangeLogType `json:"logType" gencodec:"required"`
+	Version uint32              `json:"version" gencodec:"required"`
+	// Height is the height of block whose VersionRoot proves the version
+	Height uint32 `json:"height" gencodec:"required"`
+	// the encoded trie nodes on the path from root to the version
+	Nodes []hexutil.Bytes `json:"nodes" gencodec:"required"`
+}
+
+type versionProofMarshaling struct {
+	LogType hexutil.Uint32
+	Version hexutil.Uint32
+	Height  hexutil.Uint32
+}
+
+// LogsProof contains all the RLP encoded change logs of a block, so they could be proved by the block's LogRoot
+type LogsProof struct {
+	Height uint32          `json:"height" gencodec:"required"`
+	Logs   []hexutil.Bytes `json:"logs"   gencodec:"required"`
+}
+
+type logsProofMarshaling struct {
+	Height hexutil.Uint32
+}
+
+// AccountProof proves the account data at a block.
+// The versions of all change log types are proved by the block's VersionRoot. The account fields are proved by the newest change logs, which are proved by the LogRoot of the blocks they are in.
+// Account is nil if the account is not exist, then every version is proved to be absent
+type AccountProof struct {
+	Address     common.Address     `json:"address"     gencodec:"required"`
+	Account     *types.AccountData `json:"account"`
+	BlockHash   common.Hash        `json:"blockHash"   gencodec:"required"`
+	Height      uint32             `json:"height"      gencodec:"required"`
+	VersionRoot common.Hash        `json:"versionRoot" gencodec:"required"`
+	Versions    []*VersionProof    `json:"versions"    gencodec:"required"`
+	// HistoryVersions are the versions at early blocks, which are used to check the change logs are complete
+	HistoryVersions []*VersionProof `json:"historyVersions" gencodec:"required"`
+	Logs            []*LogsProof    `json:"logs"            gencodec:"required"`
+}
+
+type accountProofMarshaling struct {
+	Height hexutil.Uint32
+}
+
+// TxProof proves the transaction is packaged in a block by the block's TxRoot
+type TxProof struct {
+	Tx *types.Transaction `json:"tx" gencodec:"required"`
+	// BoxTx is the box transaction which contains Tx. It is nil if Tx is not in a box
+	BoxTx     *types.Transaction  `json:"boxTx"`
+	BlockHash common.Hash         `json:"blockHash" gencodec:"required"`
+	Height    uint32              `json:"height"    gencodec:"required"`
+	TxRoot    common.Hash         `json:"txRoot"    gencodec:"required"`
+	Siblings  []merkle.MerkleNode `json:"siblings"  gencodec:"required"`
+}
+
+type txProofMarshaling struct {
+	Height hexutil.Uint32
+}
+
+// nodeList collects the trie nodes of a proof
+type nodeList []hexutil.Bytes
+
+func (l *nodeList) Put(flag uint32, key, value []byte) error {
+	*l = append(*l, common.CopyBytes(value))
+	return nil
+}
+
+// nodeSet is used to read the trie nodes of a proof by node hash
+type nodeSet map[common.Hash][]byte
+
+func newNodeSet(nodes []hexutil.Bytes) nodeSet {
+	set := make(nodeSet)
+	for _, node := range nodes {
+		set[crypto.Keccak256Hash(node)] = node
+	}
+	return set
+}
+
+func (s nodeSet) Get(flag uint32, key []byte) ([]byte, error) {
+	if node, ok := s[common.BytesToHash(key)]; ok {
+		return node, nil
+	}
+	return nil, nil
+}
+
+func (s nodeSet) Has(flag uint32, key []byte) (bool, error) {
+	_, ok := s[common.BytesToHash(key)]
+	return ok, nil
+}
+
+// ChainReader provides the stable blocks and trie nodes to create proofs
+type ChainReader interface {
+	GetBlockByHeight(height uint32) (*types.Block, error)
+	GetTrieDatabase() *store.TrieDatabase
+}
+
+// HeaderReader returns the trusted block header in height, or nil if it is not found
+type HeaderReader func(height uint32) *types.Header
+
+// versionReader reads and proves the versions in the version trie of a block
+type versionReader struct {
+	height uint32
+	trie   *trie.SecureTrie
+}
+
+func newVersionReader(db ChainReader, header *types.Header) (*versionReader, error) {
+	versionTrie, err := trie.NewSecure(header.VersionRoot, db.GetTrieDatabase(), account.MaxTrieCacheGen)
+	if err != nil {
+		return nil, err
+	}
+	return &versionReader{header.Height, versionTrie}, nil
+}
+
+func (r *versionReader) get(address common.Address, logType types.ChangeLogType) (uint32, error) {
+	value, err := r.trie.TryGet(account.VersionTrieKey(address, logType))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(new(big.Int).SetBytes(value).Uint64()), nil
+}
+
+func (r *versionReader) prove(address common.Address, logType types.ChangeLogType) (*VersionProof, error) {
+	version, err := r.get(address, logType)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(nodeList, 0)
+	if err := r.trie.Prove(account.VersionTrieKey(address, logType), 0, &nodes); err != nil {
+		return nil, err
+	}
+	return &VersionProof{LogType: logType, Version: version, Height: r.height, Nodes: nodes}, nil
+}
+
+// NewAccountProof creates the proof of account data at the stable block. The data must be loaded from the state of the block, or nil if the account is not exist
+func NewAccountProof(db ChainReader, header *types.Header, address common.Address, data *types.AccountData) (*AccountProof, error) {
+	if data != nil && data.Address != address {
+		return nil, ErrInvalidProof
+	}
+	reader, err := newVersionReader(db, header)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountProof{
+		Address:         address,
+		Account:         data,
+		BlockHash:       header.Hash(),
+		Height:          header.Height,
+		VersionRoot:     header.VersionRoot,
+		Versions:        make([]*VersionProof, 0, account.LOG_TYPE_STOP-1),
+		HistoryVersions: make([]*VersionProof, 0),
+		Logs:            make([]*LogsProof, 0),
+	}
+	// prove all types, so that no version could be hidden
+	for logType := account.BalanceLog; logType < account.LOG_TYPE_STOP; logType++ {
+		versionProof, err := reader.prove(address, logType)
+		if err != nil {
+			return nil, err
+		}
+		result.Versions = append(result.Versions, versionProof)
+	}
+	if data == nil {
+		return result, nil
+	}
+
+	heights, err := changedHeights(db, data, result)
+	if err != nil {
+		return nil, err
+	}
+	for _, height := range heights {
+		block, err := db.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		logsProof := &LogsProof{Height: height, Logs: make([]hexutil.Bytes, 0, len(block.ChangeLogs))}
+		for _, changeLog := range block.ChangeLogs {
+			buf, err := rlp.EncodeToBytes(changeLog)
+			if err != nil {
+				return nil, err
+			}
+			logsProof.Logs = append(logsProof.Logs, buf)
+		}
+		result.Logs = append(result.Logs, logsProof)
+	}
+	return result, nil
+}
+
+// changedHeights returns the sorted heights of blocks which contain the change logs to prove the account fields. The history versions are appended to proof
+func changedHeights(db ChainReader, data *types.AccountData, proof *AccountProof) ([]uint32, error) {
+	if _, ok := data.NewestRecords[account.SuicideLog]; ok {
+		return nil, ErrAccountUnprovable
+	}
+	heightSet := make(map[uint32]bool)
+	for _, record := range data.NewestRecords {
+		heightSet[record.Height] = true
+	}
+
+	// the candidate profile is changed by CandidateStateLogs after the newest CandidateLog. Find the blocks which contain them
+	candidateRecord, hasCandidate := data.NewestRecords[account.CandidateLog]
+	stateRecord, hasState := data.NewestRecords[account.CandidateStateLog]
+	if hasState && (!hasCandidate || stateRecord.Height > candidateRecord.Height) {
+		readers := make(map[uint32]*versionReader)
+		versionAt := func(height uint32) (uint32, error) {
+			reader, ok := readers[height]
+			if !ok {
+				block, err := db.GetBlockByHeight(height)
+				if err != nil {
+					return 0, err
+				}
+				if reader, err = newVersionReader(db, block.Header); err != nil {
+					return 0, err
+				}
+				readers[height] = reader
+			}
+			return reader.get(data.Address, account.CandidateStateLog)
+		}
+
+		from := uint32(0)
+		nextVersion := uint32(1)
+		if hasCandidate {
+			block, err := db.GetBlockByHeight(candidateRecord.Height)
+			if err != nil {
+				return nil, err
+			}
+			reader, err := newVersionReader(db, block.Header)
+			if err != nil {
+				return nil, err
+			}
+			versionProof, err := reader.prove(data.Address, account.CandidateStateLog)
+			if err != nil {
+				return nil, err
+			}
+			proof.HistoryVersions = append(proof.HistoryVersions, versionProof)
+			from = candidateRecord.Height + 1
+			nextVersion = versionProof.Version + 1
+		}
+		for nextVersion <= stateRecord.Version {
+			if from > stateRecord.Height {
+				return nil, ErrChangeLogMissing
+			}
+			// binary search the first block which contains the next version
+			var searchErr error
+			offset := sort.Search(int(stateRecord.Height-from+1), func(i int) bool {
+				version, err := versionAt(from + uint32(i))
+				if err != nil {
+					searchErr = err
+				}
+				return version >= nextVersion
+			})
+			if searchErr != nil {
+				return nil, searchErr
+			}
+			height := from + uint32(offset)
+			version, err := versionAt(height)
+			if err != nil {
+				return nil, err
+			}
+			if version < nextVersion {
+				return nil, ErrChangeLogMissing
+			}
+			heightSet[height] = true
+			from = height + 1
+			nextVersion = version + 1
+		}
+	}
+
+	heights := make([]uint32, 0, len(heightSet))
+	for height := range heightSet {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// verifyVersionProof checks the version proof with the VersionRoot
+func verifyVersionProof(versionRoot common.Hash, address common.Address, versionProof *VersionProof) error {
+	key := crypto.Keccak256(account.VersionTrieKey(address, versionProof.LogType))
+	value, err, _ := trie.VerifyProof(versionRoot, key, newNodeSet(versionProof.Nodes))
+	if err != nil {
+		return err
+	}
+	// nil value means the version is absent
+	if value == nil && versionProof.Version == 0 {
+		return nil
+	}
+	if !bytes.Equal(value, new(big.Int).SetUint64(uint64(versionProof.Version)).Bytes()) {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// VerifyAccountProof checks the account proof with the trusted block headers. The headers in proof.Height and the heights of proof.Logs are required
+func VerifyAccountProof(proof *AccountProof, getHeader HeaderReader) error {
+	if proof == nil || (proof.Account != nil && proof.Account.Address != proof.Address) {
+		return ErrInvalidProof
+	}
+	header := getHeader(proof.Height)
+	if header == nil || header.Hash() != proof.BlockHash || header.VersionRoot != proof.VersionRoot {
+		return ErrInvalidProof
+	}
+
+	// every type must be proved
+	versions := make(map[types.ChangeLogType]uint32)
+	for _, versionProof := range proof.Versions {
+		if _, ok := versions[versionProof.LogType]; ok || versionProof.Height != proof.Height {
+			return ErrInvalidProof
+		}
+		if err := verifyVersionProof(proof.VersionRoot, proof.Address, versionProof); err != nil {
+			return err
+		}
+		versions[versionProof.LogType] = versionProof.Version
+	}
+	for logType := account.BalanceLog; logType < account.LOG_TYPE_STOP; logType++ {
+		if _, ok := versions[logType]; !ok {
+			return ErrVersionProofMissing
+		}
+	}
+
+	if proof.Account == nil {
+		// the account is not exist only if it has no change log
+		for _, version := range versions {
+			if version != 0 {
+				return ErrVersionMismatch
+			}
+		}
+		return nil
+	}
+	for logType, version := range versions {
+		record, ok := proof.Account.NewestRecords[logType]
+		if ok != (version != 0) || record.Version != version {
+			return ErrVersionMismatch
+		}
+	}
+	if len(proof.Account.NewestRecords) == 0 {
+		return ErrVersionMismatch
+	}
+	for logType := range proof.Account.NewestRecords {
+		if _, ok := versions[logType]; !ok {
+			return ErrVersionMismatch
+		}
+	}
+
+	historyVersions := make(map[uint32]uint32)
+	for _, versionProof := range proof.HistoryVersions {
+		historyHeader := getHeader(versionProof.Height)
+		if historyHeader == nil || versionProof.Height > proof.Height || versionProof.LogType != account.CandidateStateLog {
+			return ErrInvalidProof
+		}
+		if err := verifyVersionProof(historyHeader.VersionRoot, proof.Address, versionProof); err != nil {
+			return err
+		}
+		historyVersions[versionProof.Height] = versionProof.Version
+	}
+
+	logsByHeight, err := verifyLogsProofs(proof, getHeader)
+	if err != nil {
+		return err
+	}
+	return verifyAccountFields(proof.Account, logsByHeight, historyVersions)
+}
+
+// verifyLogsProofs decodes the change logs and checks them with the LogRoot of trusted headers
+func verifyLogsProofs(proof *AccountProof, getHeader HeaderReader) (map[uint32]types.ChangeLogSlice, error) {
+	result := make(map[uint32]types.ChangeLogSlice)
+	for _, logsProof := range proof.Logs {
+		header := getHeader(logsProof.Height)
+		if _, ok := result[logsProof.Height]; ok || header == nil || logsProof.Height > proof.Height {
+			return nil, ErrInvalidProof
+		}
+		logs := make(types.ChangeLogSlice, 0, len(logsProof.Logs))
+		for _, buf := range logsProof.Logs {
+			changeLog := new(types.ChangeLog)
+			if err := rlp.DecodeBytes(buf, changeLog); err != nil {
+				return nil, err
+			}
+			logs = append(logs, changeLog)
+		}
+		if logs.MerkleRootSha() != header.LogRoot {
+			return nil, ErrInvalidProof
+		}
+		result[logsProof.Height] = logs
+	}
+	return result, nil
+}
+
+// NewTxProof creates the proof of transaction in the block. The boxTx is the box transaction which contains tx, or nil if tx is not in a box
+func NewTxProof(block *types.Block, tx *types.Transaction, boxTx *types.Transaction) (*TxProof, error) {
+	leaf := tx
+	if boxTx != nil {
+		leaf = boxTx
+	}
+	leaves := make([]common.Hash, len(block.Txs))
+	for i, item := range block.Txs {
+		leaves[i] = item.Hash()
+	}
+	siblings, err := merkle.FindSiblingNodes(leaf.Hash(), merkle.New(leaves).HashNodes())
+	if err != nil {
+		return nil, ErrTxNotInBlock
+	}
+
+	return &TxProof{
+		Tx:        tx,
+		BoxTx:     boxTx,
+		BlockHash: block.Hash(),
+		Height:    block.Height(),
+		TxRoot:    block.Header.TxRoot,
+		Siblings:  siblings,
+	}, nil
+}
+
+// VerifyTxProof checks the transaction proof with the TxRoot from a trusted block header
+func VerifyTxProof(txRoot common.Hash, proof *TxProof) error {
+	if proof == nil || proof.Tx == nil || proof.TxRoot != txRoot {
+		return ErrInvalidProof
+	}
+
+	leaf := proof.Tx.Hash()
+	if proof.BoxTx != nil {
+		box, err := types.GetBox(proof.BoxTx.Data())
+		if err != nil {
+			return err
+		}
+		inBox := false
+		for _, subTx := range box.SubTxList {
+			if subTx.Hash() == leaf {
+				inBox = true
+				break
+			}
+		}
+		if !inBox {
+			return ErrTxNotInBox
+		}
+		leaf = proof.BoxTx.Hash()
+	}
+
+	if !merkle.Verify(leaf, txRoot, proof.Siblings) {
+		return ErrInvalidProof
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package proof_test
+
+import (
+	"encoding/json"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/proof"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/testchain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func makeTx(amount int64) *types.Transaction {
+	return types.NewTransaction(common.HexToAddress("0x10000"), common.HexToAddress("0x1"), big.NewInt(amount), 21000, params.MinGasPrice, nil, params.OrdinaryTx, 100, 1538210391, "", "")
+}
+
+func makeBlock(txs types.Transactions) *types.Block {
+	header := &types.Header{Height: 10, TxRoot: txs.MerkleRootSha()}
+	return types.NewBlock(header, txs, nil)
+}
+
+func testHeaderReader(maxHeight uint32) proof.HeaderReader {
+	return func(height uint32) *types.Header {
+		if height > maxHeight {
+			return nil
+		}
+		return testchain.LoadDefaultBlock(int(height)).Header
+	}
+}
+
+func TestVerifyAccountProof(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	getHeader := testHeaderReader(1)
+
+	block := testchain.LoadDefaultBlock(1)
+	acctDb, err := db.GetHistoryActDatabase(block.Hash())
+	assert.NoError(t, err)
+	// all accounts changed in stable blocks
+	addresses := make(map[common.Address]bool)
+	for _, changeLog := range append(testchain.LoadDefaultBlock(0).ChangeLogs, block.ChangeLogs...) {
+		addresses[changeLog.Address] = true
+	}
+	for address := range addresses {
+		data, err := acctDb.Get(address)
+		assert.NoError(t, err)
+		accountProof, err := proof.NewAccountProof(db, block.Header, address, data)
+		assert.NoError(t, err, "address=%s", address.String())
+		assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader), "address=%s", address.String())
+	}
+
+	founder := common.HexToAddress("0x10000")
+	data, err := acctDb.Get(founder)
+	assert.NoError(t, err)
+	assert.NotEqual(t, 0, len(data.NewestRecords))
+	accountProof, err := proof.NewAccountProof(db, block.Header, founder, data)
+	assert.NoError(t, err)
+	assert.Equal(t, int(account.LOG_TYPE_STOP-1), len(accountProof.Versions))
+	assert.Equal(t, block.Hash(), accountProof.BlockHash)
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+
+	// json
+	buf, err := json.Marshal(accountProof)
+	assert.NoError(t, err)
+	var decoded proof.AccountProof
+	assert.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.NoError(t, proof.VerifyAccountProof(&decoded, getHeader))
+
+	// untrusted block
+	assert.Equal(t, proof.ErrInvalidProof, proof.VerifyAccountProof(accountProof, testHeaderReader(0)))
+	// missing version
+	versions := accountProof.Versions
+	accountProof.Versions = versions[1:]
+	assert.Equal(t, proof.ErrVersionProofMissing, proof.VerifyAccountProof(accountProof, getHeader))
+	accountProof.Versions = versions
+	// the version in account is changed
+	logType := account.BalanceLog
+	record := data.NewestRecords[logType]
+	record.Version++
+	data.NewestRecords[logType] = record
+	assert.Equal(t, proof.ErrVersionMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	// the version in proof is changed too
+	versions[0].Version++
+	assert.Equal(t, proof.ErrVersionMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	versions[0].Version--
+	record.Version--
+	data.NewestRecords[logType] = record
+	// hide a record
+	delete(data.NewestRecords, logType)
+	assert.Equal(t, proof.ErrVersionMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	data.NewestRecords[logType] = record
+	// bad node
+	node := versions[0].Nodes[0]
+	versions[0].Nodes[0] = append([]byte{}, node[1:]...)
+	assert.Error(t, proof.VerifyAccountProof(accountProof, getHeader))
+	versions[0].Nodes[0] = node
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+
+	// tampered balance
+	balance := data.Balance
+	data.Balance = new(big.Int).Add(balance, big.NewInt(1))
+	assert.Equal(t, proof.ErrAccountMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	data.Balance = balance
+	// tampered storage root
+	storageRoot := data.StorageRoot
+	data.StorageRoot = common.HexToHash("0x1")
+	assert.Equal(t, proof.ErrAccountMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	data.StorageRoot = storageRoot
+	// tampered votes
+	votes := data.Candidate.Votes
+	data.Candidate.Votes = big.NewInt(100)
+	assert.Equal(t, proof.ErrAccountMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	data.Candidate.Votes = votes
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+
+	// tampered change log
+	logs := accountProof.Logs[0].Logs
+	accountProof.Logs[0].Logs = logs[1:]
+	assert.Equal(t, proof.ErrInvalidProof, proof.VerifyAccountProof(accountProof, getHeader))
+	// missing change log
+	accountProof.Logs = accountProof.Logs[1:]
+	assert.Equal(t, proof.ErrChangeLogMissing, proof.VerifyAccountProof(accountProof, getHeader))
+}
+
+func TestVerifyAccountProof_candidate(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	getHeader := testHeaderReader(1)
+
+	block := testchain.LoadDefaultBlock(1)
+	acctDb, err := db.GetHistoryActDatabase(block.Hash())
+	assert.NoError(t, err)
+	deputy := testchain.LoadDefaultBlock(0).DeputyNodes[1]
+	data, err := acctDb.Get(deputy.MinerAddress)
+	assert.NoError(t, err)
+	assert.NotEqual(t, 0, len(data.Candidate.Profile))
+	accountProof, err := proof.NewAccountProof(db, block.Header, deputy.MinerAddress, data)
+	assert.NoError(t, err)
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+
+	// tampered profile
+	host := data.Candidate.Profile[types.CandidateKeyHost]
+	data.Candidate.Profile[types.CandidateKeyHost] = "tampered.host"
+	assert.Equal(t, proof.ErrAccountMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	data.Candidate.Profile[types.CandidateKeyHost] = host
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+}
+
+func TestVerifyAccountProof_absent(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	getHeader := testHeaderReader(1)
+
+	block := testchain.LoadDefaultBlock(1)
+	accountProof, err := proof.NewAccountProof(db, block.Header, common.HexToAddress("0x12345"), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, accountProof.Account)
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+	buf, err := json.Marshal(accountProof)
+	assert.NoError(t, err)
+	var decoded proof.AccountProof
+	assert.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.NoError(t, proof.VerifyAccountProof(&decoded, getHeader))
+
+	// an existing account can't be proved absent
+	founder := common.HexToAddress("0x10000")
+	accountProof, err = proof.NewAccountProof(db, block.Header, founder, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, proof.ErrVersionMismatch, proof.VerifyAccountProof(accountProof, getHeader))
+	// the versions of another account
+	accountProof.Address = common.HexToAddress("0x12345")
+	assert.Error(t, proof.VerifyAccountProof(accountProof, getHeader))
+}
+
+func TestVerifyTxProof(t *testing.T) {
+	txs := types.Transactions{makeTx(1), makeTx(2), makeTx(3), makeTx(4), makeTx(5)}
+	block := makeBlock(txs)
+	for i, tx := range txs {
+		txProof, err := proof.NewTxProof(block, tx, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, proof.VerifyTxProof(block.Header.TxRoot, txProof), "index=%d", i)
+
+		// json
+		buf, err := json.Marshal(txProof)
+		assert.NoError(t, err)
+		var decoded proof.TxProof
+		assert.NoError(t, json.Unmarshal(buf, &decoded))
+		assert.NoError(t, proof.VerifyTxProof(block.Header.TxRoot, &decoded), "index=%d", i)
+	}
+
+	txProof, err := proof.NewTxProof(block, txs[2], nil)
+	assert.NoError(t, err)
+	// wrong root
+	assert.Equal(t, proof.ErrInvalidProof, proof.VerifyTxProof(common.HexToHash("0x1"), txProof))
+	// another transaction
+	txProof.Tx = makeTx(6)
+	assert.Equal(t, proof.ErrInvalidProof, proof.VerifyTxProof(block.Header.TxRoot, txProof))
+	// not in block
+	_, err = proof.NewTxProof(block, makeTx(6), nil)
+	assert.Equal(t, proof.ErrTxNotInBlock, err)
+
+	// only one transaction
+	block = makeBlock(types.Transactions{txs[0]})
+	txProof, err = proof.NewTxProof(block, txs[0], nil)
+	assert.NoError(t, err)
+	assert.NoError(t, proof.VerifyTxProof(block.Header.TxRoot, txProof))
+}
+
+func TestVerifyTxProof_box(t *testing.T) {
+	subTxs := types.Transactions{makeTx(1), makeTx(2)}
+	boxData, err := json.Marshal(&types.Box{SubTxList: subTxs})
+	assert.NoError(t, err)
+	boxTx := types.NewTransaction(common.HexToAddress("0x10000"), common.Address{}, nil, 21000, params.MinGasPrice, boxData, params.BoxTx, 100, 1538210391, "", "")
+	block := makeBlock(types.Transactions{makeTx(3), boxTx})
+
+	txProof, err := proof.NewTxProof(block, subTxs[1], boxTx)
+	assert.NoError(t, err)
+	assert.NoError(t, proof.VerifyTxProof(block.Header.TxRoot, txProof))
+	// not in box
+	txProof.Tx = makeTx(3)
+	assert.Equal(t, proof.ErrTxNotInBox, proof.VerifyTxProof(block.Header.TxRoot, txProof))
+}
@@ -0,0 +1,144 @@
+package chain
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/LemoFoundationLtd/lemochain-core/store/trie"
+	"time"
+)
+
+// statePruner removes the states which are neither in the recent stable blocks nor in the term snapshot blocks, then compacts the database files
+type statePruner struct {
+	bc   *BlockChain
+	db   *store.ChainDatabase
+	keep uint32 // the count of recent stable blocks whose states are kept. All states are kept if it is 0
+}
+
+func newStatePruner(bc *BlockChain, db *store.ChainDatabase, keep uint32) *statePruner {
+	return &statePruner{bc: bc, db: db, keep: keep}
+}
+
+// run prunes the states and compacts the database. It stops if quit is closed
+func (p *statePruner) run(quit <-chan struct{}) error {
+	stable := p.bc.StableBlock()
+	if p.keep == 0 || stable.Height() <= p.keep {
+		// only reclaim the overwritten records
+		_, err := p.db.Compact(nil, nil, quit)
+		return err
+	}
+
+	start := time.Now()
+	pruneHeight := stable.Height() - p.keep
+	// the trie nodes which are written after watermarks are always kept, because they may be referenced by the new states
+	watermarks := p.db.CompactWatermarks()
+	marker := newTrieMarker(p.db.GetTrieDatabase(), quit)
+
+	// mark the unconfirmed states before they become stable
+	err := p.db.IterateUnconfirmedStates(func(block *types.Block, accounts []*types.AccountData) error {
+		if err := marker.markTrie(block.VersionRoot()); err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			if err := marker.markAccount(account); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	retained, err := p.db.PruneActHistory(pruneHeight, marker.markAccount, quit)
+	if err != nil {
+		return err
+	}
+	if err := p.db.IterateAccounts(marker.markAccount); err != nil {
+		return err
+	}
+	// the blocks which become stable during marking are included
+	keepHeights := store.PruneKeepHeights(pruneHeight)
+	heights := keepHeights[:len(keepHeights)-1]
+	for height := pruneHeight; height <= p.bc.StableBlock().Height(); height++ {
+		heights = append(heights, height)
+	}
+	for _, height := range heights {
+		block := p.bc.GetBlockByHeight(height)
+		// the blocks before state snapshot may be not synchronized
+		if block == nil {
+			continue
+		}
+		if err := marker.markTrie(block.VersionRoot()); err != nil {
+			return err
+		}
+	}
+	log.Info("Mark states done", "pruneHeight", pruneHeight, "nodes", len(marker.marked), "time", time.Since(start))
+
+	_, err = p.db.Compact(watermarks, func(flag uint32, key []byte) bool {
+		// the pre-images of secure trie keys are small, so they are always kept
+		if flag == leveldb.ItemFlagTrie && len(key) == common.HashLength {
+			return marker.marked[common.BytesToHash(key)]
+		}
+		if store.IsActHistoryRecord(flag, key) {
+			return retained[string(key)]
+		}
+		return true
+	}, quit)
+	return err
+}
+
+// trieMarker marks the trie nodes which are reachable from the state roots
+type trieMarker struct {
+	db     *store.TrieDatabase
+	marked map[common.Hash]bool
+	quit   <-chan struct{}
+}
+
+func newTrieMarker(db *store.TrieDatabase, quit <-chan struct{}) *trieMarker {
+	return &trieMarker{
+		db:     db,
+		marked: make(map[common.Hash]bool),
+		quit:   quit,
+	}
+}
+
+// markTrie marks all nodes in the trie. The sub trie which is marked already is skipped
+func (m *trieMarker) markTrie(root common.Hash) error {
+	if root == (common.Hash{}) || m.marked[root] {
+		return nil
+	}
+	tr, err := trie.New(root, m.db)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	descend := true
+	for it.Next(descend) {
+		hash := it.Hash()
+		// the node which is embedded in its parent has no hash
+		if hash == (common.Hash{}) {
+			descend = true
+			continue
+		}
+		descend = !m.marked[hash]
+		m.marked[hash] = true
+	}
+	return it.Error()
+}
+
+// markAccount marks the tries of the account
+func (m *trieMarker) markAccount(account *types.AccountData) error {
+	select {
+	case <-m.quit:
+		return store.ErrCompactAborted
+	default:
+	}
+	for _, root := range []common.Hash{account.StorageRoot, account.AssetCodeRoot, account.AssetIdRoot, account.EquityRoot} {
+		if err := m.markTrie(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -155,7 +155,7 @@ func NewTestChain() (*chain.BlockChain, protocol.ChainDB) {
 	// must save genesis before new deputy manager
 	dm := deputynode.NewManager(5, db)
 	initBlocks(db, dm)
-	bc, err := chain.NewBlockChain(chain.Config{chainID, 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
+	bc, err := chain.NewBlockChain(chain.Config{ChainID: chainID, MineTimeout: 10000}, dm, db, flag.CmdFlags{}, txpool.NewTxPool())
 	if err != nil {
 		panic(err)
 	}
@@ -197,7 +197,7 @@ func InitGenesis(db protocol.ChainDB) *types.Block {
 	if err != nil {
 		panic(err)
 	}
-	saveBlock(db, am, block, defaultBlockInfos[0].status)
+	saveBlock(db, am, block, nil, defaultBlockInfos[0].status)
 	return block
 }
 
@@ -222,7 +222,7 @@ func makeBlock(db protocol.ChainDB, dm *deputynode.Manager, info blockInfo, pare
 	if err != nil {
 		panic(err)
 	}
-	block, validTxs, err := assembler.MineBlock(header, info.txList, 2)
+	block, receipts, validTxs, err := assembler.MineBlock(header, info.txList, 2)
 	if err != nil {
 		panic(err)
 	}
@@ -235,17 +235,22 @@ func makeBlock(db protocol.ChainDB, dm *deputynode.Manager, info blockInfo, pare
 		}
 	}
 
-	saveBlock(db, am, block, info.status)
+	saveBlock(db, am, block, receipts, info.status)
 	return block
 }
 
-func saveBlock(db protocol.ChainDB, am *account.Manager, block *types.Block, dbOp dbStatus) {
+func saveBlock(db protocol.ChainDB, am *account.Manager, block *types.Block, receipts types.Receipts, dbOp dbStatus) {
 	blockHash := block.Hash()
 	if dbOp != NotInStore {
 		err := db.SetBlock(blockHash, block)
 		if err != nil && err != store.ErrExist {
 			panic(err)
 		}
+		if receipts != nil && err == nil {
+			if err = db.SetReceipts(blockHash, receipts); err != nil {
+				panic(err)
+			}
+		}
 		err = am.Save(blockHash)
 		if err != nil {
 			panic(err)
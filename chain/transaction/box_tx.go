@@ -47,10 +47,11 @@ func (b *BoxTxEnv) RunBoxTxs(gp *types.GasPool, boxTx *types.Transaction, header
 			log.Errorf("Box txs runtime: %fs", time.Since(now).Seconds())
 			return 0, ErrApplyBoxTxsTimeout
 		}
-		gas, err := b.p.applyTx(gp, header, tx, txIndex, header.Hash(), math.MaxInt64)
+		receipt, err := b.p.applyTx(gp, header, tx, txIndex, header.Hash(), math.MaxInt64)
 		if err != nil {
 			return 0, err
 		}
+		gas := receipt.GasUsed
 		tx.SetGasUsed(gas)
 		newBoxTxList = append(newBoxTxList, tx)
 		gasUsed += gas
@@ -48,6 +48,7 @@ var (
 	ErrGasPayer                  = errors.New("the gasPayer error")
 	ErrAddressType               = errors.New("address type wrong")
 	ErrTempAddress               = errors.New("the issuer part in temp address is incorrect")
+	ErrCallTimeout               = errors.New("execution timeout")
 )
 
 type TxProcessor struct {
@@ -65,6 +66,7 @@ func NewTxProcessor(issueRewardAddress common.Address, chainID uint16, blockLoad
 	cfg := &vm.Config{
 		Debug:         false,
 		RewardManager: issueRewardAddress,
+		ChainID:       chainID,
 	}
 	return &TxProcessor{
 		ChainID:     chainID,
@@ -76,14 +78,15 @@ func NewTxProcessor(issueRewardAddress common.Address, chainID uint16, blockLoad
 	}
 }
 
-// Process processes all transactions in a block. Change accounts' data and execute contract codes.
-func (p *TxProcessor) Process(header *types.Header, txs types.Transactions) (uint64, error) {
+// Process processes all transactions in a block. Change accounts' data and execute contract codes. It returns the receipts of transactions
+func (p *TxProcessor) Process(header *types.Header, txs types.Transactions) (uint64, types.Receipts, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	var (
 		gp          = new(types.GasPool).AddGas(header.GasLimit)
 		gasUsed     = uint64(0)
 		totalGasFee = new(big.Int)
+		receipts    = make(types.Receipts, 0, len(txs))
 	)
 
 	p.am.Reset(header.ParentHash)
@@ -95,15 +98,17 @@ func (p *TxProcessor) Process(header *types.Header, txs types.Transactions) (uin
 	}
 	// Iterate over and process the individual transactions
 	for i, tx := range txs {
-		gas, err := p.applyTx(gp, header, tx, uint(i), header.Hash(), math.MaxInt64)
+		receipt, err := p.applyTx(gp, header, tx, uint(i), header.Hash(), math.MaxInt64)
 		if err != nil {
 			log.Info("Invalid transaction", "hash", tx.Hash(), "err", err)
-			return gasUsed, ErrInvalidTxInBlock
+			return gasUsed, nil, ErrInvalidTxInBlock
 		}
+		gas := receipt.GasUsed
 		if tx.GasUsed() != gas {
 			log.Error("Transaction gas used not equal", "hash", tx.Hash(), "oldGasUsed", tx.GasUsed(), "newGasUsed", gas)
-			return gasUsed, ErrTxGasUsedNotEqual
+			return gasUsed, nil, ErrTxGasUsedNotEqual
 		}
+		receipts = append(receipts, receipt)
 		gasUsed = gasUsed + gas
 		fee := new(big.Int).Mul(new(big.Int).SetUint64(gas), tx.GasPrice())
 		totalGasFee.Add(totalGasFee, fee)
@@ -113,17 +118,45 @@ func (p *TxProcessor) Process(header *types.Header, txs types.Transactions) (uin
 	if len(txs) > 0 {
 		log.Infof("Process %d transactions", len(txs))
 	}
-	return gasUsed, nil
+	return gasUsed, receipts, nil
 }
 
-// ApplyTxs picks and processes transactions from miner's tx pool.
-func (p *TxProcessor) ApplyTxs(header *types.Header, txs types.Transactions, timeLimitSecond int64) (types.Transactions, types.Transactions, uint64) {
+// TraceTxs re-executes the transactions of a block on its parent state. newTracer returns the vm tracer for each transaction, or nil if the transaction needn't be traced. The processor should have its own account manager which is based on the parent block, so that the history state could be used
+func (p *TxProcessor) TraceTxs(header *types.Header, txs types.Transactions, newTracer func(index int) vm.Tracer) (types.Receipts, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	defer func(cfg *vm.Config) { p.cfg = cfg }(p.cfg)
+
+	var (
+		gp       = new(types.GasPool).AddGas(header.GasLimit)
+		receipts = make(types.Receipts, 0, len(txs))
+		cfg      = *p.cfg
+	)
+	for i, tx := range txs {
+		traceCfg := cfg
+		if tracer := newTracer(i); tracer != nil {
+			traceCfg.Debug = true
+			traceCfg.Tracer = tracer
+		}
+		p.cfg = &traceCfg
+		receipt, err := p.applyTx(gp, header, tx, uint(i), header.Hash(), math.MaxInt64)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// ApplyTxs picks and processes transactions from miner's tx pool. It returns the selected transactions, invalid transactions, the receipts of selected transactions and total gas used
+func (p *TxProcessor) ApplyTxs(header *types.Header, txs types.Transactions, timeLimitSecond int64) (types.Transactions, types.Transactions, types.Receipts, uint64) {
 	var (
 		gp          = new(types.GasPool).AddGas(header.GasLimit)
 		gasUsed     = uint64(0)
 		totalGasFee = new(big.Int)
 		selectedTxs = make(types.Transactions, 0, len(txs))
 		invalidTxs  = make(types.Transactions, 0)
+		receipts    = make(types.Receipts, 0, len(txs))
 	)
 
 	p.am.Reset(header.ParentHash)
@@ -154,7 +187,7 @@ txsLoop:
 		snap := p.am.Snapshot()
 
 		log.Debug("applyTx", "hash", tx.Hash(), "from", tx.From())
-		gas, err := p.applyTx(gp, header, tx, uint(len(selectedTxs)), common.Hash{}, restApplyTime)
+		receipt, err := p.applyTx(gp, header, tx, uint(len(selectedTxs)), common.Hash{}, restApplyTime)
 		if err != nil {
 			p.am.RevertToSnapshot(snap)
 			if err == types.ErrGasLimitReached {
@@ -168,8 +201,10 @@ txsLoop:
 			}
 			continue
 		}
+		gas := receipt.GasUsed
 		tx.SetGasUsed(gas)
 		selectedTxs = append(selectedTxs, tx)
+		receipts = append(receipts, receipt)
 
 		gasUsed = gasUsed + gas
 		fee := new(big.Int).Mul(new(big.Int).SetUint64(gas), tx.GasPrice())
@@ -180,7 +215,7 @@ txsLoop:
 	if len(selectedTxs) > 0 {
 		log.Infof("Process %d transactions", len(selectedTxs))
 	}
-	return selectedTxs, invalidTxs, gasUsed
+	return selectedTxs, invalidTxs, receipts, gasUsed
 }
 
 // buyAndPayIntrinsicGas
@@ -331,13 +366,16 @@ func (p *TxProcessor) VerifyTxBeforeApply(tx *types.Transaction) error {
 	return nil
 }
 
-// applyTx processes transaction. Change accounts' data and execute contract codes.
-func (p *TxProcessor) applyTx(gp *types.GasPool, header *types.Header, tx *types.Transaction, txIndex uint, blockHash common.Hash, restApplyTime int64) (uint64, error) {
+// applyTx processes transaction. Change accounts' data and execute contract codes. It returns the receipt of the transaction
+func (p *TxProcessor) applyTx(gp *types.GasPool, header *types.Header, tx *types.Transaction, txIndex uint, blockHash common.Hash, restApplyTime int64) (*types.Receipt, error) {
+	if err := tx.VerifyVersion(header.Height); err != nil {
+		return nil, err
+	}
 	// 执行交易之前的交易校验
 	err := p.VerifyTxBeforeApply(tx)
 	if err != nil {
 		log.Warn("VerifyTxBeforeApply fail", "error", err.Error())
-		return 0, err
+		return nil, err
 	}
 
 	var (
@@ -348,18 +386,19 @@ func (p *TxProcessor) applyTx(gp *types.GasPool, header *types.Header, tx *types
 		restGas              = tx.GasLimit()
 		vmErr, execErr       error
 		gasUsed              uint64
+		contractAddr         common.Address
 	)
 
 	restGas, err = p.buyAndPayIntrinsicGas(gp, tx, restGas)
 	if err != nil {
 		log.Warn("buyAndPayIntrinsicGas fail", "error", err.Error())
-		return 0, err
+		return nil, err
 	}
 	// 执行交易. 注：如果此交易为箱子交易，则返回的gasUsed为箱子中的子交易消耗gas与箱子交易本身消耗gas之和
-	restGas, gasUsed, vmErr, execErr = p.handleTx(tx, header, txIndex, blockHash, initialSenderBalance, restGas, gp, restApplyTime)
+	restGas, gasUsed, contractAddr, vmErr, execErr = p.handleTx(tx, header, txIndex, blockHash, initialSenderBalance, restGas, gp, restApplyTime)
 	if execErr != nil {
 		log.Errorf("Apply transaction failure. error:%s, transaction: %s.", execErr.Error(), tx.String())
-		return 0, execErr
+		return nil, execErr
 	}
 
 	if vmErr != nil {
@@ -368,17 +407,39 @@ func (p *TxProcessor) applyTx(gp *types.GasPool, header *types.Header, tx *types
 		// sufficient balance to make the transfer happen. The first
 		// balance transfer may never fail.
 		if vmErr == vm.ErrInsufficientBalance {
-			return 0, vmErr
+			return nil, vmErr
 		}
 	}
 	p.refundGas(gp, tx, restGas)
 
-	return gasUsed, nil
+	receipt := types.NewReceipt(tx, txIndex, gasUsed, vmErr)
+	if vmErr == nil {
+		receipt.ContractAddress = contractAddr
+	}
+	receipt.Events = p.txEvents(tx)
+	return receipt, nil
+}
+
+// txEvents returns the events of the transaction. The events of sub transactions are included if it is a box transaction
+func (p *TxProcessor) txEvents(tx *types.Transaction) []*types.Event {
+	events := p.am.GetEventsByTx(tx.Hash())
+	if tx.Type() != params.BoxTx {
+		return events
+	}
+	box, err := types.GetBox(tx.Data())
+	if err != nil {
+		return events
+	}
+	for _, subTx := range box.SubTxList {
+		events = append(events, p.am.GetEventsByTx(subTx.Hash())...)
+	}
+	return events
 }
 
 // handleTx 执行交易,返回消耗之后剩余的gas、evm中执行的error和交易执行不成功的error.
 // 注：initialSenderBalance参数代表的是sender执行交易之前的balance值，为投票交易中计算初始票数使用
-func (p *TxProcessor) handleTx(tx *types.Transaction, header *types.Header, txIndex uint, blockHash common.Hash, initialSenderBalance *big.Int, restGas uint64, gp *types.GasPool, restApplyTime int64) (gas, gasUsed uint64, vmErr, err error) {
+// contractAddr is the address of new contract if the transaction is a contract creation
+func (p *TxProcessor) handleTx(tx *types.Transaction, header *types.Header, txIndex uint, blockHash common.Hash, initialSenderBalance *big.Int, restGas uint64, gp *types.GasPool, restApplyTime int64) (gas, gasUsed uint64, contractAddr common.Address, vmErr, err error) {
 	senderAddr := tx.From()
 	var (
 		recipientAddr common.Address
@@ -400,7 +461,7 @@ func (p *TxProcessor) handleTx(tx *types.Transaction, header *types.Header, txIn
 	case params.CreateContractTx:
 		newContext := NewEVMContext(tx, header, txIndex, blockHash, p.blockLoader)
 		vmEnv := vm.NewEVM(newContext, p.am, *p.cfg)
-		_, recipientAddr, restGas, vmErr = vmEnv.Create(sender, tx.Data(), restGas, tx.Amount())
+		_, contractAddr, restGas, vmErr = vmEnv.Create(sender, tx.Data(), restGas, tx.Amount())
 	case params.VoteTx:
 		candidateVoteEnv := NewCandidateVoteEnv(p.am, p.dm)
 		err = candidateVoteEnv.CallVoteTx(senderAddr, recipientAddr, initialSenderBalance)
@@ -439,12 +500,12 @@ func (p *TxProcessor) handleTx(tx *types.Transaction, header *types.Header, txIn
 
 	default:
 		log.Errorf("The type of transaction is not defined. ErrType = %d\n", tx.Type())
-		return 0, 0, nil, types.ErrTxType
+		return 0, 0, common.Address{}, nil, types.ErrTxType
 	}
 	// 只有交易类型为BoxTx时，subTxsGasUsed才有值
 	gasUsed = gasLimit - restGas + subTxsGasUsed
 
-	return restGas, gasUsed, vmErr, err
+	return restGas, gasUsed, contractAddr, vmErr, err
 }
 
 func (p *TxProcessor) buyGas(gp *types.GasPool, tx *types.Transaction) error {
@@ -630,6 +691,60 @@ func (p *TxProcessor) ReadContract(accM *account.ReadOnlyManager, header *types.
 	return ret, err
 }
 
+// CallTx executes the transaction with the accounts in accM, and the changes will not be saved. It returns the vm output and the gas used. The gas is not bought from sender, and only the intrinsic gas is used for the transactions which are not executed in vm
+func (p *TxProcessor) CallTx(accM *account.ReadOnlyManager, header *types.Header, tx *types.Transaction, timeout time.Duration) ([]byte, uint64, error) {
+	gas, err := IntrinsicGas(tx.Type(), tx.Data(), tx.Message())
+	if err != nil {
+		return nil, 0, err
+	}
+	if tx.GasLimit() < gas {
+		return nil, 0, vm.ErrOutOfGas
+	}
+	restGas := tx.GasLimit() - gas
+
+	var (
+		ret           []byte
+		vmErr         error
+		recipientAddr common.Address
+		sender        = accM.GetAccount(tx.From())
+		vmEnv         = getEVM(tx, header, 0, common.Hash{}, p.blockLoader, *p.cfg, accM)
+	)
+	if tx.To() != nil {
+		recipientAddr = *tx.To()
+	}
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	// listen timeout
+	go func() {
+		<-ctx.Done()
+		vmEnv.Cancel()
+	}()
+
+	switch tx.Type() {
+	case params.OrdinaryTx:
+		ret, restGas, vmErr = vmEnv.Call(sender, recipientAddr, tx.Data(), restGas, tx.Amount())
+	case params.CreateContractTx:
+		ret, _, restGas, vmErr = vmEnv.Create(sender, tx.Data(), restGas, tx.Amount())
+	case params.TransferAssetTx:
+		ret, restGas, err, vmErr = vmEnv.TransferAssetTx(sender, recipientAddr, restGas, tx.Data(), p.db)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, 0, ErrCallTimeout
+	}
+	return ret, tx.GasLimit() - restGas, vmErr
+}
+
 // getEVM
 func getEVM(tx *types.Transaction, header *types.Header, txIndex uint, blockHash common.Hash, chain ParentBlockLoader, cfg vm.Config, accM vm.AccountManager) *vm.EVM {
 	evmContext := NewEVMContext(tx, header, txIndex, blockHash, chain)
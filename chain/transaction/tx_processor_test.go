@@ -8,6 +8,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/vm"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
@@ -58,7 +59,7 @@ func TestTxProcessor_Process(t *testing.T) {
 	// 创建一个余额不足的交易
 	randPrivate, _ := crypto.GenerateKey()
 	tx := makeTx(randPrivate, crypto.PubkeyToAddress(randPrivate.PublicKey), godAddr, nil, params.OrdinaryTx, big.NewInt(4000000))
-	_, err = p.Process(block01.Header, types.Transactions{tx})
+	_, _, err = p.Process(block01.Header, types.Transactions{tx})
 	assert.Equal(t, ErrInvalidTxInBlock, err)
 }
 
@@ -83,8 +84,15 @@ func TestTxProcessor_Process_applyTxs(t *testing.T) {
 	gasUsed := block01.GasUsed()
 	applyTxsVersionRoot := block01.VersionRoot()
 	// 执行交易
-	newGasUsed, err := p.Process(block01.Header, txs)
+	newGasUsed, receipts, err := p.Process(block01.Header, txs)
 	assert.NoError(t, err)
+	assert.Equal(t, len(txs), len(receipts))
+	for i, receipt := range receipts {
+		assert.Equal(t, txs[i].Hash(), receipt.TxHash)
+		assert.Equal(t, uint(i), receipt.TxIndex)
+		assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+		assert.Equal(t, txs[i].GasUsed(), receipt.GasUsed)
+	}
 	am.Finalise()
 	processLogs := am.GetChangeLogs()
 	assert.Equal(t, applyTxsLogs, processLogs)                // 验证changlogs一致性
@@ -93,6 +101,45 @@ func TestTxProcessor_Process_applyTxs(t *testing.T) {
 
 }
 
+func TestTxProcessor_TraceTxs(t *testing.T) {
+	ClearData()
+	db, genesisHash := newCoverGenesisDB()
+	defer db.Close()
+	am := account.NewManager(genesisHash, db)
+	dm := deputynode.NewManager(5, db)
+
+	txs := make(types.Transactions, 0)
+	for i := 0; i < 3; i++ {
+		tx := makeTx(godPrivate, godAddr, common.HexToAddress("0x9920"+strconv.Itoa(i)), nil, params.OrdinaryTx, big.NewInt(50000))
+		txs = append(txs, tx)
+	}
+	block01 := newBlockForTest(1, txs, am, nil, db, false)
+	// the accounts in am are changed by block01, so trace with a new manager on the parent block
+	p := NewTxProcessor(config.RewardManager, config.ChainID, newTestChain(db), account.NewManager(genesisHash, db), db, dm)
+
+	// only trace the second transaction
+	tracer := vm.NewCallTracer()
+	receipts, err := p.TraceTxs(block01.Header, txs, func(index int) vm.Tracer {
+		if index == 1 {
+			return tracer
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(txs), len(receipts))
+	for i, receipt := range receipts {
+		assert.Equal(t, txs[i].Hash(), receipt.TxHash)
+		assert.Equal(t, txs[i].GasUsed(), receipt.GasUsed)
+	}
+	frame := tracer.Result()
+	assert.Equal(t, godAddr, frame.From)
+	assert.Equal(t, *txs[1].To(), frame.To)
+	assert.Equal(t, txs[1].Amount(), (*big.Int)(frame.Value))
+	// the vm config is restored
+	assert.False(t, p.cfg.Debug)
+	assert.Nil(t, p.cfg.Tracer)
+}
+
 // Test_ApplyTxs_TimeoutTime 测试执行交易超时情况
 func Test_ApplyTxs_TimeoutTime(t *testing.T) {
 	ClearData()
@@ -117,12 +164,66 @@ func Test_ApplyTxs_TimeoutTime(t *testing.T) {
 	for i := 0; i < txNum; i++ {
 		txs = append(txs, tx01)
 	}
-	selectedTxs01, _, _ := p.ApplyTxs(header, txs, int64(0))
+	selectedTxs01, _, _, _ := p.ApplyTxs(header, txs, int64(0))
 	assert.NotEqual(t, len(selectedTxs01), txNum)
-	selectedTxs02, _, _ := p.ApplyTxs(header, txs, int64(2))
+	selectedTxs02, _, _, _ := p.ApplyTxs(header, txs, int64(2))
 	assert.NotEqual(t, len(selectedTxs02), txNum)
-	selectedTxs03, _, _ := p.ApplyTxs(header, txs, int64(5000))
+	selectedTxs03, _, receipts03, _ := p.ApplyTxs(header, txs, int64(5000))
 	assert.Equal(t, len(selectedTxs03), txNum)
+	assert.Equal(t, len(receipts03), txNum)
+}
+
+// TestTxProcessor_ApplyTxs_receipts 测试交易执行之后的收据
+func TestTxProcessor_ApplyTxs_receipts(t *testing.T) {
+	ClearData()
+	db, genesisHash := newCoverGenesisDB()
+	defer db.Close()
+	am := account.NewManager(genesisHash, db)
+	dm := deputynode.NewManager(5, db)
+	p := NewTxProcessor(config.RewardManager, config.ChainID, newTestChain(db), am, db, dm)
+
+	parentBlock, err := db.LoadLatestBlock()
+	assert.NoError(t, err)
+	header := &types.Header{
+		ParentHash:   parentBlock.Hash(),
+		MinerAddress: parentBlock.MinerAddress(),
+		Height:       parentBlock.Height() + 1,
+		GasLimit:     parentBlock.GasLimit(),
+	}
+
+	by, err := ioutil.ReadFile("../transaction/contract_code.txt")
+	assert.NoError(t, err)
+	createContractTx := signTransaction(types.NewContractCreation(godAddr, nil, uint64(5000000), common.Big1, common.FromHex(string(by)), params.CreateContractTx, chainID, uint64(time.Now().Unix()+30*60), "", ""), godPrivate)
+	contractAddr := crypto.CreateContractAddress(godAddr, createContractTx.Hash())
+	transferTx := makeTx(godPrivate, godAddr, common.HexToAddress("0x11223"), nil, params.OrdinaryTx, common.Big1)
+	// call a not exist function, the contract will revert
+	failedTx := makeTx(godPrivate, godAddr, contractAddr, common.FromHex("0x12345678"), params.OrdinaryTx, common.Big0)
+	txs := types.Transactions{createContractTx, transferTx, failedTx}
+
+	selectedTxs, invalidTxs, receipts, _ := p.ApplyTxs(header, txs, int64(5000))
+	assert.Equal(t, 3, len(selectedTxs))
+	assert.Equal(t, 0, len(invalidTxs))
+	assert.Equal(t, 3, len(receipts))
+	for i, receipt := range receipts {
+		assert.Equal(t, txs[i].Hash(), receipt.TxHash)
+		assert.Equal(t, uint(i), receipt.TxIndex)
+		assert.Equal(t, selectedTxs[i].GasUsed(), receipt.GasUsed)
+	}
+
+	// create contract
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipts[0].Status)
+	assert.Equal(t, contractAddr, receipts[0].ContractAddress)
+	lastEvent := receipts[0].Events[len(receipts[0].Events)-1]
+	assert.Equal(t, types.TopicContractCreation, lastEvent.Topics[0])
+	assert.Equal(t, createContractTx.Hash(), lastEvent.TxHash)
+	// transfer
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipts[1].Status)
+	assert.Equal(t, common.Address{}, receipts[1].ContractAddress)
+	assert.Equal(t, 0, len(receipts[1].Events))
+	assert.Equal(t, "", receipts[1].VmErr)
+	// failed contract call
+	assert.Equal(t, types.ReceiptStatusFailed, receipts[2].Status)
+	assert.NotEqual(t, "", receipts[2].VmErr)
 }
 
 // Test_CreatRegisterTxData 构造注册候选节点所用交易data
@@ -11,5 +11,8 @@ var (
 	ErrInvalidTx          = errors.New("the transaction is broken")
 	ErrTxIsExist          = errors.New("the transaction is exist in txPool")
 	ErrTxPoolExtendFail   = errors.New("txPool extend fail")
+	ErrTxPoolFull         = errors.New("txPool is full and the transaction's gas price is too low")
+	ErrTxAccountLimit     = errors.New("too many transactions from the account in txPool")
+	ErrTxGasPriceTooLow   = errors.New("the transaction's gas price is lower than the txPool accepted")
 	ErrInvalidBaseTime    = errors.New("invalid stable block time")
 )
@@ -0,0 +1,45 @@
+package txpool
+
+import "github.com/LemoFoundationLtd/lemochain-core/chain/types"
+
+// pricedTx is an item in priceHeap
+type pricedTx struct {
+	tx    *types.Transaction
+	slot  int // the index in TxPool.txs. The later added transaction has the larger slot
+	index int // the index in priceHeap
+}
+
+// priceHeap is a min heap of transactions ordered by gas price. The later one is in front if their gas price are same
+type priceHeap []*pricedTx
+
+func (h priceHeap) Len() int {
+	return len(h)
+}
+
+func (h priceHeap) Less(i, j int) bool {
+	if cmp := h[i].tx.GasPrice().Cmp(h[j].tx.GasPrice()); cmp != 0 {
+		return cmp < 0
+	}
+	return h[i].slot > h[j].slot
+}
+
+func (h priceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	item := x.(*pricedTx)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *priceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,29 @@
+package txpool
+
+import (
+	"container/heap"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestPriceHeap(t *testing.T) {
+	price1 := params.MinGasPrice
+	price2 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(2))
+	tx1 := &pricedTx{tx: makePricedTx(101, price2), slot: 0}
+	tx2 := &pricedTx{tx: makePricedTx(102, price1), slot: 1}
+	tx3 := &pricedTx{tx: makePricedTx(103, price1), slot: 2}
+	h := priceHeap{}
+	heap.Push(&h, tx1)
+	heap.Push(&h, tx2)
+	heap.Push(&h, tx3)
+
+	// the later one is in front if gas price are same
+	assert.Equal(t, tx3, h[0])
+	heap.Remove(&h, tx3.index)
+	assert.Equal(t, tx2, h[0])
+	assert.Equal(t, tx2, heap.Pop(&h))
+	assert.Equal(t, tx1, heap.Pop(&h))
+	assert.Equal(t, 0, h.Len())
+}
@@ -1,36 +1,81 @@
 package txpool
 
 import (
+	"container/heap"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/metrics"
+	"math/big"
+	"sort"
 	"sync"
 )
 
 var (
-	txPoolTotalNumberCounter = metrics.NewCounter(metrics.TxpoolNumber_counterName) // 交易池中剩下的总交易数量
-	blockTradeAmount         = common.Lemo2Mo("500000")                             // 如果交易的amount 大于此值则进行事件通知
-	defaultPoolCap           = 128
+	txPoolTotalNumberCounter    = metrics.NewCounter(metrics.TxpoolNumber_counterName)        // 交易池中剩下的总交易数量
+	rejectedFullCounter         = metrics.NewCounter(metrics.TxpoolRejectFull_counterName)    // 交易池已满而被拒绝的交易数量
+	rejectedAccountLimitCounter = metrics.NewCounter(metrics.TxpoolRejectAccount_counterName) // 单个账户交易过多而被拒绝的交易数量
+	rejectedLowGasPriceCounter  = metrics.NewCounter(metrics.TxpoolRejectPrice_counterName)   // gas price过低而被拒绝的交易数量
+	rejectedExistCounter        = metrics.NewCounter(metrics.TxpoolRejectExist_counterName)   // 重复而被拒绝的交易数量
+	blockTradeAmount            = common.Lemo2Mo("500000")                                    // 如果交易的amount 大于此值则进行事件通知
+	defaultPoolCap              = 128
 )
 
+// Config is the limits of TxPool
+type Config struct {
+	MaxTxs           int      // the max count of transactions in pool. The cheapest transaction will be evicted if the pool is full
+	MaxTxsPerAccount int      // the max count of transactions sent from one account
+	MinGasPrice      *big.Int // the transaction with lower gas price will be rejected
+}
+
+var DefaultConfig = Config{
+	MaxTxs:           4 * params.MaxTxsForMiner,
+	MaxTxsPerAccount: 1024,
+	MinGasPrice:      big.NewInt(0),
+}
+
 // TxPool saves the transactions which could be packaged in current fork
 type TxPool struct {
 	txs types.Transactions
 	cap int
+	// the count of not nil items in txs
+	count  int
+	config Config
+	// the count of transactions in pool of every sender
+	accountCounts map[common.Address]int
 
 	// it is used to find the index of txs by tx hash. sub tx hash will be point to its box tx index
 	hashIndexMap map[common.Hash]int
+	// the transactions in txs ordered by gas price, so that the cheapest one could be evicted quickly
+	priced    priceHeap
+	pricedTxs map[common.Hash]*pricedTx
 
 	RW sync.RWMutex
 }
 
 func NewTxPool() *TxPool {
+	return NewTxPoolWithConfig(DefaultConfig)
+}
+
+// NewTxPoolWithConfig creates a TxPool with the limits in config. The zero value fields in config are set to default
+func NewTxPoolWithConfig(config Config) *TxPool {
+	if config.MaxTxs <= 0 {
+		config.MaxTxs = DefaultConfig.MaxTxs
+	}
+	if config.MaxTxsPerAccount <= 0 {
+		config.MaxTxsPerAccount = DefaultConfig.MaxTxsPerAccount
+	}
+	if config.MinGasPrice == nil {
+		config.MinGasPrice = DefaultConfig.MinGasPrice
+	}
 	pool := &TxPool{}
 	pool.cap = defaultPoolCap
+	pool.config = config
 	pool.txs = make(types.Transactions, 0, pool.cap)
+	pool.accountCounts = make(map[common.Address]int)
 	pool.hashIndexMap = make(map[common.Hash]int)
+	pool.pricedTxs = make(map[common.Hash]*pricedTx)
 	return pool
 }
 
@@ -46,11 +91,35 @@ func (pool *TxPool) addTx(tx *types.Transaction) error {
 		return ErrInvalidTx
 	}
 	if pool.isTxExist(tx) {
+		rejectedExistCounter.Inc(1)
 		return ErrTxIsExist
 	}
+	if tx.GasPrice().Cmp(pool.config.MinGasPrice) < 0 {
+		rejectedLowGasPriceCounter.Inc(1)
+		return ErrTxGasPriceTooLow
+	}
+	if pool.accountCounts[tx.From()] >= pool.config.MaxTxsPerAccount {
+		rejectedAccountLimitCounter.Inc(1)
+		return ErrTxAccountLimit
+	}
+
+	// make room for the new transaction
+	if pool.count >= pool.config.MaxTxs {
+		cheapest := pool.cheapestTx()
+		if cheapest == nil || cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0 {
+			rejectedFullCounter.Inc(1)
+			return ErrTxPoolFull
+		}
+		log.Debugf("TxPool is full, evict the cheapest tx %s", cheapest.Hash().Hex())
+		pool.delTx(cheapest)
+	}
 
-	// extend storage
+	// reuse the deleted slots if they are more than the transactions, or extend storage
 	txCount := len(pool.txs)
+	if pool.cap-txCount < 1 && txCount-pool.count > pool.count {
+		pool.compact()
+		txCount = len(pool.txs)
+	}
 	if pool.cap-txCount < 1 {
 		pool.cap *= 2
 		tmp := make(types.Transactions, txCount, pool.cap)
@@ -65,6 +134,11 @@ func (pool *TxPool) addTx(tx *types.Transaction) error {
 	index := len(pool.txs)
 	pool.txs = append(pool.txs, tx)
 	pool.hashIndexMap[tx.Hash()] = index
+	pool.count++
+	pool.accountCounts[tx.From()]++
+	item := &pricedTx{tx: tx, slot: index}
+	heap.Push(&pool.priced, item)
+	pool.pricedTxs[tx.Hash()] = item
 	// save sub transactions in box transaction
 	if tx.Type() == params.BoxTx {
 		for _, subTx := range getSubTxs(tx) {
@@ -110,7 +184,16 @@ func (pool *TxPool) AddTxs(txs types.Transactions) int {
 	return count
 }
 
+// cheapestTx returns the transaction with the lowest gas price. The later one is returned if their gas price are same
+func (pool *TxPool) cheapestTx() *types.Transaction {
+	if len(pool.priced) == 0 {
+		return nil
+	}
+	return pool.priced[0].tx
+}
+
 /* 本节点出块时，从交易池中取出交易进行打包，但并不从交易池中删除 */
+// GetTxs returns the transactions ordered by gas price. The earlier one is in front if their gas price are same. The transactions whose gas price is lower than params.MinGasPrice are skipped
 func (pool *TxPool) GetTxs(time uint32, size int) types.Transactions {
 	result := make([]*types.Transaction, 0, size)
 	if size <= 0 {
@@ -121,6 +204,8 @@ func (pool *TxPool) GetTxs(time uint32, size int) types.Transactions {
 	defer pool.RW.Unlock()
 
 	timeoutCount := 0
+	cheapCount := 0
+	candidates := make(types.Transactions, 0, pool.count)
 	for _, tx := range pool.txs {
 		if tx == nil {
 			continue
@@ -130,13 +215,22 @@ func (pool *TxPool) GetTxs(time uint32, size int) types.Transactions {
 			timeoutCount++
 			continue
 		}
-		result = append(result, tx)
-		if len(result) >= size {
-			break
+		// keep the cheap transaction in pool, it may be packaged if the least gas price is changed
+		if tx.GasPrice().Cmp(params.MinGasPrice) < 0 {
+			cheapCount++
+			continue
 		}
+		candidates = append(candidates, tx)
 	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].GasPrice().Cmp(candidates[j].GasPrice()) > 0
+	})
+	if len(candidates) > size {
+		candidates = candidates[:size]
+	}
+	result = append(result, candidates...)
 
-	log.Debugf("pick %d txs from txPool. timeoutCount=%d, txInPool=%d", len(result), timeoutCount, len(pool.txs))
+	log.Debugf("pick %d txs from txPool. timeoutCount=%d, cheapCount=%d, txInPool=%d", len(result), timeoutCount, cheapCount, pool.count)
 	return result
 }
 
@@ -149,6 +243,18 @@ func (pool *TxPool) delTx(tx *types.Transaction) {
 	if index, ok := pool.hashIndexMap[hash]; ok {
 		// If tx is a sub tx (from other miner's block). This will delete the box tx. So the box tx would not be packaged, it will be deleted when expired
 		// There is a small problem is that the other sub txs in the box could not be add into pool, because they are already in hashIndexMap, but they are not in txs
+		if indexTx := pool.txs[index]; indexTx != nil {
+			pool.count--
+			if pool.accountCounts[indexTx.From()] <= 1 {
+				delete(pool.accountCounts, indexTx.From())
+			} else {
+				pool.accountCounts[indexTx.From()]--
+			}
+			if item, ok := pool.pricedTxs[indexTx.Hash()]; ok {
+				heap.Remove(&pool.priced, item.index)
+				delete(pool.pricedTxs, indexTx.Hash())
+			}
+		}
 		pool.txs[index] = nil
 		delete(pool.hashIndexMap, hash)
 
@@ -207,8 +313,43 @@ func (pool *TxPool) gc() {
 			pool.cap--
 		}
 		pool.txs = make(types.Transactions, 0, pool.cap)
+		pool.count = 0
+		pool.accountCounts = make(map[common.Address]int)
 		pool.hashIndexMap = make(map[common.Hash]int)
+		pool.priced = nil
+		pool.pricedTxs = make(map[common.Hash]*pricedTx)
+	} else if len(pool.txs) > defaultPoolCap && len(pool.txs)-pool.count > pool.count {
+		pool.compact()
+	}
+}
+
+// compact removes the nil items in txs and rebuilds the indexes. The indexes of sub txs whose box tx has been deleted are dropped too
+func (pool *TxPool) compact() {
+	for pool.cap > defaultPoolCap && pool.count*4 <= pool.cap {
+		pool.cap /= 2
+	}
+	txs := make(types.Transactions, 0, pool.cap)
+	pool.hashIndexMap = make(map[common.Hash]int, pool.count)
+	pool.priced = make(priceHeap, 0, pool.count)
+	pool.pricedTxs = make(map[common.Hash]*pricedTx, pool.count)
+	for _, tx := range pool.txs {
+		if tx == nil {
+			continue
+		}
+		index := len(txs)
+		txs = append(txs, tx)
+		pool.hashIndexMap[tx.Hash()] = index
+		if tx.Type() == params.BoxTx {
+			for _, subTx := range getSubTxs(tx) {
+				pool.hashIndexMap[subTx.Hash()] = index
+			}
+		}
+		item := &pricedTx{tx: tx, slot: index, index: len(pool.priced)}
+		pool.priced = append(pool.priced, item)
+		pool.pricedTxs[tx.Hash()] = item
 	}
+	heap.Init(&pool.priced)
+	pool.txs = txs
 }
 
 func isTxTimeOut(tx *types.Transaction, time uint32) bool {
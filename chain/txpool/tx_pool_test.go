@@ -2,11 +2,14 @@ package txpool
 
 import (
 	"fmt"
+	"math/big"
 	"math/rand"
 	"testing"
 	"time"
 
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,7 +97,8 @@ func TestTxPool_AddTxs2(t *testing.T) {
 	type testInfo struct {
 		txs types.Transactions
 	}
-	pool := NewTxPool()
+	// all test txs are sent from the same account
+	pool := NewTxPoolWithConfig(Config{MaxTxsPerAccount: 10000})
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	// make 100 test cases
@@ -268,3 +272,143 @@ func TestTxPool_GetTxs(t *testing.T) {
 	assert.Equal(t, 2, len(pool.txs))
 	assert.Equal(t, (*types.Transaction)(nil), pool.txs[0])
 }
+
+func TestTxPool_GetTxs_gasPrice(t *testing.T) {
+	pool := NewTxPool()
+	cur := uint32(time.Now().Unix())
+	price1 := params.MinGasPrice
+	price2 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(2))
+	price3 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(3))
+	tx1 := makePricedTx(101, price1)
+	tx2 := makePricedTx(102, price2)
+	tx3 := makePricedTx(103, price3)
+	tx4 := makePricedTx(104, price2)
+	cheapTx := makePricedTx(105, new(big.Int).Sub(params.MinGasPrice, common.Big1))
+	_ = pool.AddTxs(types.Transactions{tx1, tx2, cheapTx, tx3, tx4})
+
+	// ordered by gas price, the earlier one is in front if gas price are same
+	txs := pool.GetTxs(cur, 10)
+	assert.Equal(t, types.Transactions{tx3, tx2, tx4, tx1}, txs)
+	txs = pool.GetTxs(cur, 2)
+	assert.Equal(t, types.Transactions{tx3, tx2}, txs)
+	// the cheap tx is kept in pool
+	assert.Equal(t, 5, pool.count)
+	assert.Equal(t, true, pool.isTxExist(cheapTx))
+
+	// change least gas price
+	oldPrice := params.MinGasPrice
+	params.MinGasPrice = common.Big1
+	txs = pool.GetTxs(cur, 10)
+	assert.Equal(t, types.Transactions{tx3, tx2, tx4, tx1, cheapTx}, txs)
+	params.MinGasPrice = price2
+	txs = pool.GetTxs(cur, 10)
+	assert.Equal(t, types.Transactions{tx3, tx2, tx4}, txs)
+	params.MinGasPrice = oldPrice
+}
+
+func TestTxPool_AddTx_evict(t *testing.T) {
+	pool := NewTxPool()
+	pool.config.MaxTxs = 3
+	price1 := params.MinGasPrice
+	price2 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(2))
+	price3 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(3))
+	tx1 := makePricedTx(101, price2)
+	tx2 := makePricedTx(102, price1)
+	tx3 := makePricedTx(103, price1)
+	_ = pool.AddTxs(types.Transactions{tx1, tx2, tx3})
+	assert.Equal(t, 3, pool.count)
+
+	// not more expensive than the cheapest one
+	err := pool.AddTx(makePricedTx(104, price1))
+	assert.Equal(t, ErrTxPoolFull, err)
+	assert.Equal(t, 3, pool.count)
+
+	// evict the later one of the cheapest txs
+	tx5 := makePricedTx(105, price3)
+	assert.NoError(t, pool.AddTx(tx5))
+	assert.Equal(t, 3, pool.count)
+	assert.Equal(t, false, pool.isTxExist(tx3))
+	tx6 := makePricedTx(106, price2)
+	assert.NoError(t, pool.AddTx(tx6))
+	assert.Equal(t, false, pool.isTxExist(tx2))
+	assert.Equal(t, types.Transactions{tx5, tx1, tx6}, pool.GetTxs(uint32(time.Now().Unix()), 10))
+
+	// count is decreased after deleting
+	pool.DelTxs(types.Transactions{tx1, tx1})
+	assert.Equal(t, 2, pool.count)
+	assert.NoError(t, pool.AddTx(tx2))
+	assert.Equal(t, 3, pool.count)
+}
+
+func TestTxPool_AddTx_limit(t *testing.T) {
+	price1 := params.MinGasPrice
+	price2 := new(big.Int).Mul(params.MinGasPrice, big.NewInt(2))
+	pool := NewTxPoolWithConfig(Config{MaxTxsPerAccount: 2, MinGasPrice: price2})
+	assert.Equal(t, DefaultConfig.MaxTxs, pool.config.MaxTxs)
+
+	// low gas price
+	err := pool.AddTx(makePricedTx(101, price1))
+	assert.Equal(t, ErrTxGasPriceTooLow, err)
+	assert.Equal(t, 0, pool.count)
+
+	// account limit
+	tx2 := makePricedTx(102, price2)
+	tx3 := makePricedTx(103, price2)
+	assert.Equal(t, 2, pool.AddTxs(types.Transactions{tx2, tx3}))
+	err = pool.AddTx(makePricedTx(104, price2))
+	assert.Equal(t, ErrTxAccountLimit, err)
+	assert.Equal(t, 2, pool.accountCounts[tx2.From()])
+
+	// the account could send again after its tx is deleted
+	pool.DelTxs(types.Transactions{tx2})
+	assert.Equal(t, 1, pool.accountCounts[tx2.From()])
+	assert.NoError(t, pool.AddTx(makePricedTx(104, price2)))
+	pool.DelTxs(types.Transactions{tx3})
+	assert.Equal(t, 1, pool.accountCounts[tx2.From()])
+
+	// box tx is counted by its sender
+	pool = NewTxPoolWithConfig(Config{MaxTxsPerAccount: 1})
+	subTx := makeTx(1050, 0)
+	boxTx := makeBoxTx(105, 0, subTx)
+	assert.NoError(t, pool.AddTx(boxTx))
+	assert.Equal(t, ErrTxAccountLimit, pool.AddTx(makeTx(106, 0)))
+	// delete box by sub tx
+	pool.DelTxs(types.Transactions{subTx})
+	assert.Equal(t, 0, len(pool.accountCounts))
+	assert.NoError(t, pool.AddTx(makeTx(106, 0)))
+}
+
+func TestTxPool_AddTx_churn(t *testing.T) {
+	maxTxs := 200
+	pool := NewTxPoolWithConfig(Config{MaxTxs: maxTxs, MaxTxsPerAccount: 100000})
+
+	// every new tx is more expensive, so the cheapest one is evicted
+	var last *types.Transaction
+	for i := 0; i < 20*maxTxs; i++ {
+		last = makePricedTx(int64(i), new(big.Int).Add(params.MinGasPrice, big.NewInt(int64(i))))
+		assert.NoError(t, pool.AddTx(last))
+		assert.Equal(t, true, len(pool.txs) <= 4*maxTxs)
+		assert.Equal(t, true, pool.cap <= 4*maxTxs)
+	}
+	assert.Equal(t, maxTxs, pool.count)
+	assert.Equal(t, maxTxs, len(pool.priced))
+	assert.Equal(t, last, pool.GetTxs(uint32(time.Now().Unix()), 1)[0])
+	assert.Equal(t, new(big.Int).Add(params.MinGasPrice, big.NewInt(int64(19*maxTxs))), pool.cheapestTx().GasPrice())
+
+	// the packaged txs are deleted
+	for i := 0; i < 20; i++ {
+		txs := make(types.Transactions, maxTxs/2)
+		for j := range txs {
+			txs[j] = makePricedTx(int64(100000+i*maxTxs+j), params.MinGasPrice)
+		}
+		_ = pool.AddTxs(txs)
+		pool.DelTxs(txs)
+		assert.Equal(t, true, len(pool.txs) <= 4*maxTxs)
+	}
+	assert.Equal(t, maxTxs, pool.count)
+	for _, tx := range pool.txs {
+		if tx != nil {
+			assert.Equal(t, true, pool.isTxExist(tx))
+		}
+	}
+}
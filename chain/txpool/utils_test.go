@@ -26,7 +26,7 @@ func makeBoxTx(amount int64, nowOffset int64, txs ...*types.Transaction) *types.
 	}
 
 	from := crypto.PubkeyToAddress(testPrivate.PublicKey)
-	tx := types.NoReceiverTransaction(from, big.NewInt(amount), 20000, big.NewInt(30000), data, params.BoxTx, chainID, uint64(time.Now().Unix()+nowOffset), "", "")
+	tx := types.NoReceiverTransaction(from, big.NewInt(amount), 20000, params.MinGasPrice, data, params.BoxTx, chainID, uint64(time.Now().Unix()+nowOffset), "", "")
 	return signTransaction(tx, testPrivate)
 }
 
@@ -39,9 +39,17 @@ func makeTx(amount int64, nowOffset int64) *types.Transaction {
 }
 
 func makeTransaction(txType uint16, amount *big.Int, expiration uint64) *types.Transaction {
+	return makePricedTransaction(txType, amount, params.MinGasPrice, expiration)
+}
+
+func makePricedTx(amount int64, gasPrice *big.Int) *types.Transaction {
+	return makePricedTransaction(params.OrdinaryTx, big.NewInt(amount), gasPrice, uint64(time.Now().Unix()+100))
+}
+
+func makePricedTransaction(txType uint16, amount *big.Int, gasPrice *big.Int, expiration uint64) *types.Transaction {
 	pubKey := testPrivate.PublicKey
 	from := crypto.PubkeyToAddress(pubKey)
-	tx := types.NewTransaction(from, common.HexToAddress("12AB"), amount, 1000000, common.Big1, []byte{}, txType, chainID, expiration, "", "")
+	tx := types.NewTransaction(from, common.HexToAddress("12AB"), amount, 1000000, gasPrice, []byte{}, txType, chainID, expiration, "", "")
 	return signTransaction(tx, testPrivate)
 }
 
@@ -236,6 +236,10 @@ func (a *AccountData) EncodeRLP(w io.Writer) error {
 	for logType, record := range a.NewestRecords {
 		NewestRecords = append(NewestRecords, rlpVersionRecord{logType, record.Version, record.Height})
 	}
+	// sort records so that the same account always has the same encoding
+	sort.Slice(NewestRecords, func(i, j int) bool {
+		return NewestRecords[i].LogType < NewestRecords[j].LogType
+	})
 
 	candidate := rlpCandidate{
 		Votes:   a.Candidate.Votes,
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+)
+
+const (
+	// BloomByteLength represents the number of bytes used in a block bloom.
+	BloomByteLength = 256
+	// BloomBitLength represents the number of bits used in a block bloom.
+	BloomBitLength = 8 * BloomByteLength
+)
+
+// Bloom represents a 2048 bit bloom filter. It is used to find out the blocks which may contain the events quickly
+type Bloom [BloomByteLength]byte
+
+// BytesToBloom converts a byte slice to a bloom filter.
+func BytesToBloom(b []byte) Bloom {
+	var bloom Bloom
+	bloom.SetBytes(b)
+	return bloom
+}
+
+// SetBytes sets the content of b to the given bytes. It panics if d is not of suitable size.
+func (b *Bloom) SetBytes(d []byte) {
+	if len(b) < len(d) {
+		panic("bloom bytes too big")
+	}
+	copy(b[BloomByteLength-len(d):], d)
+}
+
+// Add adds d to the filter.
+func (b *Bloom) Add(d []byte) {
+	h := crypto.Keccak256(d)
+	// take the first 3 pairs of bytes as 3 bit indexes
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(h[i+1]) + (uint(h[i]) << 8)) & (BloomBitLength - 1)
+		b[BloomByteLength-1-bit/8] |= byte(1) << (bit % 8)
+	}
+}
+
+// Test checks if d may be in the filter.
+func (b Bloom) Test(d []byte) bool {
+	var expect Bloom
+	expect.Add(d)
+	for i := range b {
+		if b[i]&expect[i] != expect[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Bytes returns the backing byte slice of the bloom
+func (b Bloom) Bytes() []byte {
+	return b[:]
+}
+
+// MarshalText encodes b as a hex string with 0x prefix.
+func (b Bloom) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(b[:]).MarshalText()
+}
+
+// UnmarshalText b as a hex string with 0x prefix.
+func (b *Bloom) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("Bloom", input, b[:], true)
+}
+
+// CreateBloom creates a bloom filter with the addresses and topics of all events in receipts
+func CreateBloom(receipts Receipts) Bloom {
+	var bloom Bloom
+	for _, receipt := range receipts {
+		for _, event := range receipt.Events {
+			bloom.Add(event.Address.Bytes())
+			for _, topic := range event.Topics {
+				bloom.Add(topic[:])
+			}
+		}
+	}
+	return bloom
+}
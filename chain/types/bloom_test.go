@@ -0,0 +1,65 @@
+package types
+
+import (
+	"encoding/json"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBloom_Test(t *testing.T) {
+	positive := [][]byte{
+		[]byte("testtest"),
+		[]byte("test"),
+		[]byte("hellohello"),
+		[]byte("tester"),
+		[]byte("hello"),
+	}
+	negative := [][]byte{
+		[]byte("lemo"),
+		[]byte("lemochain"),
+	}
+
+	var bloom Bloom
+	for _, data := range positive {
+		bloom.Add(data)
+	}
+	for _, data := range positive {
+		assert.True(t, bloom.Test(data))
+	}
+	for _, data := range negative {
+		assert.False(t, bloom.Test(data))
+	}
+
+	// empty bloom contains nothing
+	assert.False(t, Bloom{}.Test([]byte("test")))
+}
+
+func TestCreateBloom(t *testing.T) {
+	addr1 := common.HexToAddress("0x01")
+	addr2 := common.HexToAddress("0x02")
+	topic1 := common.HexToHash("0x11")
+	topic2 := common.HexToHash("0x22")
+	receipts := Receipts{
+		{Events: []*Event{{Address: addr1, Topics: []common.Hash{topic1}}}},
+		{Events: []*Event{}},
+		{Events: []*Event{{Address: addr2, Topics: []common.Hash{}}}},
+	}
+	bloom := CreateBloom(receipts)
+	assert.True(t, bloom.Test(addr1.Bytes()))
+	assert.True(t, bloom.Test(addr2.Bytes()))
+	assert.True(t, bloom.Test(topic1.Bytes()))
+	assert.False(t, bloom.Test(topic2.Bytes()))
+	assert.False(t, bloom.Test(common.HexToAddress("0x03").Bytes()))
+
+	// no event
+	assert.Equal(t, Bloom{}, CreateBloom(Receipts{}))
+
+	// encode and decode
+	assert.Equal(t, bloom, BytesToBloom(bloom.Bytes()))
+	data, err := json.Marshal(bloom)
+	assert.NoError(t, err)
+	var decoded Bloom
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, bloom, decoded)
+}
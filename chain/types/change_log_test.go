@@ -450,7 +450,7 @@ func testMarshal() []*testMarshalChangeLog {
 			Index:   2,
 			Removed: false,
 		}},
-		json: `{"type":"15","address":"Lemo888888888888888888888888888888885ZCK","version":"0","newValue":{"address":"Lemo88888888888888888888888888888888DY7T","topics":["0x0000000000000000000000000000000000000000000000000000000000000011","0x0000000000000000000000000000000000000000000000000000000000000022"],"data":"0x01020304050607","transactionHash":"0x0000000000000000000000000000000000000000000000000000000000000444","transactionIndex":"1","eventIndex":"2","removed":false},"extra":null}`,
+		json: `{"type":"15","address":"Lemo888888888888888888888888888888885ZCK","version":"0","newValue":{"address":"Lemo88888888888888888888888888888888DY7T","topics":["0x0000000000000000000000000000000000000000000000000000000000000011","0x0000000000000000000000000000000000000000000000000000000000000022"],"data":"0x01020304050607","blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000","blockHeight":"0","transactionHash":"0x0000000000000000000000000000000000000000000000000000000000000444","transactionIndex":"1","eventIndex":"2","removed":false},"extra":null}`,
 	}
 	// SuicideLog
 	test16 := &testMarshalChangeLog{
@@ -17,6 +17,9 @@ var (
 	// by a transaction is higher than what's left in the block.
 	ErrGasLimitReached = errors.New("block gas limit reached")
 
+	// ErrVerifyBlockFailed is returned if a block to import is invalid.
+	ErrVerifyBlockFailed = errors.New("verify block error")
+
 	// ErrBlacklistedHash is returned if a block to import is on the blacklist.
 	ErrBlacklistedHash = errors.New("blacklisted hash")
 	ErrInvalidSig      = errors.New("invalid transaction sig")
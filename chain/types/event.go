@@ -32,6 +32,8 @@ type Event struct {
 	// Derived fields. These fields are filled in by the node
 	// but not secured by consensus.
 	// block in which the transaction was included
+	BlockHash   common.Hash `json:"blockHash"`
+	BlockHeight uint32      `json:"blockHeight"`
 	// hash of the transaction
 	TxHash common.Hash `json:"transactionHash" gencodec:"required"`
 	// index of the transaction in the block
@@ -45,9 +47,10 @@ type Event struct {
 }
 
 type eventMarshaling struct {
-	Data    hexutil.Bytes
-	TxIndex hexutil.Uint64
-	Index   hexutil.Uint64
+	Data        hexutil.Bytes
+	BlockHeight hexutil.Uint32
+	TxIndex     hexutil.Uint64
+	Index       hexutil.Uint64
 }
 
 type rlpEvent struct {
@@ -15,18 +15,22 @@ var _ = (*eventMarshaling)(nil)
 // MarshalJSON marshals as JSON.
 func (e Event) MarshalJSON() ([]byte, error) {
 	type Event struct {
-		Address common.Address `json:"address" gencodec:"required"`
-		Topics  []common.Hash  `json:"topics" gencodec:"required"`
-		Data    hexutil.Bytes  `json:"data" gencodec:"required"`
-		TxHash  common.Hash    `json:"transactionHash" gencodec:"required"`
-		TxIndex hexutil.Uint64 `json:"transactionIndex" gencodec:"required"`
-		Index   hexutil.Uint64 `json:"eventIndex" gencodec:"required"`
-		Removed bool           `json:"removed"`
+		Address     common.Address `json:"address" gencodec:"required"`
+		Topics      []common.Hash  `json:"topics" gencodec:"required"`
+		Data        hexutil.Bytes  `json:"data" gencodec:"required"`
+		BlockHash   common.Hash    `json:"blockHash"`
+		BlockHeight hexutil.Uint32 `json:"blockHeight"`
+		TxHash      common.Hash    `json:"transactionHash" gencodec:"required"`
+		TxIndex     hexutil.Uint64 `json:"transactionIndex" gencodec:"required"`
+		Index       hexutil.Uint64 `json:"eventIndex" gencodec:"required"`
+		Removed     bool           `json:"removed"`
 	}
 	var enc Event
 	enc.Address = e.Address
 	enc.Topics = e.Topics
 	enc.Data = e.Data
+	enc.BlockHash = e.BlockHash
+	enc.BlockHeight = hexutil.Uint32(e.BlockHeight)
 	enc.TxHash = e.TxHash
 	enc.TxIndex = hexutil.Uint64(e.TxIndex)
 	enc.Index = hexutil.Uint64(e.Index)
@@ -37,13 +41,15 @@ func (e Event) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON unmarshals from JSON.
 func (e *Event) UnmarshalJSON(input []byte) error {
 	type Event struct {
-		Address *common.Address `json:"address" gencodec:"required"`
-		Topics  []common.Hash   `json:"topics" gencodec:"required"`
-		Data    *hexutil.Bytes  `json:"data" gencodec:"required"`
-		TxHash  *common.Hash    `json:"transactionHash" gencodec:"required"`
-		TxIndex *hexutil.Uint64 `json:"transactionIndex" gencodec:"required"`
-		Index   *hexutil.Uint64 `json:"eventIndex" gencodec:"required"`
-		Removed *bool           `json:"removed"`
+		Address     *common.Address `json:"address" gencodec:"required"`
+		Topics      []common.Hash   `json:"topics" gencodec:"required"`
+		Data        *hexutil.Bytes  `json:"data" gencodec:"required"`
+		BlockHash   *common.Hash    `json:"blockHash"`
+		BlockHeight *hexutil.Uint32 `json:"blockHeight"`
+		TxHash      *common.Hash    `json:"transactionHash" gencodec:"required"`
+		TxIndex     *hexutil.Uint64 `json:"transactionIndex" gencodec:"required"`
+		Index       *hexutil.Uint64 `json:"eventIndex" gencodec:"required"`
+		Removed     *bool           `json:"removed"`
 	}
 	var dec Event
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -61,6 +67,12 @@ func (e *Event) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'data' for Event")
 	}
 	e.Data = *dec.Data
+	if dec.BlockHash != nil {
+		e.BlockHash = *dec.BlockHash
+	}
+	if dec.BlockHeight != nil {
+		e.BlockHeight = uint32(*dec.BlockHeight)
+	}
 	if dec.TxHash == nil {
 		return errors.New("missing required field 'transactionHash' for Event")
 	}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"io"
+)
+
+//go:generate gencodec -type Receipt --field-override receiptMarshaling -out gen_receipt_json.go
+
+const (
+	// ReceiptStatusFailed is the status code of a transaction if execution failed in vm.
+	ReceiptStatusFailed = uint32(0)
+	// ReceiptStatusSuccessful is the status code of a transaction if execution succeeded.
+	ReceiptStatusSuccessful = uint32(1)
+)
+
+// Receipt represents the result of a transaction's execution
+type Receipt struct {
+	Status          uint32         `json:"status"           gencodec:"required"`
+	VmErr           string         `json:"vmErr"`
+	GasUsed         uint64         `json:"gasUsed"          gencodec:"required"`
+	Events          []*Event       `json:"events"           gencodec:"required"`
+	ContractAddress common.Address `json:"contractAddress"` // the address of contract which is created by the transaction
+
+	TxHash  common.Hash `json:"transactionHash"  gencodec:"required"`
+	TxIndex uint        `json:"transactionIndex" gencodec:"required"`
+
+	// Derived fields. These fields are filled in by the node when the receipt is loaded
+	BlockHash common.Hash `json:"blockHash"`
+	Height    uint32      `json:"height"`
+}
+
+type receiptMarshaling struct {
+	Status  hexutil.Uint32
+	GasUsed hexutil.Uint64
+	TxIndex hexutil.Uint64
+	Height  hexutil.Uint32
+}
+
+type rlpStorageReceipt struct {
+	Status          uint32
+	VmErr           string
+	GasUsed         uint64
+	Events          []*EventForStorage
+	ContractAddress common.Address
+	TxHash          common.Hash
+	TxIndex         uint
+}
+
+// NewReceipt creates a receipt for the transaction. The vmErr is the error returned from vm, it means the transaction execution is failed
+func NewReceipt(tx *Transaction, txIndex uint, gasUsed uint64, vmErr error) *Receipt {
+	r := &Receipt{
+		Status:  ReceiptStatusSuccessful,
+		GasUsed: gasUsed,
+		Events:  make([]*Event, 0),
+		TxHash:  tx.Hash(),
+		TxIndex: txIndex,
+	}
+	if vmErr != nil {
+		r.Status = ReceiptStatusFailed
+		r.VmErr = vmErr.Error()
+	}
+	return r
+}
+
+// EncodeRLP implements rlp.Encoder. The derived fields are not encoded
+func (r *Receipt) EncodeRLP(w io.Writer) error {
+	events := make([]*EventForStorage, len(r.Events))
+	for i, event := range r.Events {
+		events[i] = (*EventForStorage)(event)
+	}
+	return rlp.Encode(w, &rlpStorageReceipt{
+		Status:          r.Status,
+		VmErr:           r.VmErr,
+		GasUsed:         r.GasUsed,
+		Events:          events,
+		ContractAddress: r.ContractAddress,
+		TxHash:          r.TxHash,
+		TxIndex:         r.TxIndex,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
+	var dec rlpStorageReceipt
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	r.Status, r.VmErr, r.GasUsed, r.ContractAddress, r.TxHash, r.TxIndex = dec.Status, dec.VmErr, dec.GasUsed, dec.ContractAddress, dec.TxHash, dec.TxIndex
+	r.Events = make([]*Event, len(dec.Events))
+	for i, event := range dec.Events {
+		r.Events[i] = (*Event)(event)
+	}
+	return nil
+}
+
+func (r *Receipt) String() string {
+	return fmt.Sprintf("receipt: %s status: %d gasUsed: %d events: %d vmErr: %s", r.TxHash.Hex(), r.Status, r.GasUsed, len(r.Events), r.VmErr)
+}
+
+// Receipts is the receipt list of transactions in a block
+type Receipts []*Receipt
+
+// Find returns the receipt of the transaction
+func (rs Receipts) Find(txHash common.Hash) *Receipt {
+	for _, r := range rs {
+		if r.TxHash == txHash {
+			return r
+		}
+	}
+	return nil
+}
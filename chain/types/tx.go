@@ -25,8 +25,8 @@ const (
 //go:generate gencodec -type txdata --field-override txdataMarshaling -out gen_tx_json.go
 
 var (
-	DefaultTTTL uint64 = 2 * 60 * 60 // Transaction Time To Live, 2hours
-	TxVersion   uint8  = 1           // current transaction version. should between 0 and 128
+	DefaultTTTL uint64 = 2 * 60 * 60      // Transaction Time To Live, 2hours
+	TxVersion   uint8  = params.TxVersion // current transaction version. should between 0 and 128
 )
 
 var (
@@ -186,11 +186,7 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 	if err := dec.UnmarshalJSON(input); err != nil {
 		return err
 	}
-	version := dec.Version
-	if version != TxVersion {
-		return ErrInvalidVersion
-	}
-
+	// the version is checked by VerifyVersion with the height of block
 	for _, sig := range dec.Sigs {
 		if len(sig) != TxSigLength {
 			return ErrInvalidSig
@@ -399,6 +395,14 @@ func (tx *Transaction) Clone() *Transaction {
 	return &cpy
 }
 
+// VerifyVersion checks whether the transaction version is accepted in the block at the height
+func (tx *Transaction) VerifyVersion(height uint32) error {
+	if tx.Version() == 0 || tx.Version() > params.MaxTxVersion(height) {
+		return ErrInvalidVersion
+	}
+	return nil
+}
+
 // VerifyTxBody isBlockTx 为true表示验证block中的tx, 为false表示验证收到的交易
 func (tx *Transaction) VerifyTxBody(chainID uint16, timeStamp uint64, isBlockTx bool) (err error) {
 	defer func() {
@@ -7,6 +7,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"math/big"
 	"testing"
 	"time"
@@ -57,6 +58,27 @@ func TestNewTransaction(t *testing.T) {
 	assert.Empty(t, tx.Message())
 }
 
+func TestTransaction_VerifyVersion(t *testing.T) {
+	assert.NoError(t, testTx.VerifyVersion(0))
+	tx := newTransaction(testAddr, params.OrdinaryTx, 0, 200, nil, nil, common.Big1, 100, common.Big2, nil, 1544584596, "", "")
+	assert.Equal(t, ErrInvalidVersion, tx.VerifyVersion(0))
+	tx = newTransaction(testAddr, params.OrdinaryTx, TxVersion+1, 200, nil, nil, common.Big1, 100, common.Big2, nil, 1544584596, "", "")
+	assert.Equal(t, ErrInvalidVersion, tx.VerifyVersion(math.MaxUint32))
+
+	// the newer version can be decoded, and is rejected by height
+	data, err := json.Marshal(tx)
+	assert.NoError(t, err)
+	var parsedTx *Transaction
+	assert.NoError(t, json.Unmarshal(data, &parsedTx))
+	assert.Equal(t, TxVersion+1, parsedTx.Version())
+	assert.Equal(t, ErrInvalidVersion, parsedTx.VerifyVersion(math.MaxUint32))
+	data, err = rlp.EncodeToBytes(tx)
+	assert.NoError(t, err)
+	parsedTx = new(Transaction)
+	assert.NoError(t, rlp.DecodeBytes(data, parsedTx))
+	assert.Equal(t, TxVersion+1, parsedTx.Version())
+}
+
 func TestNewContractCreation(t *testing.T) {
 	expiration := ExpirationFromNow()
 	tx := NewContractCreation(testAddr, common.Big1, 100, common.Big2, []byte{0x01, 0x02}, params.CreateContractTx, 200, expiration, "aa", "")
@@ -0,0 +1,181 @@
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+)
+
+var errCallFailed = errors.New("call failed")
+
+// CallFrame is a call or contract creation in transaction execution. The nested calls are in Calls
+type CallFrame struct {
+	Type    string         `json:"type"` // CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE or CREATE2
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big10 `json:"value"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []*CallFrame   `json:"calls"`
+}
+
+// callEntry is a frame being executed
+type callEntry struct {
+	frame *CallFrame
+	gasIn uint64 // the caller's gas left when the frame is entered, excluding the gas given to the frame
+}
+
+// CallTracer records the tree of calls and contract creations in transaction execution. It implements Tracer
+type CallTracer struct {
+	root      *CallFrame
+	callStack []callEntry
+}
+
+// NewCallTracer returns a new call tracer
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+func (t *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.root = &CallFrame{
+		Type:  CALL.String(),
+		From:  from,
+		To:    to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if create {
+		t.root.Type = CREATE.String()
+	}
+	if value != nil {
+		t.root.Value = (*hexutil.Big10)(new(big.Int).Set(value))
+	}
+	t.callStack = []callEntry{{frame: t.root}}
+	return nil
+}
+
+// CaptureState finishes the frames which are returned, and enters a new frame if the operation is a call or contract creation
+func (t *CallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if err != nil {
+		t.captureError(depth, err)
+		return nil
+	}
+	// the frames deeper than current depth are returned
+	for len(t.callStack) > depth && len(t.callStack) > 1 {
+		t.exit(env, gas, stack)
+	}
+	switch op {
+	case CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE, CREATE2:
+		t.enter(env, op, gas, cost, memory, stack, contract)
+	}
+	return nil
+}
+
+func (t *CallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	t.captureError(depth, err)
+	return nil
+}
+
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if t.root == nil {
+		return nil
+	}
+	t.root.Output = common.CopyBytes(output)
+	t.root.GasUsed = hexutil.Uint64(gasUsed)
+	if err != nil && t.root.Error == "" {
+		t.root.Error = err.Error()
+	}
+	t.callStack = nil
+	return nil
+}
+
+// Result returns the root frame. It is nil if the transaction is not executed in vm
+func (t *CallTracer) Result() *CallFrame {
+	return t.root
+}
+
+// enter records the new frame with the caller's operation arguments on stack
+func (t *CallTracer) enter(env *EVM, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract) {
+	if len(t.callStack) == 0 || gas < cost {
+		return
+	}
+	frame := &CallFrame{
+		Type: op.String(),
+		From: contract.GetAddress(),
+	}
+	var (
+		gasIn   = gas - cost
+		callGas uint64
+		value   *big.Int
+	)
+	switch op {
+	case CREATE, CREATE2:
+		value = new(big.Int).Set(stack.Back(0))
+		frame.Input = memory.Get(stack.Back(1).Int64(), stack.Back(2).Int64())
+		callGas = gasIn - gasIn/64
+		gasIn -= callGas
+	case CALL, CALLCODE:
+		frame.To = common.BigToAddress(stack.Back(1))
+		value = new(big.Int).Set(stack.Back(2))
+		frame.Input = memory.Get(stack.Back(3).Int64(), stack.Back(4).Int64())
+		callGas = env.callGasTemp
+		if value.Sign() != 0 {
+			callGas += params.CallStipend
+		}
+	case DELEGATECALL, STATICCALL:
+		frame.To = common.BigToAddress(stack.Back(1))
+		frame.Input = memory.Get(stack.Back(2).Int64(), stack.Back(3).Int64())
+		callGas = env.callGasTemp
+	}
+	frame.Value = (*hexutil.Big10)(value)
+	frame.Gas = hexutil.Uint64(callGas)
+	parent := t.callStack[len(t.callStack)-1].frame
+	parent.Calls = append(parent.Calls, frame)
+	t.callStack = append(t.callStack, callEntry{frame: frame, gasIn: gasIn})
+}
+
+// exit finishes the deepest frame. The stack is the caller's, which has the result of call on top
+func (t *CallTracer) exit(env *EVM, gas uint64, stack *Stack) {
+	entry := t.callStack[len(t.callStack)-1]
+	t.callStack = t.callStack[:len(t.callStack)-1]
+	frame := entry.frame
+
+	if returnGas := gas - entry.gasIn; gas >= entry.gasIn && uint64(frame.Gas) >= returnGas {
+		frame.GasUsed = frame.Gas - hexutil.Uint64(returnGas)
+	}
+	frame.Output = common.CopyBytes(env.interpreter.returnData)
+	if stack.len() == 0 {
+		return
+	}
+	result := stack.peek()
+	if result.Sign() == 0 {
+		if frame.Error == "" {
+			frame.Error = errCallFailed.Error()
+		}
+		return
+	}
+	if frame.Type == CREATE.String() || frame.Type == CREATE2.String() {
+		frame.To = common.BigToAddress(result)
+		if code, err := env.am.GetAccount(frame.To).GetCode(); err == nil {
+			frame.Output = common.CopyBytes(code)
+		}
+	}
+}
+
+// captureError records the error to the frame at depth
+func (t *CallTracer) captureError(depth int, err error) {
+	if depth < 1 || depth > len(t.callStack) {
+		return
+	}
+	frame := t.callStack[depth-1].frame
+	if frame.Error == "" {
+		frame.Error = err.Error()
+	}
+}
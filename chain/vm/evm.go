@@ -6,6 +6,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/math"
 	"math/big"
 	"strconv"
 	"sync/atomic"
@@ -471,7 +472,20 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 
 // Create creates a new contract using code as deployment code.
 func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	contractAddr = crypto.CreateContractAddress(caller.GetAddress(), evm.TxHash)
+	return evm.create(caller, code, gas, value, contractAddr)
+}
+
+// Create2 creates a new contract using code as deployment code. The contract address is derived from the caller, salt and the hash of code
+func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, value *big.Int, salt *big.Int) (ret []byte, contractAddr common.Address, leftOverGas uint64, err error) {
+	var saltBytes [32]byte
+	copy(saltBytes[:], math.PaddedBigBytes(salt, 32))
+	contractAddr = crypto.CreateContractAddress2(caller.GetAddress(), saltBytes, crypto.Keccak256(code))
+	return evm.create(caller, code, gas, value, contractAddr)
+}
 
+// create creates a new contract at the address
+func (evm *EVM) create(caller ContractRef, code []byte, gas uint64, value *big.Int, contractAddr common.Address) (ret []byte, addr common.Address, leftOverGas uint64, err error) {
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
@@ -480,10 +494,9 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	if !evm.CanTransfer(evm.am, caller.GetAddress(), value) {
 		return nil, common.Address{}, gas, ErrInsufficientBalance
 	}
-	// Ensure there's no existing contract already at the designated address
-	contractAddr = crypto.CreateContractAddress(caller.GetAddress(), evm.TxHash)
 	// print out the contract address
 	log.Warnf("Created the contract address = %v", contractAddr.String())
+	// Ensure there's no existing contract already at the designated address
 	contractAccount := evm.am.GetAccount(contractAddr)
 	if !contractAccount.IsEmpty() {
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -24,6 +24,8 @@ type Config struct {
 	JumpTable [256]operation
 	// RewardManager is the owner of reward setting precompiled contract
 	RewardManager common.Address
+	// ChainID is returned by CHAINID instruction
+	ChainID uint16
 }
 
 // Interpreter is used to run Lemochain based contracts and will utilise the
@@ -46,7 +48,11 @@ func NewInterpreter(evm *EVM, cfg Config) *Interpreter {
 	// the jump table was initialised. If it was not
 	// we'll set the default jump table.
 	if !cfg.JumpTable[STOP].valid {
-		cfg.JumpTable = NewInstructionSet()
+		if params.IsActive(params.EVMUpgradeFork, evm.BlockHeight) {
+			cfg.JumpTable = NewUpgradedInstructionSet()
+		} else {
+			cfg.JumpTable = NewInstructionSet()
+		}
 	}
 
 	return &Interpreter{
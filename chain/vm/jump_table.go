@@ -902,3 +902,36 @@ func NewInstructionSet() [256]operation {
 		},
 	}
 }
+
+// NewUpgradedInstructionSet returns the instructions enabled by params.EVMUpgradeFork
+func NewUpgradedInstructionSet() [256]operation {
+	instructionSet := NewInstructionSet()
+	instructionSet[EXTCODEHASH] = operation{
+		execute:       opExtCodeHash,
+		gasCost:       gasExtCodeHash,
+		validateStack: makeStackFunc(1, 1),
+		valid:         true,
+	}
+	instructionSet[CHAINID] = operation{
+		execute:       opChainID,
+		gasCost:       constGasFunc(GasQuickStep),
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	instructionSet[SELFBALANCE] = operation{
+		execute:       opSelfBalance,
+		gasCost:       constGasFunc(GasFastStep),
+		validateStack: makeStackFunc(0, 1),
+		valid:         true,
+	}
+	instructionSet[CREATE2] = operation{
+		execute:       opCreate2,
+		gasCost:       gasCreate2,
+		validateStack: makeStackFunc(4, 1),
+		memorySize:    memoryCreate,
+		valid:         true,
+		writes:        true,
+		returns:       true,
+	}
+	return instructionSet
+}
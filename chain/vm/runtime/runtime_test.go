@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/vm/abi"
 	"github.com/LemoFoundationLtd/lemochain-core/store"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/LemoFoundationLtd/lemochain-core/chain/vm"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
 )
 
 func clearDB(db *store.ChainDatabase, path string) {
@@ -149,3 +152,119 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestUpgradedInstructions(t *testing.T) {
+	db := store.NewChainDataBase("../../../testdata/vm_TestUpgradedInstructions")
+	defer clearDB(db, "../../../testdata/vm_TestUpgradedInstructions")
+	defer params.SetForkConfig(params.DefaultForkConfig())
+	assert.NoError(t, params.SetForkConfig(params.ForkConfig{params.EVMUpgradeFork: 100}))
+
+	chainIDCode := []byte{
+		byte(vm.CHAINID),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	// not enabled before the upgrade height
+	cfg := &Config{
+		AccountManager: account.NewManager(common.Hash{}, db),
+		BlockHeight:    99,
+		EVMConfig:      vm.Config{ChainID: 200},
+	}
+	_, err := Execute(chainIDCode, nil, cfg)
+	assert.Error(t, err)
+
+	// CHAINID
+	cfg.BlockHeight = 100
+	ret, err := Execute(chainIDCode, nil, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(200), new(big.Int).SetBytes(ret))
+
+	// CREATE2 with salt 5. The init code is a STOP instruction at memory[0]
+	ret, err = Execute([]byte{
+		byte(vm.PUSH1), 5,
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.CREATE2),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}, nil, cfg)
+	assert.NoError(t, err)
+	expect := crypto.CreateContractAddress2(common.BytesToAddress([]byte("contract")), [32]byte{31: 5}, crypto.Keccak256([]byte{byte(vm.STOP)}))
+	assert.Equal(t, expect, common.BytesToAddress(ret))
+}
+
+func TestCallTracer(t *testing.T) {
+	db := store.NewChainDataBase("../../../testdata/vm_TestCallTracer")
+	defer clearDB(db, "../../../testdata/vm_TestCallTracer")
+	am := account.NewManager(common.Hash{}, db)
+	// returns 42
+	okAddr := common.HexToAddress("0xbbbb")
+	am.GetAccount(okAddr).SetCode([]byte{
+		byte(vm.PUSH1), 42,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+	// fails with invalid opcode
+	failAddr := common.HexToAddress("0xcccc")
+	am.GetAccount(failAddr).SetCode([]byte{0xfe})
+	call := func(to byte) []byte {
+		return []byte{
+			byte(vm.PUSH1), 32,
+			byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0,
+			byte(vm.PUSH2), to, to,
+			byte(vm.PUSH2), 0xff, 0xff,
+			byte(vm.CALL),
+			byte(vm.POP),
+		}
+	}
+	code := append(call(0xbb), call(0xcc)...)
+	code = append(code,
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	)
+
+	tracer := vm.NewCallTracer()
+	cfg := &Config{
+		AccountManager: am,
+		GasLimit:       1000000,
+		EVMConfig:      vm.Config{Debug: true, Tracer: tracer},
+	}
+	ret, err := Execute(code, nil, cfg)
+	assert.NoError(t, err)
+
+	root := tracer.Result()
+	assert.Equal(t, "CALL", root.Type)
+	assert.Equal(t, common.BytesToAddress([]byte("contract")), root.To)
+	assert.Equal(t, hexutil.Bytes(ret), root.Output)
+	assert.NotZero(t, root.GasUsed)
+	assert.Empty(t, root.Error)
+	assert.Equal(t, 2, len(root.Calls))
+
+	okCall := root.Calls[0]
+	assert.Equal(t, "CALL", okCall.Type)
+	assert.Equal(t, root.To, okCall.From)
+	assert.Equal(t, okAddr, okCall.To)
+	assert.Equal(t, hexutil.Uint64(0xffff), okCall.Gas)
+	assert.True(t, okCall.GasUsed > 0 && okCall.GasUsed < okCall.Gas)
+	assert.Equal(t, big.NewInt(42), new(big.Int).SetBytes(okCall.Output))
+	assert.Empty(t, okCall.Error)
+
+	failCall := root.Calls[1]
+	assert.Equal(t, failAddr, failCall.To)
+	assert.Equal(t, failCall.Gas, failCall.GasUsed)
+	assert.Equal(t, "invalid opcode 0xfe", failCall.Error)
+}
@@ -59,6 +59,16 @@ func CreateContractAddress(b common.Address, hash common.Hash) common.Address {
 	return encodeDataToAddress(data, common.ContractAddressType)
 }
 
+// CreateContractAddress2 creates a contract address by CREATE2 instruction. The address is decided by the creator, salt and the hash of init code, so it can be known before deployment
+func CreateContractAddress2(b common.Address, salt [32]byte, initCodeHash []byte) common.Address {
+	data := make([]byte, 0, 1+common.AddressLength+len(salt)+len(initCodeHash))
+	data = append(data, 0xff)
+	data = append(data, b.Bytes()...)
+	data = append(data, salt[:]...)
+	data = append(data, initCodeHash...)
+	return encodeDataToAddress(data, common.ContractAddressType)
+}
+
 // CreateTempAddress return a temp account address
 func CreateTempAddress(creator common.Address, userId [10]byte) common.Address {
 	tempAddress := make([]byte, common.AddressLength)
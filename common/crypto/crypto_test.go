@@ -84,6 +84,20 @@ func TestNewContractAddress(t *testing.T) {
 	assert.Equal(t, common.HexToAddress("0x028CadC3967854c060268084256A9c8A7C1B7c20"), caddr2)
 }
 
+func TestCreateContractAddress2(t *testing.T) {
+	addr := common.HexToAddress(testAddrHex)
+	codeHash := Keccak256([]byte{0x00})
+	caddr0 := CreateContractAddress2(addr, [32]byte{}, codeHash)
+	caddr1 := CreateContractAddress2(addr, [32]byte{1}, codeHash)
+	caddr2 := CreateContractAddress2(addr, [32]byte{}, Keccak256([]byte{0x01}))
+
+	assert.Equal(t, caddr0, CreateContractAddress2(addr, [32]byte{}, codeHash))
+	assert.Equal(t, byte(common.ContractAddressType), caddr0[0])
+	assert.NotEqual(t, caddr0, caddr1)
+	assert.NotEqual(t, caddr0, caddr2)
+	assert.NotEqual(t, caddr0, CreateContractAddress(addr, common.BytesToHash(codeHash)))
+}
+
 func TestLoadECDSAFile(t *testing.T) {
 	keyBytes := common.FromHex(testPrivHex)
 	fileName0 := "test_key0"
@@ -3,6 +3,7 @@ package common
 const (
 	DataDir          = "datadir"
 	ListenPort       = "port"
+	NoDiscovery      = "nodiscover"
 	MiningEnabled    = "mine"
 	RPCEnabled       = "rpc"
 	RPCPort          = "rpcport"
@@ -14,4 +15,9 @@ const (
 	WSPort           = "wsport"
 	WSAllowedOrigins = "wsorigins"
 	LogLevel         = "loglevel"
+	TxPoolMaxTxs     = "txpool.maxtxs"
+	TxPoolAccountTxs = "txpool.accounttxs"
+	TxPoolPriceLimit = "txpool.pricelimit"
+	SyncMode         = "syncmode"
+	BanDuration      = "banduration"
 )
@@ -26,8 +26,8 @@ var (
 
 // MerkleNode 用在获取与验证伴随节点
 type MerkleNode struct {
-	Hash     common.Hash
-	NodeType NodeTypeFlag
+	Hash     common.Hash  `json:"hash"`
+	NodeType NodeTypeFlag `json:"type"`
 }
 
 type MerkleTree struct {
@@ -143,24 +143,44 @@ func (r *CentralRouteSub) clearSub() {
 	r.names = make(map[string]*CaseListItem)
 }
 
-func Sub(name string, ch interface{}) {
-	if err := centralRoute.sub(name, ch); err != nil {
+// Sub subscribes the event by name on this route
+func (r *CentralRouteSub) Sub(name string, ch interface{}) {
+	if err := r.sub(name, ch); err != nil {
 		log.Error(err.Error())
 	}
 }
 
-func UnSub(name string, ch interface{}) {
-	if err := centralRoute.unSub(name, ch); err != nil {
+// UnSub unsubscribes the event by name on this route
+func (r *CentralRouteSub) UnSub(name string, ch interface{}) {
+	if err := r.unSub(name, ch); err != nil {
+		log.Error(err.Error())
+	}
+}
+
+// Send sends the event to the subscribers on this route
+func (r *CentralRouteSub) Send(name string, value interface{}) {
+	if err := r.send(name, value); err != nil {
 		log.Error(err.Error())
 	}
 }
 
+// DefaultRoute returns the global event bus
+func DefaultRoute() *CentralRouteSub {
+	return centralRoute
+}
+
+func Sub(name string, ch interface{}) {
+	centralRoute.Sub(name, ch)
+}
+
+func UnSub(name string, ch interface{}) {
+	centralRoute.UnSub(name, ch)
+}
+
 func ClearSub() {
 	centralRoute.clearSub()
 }
 
 func Send(name string, value interface{}) {
-	if err := centralRoute.send(name, value); err != nil {
-		log.Error(err.Error())
-	}
+	centralRoute.Send(name, value)
 }
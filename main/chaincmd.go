@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/chain"
+	"github.com/LemoFoundationLtd/lemochain-core/common/flag"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/main/node"
+	"gopkg.in/urfave/cli.v1"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	exportCommand = cli.Command{
+		Action:    exportChain,
+		Name:      "export",
+		Usage:     "Export stable blocks into file",
+		ArgsUsage: "<filename> [<fromHeight> <toHeight>]",
+		Flags:     []cli.Flag{node.DataDirFlag, node.LogLevelFlag},
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Export stable blocks with confirms and change logs into a block archive file.
+The blocks are from height 1 to the stable block by default.
+If the filename ends with ".gz", the file is gzip compressed.`,
+	}
+
+	importCommand = cli.Command{
+		Action:    importChain,
+		Name:      "import",
+		Usage:     "Import blocks from file",
+		ArgsUsage: "<filename>",
+		Flags:     []cli.Flag{node.DataDirFlag, node.LogLevelFlag},
+		Category:  "BLOCKCHAIN COMMANDS",
+		Description: `
+Import blocks from a block archive file which is made by export command.
+Every block is verified before it is inserted. The blocks which are already in chain are skipped.
+If the filename ends with ".gz", the file is gzip decompressed.`,
+	}
+)
+
+var (
+	ErrArchiveArgs  = errors.New("invalid arguments")
+	ErrArchiveRange = errors.New("invalid block height")
+)
+
+// exportChain 导出稳定块到文件
+func exportChain(ctx *cli.Context) error {
+	if ctx.NArg() != 1 && ctx.NArg() != 3 {
+		return ErrArchiveArgs
+	}
+	bc, closeChain := openChain(ctx)
+	defer closeChain()
+
+	from, to := uint32(1), bc.StableBlock().Height()
+	if ctx.NArg() == 3 {
+		var err error
+		if from, err = parseHeight(ctx.Args().Get(1)); err != nil {
+			return err
+		}
+		if to, err = parseHeight(ctx.Args().Get(2)); err != nil {
+			return err
+		}
+	}
+
+	fileName := ctx.Args().First()
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = file
+	var gzWriter *gzip.Writer
+	if strings.HasSuffix(fileName, ".gz") {
+		gzWriter = gzip.NewWriter(file)
+		w = gzWriter
+	}
+
+	if err := chain.ExportChain(w, bc, from, to); err != nil {
+		file.Close()
+		return err
+	}
+	// the archive is complete only if the buffered data is flushed by closing the gzip writer and then the file
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Export blocks from %d to %d succeed\n", from, to)
+	return nil
+}
+
+// importChain 从文件导入区块
+func importChain(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return ErrArchiveArgs
+	}
+	fileName := ctx.Args().First()
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Errorf("close archive file failed. %v", err)
+		}
+	}()
+	var r io.Reader = file
+	if strings.HasSuffix(fileName, ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzReader.Close()
+		r = gzReader
+	}
+
+	bc, closeChain := openChain(ctx)
+	defer closeChain()
+	count, err := chain.ImportChain(r, bc)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Import %d blocks succeed. Stable height: %d\n", count, bc.StableBlock().Height())
+	return nil
+}
+
+// openChain opens the chain in data dir, and returns a function to close it
+func openChain(ctx *cli.Context) (*chain.BlockChain, func()) {
+	initLog(ctx)
+	flags := flag.NewCmdFlags(ctx, []cli.Flag{node.DataDirFlag, node.LogLevelFlag})
+	bc, db := node.OpenChain(flags)
+	return bc, func() {
+		bc.Stop()
+		if err := db.Close(); err != nil {
+			log.Errorf("close db failed. %v", err)
+		}
+	}
+}
+
+func parseHeight(str string) (uint32, error) {
+	height, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return 0, ErrArchiveRange
+	}
+	return uint32(height), nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseHeight(t *testing.T) {
+	height, err := parseHeight("100")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(100), height)
+	_, err = parseHeight("-1")
+	assert.Equal(t, ErrArchiveRange, err)
+	_, err = parseHeight("4294967296")
+	assert.Equal(t, ErrArchiveRange, err)
+	_, err = parseHeight("abc")
+	assert.Equal(t, ErrArchiveRange, err)
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/metrics"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
 	"os"
 	"path/filepath"
 )
@@ -15,6 +17,10 @@ import (
 const (
 	JsonFileName   = "config.json"
 	ConfigGuideUrl = "Please visit https://github.com/LemoFoundationLtd/lemochain-core#configuration-file for more detail"
+	// MinPruneBlocks is the minimum count of recent stable blocks whose states are kept in pruning mode
+	MinPruneBlocks = 128
+	// DefaultCompactInterval is the default interval of pruning in minutes
+	DefaultCompactInterval = 60
 )
 
 var (
@@ -22,29 +28,48 @@ var (
 	ErrSleepTimeInConfig = fmt.Errorf(`file "%s" error: sleepTime can't be larger than timeout`, JsonFileName)
 	ErrTimeoutInConfig   = fmt.Errorf(`file "%s" error: timeout must be larger than 3000ms`, JsonFileName)
 	ErrChainIDInConfig   = fmt.Errorf(`file "%s" error: chainID must be in [1, 65535]`, JsonFileName)
+	ErrRateLimitInConfig = fmt.Errorf(`file "%s" error: rateLimits must be keyed by request message name, and the rate and burst must be larger than 0`, JsonFileName)
+	ErrForkInConfig      = fmt.Errorf(`file "%s" error: forks must be keyed by known fork name`, JsonFileName)
+	ErrPruneInConfig     = fmt.Errorf(`file "%s" error: pruneBlocks must be 0 or not less than %d`, JsonFileName, MinPruneBlocks)
+	ErrBackendInConfig   = fmt.Errorf(`file "%s" error: storeBackend must be "%s" or "%s"`, JsonFileName, store.BackendBitCask, store.BackendLevelDB)
 )
 
 //go:generate gencodec -type ConfigFromFile -field-override ConfigFromFileMarshaling -out gen_config_from_file_json.go
 
 type ConfigFromFile struct {
-	ChainID         uint64 `json:"chainID"        gencodec:"required"`
-	DeputyCount     uint64 `json:"deputyCount"`
-	SleepTime       uint64 `json:"sleepTime"`
-	Timeout         uint64 `json:"timeout"`
-	TermDuration    uint64 `json:"termDuration"`
-	InterimDuration uint64 `json:"interimDuration"`
-	ConnectionLimit uint64 `json:"connectionLimit"`
-	AlarmUrl        string `json:"alarmUrl"`
+	ChainID             uint64                     `json:"chainID"        gencodec:"required"`
+	DeputyCount         uint64                     `json:"deputyCount"`
+	SleepTime           uint64                     `json:"sleepTime"`
+	Timeout             uint64                     `json:"timeout"`
+	TermDuration        uint64                     `json:"termDuration"`
+	InterimDuration     uint64                     `json:"interimDuration"`
+	ConnectionLimit     uint64                     `json:"connectionLimit"`
+	AlarmUrl            string                     `json:"alarmUrl"`
+	MaxBlocksPerRequest uint64                     `json:"maxBlocksPerRequest"`
+	RateLimits          map[string]RateLimitConfig `json:"rateLimits"`      // the limits of request messages from each peer by message name, e.g. "GetBlocksMsg"
+	Forks               params.ForkConfig          `json:"forks"`           // the activation heights of consensus upgrades by fork name, e.g. "evmUpgrade"
+	PruneBlocks         uint64                     `json:"pruneBlocks"`     // the count of recent stable blocks whose states are kept. 0 means all states are kept
+	CompactInterval     uint64                     `json:"compactInterval"` // the interval of pruning states and compacting database files in minutes. 0 means disabled
+	StoreBackend        string                     `json:"storeBackend"`    // the backend which stores the chain data, "bitcask" or "leveldb". The existing database's backend is used if it is empty
+}
+
+// RateLimitConfig is the token bucket setting of one kind of request message
+type RateLimitConfig struct {
+	Rate  float64 `json:"rate"`  // the count of requests allowed per second
+	Burst uint64  `json:"burst"` // the max count of requests allowed at once
 }
 
 type ConfigFromFileMarshaling struct {
-	ChainID         hexutil.Uint64
-	DeputyCount     hexutil.Uint64
-	SleepTime       hexutil.Uint64
-	Timeout         hexutil.Uint64
-	TermDuration    hexutil.Uint64
-	InterimDuration hexutil.Uint64
-	ConnectionLimit hexutil.Uint64
+	ChainID             hexutil.Uint64
+	DeputyCount         hexutil.Uint64
+	SleepTime           hexutil.Uint64
+	Timeout             hexutil.Uint64
+	TermDuration        hexutil.Uint64
+	InterimDuration     hexutil.Uint64
+	ConnectionLimit     hexutil.Uint64
+	MaxBlocksPerRequest hexutil.Uint64
+	PruneBlocks         hexutil.Uint64
+	CompactInterval     hexutil.Uint64
 }
 
 func WriteConfigFile(dir string, cfg *ConfigFromFile) error {
@@ -128,4 +153,24 @@ func (c *ConfigFromFile) Check() {
 	if len(c.AlarmUrl) > 0 { // if configured, then start metrics and alarm system client
 		metrics.AlarmUrl = c.AlarmUrl
 	}
+	for name, limit := range c.RateLimits {
+		if _, ok := p2p.ParseRequestMsgCode(name); !ok || limit.Rate <= 0 || limit.Burst == 0 {
+			panic(ErrRateLimitInConfig)
+		}
+	}
+	if err := c.Forks.Check(); err != nil {
+		panic(ErrForkInConfig)
+	}
+	if c.PruneBlocks > 0 && c.PruneBlocks < MinPruneBlocks {
+		panic(ErrPruneInConfig)
+	}
+	if len(c.StoreBackend) > 0 {
+		if store.CheckBackend(c.StoreBackend) != nil {
+			panic(ErrBackendInConfig)
+		}
+	}
+	// the states are pruned in compaction
+	if c.PruneBlocks > 0 && c.CompactInterval == 0 {
+		c.CompactInterval = DefaultCompactInterval
+	}
 }
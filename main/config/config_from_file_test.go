@@ -75,6 +75,49 @@ func TestReadConfigFile_Check_Error(t *testing.T) {
 	assert.PanicsWithValue(t, ErrSleepTimeInConfig, func() {
 		cfg.Check()
 	})
+
+	cfg = getTestConfig()
+	cfg.RateLimits = map[string]RateLimitConfig{"NotExistMsg": {Rate: 1, Burst: 1}}
+	assert.PanicsWithValue(t, ErrRateLimitInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.RateLimits = map[string]RateLimitConfig{"BlocksMsg": {Rate: 1, Burst: 1}}
+	assert.PanicsWithValue(t, ErrRateLimitInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.RateLimits = map[string]RateLimitConfig{"GetBlocksMsg": {Rate: 0, Burst: 1}}
+	assert.PanicsWithValue(t, ErrRateLimitInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.RateLimits = map[string]RateLimitConfig{"GetBlocksMsg": {Rate: 1, Burst: 0}}
+	assert.PanicsWithValue(t, ErrRateLimitInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.Forks = params.ForkConfig{"notExistFork": 100}
+	assert.PanicsWithValue(t, ErrForkInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.PruneBlocks = 10
+	assert.PanicsWithValue(t, ErrPruneInConfig, func() {
+		cfg.Check()
+	})
+
+	cfg = getTestConfig()
+	cfg.StoreBackend = "rocksdb"
+	assert.PanicsWithValue(t, ErrBackendInConfig, func() {
+		cfg.Check()
+	})
+
 }
 
 func TestReadConfigFile_Check_DefaultValue(t *testing.T) {
@@ -102,4 +145,21 @@ func TestReadConfigFile_Check_DefaultValue(t *testing.T) {
 	assert.Equal(t, uint64(50), cfg.ConnectionLimit)
 
 	assert.Equal(t, cfg.AlarmUrl, metrics.AlarmUrl)
+
+	// the forks are not loaded by Check
+	cfg = getTestConfig()
+	cfg.Forks = params.ForkConfig{params.EVMUpgradeFork: 100}
+	cfg.Check()
+	assert.False(t, params.IsActive(params.EVMUpgradeFork, 100))
+
+	cfg = getTestConfig()
+	cfg.PruneBlocks = 1000
+	cfg.Check()
+	assert.Equal(t, uint64(60), cfg.CompactInterval)
+
+	cfg = getTestConfig()
+	cfg.StoreBackend = "leveldb"
+	cfg.PruneBlocks = 1000
+	cfg.Check()
+	assert.Equal(t, uint64(60), cfg.CompactInterval)
 }
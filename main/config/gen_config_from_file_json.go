@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
 )
 
@@ -14,14 +15,20 @@ var _ = (*ConfigFromFileMarshaling)(nil)
 // MarshalJSON marshals as JSON.
 func (c ConfigFromFile) MarshalJSON() ([]byte, error) {
 	type ConfigFromFile struct {
-		ChainID         hexutil.Uint64 `json:"chainID"        gencodec:"required"`
-		DeputyCount     hexutil.Uint64 `json:"deputyCount"`
-		SleepTime       hexutil.Uint64 `json:"sleepTime"`
-		Timeout         hexutil.Uint64 `json:"timeout"`
-		TermDuration    hexutil.Uint64 `json:"termDuration"`
-		InterimDuration hexutil.Uint64 `json:"interimDuration"`
-		ConnectionLimit hexutil.Uint64 `json:"connectionLimit"`
-		AlarmUrl        string         `json:"alarmUrl"`
+		ChainID             hexutil.Uint64             `json:"chainID"        gencodec:"required"`
+		DeputyCount         hexutil.Uint64             `json:"deputyCount"`
+		SleepTime           hexutil.Uint64             `json:"sleepTime"`
+		Timeout             hexutil.Uint64             `json:"timeout"`
+		TermDuration        hexutil.Uint64             `json:"termDuration"`
+		InterimDuration     hexutil.Uint64             `json:"interimDuration"`
+		ConnectionLimit     hexutil.Uint64             `json:"connectionLimit"`
+		AlarmUrl            string                     `json:"alarmUrl"`
+		MaxBlocksPerRequest hexutil.Uint64             `json:"maxBlocksPerRequest"`
+		RateLimits          map[string]RateLimitConfig `json:"rateLimits"`
+		Forks               params.ForkConfig          `json:"forks"`
+		PruneBlocks         hexutil.Uint64             `json:"pruneBlocks"`
+		CompactInterval     hexutil.Uint64             `json:"compactInterval"`
+		StoreBackend        string                     `json:"storeBackend"`
 	}
 	var enc ConfigFromFile
 	enc.ChainID = hexutil.Uint64(c.ChainID)
@@ -32,20 +39,32 @@ func (c ConfigFromFile) MarshalJSON() ([]byte, error) {
 	enc.InterimDuration = hexutil.Uint64(c.InterimDuration)
 	enc.ConnectionLimit = hexutil.Uint64(c.ConnectionLimit)
 	enc.AlarmUrl = c.AlarmUrl
+	enc.MaxBlocksPerRequest = hexutil.Uint64(c.MaxBlocksPerRequest)
+	enc.RateLimits = c.RateLimits
+	enc.Forks = c.Forks
+	enc.PruneBlocks = hexutil.Uint64(c.PruneBlocks)
+	enc.CompactInterval = hexutil.Uint64(c.CompactInterval)
+	enc.StoreBackend = c.StoreBackend
 	return json.Marshal(&enc)
 }
 
 // UnmarshalJSON unmarshals from JSON.
 func (c *ConfigFromFile) UnmarshalJSON(input []byte) error {
 	type ConfigFromFile struct {
-		ChainID         *hexutil.Uint64 `json:"chainID"        gencodec:"required"`
-		DeputyCount     *hexutil.Uint64 `json:"deputyCount"`
-		SleepTime       *hexutil.Uint64 `json:"sleepTime"`
-		Timeout         *hexutil.Uint64 `json:"timeout"`
-		TermDuration    *hexutil.Uint64 `json:"termDuration"`
-		InterimDuration *hexutil.Uint64 `json:"interimDuration"`
-		ConnectionLimit *hexutil.Uint64 `json:"connectionLimit"`
-		AlarmUrl        *string         `json:"alarmUrl"`
+		ChainID             *hexutil.Uint64            `json:"chainID"        gencodec:"required"`
+		DeputyCount         *hexutil.Uint64            `json:"deputyCount"`
+		SleepTime           *hexutil.Uint64            `json:"sleepTime"`
+		Timeout             *hexutil.Uint64            `json:"timeout"`
+		TermDuration        *hexutil.Uint64            `json:"termDuration"`
+		InterimDuration     *hexutil.Uint64            `json:"interimDuration"`
+		ConnectionLimit     *hexutil.Uint64            `json:"connectionLimit"`
+		AlarmUrl            *string                    `json:"alarmUrl"`
+		MaxBlocksPerRequest *hexutil.Uint64            `json:"maxBlocksPerRequest"`
+		RateLimits          map[string]RateLimitConfig `json:"rateLimits"`
+		Forks               params.ForkConfig          `json:"forks"`
+		PruneBlocks         *hexutil.Uint64            `json:"pruneBlocks"`
+		CompactInterval     *hexutil.Uint64            `json:"compactInterval"`
+		StoreBackend        *string                    `json:"storeBackend"`
 	}
 	var dec ConfigFromFile
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -76,5 +95,23 @@ func (c *ConfigFromFile) UnmarshalJSON(input []byte) error {
 	if dec.AlarmUrl != nil {
 		c.AlarmUrl = *dec.AlarmUrl
 	}
+	if dec.MaxBlocksPerRequest != nil {
+		c.MaxBlocksPerRequest = uint64(*dec.MaxBlocksPerRequest)
+	}
+	if dec.RateLimits != nil {
+		c.RateLimits = dec.RateLimits
+	}
+	if dec.Forks != nil {
+		c.Forks = dec.Forks
+	}
+	if dec.PruneBlocks != nil {
+		c.PruneBlocks = uint64(*dec.PruneBlocks)
+	}
+	if dec.CompactInterval != nil {
+		c.CompactInterval = uint64(*dec.CompactInterval)
+	}
+	if dec.StoreBackend != nil {
+		c.StoreBackend = *dec.StoreBackend
+	}
 	return nil
 }
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/keystore"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/main/console"
+	"github.com/LemoFoundationLtd/lemochain-core/main/node"
 	"github.com/LemoFoundationLtd/lemochain-core/network/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -57,19 +60,45 @@ Create nodekey and nodeID when we execute "./glemo createnodekey".`,
 	}
 )
 
-// createAccount
+// createAccount create an account and store its private key into keystore, encrypted with a passphrase
 func createAccount(ctx *cli.Context) error {
-	acc, err := crypto.GenerateAddress()
-	if err == nil {
-		fmt.Println("Please keep your account safe! \nPlease apply again if the private key is divulged!\n ")
-		fmt.Printf("Private:\n%s\n", acc.Private)
-		fmt.Printf("LemoAddress:\n%s\n\n", acc.Address.String())
+	passphrase, err := promptPassphrase()
+	if err != nil {
+		fmt.Println("Create account error:", err.Error())
 		return nil
-	} else {
+	}
+	dataDir := ctx.GlobalString(node.DataDirFlag.Name)
+	if ctx.IsSet(node.DataDirFlag.Name) {
+		dataDir = ctx.String(node.DataDirFlag.Name)
+	}
+	cfg := &node.Config{DataDir: dataDir}
+	ks := keystore.NewKeyStore(cfg.KeyStoreDir(), keystore.StandardScryptN, keystore.StandardScryptP)
+	addr, err := ks.NewAccount(passphrase)
+	if err != nil {
 		fmt.Println("Create account error:", err.Error())
 		fmt.Println("Suggest to retry!!!")
 		return nil
 	}
+	fmt.Println("Please keep your passphrase safe! \nThe account can't be unlocked without it!\n ")
+	fmt.Printf("LemoAddress:\n%s\n", addr.String())
+	fmt.Printf("KeyStore:\n%s\n\n", ks.Dir())
+	return nil
+}
+
+// promptPassphrase prompt user to input the passphrase twice
+func promptPassphrase() (string, error) {
+	passphrase, err := console.Stdin.PromptPassword("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := console.Stdin.PromptPassword("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
 }
 
 // createNodekey create nodekey and nodeID
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common/flock"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/main/node"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"path/filepath"
+)
+
+var (
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Offline database checking, repairing and migrating",
+		Category: "DATABASE COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Action: verifyDB,
+				Name:   "verify",
+				Usage:  "Verify the integrity of database",
+				Flags:  []cli.Flag{node.DataDirFlag, node.LogLevelFlag},
+				Description: `
+Check the CRC of all records in data files, the consistency of index, the hash of stable blocks and the candidates in run context.
+The node must be stopped before running this command.`,
+			},
+			{
+				Action: repairDB,
+				Name:   "repair",
+				Usage:  "Rebuild the index of database from data files",
+				Flags:  []cli.Flag{node.DataDirFlag, node.LogLevelFlag},
+				Description: `
+Rebuild the index from the records in data files after crash. The broken records are skipped.
+The node must be stopped before running this command.`,
+			},
+			{
+				Action:    migrateDB,
+				Name:      "migrate",
+				Usage:     "Convert the database to another store backend",
+				ArgsUsage: "<backend>",
+				Flags:     []cli.Flag{node.DataDirFlag, node.LogLevelFlag},
+				Description: `
+Copy the database into a new one which is stored by the backend, "bitcask" or "leveldb".
+The old database is renamed to "chaindata.<old backend>.bak", and it can be removed after checking the new one.
+Remove the "storeBackend" in config.json or set it to the new backend before starting the node.
+The node must be stopped before running this command.`,
+			},
+		},
+	}
+)
+
+var (
+	ErrDBIssueFound = errors.New("database has issues")
+	ErrMigrateArgs  = errors.New("invalid arguments")
+)
+
+// verifyDB 检查数据库完整性
+func verifyDB(ctx *cli.Context) error {
+	return withDBLock(ctx, func(chainDataPath string) error {
+		report, err := store.VerifyDB(chainDataPath)
+		if err != nil {
+			return err
+		}
+		printDBReport(report)
+		if !report.OK() {
+			return ErrDBIssueFound
+		}
+		fmt.Println("Database is OK")
+		return nil
+	})
+}
+
+// repairDB 从数据文件重建索引
+func repairDB(ctx *cli.Context) error {
+	return withDBLock(ctx, func(chainDataPath string) error {
+		report, err := store.RepairDB(chainDataPath)
+		if err != nil {
+			return err
+		}
+		printDBReport(report)
+		fmt.Println("Rebuild index done")
+		return nil
+	})
+}
+
+// migrateDB 将数据库转换为另一种存储后端
+func migrateDB(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return ErrMigrateArgs
+	}
+	backend := ctx.Args().First()
+	if err := store.CheckBackend(backend); err != nil {
+		return err
+	}
+	return withDBLock(ctx, func(chainDataPath string) error {
+		tmpPath := chainDataPath + ".migrate"
+		// the unfinished migration is discarded
+		if err := os.RemoveAll(tmpPath); err != nil {
+			return err
+		}
+		stats, err := store.MigrateDB(chainDataPath, tmpPath, backend)
+		if err != nil {
+			os.RemoveAll(tmpPath)
+			return err
+		}
+		bakPath := fmt.Sprintf("%s.%s.bak", chainDataPath, stats.From)
+		if err := os.Rename(chainDataPath, bakPath); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, chainDataPath); err != nil {
+			return err
+		}
+		fmt.Printf("Migrate database from %s to %s done. items: %d, indexes: %d\n", stats.From, backend, stats.Items, stats.Indexes)
+		fmt.Printf("The old database is moved to %s\n", bakPath)
+		return nil
+	})
+}
+
+// withDBLock locks the data dir so that the node can't start while processing database
+func withDBLock(ctx *cli.Context, fn func(chainDataPath string) error) error {
+	initLog(ctx)
+	dataDir := ctx.GlobalString(node.DataDirFlag.Name)
+	if ctx.IsSet(node.DataDirFlag.Name) {
+		dataDir = ctx.String(node.DataDirFlag.Name)
+	}
+	release, _, err := flock.New(filepath.Join(dataDir, "LOCK"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := release.Release(); err != nil {
+			log.Errorf("Can't release datadir lock: %v", err)
+		}
+	}()
+	return fn(node.GetChainDataPath(dataDir))
+}
+
+func printDBReport(report *store.DBReport) {
+	fmt.Printf("Records: %d, broken records: %d, queued records: %d, blocks: %d, candidates: %d\n",
+		report.Records, report.BrokenRecords, report.QueuedRecords, report.Blocks, report.Candidates)
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+}
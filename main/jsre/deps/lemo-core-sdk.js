@@ -25132,7 +25132,6 @@
 	      return _getBalance.apply(this, arguments);
 	    };
 	  }(),
-	  newKeyPair: generateAccount,
 
 	  /**
 	   * Create temp address
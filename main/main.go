@@ -25,8 +25,14 @@ var (
 	nodeFlags = []cli.Flag{
 		node.DataDirFlag,
 		node.ListenPortFlag,
+		node.NoDiscoveryFlag,
 		node.AutoMineFlag,
 		node.LogLevelFlag,
+		node.TxPoolMaxTxsFlag,
+		node.TxPoolAccountTxsFlag,
+		node.TxPoolPriceLimitFlag,
+		node.SyncModeFlag,
+		node.BanDurationFlag,
 	}
 
 	rpcFlags = []cli.Flag{
@@ -42,7 +48,7 @@ var (
 	}
 
 	attachFlags        = make([]cli.Flag, 0)
-	createaccountFlags = make([]cli.Flag, 0)
+	createaccountFlags = []cli.Flag{node.DataDirFlag}
 	createnodekeyFlags = make([]cli.Flag, 0)
 )
 
@@ -52,6 +58,9 @@ func init() {
 	app.Copyright = "Copyright 2017-2018 The lemochain-core Authors"
 	app.Commands = []cli.Command{
 		initCommand,
+		exportCommand,
+		importCommand,
+		dbCommand,
 		consoleCommand,
 		attachCommand,
 		createaccountCommand,  // create an account when run "./glemo createaccount"
@@ -8,16 +8,21 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/consensus"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/keystore"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/miner"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/proof"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/transaction"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/vm"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
-	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
 	"github.com/LemoFoundationLtd/lemochain-core/network"
 	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/protocol"
 	"math/big"
 	"runtime"
 	"strconv"
@@ -37,50 +42,111 @@ var (
 	ErrInputParams    = errors.New("input params incorrect")
 	ErrTxTo           = errors.New("transaction to is incorrect")
 	ErrNotMiner       = errors.New("the node is not a miner")
+	ErrUnlockDuration = errors.New("unlock duration is too large")
+)
+
+const (
+	// maxUnlockDuration is the max seconds of unlocking account, about one year
+	maxUnlockDuration = 365 * 24 * 3600
+	// callTimeout is the max execution time of a message in tx_call or tx_estimateGas
+	callTimeout = 5 * time.Second
 )
 
 // Private
 type PrivateAccountAPI struct {
-	manager *account.Manager
+	manager  *account.Manager
+	keystore *keystore.KeyStore
 }
 
 // NewPrivateAccountAPI
-func NewPrivateAccountAPI(m *account.Manager) *PrivateAccountAPI {
-	return &PrivateAccountAPI{m}
+func NewPrivateAccountAPI(m *account.Manager, ks *keystore.KeyStore) *PrivateAccountAPI {
+	return &PrivateAccountAPI{m, ks}
 }
 
-//go:generate gencodec -type LemoAccount -out gen_lemo_account_json.go
-type LemoAccount struct {
-	Private string         `json:"private"`
-	Address common.Address `json:"address"`
+// NewAccount create a new account and store its private key into keystore, encrypted with the passphrase
+func (a *PrivateAccountAPI) NewAccount(passphrase string) (common.Address, error) {
+	return a.keystore.NewAccount(passphrase)
 }
 
-// NewAccount get lemo address api
-func (a *PrivateAccountAPI) NewKeyPair() (*LemoAccount, error) {
-	accountKey, err := crypto.GenerateAddress()
+// ListAccounts get all account addresses in keystore
+func (a *PrivateAccountAPI) ListAccounts() ([]common.Address, error) {
+	return a.keystore.Accounts()
+}
+
+// Unlock unlock the account for duration seconds. The account will be unlocked until lock api is called if duration is 0
+func (a *PrivateAccountAPI) Unlock(lemoAddress string, passphrase string, duration uint64) (bool, error) {
+	addr, err := common.StringToAddress(lemoAddress)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	if duration > maxUnlockDuration {
+		return false, ErrUnlockDuration
+	}
+	if err := a.keystore.Unlock(addr, passphrase, time.Duration(duration)*time.Second); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Lock lock the account
+func (a *PrivateAccountAPI) Lock(lemoAddress string) (bool, error) {
+	addr, err := common.StringToAddress(lemoAddress)
+	if err != nil {
+		return false, err
 	}
-	acc := &LemoAccount{
-		Private: accountKey.Private,
-		Address: accountKey.Address,
+	if err := a.keystore.Lock(addr); err != nil {
+		return false, err
 	}
-	return acc, nil
+	return true, nil
+}
+
+// SignTx sign the transaction with the unlocked account of tx.From()
+func (a *PrivateAccountAPI) SignTx(tx *types.Transaction) (*types.Transaction, error) {
+	if tx == nil {
+		return nil, ErrInputParams
+	}
+	return a.keystore.SignTx(tx.From(), tx)
+}
+
+// BlockHeightOrHash specifies a block by height in json number or by hash in json string
+type BlockHeightOrHash struct {
+	Height *uint32
+	Hash   *common.Hash
+}
+
+// UnmarshalJSON parses a block height or a block hash
+func (b *BlockHeightOrHash) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		var hash common.Hash
+		if err := json.Unmarshal(input, &hash); err != nil {
+			return err
+		}
+		b.Height, b.Hash = nil, &hash
+		return nil
+	}
+	var height uint32
+	if err := json.Unmarshal(input, &height); err != nil {
+		return err
+	}
+	b.Height, b.Hash = &height, nil
+	return nil
 }
 
 // PublicAccountAPI API for access to account information
 type PublicAccountAPI struct {
 	manager *account.Manager
+	chain   *chain.BlockChain
+	db      protocol.ChainDB
 }
 
 // NewPublicAccountAPI
-func NewPublicAccountAPI(m *account.Manager) *PublicAccountAPI {
-	return &PublicAccountAPI{m}
+func NewPublicAccountAPI(m *account.Manager, chain *chain.BlockChain, db protocol.ChainDB) *PublicAccountAPI {
+	return &PublicAccountAPI{m, chain, db}
 }
 
-// GetBalance get balance in mo
-func (a *PublicAccountAPI) GetBalance(lemoAddress string) (string, error) {
-	lemoAccount, err := a.GetAccount(lemoAddress)
+// GetBalance get balance in mo. The balance at the block is returned if the block is set
+func (a *PublicAccountAPI) GetBalance(lemoAddress string, block *BlockHeightOrHash) (string, error) {
+	lemoAccount, err := a.GetAccount(lemoAddress, block)
 	if err != nil {
 		return "", err
 	}
@@ -89,21 +155,45 @@ func (a *PublicAccountAPI) GetBalance(lemoAddress string) (string, error) {
 	return balance, nil
 }
 
-// GetAccount return the struct of the &AccountData{}
-func (a *PublicAccountAPI) GetAccount(lemoAddress string) (types.AccountAccessor, error) {
+// GetAccount return the struct of the &AccountData{}. The account state at the block is returned if the block is set, otherwise the newest stable state is returned
+func (a *PublicAccountAPI) GetAccount(lemoAddress string, block *BlockHeightOrHash) (types.AccountAccessor, error) {
 	address, err := common.StringToAddress(lemoAddress)
 	if err != nil {
 		log.Warnf("lemoAddress is incorrect. lemoAddress: %s", lemoAddress)
 		return nil, err
 	}
-	accountData := a.manager.GetCanonicalAccount(address)
-	// accountData := a.manager.GetAccount(address)
-	return accountData, nil
+	if block == nil {
+		return a.manager.GetCanonicalAccount(address), nil
+	}
+	return a.getAccountAt(address, block)
+}
+
+// getAccountAt loads the readonly account state at the block
+func (a *PublicAccountAPI) getAccountAt(address common.Address, selector *BlockHeightOrHash) (types.AccountAccessor, error) {
+	var block *types.Block
+	if selector.Hash != nil {
+		block = a.chain.GetBlockByHash(*selector.Hash)
+	} else if selector.Height != nil {
+		block = a.chain.GetBlockByHeight(*selector.Height)
+	}
+	if block == nil {
+		return nil, store.ErrBlockNotExist
+	}
+
+	acctDb, err := a.db.GetHistoryActDatabase(block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	data, err := acctDb.Get(address)
+	if err != nil && err != store.ErrAccountNotExist {
+		return nil, err
+	}
+	return account.NewReadOnlyAccount(a.db, address, data), nil
 }
 
 // GetVoteFor
-func (a *PublicAccountAPI) GetVoteFor(lemoAddress string) (string, error) {
-	candiAccount, err := a.GetAccount(lemoAddress)
+func (a *PublicAccountAPI) GetVoteFor(lemoAddress string, block *BlockHeightOrHash) (string, error) {
+	candiAccount, err := a.GetAccount(lemoAddress, block)
 	if err != nil {
 		return "", err
 	}
@@ -112,8 +202,8 @@ func (a *PublicAccountAPI) GetVoteFor(lemoAddress string) (string, error) {
 }
 
 // GetEquity returns asset equity
-func (a *PublicAccountAPI) GetEquity(lemoAddress string, assetId common.Hash) (*types.AssetEquity, error) {
-	acc, err := a.GetAccount(lemoAddress)
+func (a *PublicAccountAPI) GetEquity(lemoAddress string, assetId common.Hash, block *BlockHeightOrHash) (*types.AssetEquity, error) {
+	acc, err := a.GetAccount(lemoAddress, block)
 	if err != nil {
 		return nil, err
 	}
@@ -350,6 +440,74 @@ func (c *PublicChainAPI) NodeVersion() string {
 	return params.Version
 }
 
+// PublicProofAPI API for the merkle proofs of stable data. The proofs could be verified by package chain/proof
+type PublicProofAPI struct {
+	chain *chain.BlockChain
+	db    protocol.ChainDB
+}
+
+// NewPublicProofAPI
+func NewPublicProofAPI(chain *chain.BlockChain, db protocol.ChainDB) *PublicProofAPI {
+	return &PublicProofAPI{chain, db}
+}
+
+// GetAccountProof returns the account data at the stable block in height, with the proof by the block's VersionRoot and the change logs. The account in proof is nil if it is not exist
+func (p *PublicProofAPI) GetAccountProof(lemoAddress string, height uint32) (*proof.AccountProof, error) {
+	address, err := common.StringToAddress(lemoAddress)
+	if err != nil {
+		return nil, err
+	}
+	if height > p.chain.StableBlock().Height() {
+		return nil, store.ErrBlockNotExist
+	}
+	block := p.chain.GetBlockByHeight(height)
+	if block == nil {
+		return nil, store.ErrBlockNotExist
+	}
+
+	acctDb, err := p.db.GetHistoryActDatabase(block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	data, err := acctDb.Get(address)
+	if err == store.ErrAccountNotExist {
+		// prove the account is absent
+		data = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return proof.NewAccountProof(p.db, block.Header, address, data)
+}
+
+// GetTxProof returns the stable transaction with the proof by its block's TxRoot
+func (p *PublicProofAPI) GetTxProof(txHash string) (*proof.TxProof, error) {
+	if len(common.FromHex(txHash)) != common.HashLength {
+		log.Warnf("Hash is incorrect, Hash: %s", txHash)
+		return nil, ErrInputParams
+	}
+	detail, err := p.db.GetTxByHash(common.HexToHash(txHash))
+	if err == store.ErrTxNotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	block, err := p.db.GetBlockByHash(detail.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transaction in box is not in TxRoot, so we prove the box transaction
+	var boxTx *types.Transaction
+	if (detail.PHash != common.Hash{}) {
+		boxDetail, err := p.db.GetTxByHash(detail.PHash)
+		if err != nil {
+			return nil, err
+		}
+		boxTx = boxDetail.Tx
+	}
+	return proof.NewTxProof(block, detail.Tx, boxTx)
+}
+
 // PrivateChainAPI
 type PrivateChainAPI struct {
 	chain *chain.BlockChain
@@ -449,9 +607,14 @@ func (n *PrivateNetAPI) Disconnect(node string) (bool, error) {
 	return n.node.server.Disconnect(node), nil
 }
 
-// Connections
+// Connections return the connections with the reputation scores of remote peers
 func (n *PrivateNetAPI) Connections() []p2p.PeerConnInfo {
-	return n.node.server.Connections()
+	connections := n.node.server.Connections()
+	scores := n.node.pm.PeerScores()
+	for i := range connections {
+		connections[i].Score = scores[connections[i].NodeID]
+	}
+	return connections
 }
 
 // BroadcastConfirm
@@ -468,7 +631,7 @@ func (n *PrivateNetAPI) BroadcastConfirm(hash string) (bool, error) {
 	}
 
 	// find my confirm in block
-	sigBytes, err := consensus.SignBlock(block.Hash())
+	sigBytes, err := consensus.SignBlock(block.Hash(), deputynode.GetSelfNodeKey())
 	if err != nil {
 		return false, fmt.Errorf("the miner can't confirm block")
 	}
@@ -538,6 +701,11 @@ func (n *PublicNetAPI) NodeID() string {
 	return common.ToHex(deputynode.GetSelfNodeID())
 }
 
+// SyncStatus return the progress of block synchronization
+func (n *PublicNetAPI) SyncStatus() *network.SyncStatus {
+	return n.node.pm.SyncStatus()
+}
+
 // TXAPI
 type PublicTxAPI struct {
 	// txpool *chain.TxPool
@@ -555,8 +723,11 @@ func (t *PublicTxAPI) SendTx(tx *types.Transaction) (common.Hash, error) {
 		log.Errorf("VerifyTxBody error: %s", err)
 		return common.Hash{}, err
 	}
-	// 判断tx是否在当前分支已经存在了
 	currentBlock := t.node.chain.CurrentBlock()
+	if err := tx.VerifyVersion(currentBlock.Height() + 1); err != nil {
+		return common.Hash{}, err
+	}
+	// 判断tx是否在当前分支已经存在了
 	guard := t.node.chain.TxGuard()
 	isExist := guard.ExistTx(currentBlock.Hash(), tx)
 	if !isExist {
@@ -600,6 +771,172 @@ func (t *PublicTxAPI) doCallTransaction(to *common.Address, accM *account.ReadOn
 	return ret, err
 }
 
+//go:generate gencodec -type CallMsg --field-override callMsgMarshaling -out gen_call_msg_json.go
+
+// CallMsg contains the parameters of a transaction which is executed without being sent to chain
+type CallMsg struct {
+	Type     uint16          `json:"type"`
+	From     common.Address  `json:"from" gencodec:"required"`
+	To       *common.Address `json:"to"` // nil means contract creation
+	Amount   *big.Int        `json:"amount"`
+	GasLimit uint64          `json:"gasLimit"` // the block's gas limit is used if it is 0
+	GasPrice *big.Int        `json:"gasPrice"`
+	Data     []byte          `json:"data"`
+	Message  string          `json:"message"`
+}
+
+type callMsgMarshaling struct {
+	Type     hexutil.Uint16
+	Amount   *hexutil.Big10
+	GasLimit hexutil.Uint64
+	GasPrice *hexutil.Big10
+	Data     hexutil.Bytes
+}
+
+// toTransaction creates an unsigned transaction from the message
+func (msg *CallMsg) toTransaction(chainID uint16, gasLimit uint64) *types.Transaction {
+	amount := msg.Amount
+	if amount == nil {
+		amount = new(big.Int)
+	}
+	gasPrice := msg.GasPrice
+	if gasPrice == nil {
+		gasPrice = new(big.Int)
+	}
+	expiration := uint64(time.Now().Unix()) + uint64(params.MaxTxLifeTime)
+	if msg.To == nil {
+		return types.NoReceiverTransaction(msg.From, amount, gasLimit, gasPrice, msg.Data, msg.Type, chainID, expiration, "", msg.Message)
+	}
+	return types.NewTransaction(msg.From, *msg.To, amount, gasLimit, gasPrice, msg.Data, msg.Type, chainID, expiration, "", msg.Message)
+}
+
+// callMsg executes the message at the state of block, and returns the output of vm and the gas used
+func (t *PublicTxAPI) callMsg(msg *CallMsg, block *types.Block, gasLimit uint64) (ret []byte, gasUsed uint64, err error) {
+	accM := account.NewReadOnlyManager(t.node.Db(), false)
+	if err := accM.ResetHistory(block.Hash()); err != nil {
+		return nil, 0, err
+	}
+	defer recoverHistoryNotExist(&err)
+	tx := msg.toTransaction(t.node.ChainID(), gasLimit)
+	return t.node.chain.TxProcessor().CallTx(accM, block.Header, tx, callTimeout)
+}
+
+// recoverHistoryNotExist sets err instead of panic if the state of some account at an old block is not available
+func recoverHistoryNotExist(err *error) {
+	if r := recover(); r != nil {
+		if r != store.ErrActHistoryNotExist {
+			panic(r)
+		}
+		*err = store.ErrActHistoryNotExist
+	}
+}
+
+// Call executes the message at the state of the block in height, and returns the output of vm. It is not sent to chain and the changes are not saved. The current block is used if the height is not set
+func (t *PublicTxAPI) Call(msg CallMsg, height *uint32) (string, error) {
+	block := t.node.chain.CurrentBlock()
+	if height != nil {
+		if block = t.node.chain.GetBlockByHeight(*height); block == nil {
+			return "", store.ErrBlockNotExist
+		}
+	}
+	gasLimit := msg.GasLimit
+	if gasLimit == 0 {
+		gasLimit = block.GasLimit()
+	}
+	ret, _, err := t.callMsg(&msg, block, gasLimit)
+	return common.ToHex(ret), err
+}
+
+// EstimateGas returns the least gas limit with which the message could be executed successfully at current block
+func (t *PublicTxAPI) EstimateGas(msg CallMsg) (uint64, error) {
+	block := t.node.chain.CurrentBlock()
+	intrinsicGas, err := transaction.IntrinsicGas(msg.Type, msg.Data, msg.Message)
+	if err != nil {
+		return 0, err
+	}
+	// only the intrinsic gas is used if the transaction is not executed in vm
+	if msg.Type != params.OrdinaryTx && msg.Type != params.CreateContractTx && msg.Type != params.TransferAssetTx {
+		return intrinsicGas, nil
+	}
+
+	hi := msg.GasLimit
+	if hi < intrinsicGas {
+		hi = block.GasLimit()
+	}
+	executable := func(gasLimit uint64) error {
+		_, _, err := t.callMsg(&msg, block, gasLimit)
+		return err
+	}
+	// the message could not be executed even if all gas is given
+	if err := executable(hi); err != nil {
+		return 0, err
+	}
+	// binary search the least gas limit
+	lo := intrinsicGas - 1
+	for lo+1 < hi {
+		mid := (lo + hi) / 2
+		if executable(mid) == nil {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, nil
+}
+
+// GetTxByHash pull the specific stable transaction by its hash
+func (t *PublicTxAPI) GetTxByHash(txHash string) (*store.VTransactionDetail, error) {
+	if len(common.FromHex(txHash)) != common.HashLength {
+		log.Warnf("Hash is incorrect, Hash: %s", txHash)
+		return nil, ErrInputParams
+	}
+	tx, err := t.node.db.GetTxByHash(common.HexToHash(txHash))
+	if err == store.ErrTxNotExist {
+		return nil, nil
+	}
+	return tx, err
+}
+
+// GetReceipt get the execution receipt of stable transaction. It returns the receipt of box transaction if the transaction is in a box
+func (t *PublicTxAPI) GetReceipt(txHash string) (*types.Receipt, error) {
+	if len(common.FromHex(txHash)) != common.HashLength {
+		log.Warnf("Hash is incorrect, Hash: %s", txHash)
+		return nil, ErrInputParams
+	}
+	receipt, err := t.node.db.GetReceipt(common.HexToHash(txHash))
+	if err == store.ErrTxNotExist || err == store.ErrReceiptNotExist {
+		return nil, nil
+	}
+	return receipt, err
+}
+
+//go:generate gencodec -type TxListResult --field-override txListResultMarshaling -out gen_tx_list_result_json.go
+type TxListResult struct {
+	TxList []*store.VTransaction `json:"txList" gencodec:"required"`
+	Total  uint32                `json:"total" gencodec:"required"`
+}
+
+type txListResultMarshaling struct {
+	Total hexutil.Uint32
+}
+
+// GetTxListByAddress pull the stable transactions which are sent or received by the address. The index is the position of the first tx in the address's tx history, and the size is the max count of txs in this page
+func (t *PublicTxAPI) GetTxListByAddress(lemoAddress string, index int, size int) (*TxListResult, error) {
+	address, err := common.StringToAddress(lemoAddress)
+	if err != nil {
+		log.Warnf("lemoAddress is incorrect. lemoAddress: %s", lemoAddress)
+		return nil, err
+	}
+	txList, total, err := t.node.db.GetTxByAddr(address, index, size)
+	if err != nil {
+		return nil, err
+	}
+	return &TxListResult{
+		TxList: txList,
+		Total:  total,
+	}, nil
+}
+
 type PrivateTxAPI struct {
 	node *Node
 }
@@ -612,3 +949,163 @@ func NewPrivateTxAPI(node *Node) *PrivateTxAPI {
 func (t *PrivateTxAPI) GetPendingTx(size int) []*types.Transaction {
 	return t.node.txPool.GetTxs(uint32(time.Now().Unix()), size)
 }
+
+// callTracerName is the name of tracer which records the tree of calls
+const callTracerName = "callTracer"
+
+var ErrUnknownTracer = errors.New("unknown tracer")
+
+// TraceConfig is the options of tracing transactions
+type TraceConfig struct {
+	*vm.LogConfig
+	Tracer string `json:"tracer"` // "callTracer" records the tree of calls. The opcode logs are recorded if it is empty
+}
+
+// StructLogResult is the opcode logs of a transaction execution
+type StructLogResult struct {
+	Gas         hexutil.Uint64 `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue hexutil.Bytes  `json:"returnValue"`
+	StructLogs  []vm.StructLog `json:"structLogs"`
+}
+
+// TxTraceResult is the trace result of a transaction in block
+type TxTraceResult struct {
+	TxHash common.Hash `json:"txHash"`
+	Result interface{} `json:"result"`
+}
+
+// PrivateDebugAPI API for re-executing the transactions in chain with vm tracers
+type PrivateDebugAPI struct {
+	node *Node
+}
+
+// NewPrivateDebugAPI
+func NewPrivateDebugAPI(node *Node) *PrivateDebugAPI {
+	return &PrivateDebugAPI{node}
+}
+
+// TraceTransaction re-executes the transaction on the state it was packaged in, and returns the opcode logs or the tree of calls. It traces the box transaction if the transaction is in a box
+func (d *PrivateDebugAPI) TraceTransaction(txHash string, config *TraceConfig) (interface{}, error) {
+	if len(common.FromHex(txHash)) != common.HashLength {
+		log.Warnf("Hash is incorrect, Hash: %s", txHash)
+		return nil, ErrInputParams
+	}
+	detail, err := d.node.db.GetTxByHash(common.HexToHash(txHash))
+	if err != nil {
+		return nil, err
+	}
+	hash := detail.Tx.Hash()
+	if (detail.PHash != common.Hash{}) {
+		hash = detail.PHash
+	}
+	block := d.node.chain.GetBlockByHash(detail.BlockHash)
+	if block == nil {
+		return nil, store.ErrBlockNotExist
+	}
+	index := -1
+	for i, tx := range block.Txs {
+		if tx.Hash() == hash {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, store.ErrTxNotExist
+	}
+
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := d.replay(block, index+1, func(i int) vm.Tracer {
+		if i == index {
+			return tracer
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return traceResult(tracer, receipts[index]), nil
+}
+
+// TraceBlock re-executes all transactions in the block on its parent state, and returns the opcode logs or the tree of calls of each transaction
+func (d *PrivateDebugAPI) TraceBlock(height uint32, config *TraceConfig) ([]*TxTraceResult, error) {
+	if _, err := newTracer(config); err != nil {
+		return nil, err
+	}
+	block := d.node.chain.GetBlockByHeight(height)
+	if block == nil {
+		return nil, store.ErrBlockNotExist
+	}
+	tracers := make([]vm.Tracer, len(block.Txs))
+	for i := range tracers {
+		tracers[i], _ = newTracer(config)
+	}
+	receipts, err := d.replay(block, len(block.Txs), func(i int) vm.Tracer {
+		return tracers[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*TxTraceResult, len(receipts))
+	for i, receipt := range receipts {
+		results[i] = &TxTraceResult{
+			TxHash: block.Txs[i].Hash(),
+			Result: traceResult(tracers[i], receipt),
+		}
+	}
+	return results, nil
+}
+
+// replay re-executes the first count transactions in block on the state of its parent with a new processor, so the chain's accounts are not changed. It returns error if the state is not available
+func (d *PrivateDebugAPI) replay(block *types.Block, count int, newTracer func(index int) vm.Tracer) (receipts types.Receipts, err error) {
+	if count == 0 {
+		return types.Receipts{}, nil
+	}
+	acctDb, err := d.node.db.GetHistoryActDatabase(block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	defer recoverHistoryNotExist(&err)
+	bc := d.node.chain
+	am := account.NewManagerWithActDatabase(block.ParentHash(), d.node.db, acctDb)
+	p := transaction.NewTxProcessor(bc.Founder(), bc.ChainID(), bc, am, d.node.db, bc.DeputyManager())
+	return p.TraceTxs(block.Header, block.Txs[:count], newTracer)
+}
+
+// newTracer creates the vm tracer by config
+func newTracer(config *TraceConfig) (vm.Tracer, error) {
+	if config == nil {
+		return vm.NewStructLogger(nil), nil
+	}
+	switch config.Tracer {
+	case "":
+		return vm.NewStructLogger(config.LogConfig), nil
+	case callTracerName:
+		return vm.NewCallTracer(), nil
+	default:
+		return nil, ErrUnknownTracer
+	}
+}
+
+// traceResult returns the records of tracer
+func traceResult(tracer vm.Tracer, receipt *types.Receipt) interface{} {
+	switch t := tracer.(type) {
+	case *vm.CallTracer:
+		return t.Result()
+	case *vm.StructLogger:
+		logs := t.StructLogs()
+		if logs == nil {
+			logs = []vm.StructLog{}
+		}
+		return &StructLogResult{
+			Gas:         hexutil.Uint64(receipt.GasUsed),
+			Failed:      receipt.Status == types.ReceiptStatusFailed,
+			ReturnValue: t.Output(),
+			StructLogs:  logs,
+		}
+	}
+	return nil
+}
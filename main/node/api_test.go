@@ -3,13 +3,23 @@ package node
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/keystore"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/proof"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/testchain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/transaction"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/vm"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
 	"time"
 )
@@ -20,32 +30,153 @@ func TestAccountAPI_api(t *testing.T) {
 	defer testchain.CloseTestChain(bc, db)
 
 	am := bc.AccountManager()
-	acc := NewPublicAccountAPI(am)
-	priAcc := NewPrivateAccountAPI(am)
-	// Create key pair
-	addressKeyPair, err := priAcc.NewKeyPair()
+	acc := NewPublicAccountAPI(am, bc, db)
+	ksDir, err := ioutil.TempDir("", "keystore")
 	assert.NoError(t, err)
-	assert.NotNil(t, addressKeyPair.Private)
+	defer os.RemoveAll(ksDir)
+	priAcc := NewPrivateAccountAPI(am, keystore.NewKeyStore(ksDir, keystore.LightScryptN, keystore.LightScryptP))
+	// Create account
+	newAddr, err := priAcc.NewAccount("123456")
+	assert.NoError(t, err)
+	accounts, err := priAcc.ListAccounts()
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{newAddr}, accounts)
+
+	// unlock and sign tx
+	testTx := types.NewTransaction(newAddr, common.HexToAddress("0x1"), common.Big1, 100, big.NewInt(1000000000), nil, 0, 100, uint64(time.Now().Unix()+60*30), "", "")
+	_, err = priAcc.SignTx(testTx)
+	assert.Equal(t, keystore.ErrLocked, err)
+	_, err = priAcc.Unlock(newAddr.String(), "654321", 0)
+	assert.Equal(t, keystore.ErrDecrypt, err)
+	_, err = priAcc.Unlock(newAddr.String(), "123456", maxUnlockDuration+1)
+	assert.Equal(t, ErrUnlockDuration, err)
+	ok, err := priAcc.Unlock(newAddr.String(), "123456", 60)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	signedTx, err := priAcc.SignTx(testTx)
+	assert.NoError(t, err)
+	signers, err := types.MakeSigner().GetSigners(signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{newAddr}, signers)
+	ok, err = priAcc.Lock(newAddr.String())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = priAcc.SignTx(testTx)
+	assert.Equal(t, keystore.ErrLocked, err)
 
 	// getBalance api
-	_, err = acc.GetBalance("0x015780F8456F9c1532645087a19DcF9a7e0c7F97")
+	_, err = acc.GetBalance("0x015780F8456F9c1532645087a19DcF9a7e0c7F97", nil)
 	assert.Equal(t, common.ErrInvalidAddress, err)
 
 	address, err := common.StringToAddress("Lemo83GN72GYH2NZ8BA729Z9TCT7KQ5FC3CR6DJG")
 	assert.NoError(t, err)
 	b02 := acc.manager.GetCanonicalAccount(address).GetBalance().String()
-	bb02, err := acc.GetBalance("Lemo83GN72GYH2NZ8BA729Z9TCT7KQ5FC3CR6DJG")
+	bb02, err := acc.GetBalance("Lemo83GN72GYH2NZ8BA729Z9TCT7KQ5FC3CR6DJG", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, b02, bb02)
 
 	b03 := acc.manager.GetCanonicalAccount(testchain.FounderAddr).GetBalance().String()
-	bb03, err := acc.GetBalance(testchain.FounderAddr.String())
+	bb03, err := acc.GetBalance(testchain.FounderAddr.String(), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, b03, bb03)
 
 	// get account api
-	_, err = acc.GetAccount("0x015780F8456F9c1532645087a19DcF9a7e0c7F97")
+	_, err = acc.GetAccount("0x015780F8456F9c1532645087a19DcF9a7e0c7F97", nil)
+	assert.Equal(t, common.ErrInvalidAddress, err)
+}
+
+func TestPublicAccountAPI_history(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	acc := NewPublicAccountAPI(bc.AccountManager(), bc, db)
+
+	// change accounts in block 2 and make it stable
+	founder := common.HexToAddress("0x10000")
+	newAddr := common.HexToAddress("0x12345")
+	block1Hash := testchain.LoadDefaultBlock(1).Hash()
+	block2Hash := testchain.LoadDefaultBlock(2).Hash()
+	acctDb, err := db.GetActDatabase(block2Hash)
+	assert.NoError(t, err)
+	founderData, err := acctDb.Get(founder)
+	assert.NoError(t, err)
+	oldBalance := founderData.Balance.String()
+	founderData.Balance = new(big.Int).Sub(founderData.Balance, big.NewInt(100))
+	acctDb.Put(founderData, 2)
+	acctDb.Put(&types.AccountData{Address: newAddr, Balance: big.NewInt(100), NewestRecords: make(map[types.ChangeLogType]types.VersionRecord)}, 2)
+	_, err = db.SetStableBlock(block2Hash)
+	assert.NoError(t, err)
+
+	height0, height1, height2 := uint32(0), uint32(1), uint32(2)
+	balance, err := acc.GetBalance(founder.String(), &BlockHeightOrHash{Height: &height0})
+	assert.NoError(t, err)
+	assert.Equal(t, oldBalance, balance)
+	balance, err = acc.GetBalance(founder.String(), &BlockHeightOrHash{Height: &height1})
+	assert.NoError(t, err)
+	assert.Equal(t, oldBalance, balance)
+	balance, err = acc.GetBalance(founder.String(), &BlockHeightOrHash{Hash: &block1Hash})
+	assert.NoError(t, err)
+	assert.Equal(t, oldBalance, balance)
+	balance, err = acc.GetBalance(founder.String(), &BlockHeightOrHash{Height: &height2})
+	assert.NoError(t, err)
+	assert.Equal(t, founderData.Balance.String(), balance)
+	// the newest stable state
+	balance, err = acc.GetBalance(founder.String(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, founderData.Balance.String(), balance)
+	// the account is not exist before block 2
+	balance, err = acc.GetBalance(newAddr.String(), &BlockHeightOrHash{Height: &height1})
+	assert.NoError(t, err)
+	assert.Equal(t, "0", balance)
+	balance, err = acc.GetBalance(newAddr.String(), &BlockHeightOrHash{Hash: &block2Hash})
+	assert.NoError(t, err)
+	assert.Equal(t, "100", balance)
+	// block not exist
+	height := uint32(100)
+	_, err = acc.GetBalance(founder.String(), &BlockHeightOrHash{Height: &height})
+	assert.Equal(t, store.ErrBlockNotExist, err)
+
+	// json
+	var selector BlockHeightOrHash
+	assert.NoError(t, json.Unmarshal([]byte("1"), &selector))
+	assert.Equal(t, height1, *selector.Height)
+	assert.Nil(t, selector.Hash)
+	assert.NoError(t, json.Unmarshal([]byte(`"`+block1Hash.Hex()+`"`), &selector))
+	assert.Equal(t, block1Hash, *selector.Hash)
+	assert.Nil(t, selector.Height)
+	assert.Error(t, json.Unmarshal([]byte(`"0x1"`), &selector))
+}
+
+func TestPublicProofAPI(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	api := NewPublicProofAPI(bc, db)
+
+	founder := common.HexToAddress("0x10000")
+	accountProof, err := api.GetAccountProof(founder.String(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, founder, accountProof.Account.Address)
+	getHeader := func(height uint32) *types.Header {
+		return testchain.LoadDefaultBlock(int(height)).Header
+	}
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+	assert.Equal(t, testchain.LoadDefaultBlock(1).Hash(), accountProof.BlockHash)
+	// the account is not exist
+	accountProof, err = api.GetAccountProof(common.HexToAddress("0x12345").String(), 1)
+	assert.NoError(t, err)
+	assert.Nil(t, accountProof.Account)
+	assert.NoError(t, proof.VerifyAccountProof(accountProof, getHeader))
+	// unstable block
+	_, err = api.GetAccountProof(founder.String(), 2)
+	assert.Equal(t, store.ErrBlockNotExist, err)
+	_, err = api.GetAccountProof("0x1", 1)
 	assert.Equal(t, common.ErrInvalidAddress, err)
+
+	// the transaction is not exist
+	txProof, err := api.GetTxProof(common.HexToHash("0x1").Hex())
+	assert.NoError(t, err)
+	assert.Nil(t, txProof)
+	_, err = api.GetTxProof("0x1")
+	assert.Equal(t, ErrInputParams, err)
 }
 
 // TestChainAPI_api chain api test
@@ -123,6 +254,7 @@ func TestTxAPI_api(t *testing.T) {
 	tx := testchain.SignTx(testTx, testchain.FounderPrivate)
 	node := &Node{
 		chainID: 100,
+		db:      db,
 		chain:   bc,
 		txPool:  txpool.NewTxPool(),
 	}
@@ -131,6 +263,133 @@ func TestTxAPI_api(t *testing.T) {
 	sendTxHash, err := txAPI.SendTx(tx)
 	assert.NoError(t, err)
 	assert.Equal(t, tx.Hash(), sendTxHash)
+	// rejected by pool
+	_, err = txAPI.SendTx(tx)
+	assert.Equal(t, txpool.ErrTxIsExist, err)
+	node.txPool = txpool.NewTxPoolWithConfig(txpool.Config{MinGasPrice: big.NewInt(2000000000)})
+	_, err = txAPI.SendTx(tx)
+	assert.Equal(t, txpool.ErrTxGasPriceTooLow, err)
+
+	// the tx is not packaged in stable block
+	_, err = txAPI.GetTxByHash("0x1234")
+	assert.Equal(t, ErrInputParams, err)
+	result, err := txAPI.GetTxByHash(sendTxHash.Hex())
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+	_, err = txAPI.GetReceipt("0x1234")
+	assert.Equal(t, ErrInputParams, err)
+	receipt, err := txAPI.GetReceipt(sendTxHash.Hex())
+	assert.NoError(t, err)
+	assert.Nil(t, receipt)
+	txList, err := txAPI.GetTxListByAddress(from.String(), 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), txList.Total)
+	_, err = txAPI.GetTxListByAddress("0x015780F8456F9c1532645087a19DcF9a7e0c7F97", 0, 10)
+	assert.Equal(t, common.ErrInvalidAddress, err)
+}
+
+func TestTxAPI_CallAndEstimateGas(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	node := &Node{
+		chainID: 100,
+		db:      db,
+		chain:   bc,
+	}
+	txAPI := NewPublicTxAPI(node)
+	to := common.HexToAddress("0x1")
+	// the founder in genesis has all balance
+	from := common.HexToAddress("0x10000")
+	// the init code returns 32 bytes code: 0x00...01
+	initCode := common.FromHex("0x600160005260206000f3")
+
+	// call contract creation
+	ret, err := txAPI.Call(CallMsg{Type: params.CreateContractTx, From: from, Data: initCode}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, common.ToHex(common.LeftPadBytes([]byte{1}, 32)), ret)
+	// at stable height
+	height := uint32(1)
+	ret, err = txAPI.Call(CallMsg{Type: params.CreateContractTx, From: from, Data: initCode}, &height)
+	assert.NoError(t, err)
+	assert.Equal(t, common.ToHex(common.LeftPadBytes([]byte{1}, 32)), ret)
+	height = 100
+	_, err = txAPI.Call(CallMsg{From: from, To: &to}, &height)
+	assert.Equal(t, store.ErrBlockNotExist, err)
+	// not enough balance
+	_, err = txAPI.Call(CallMsg{From: common.HexToAddress("0x123"), To: &to, Amount: common.Big1}, nil)
+	assert.Equal(t, vm.ErrInsufficientBalance, err)
+	// not enough gas
+	_, err = txAPI.Call(CallMsg{From: from, To: &to, GasLimit: params.OrdinaryTxGas - 1}, nil)
+	assert.Equal(t, vm.ErrOutOfGas, err)
+
+	// transfer
+	transferMsg := CallMsg{From: from, To: &to, Amount: common.Big1}
+	gas, err := txAPI.EstimateGas(transferMsg)
+	assert.NoError(t, err)
+	assert.True(t, gas >= params.OrdinaryTxGas)
+	transferMsg.GasLimit = gas
+	_, err = txAPI.Call(transferMsg, nil)
+	assert.NoError(t, err)
+	transferMsg.GasLimit = gas - 1
+	_, err = txAPI.Call(transferMsg, nil)
+	assert.Equal(t, vm.ErrOutOfGas, err)
+	_, err = txAPI.EstimateGas(CallMsg{From: common.HexToAddress("0x123"), To: &to, Amount: common.Big1})
+	assert.Equal(t, vm.ErrInsufficientBalance, err)
+	// non-contract transaction
+	gas, err = txAPI.EstimateGas(CallMsg{Type: params.VoteTx, From: from, To: &to})
+	assert.NoError(t, err)
+	assert.Equal(t, params.VoteTxGas, gas)
+	// contract creation
+	msg := CallMsg{Type: params.CreateContractTx, From: from, Data: initCode}
+	gas, err = txAPI.EstimateGas(msg)
+	assert.NoError(t, err)
+	intrinsicGas, _ := transaction.IntrinsicGas(params.CreateContractTx, initCode, "")
+	assert.True(t, gas > intrinsicGas)
+	msg.GasLimit = gas
+	_, err = txAPI.Call(msg, nil)
+	assert.NoError(t, err)
+	msg.GasLimit = gas - 1
+	_, err = txAPI.Call(msg, nil)
+	assert.Error(t, err)
+}
+
+func TestTxAPI_Call_history(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	node := &Node{
+		chainID: 100,
+		db:      db,
+		chain:   bc,
+	}
+	txAPI := NewPublicTxAPI(node)
+	to := common.HexToAddress("0x1")
+	from := common.HexToAddress("0x10000")
+
+	// spend the balance of sender in block 2 and make it stable
+	block2Hash := testchain.LoadDefaultBlock(2).Hash()
+	acctDb, err := db.GetActDatabase(block2Hash)
+	assert.NoError(t, err)
+	fromData, err := acctDb.Get(from)
+	assert.NoError(t, err)
+	amount := new(big.Int).Set(fromData.Balance)
+	fromData.Balance = new(big.Int)
+	acctDb.Put(fromData, 2)
+	_, err = db.SetStableBlock(block2Hash)
+	assert.NoError(t, err)
+
+	msg := CallMsg{From: from, To: &to, Amount: amount}
+	height := uint32(1)
+	_, err = txAPI.Call(msg, &height)
+	assert.NoError(t, err)
+	height = 2
+	_, err = txAPI.Call(msg, &height)
+	assert.Equal(t, vm.ErrInsufficientBalance, err)
+
+	// the states at pruned height are not available
+	assert.NoError(t, leveldb.SetPruneHeight(db.(*store.ChainDatabase).LevelDB, 2))
+	height = 1
+	_, err = txAPI.Call(msg, &height)
+	assert.Equal(t, store.ErrActHistoryNotExist, err)
 }
 
 // 序列化注册候选节点所用data
@@ -180,3 +439,86 @@ func Test_CreatRegisterTxData(t *testing.T) {
 	marPro5, _ := json.Marshal(pro5)
 	fmt.Println("txData5:", common.ToHex(marPro5))
 }
+
+func TestPrivateDebugAPI(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	node := &Node{
+		chainID: 100,
+		db:      db,
+		chain:   bc,
+	}
+	debugAPI := NewPrivateDebugAPI(node)
+
+	// trace block
+	results, err := debugAPI.TraceBlock(1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(testchain.LoadDefaultBlock(1).Txs), len(results))
+	_, err = debugAPI.TraceBlock(100, nil)
+	assert.Equal(t, store.ErrBlockNotExist, err)
+	_, err = debugAPI.TraceBlock(1, &TraceConfig{Tracer: "notExistTracer"})
+	assert.Equal(t, ErrUnknownTracer, err)
+
+	// trace transaction
+	_, err = debugAPI.TraceTransaction("0x1", nil)
+	assert.Equal(t, ErrInputParams, err)
+	_, err = debugAPI.TraceTransaction(common.HexToHash("0x1").Hex(), nil)
+	assert.Equal(t, store.ErrTxNotExist, err)
+}
+
+func TestPrivateDebugAPI_replay(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+	node := &Node{
+		chainID: 100,
+		db:      db,
+		chain:   bc,
+	}
+	debugAPI := NewPrivateDebugAPI(node)
+
+	// the founder gets balance in block 2 which is stable
+	block2Hash := testchain.LoadDefaultBlock(2).Hash()
+	acctDb, err := db.GetActDatabase(block2Hash)
+	assert.NoError(t, err)
+	founderData, err := acctDb.Get(testchain.FounderAddr)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, founderData.Balance.Sign())
+	founderData.Balance = common.Lemo2Mo("100")
+	acctDb.Put(founderData, 2)
+	_, err = db.SetStableBlock(block2Hash)
+	assert.NoError(t, err)
+
+	tx := testchain.MakeTransferTx(testchain.FounderPrivate, common.HexToAddress("0x1"), big.NewInt(100))
+	noTracer := func(int) vm.Tracer { return nil }
+	// replay on the state of stable block
+	block3 := &types.Block{Header: testchain.LoadDefaultBlock(3).Header, Txs: types.Transactions{tx}}
+	tracer := vm.NewCallTracer()
+	receipts, err := debugAPI.replay(block3, 1, func(int) vm.Tracer { return tracer })
+	assert.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipts[0].Status)
+	assert.Equal(t, tx.Amount(), (*big.Int)(tracer.Result().Value))
+	// replay on the history states before the balance is got
+	block1 := &types.Block{Header: testchain.LoadDefaultBlock(1).Header, Txs: types.Transactions{tx}}
+	_, err = debugAPI.replay(block1, 1, noTracer)
+	assert.Equal(t, transaction.ErrInsufficientBalanceForGas, err)
+	block2 := &types.Block{Header: testchain.LoadDefaultBlock(2).Header, Txs: types.Transactions{tx}}
+	_, err = debugAPI.replay(block2, 1, noTracer)
+	assert.Equal(t, transaction.ErrInsufficientBalanceForGas, err)
+
+	// the states at pruned height are not available
+	assert.NoError(t, leveldb.SetPruneHeight(db.(*store.ChainDatabase).LevelDB, 2))
+	_, err = debugAPI.replay(block2, 1, noTracer)
+	assert.Equal(t, store.ErrActHistoryNotExist, err)
+}
+
+func Test_traceResult(t *testing.T) {
+	receipt := &types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 21000}
+	result := traceResult(vm.NewStructLogger(nil), receipt).(*StructLogResult)
+	assert.Equal(t, hexutil.Uint64(21000), result.Gas)
+	assert.True(t, result.Failed)
+	assert.Equal(t, []vm.StructLog{}, result.StructLogs)
+
+	tracer, err := newTracer(&TraceConfig{Tracer: callTracerName})
+	assert.NoError(t, err)
+	assert.Nil(t, traceResult(tracer, receipt).(*vm.CallFrame))
+}
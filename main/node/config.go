@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/LemoFoundationLtd/lemochain-core/chain"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/miner"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
 	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
@@ -23,6 +24,7 @@ const (
 	datadirPrivateKey   = "nodekey"
 	datadirStaticNodes  = "static-nodes.json"
 	datadirTrustedNodes = "trusted-nodes.json"
+	datadirKeyStore     = "keystore"
 )
 
 var DefaultHTTPVirtualHosts = []string{"localhost"}
@@ -35,6 +37,7 @@ type Config struct {
 	P2P     p2p.Config
 	Chain   chain.Config
 	Miner   miner.MineConfig
+	TxPool  txpool.Config
 
 	IPCPath          string   `toml:",omitempty"`
 	HTTPPort         int      `toml:",omitempty"`
@@ -101,6 +104,11 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// KeyStoreDir returns the directory of encrypted account key files
+func (c *Config) KeyStoreDir() string {
+	return filepath.Join(c.DataDir, datadirKeyStore)
+}
+
 func parseNodes(path string) []string {
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,353 @@
+package node
+
+import (
+	"context"
+	"github.com/LemoFoundationLtd/lemochain-core/chain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
+	"github.com/LemoFoundationLtd/lemochain-core/network/rpc"
+	"github.com/LemoFoundationLtd/lemochain-core/store/protocol"
+	"sync"
+	"time"
+)
+
+const (
+	// the size of channel which receives events from chain
+	chainEventChanSize = 64
+	// the size of channel which buffers events for a rpc subscriber. The events will be dropped if the subscriber is too slow
+	subEventChanSize = 256
+)
+
+type eventType int
+
+const (
+	currentBlockEvent eventType = iota
+	stableBlockEvent
+	pendingTxEvent
+	// the events in new stable blocks
+	stableEventsEvent
+)
+
+type eventSub struct {
+	typ eventType
+	ch  chan interface{}
+}
+
+// EventSystem receives the block and transaction events from chain, then push them to rpc subscribers
+type EventSystem struct {
+	chain *chain.BlockChain
+	db    protocol.ChainDB
+
+	subs    map[rpc.ID]*eventSub
+	subsMux sync.RWMutex
+	// the long-poll event filters
+	filters    map[rpc.ID]*eventFilter
+	filtersMux sync.Mutex
+	// the height of last stable block whose events have been dispatched
+	lastStableHeight uint32
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewEventSystem(chain *chain.BlockChain, db protocol.ChainDB) *EventSystem {
+	return &EventSystem{
+		chain:   chain,
+		db:      db,
+		subs:    make(map[rpc.ID]*eventSub),
+		filters: make(map[rpc.ID]*eventFilter),
+	}
+}
+
+// Start start to receive events from chain
+func (es *EventSystem) Start() {
+	es.quit = make(chan struct{})
+	es.lastStableHeight = es.chain.StableBlock().Height()
+
+	currentCh := make(chan *types.Block, chainEventChanSize)
+	currentSub := es.chain.SubscribeCurrent(currentCh)
+	stableCh := make(chan *types.Block, chainEventChanSize)
+	stableSub := es.chain.SubscribeStable(stableCh)
+	txCh := make(chan *types.Transaction, chainEventChanSize)
+	subscribe.Sub(subscribe.NewTx, txCh)
+
+	es.wg.Add(1)
+	go func() {
+		defer es.wg.Done()
+		defer func() {
+			currentSub.Unsubscribe()
+			stableSub.Unsubscribe()
+			subscribe.UnSub(subscribe.NewTx, txCh)
+		}()
+
+		for {
+			select {
+			case block := <-currentCh:
+				es.dispatch(currentBlockEvent, block)
+			case block := <-stableCh:
+				es.dispatch(stableBlockEvent, block)
+				es.dispatchStableEvents(block)
+			case tx := <-txCh:
+				es.dispatch(pendingTxEvent, tx)
+			case <-es.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stop receiving events and close all subscriptions
+func (es *EventSystem) Stop() {
+	if es.quit == nil {
+		return
+	}
+	close(es.quit)
+	es.wg.Wait()
+	es.quit = nil
+}
+
+// dispatch send event to the subscribers without blocking
+func (es *EventSystem) dispatch(typ eventType, data interface{}) {
+	es.subsMux.RLock()
+	defer es.subsMux.RUnlock()
+
+	for id, sub := range es.subs {
+		if sub.typ != typ {
+			continue
+		}
+		select {
+		case sub.ch <- data:
+		default:
+			log.Warnf("Subscriber is too slow, drop event. id: %s, type: %d", id, typ)
+		}
+	}
+}
+
+// dispatchStableEvents send the events in new stable blocks to the subscribers. The stable block notification may skip some blocks, so we dispatch the events from the last dispatched height
+func (es *EventSystem) dispatchStableEvents(block *types.Block) {
+	from := es.lastStableHeight + 1
+	if block.Height() < from {
+		return
+	}
+	es.lastStableHeight = block.Height()
+	if !es.hasSub(stableEventsEvent) {
+		return
+	}
+
+	for height := from; height <= block.Height(); height++ {
+		b := block
+		if height != block.Height() {
+			var err error
+			if b, err = es.db.GetBlockByHeight(height); err != nil {
+				log.Errorf("Load stable block fail. height: %d, err: %v", height, err)
+				continue
+			}
+		}
+		events, err := blockEvents(es.db, b)
+		if err != nil {
+			log.Errorf("Load events of stable block fail. height: %d, err: %v", height, err)
+			continue
+		}
+		if len(events) > 0 {
+			es.dispatch(stableEventsEvent, events)
+		}
+	}
+}
+
+func (es *EventSystem) hasSub(typ eventType) bool {
+	es.subsMux.RLock()
+	defer es.subsMux.RUnlock()
+	for _, sub := range es.subs {
+		if sub.typ == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (es *EventSystem) addSub(id rpc.ID, sub *eventSub) {
+	es.subsMux.Lock()
+	defer es.subsMux.Unlock()
+	es.subs[id] = sub
+}
+
+func (es *EventSystem) removeSub(id rpc.ID) {
+	es.subsMux.Lock()
+	defer es.subsMux.Unlock()
+	delete(es.subs, id)
+}
+
+// subscribe create a rpc subscription which will be notified when the specific type event comes
+func (es *EventSystem) subscribe(ctx context.Context, typ eventType, convert func(interface{}) interface{}) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	sub := &eventSub{
+		typ: typ,
+		ch:  make(chan interface{}, subEventChanSize),
+	}
+	es.addSub(rpcSub.ID, sub)
+	quit := es.quit
+
+	go func() {
+		defer es.removeSub(rpcSub.ID)
+		for {
+			select {
+			case data := <-sub.ch:
+				if convert != nil {
+					// skip the event if nothing left after conversion
+					if data = convert(data); data == nil {
+						continue
+					}
+				}
+				if err := notifier.Notify(rpcSub.ID, data); err != nil {
+					log.Debugf("Notify subscriber fail. id: %s, err: %v", rpcSub.ID, err)
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// installFilter create a long-poll filter for the events in new stable blocks
+func (es *EventSystem) installFilter(criteria *EventCriteria) rpc.ID {
+	id := rpc.NewID()
+	f := &eventFilter{
+		criteria: criteria,
+		sub: &eventSub{
+			typ: stableEventsEvent,
+			ch:  make(chan interface{}, subEventChanSize),
+		},
+		events:   make([]*types.Event, 0),
+		lastPoll: time.Now(),
+		done:     make(chan struct{}),
+	}
+	es.filtersMux.Lock()
+	es.filters[id] = f
+	es.filtersMux.Unlock()
+	es.addSub(id, f.sub)
+	quit := es.quit
+
+	go func() {
+		ticker := time.NewTicker(filterTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case data := <-f.sub.ch:
+				f.append(data.([]*types.Event))
+			case <-ticker.C:
+				if f.expired() {
+					log.Debugf("Event filter timeout. id: %s", id)
+					es.uninstallFilter(id)
+					return
+				}
+			case <-f.done:
+				return
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return id
+}
+
+// filterChanges returns the events received by filter since last poll
+func (es *EventSystem) filterChanges(id rpc.ID) ([]*types.Event, error) {
+	es.filtersMux.Lock()
+	f, ok := es.filters[id]
+	es.filtersMux.Unlock()
+	if !ok {
+		return nil, ErrFilterNotExist
+	}
+	return f.poll(), nil
+}
+
+// uninstallFilter removes the filter. It returns false if the filter is not exist
+func (es *EventSystem) uninstallFilter(id rpc.ID) bool {
+	es.filtersMux.Lock()
+	f, ok := es.filters[id]
+	delete(es.filters, id)
+	es.filtersMux.Unlock()
+	if !ok {
+		return false
+	}
+	es.removeSub(id)
+	close(f.done)
+	return true
+}
+
+// onlyHeader remove the body of block
+func onlyHeader(data interface{}) interface{} {
+	block := data.(*types.Block)
+	return &types.Block{
+		Header: block.Header,
+	}
+}
+
+// PublicChainEventAPI API for subscribing chain events
+type PublicChainEventAPI struct {
+	events *EventSystem
+}
+
+// NewPublicChainEventAPI
+func NewPublicChainEventAPI(events *EventSystem) *PublicChainEventAPI {
+	return &PublicChainEventAPI{events}
+}
+
+// NewCurrentBlock subscribe the latest block. It may not be confirmed by enough deputy nodes
+func (c *PublicChainEventAPI) NewCurrentBlock(ctx context.Context, withBody bool) (*rpc.Subscription, error) {
+	if withBody {
+		return c.events.subscribe(ctx, currentBlockEvent, nil)
+	}
+	return c.events.subscribe(ctx, currentBlockEvent, onlyHeader)
+}
+
+// NewStableBlock subscribe the new stable block
+func (c *PublicChainEventAPI) NewStableBlock(ctx context.Context, withBody bool) (*rpc.Subscription, error) {
+	if withBody {
+		return c.events.subscribe(ctx, stableBlockEvent, nil)
+	}
+	return c.events.subscribe(ctx, stableBlockEvent, onlyHeader)
+}
+
+// Events subscribe the events in new stable blocks. The events are filtered by addresses and topics. Topics is a list of topic choices on each position, an empty choice matches any topic
+func (c *PublicChainEventAPI) Events(ctx context.Context, addresses []string, topics [][]common.Hash) (*rpc.Subscription, error) {
+	criteria, err := newEventCriteria(addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+	return c.events.subscribe(ctx, stableEventsEvent, func(data interface{}) interface{} {
+		events := criteria.Filter(data.([]*types.Event))
+		if len(events) == 0 {
+			return nil
+		}
+		return events
+	})
+}
+
+// PublicTxEventAPI API for subscribing transaction events
+type PublicTxEventAPI struct {
+	events *EventSystem
+}
+
+// NewPublicTxEventAPI
+func NewPublicTxEventAPI(events *EventSystem) *PublicTxEventAPI {
+	return &PublicTxEventAPI{events}
+}
+
+// PendingTx subscribe the new transactions which are added into tx pool
+func (t *PublicTxEventAPI) PendingTx(ctx context.Context) (*rpc.Subscription, error) {
+	return t.events.subscribe(ctx, pendingTxEvent, nil)
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"context"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/testchain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
+	"github.com/LemoFoundationLtd/lemochain-core/network/rpc"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEventSystem_PendingTx(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+
+	events := NewEventSystem(bc, db)
+	events.Start()
+	defer events.Stop()
+
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("tx", NewPublicTxEventAPI(events)))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	txCh := make(chan *types.Transaction, 1)
+	sub, err := client.Subscribe(context.Background(), "tx", txCh, "pendingTx")
+	assert.NoError(t, err)
+	defer sub.Unsubscribe()
+	// wait for the subscription to be activated
+	time.Sleep(100 * time.Millisecond)
+
+	tx := types.NewTransaction(testchain.FounderAddr, common.HexToAddress("0x1"), common.Big1, 100, big.NewInt(1000000000), nil, params.OrdinaryTx, 100, uint64(time.Now().Unix()+300), "", "")
+	subscribe.Send(subscribe.NewTx, tx)
+	select {
+	case received := <-txCh:
+		assert.Equal(t, tx.Hash(), received.Hash())
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending tx notification timeout")
+	}
+
+	// http connection doesn't support subscription
+	_, err = NewPublicTxEventAPI(events).PendingTx(context.Background())
+	assert.Equal(t, rpc.ErrNotificationsUnsupported, err)
+}
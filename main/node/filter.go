@@ -0,0 +1,255 @@
+package node
+
+import (
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/network/rpc"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/protocol"
+	"sync"
+	"time"
+)
+
+const (
+	// the max count of blocks which could be scanned in one events query
+	maxEventsQueryRange = 10000
+	// the max count of events which are buffered in a filter. The older events will be dropped if the filter is not polled in time
+	maxFilterEvents = 10000
+)
+
+var (
+	// the filter will be uninstalled if it is not polled for a while
+	filterTimeout = 5 * time.Minute
+
+	ErrEventsQueryRange = errors.New("the block range of events query is too large")
+	ErrFilterNotExist   = errors.New("filter does not exist")
+)
+
+// EventCriteria is the condition to filter events. The event address must be one of Addresses. Topics is a list of topic choices on each position, an empty choice matches any topic
+type EventCriteria struct {
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+func newEventCriteria(addresses []string, topics [][]common.Hash) (*EventCriteria, error) {
+	criteria := &EventCriteria{Topics: topics}
+	for _, lemoAddress := range addresses {
+		addr, err := common.StringToAddress(lemoAddress)
+		if err != nil {
+			return nil, err
+		}
+		criteria.Addresses = append(criteria.Addresses, addr)
+	}
+	return criteria, nil
+}
+
+// MatchBloom checks if the block may contain the events which match the criteria
+func (c *EventCriteria) MatchBloom(bloom types.Bloom) bool {
+	if len(c.Addresses) > 0 {
+		included := false
+		for _, addr := range c.Addresses {
+			if bloom.Test(addr.Bytes()) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, choices := range c.Topics {
+		included := len(choices) == 0
+		for _, topic := range choices {
+			if bloom.Test(topic[:]) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	return true
+}
+
+// Match checks if the event matches the criteria
+func (c *EventCriteria) Match(event *types.Event) bool {
+	if len(c.Addresses) > 0 && !containsAddress(c.Addresses, event.Address) {
+		return false
+	}
+	// the event must have enough topics to match the specific positions
+	if len(c.Topics) > len(event.Topics) {
+		return false
+	}
+	for i, choices := range c.Topics {
+		if len(choices) == 0 {
+			continue
+		}
+		matched := false
+		for _, topic := range choices {
+			if topic == event.Topics[i] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the events which match the criteria
+func (c *EventCriteria) Filter(events []*types.Event) []*types.Event {
+	result := make([]*types.Event, 0)
+	for _, event := range events {
+		if c.Match(event) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func containsAddress(addresses []common.Address, addr common.Address) bool {
+	for _, a := range addresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// blockEvents returns all events in the block with the block information filled
+func blockEvents(db protocol.ChainDB, block *types.Block) ([]*types.Event, error) {
+	receipts, err := db.GetReceipts(block.Hash())
+	if err == store.ErrReceiptNotExist {
+		// the block is synchronized before receipts are recorded
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]*types.Event, 0)
+	for _, receipt := range receipts {
+		for _, event := range receipt.Events {
+			// copy the event so that the cached receipts are not modified
+			e := *event
+			e.BlockHash = block.Hash()
+			e.BlockHeight = block.Height()
+			result = append(result, &e)
+		}
+	}
+	return result, nil
+}
+
+// queryStableEvents scans the stable blocks in range [from, to] and returns the events which match the criteria
+func queryStableEvents(db protocol.ChainDB, from, to uint32, criteria *EventCriteria) ([]*types.Event, error) {
+	result := make([]*types.Event, 0)
+	for height := from; height <= to; height++ {
+		block, err := db.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		// skip the blocks which do not contain the events we want
+		bloom, err := db.GetBloom(block.Hash())
+		if err == store.ErrBloomNotExist {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !criteria.MatchBloom(bloom) {
+			continue
+		}
+
+		events, err := blockEvents(db, block)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, criteria.Filter(events)...)
+	}
+	return result, nil
+}
+
+// eventFilter buffers the matched events until they are polled
+type eventFilter struct {
+	criteria *EventCriteria
+	sub      *eventSub
+	events   []*types.Event
+	lastPoll time.Time
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+func (f *eventFilter) append(events []*types.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, f.criteria.Filter(events)...)
+	if len(f.events) > maxFilterEvents {
+		f.events = f.events[len(f.events)-maxFilterEvents:]
+	}
+}
+
+// poll returns the buffered events and clears the buffer
+func (f *eventFilter) poll() []*types.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events := f.events
+	f.events = make([]*types.Event, 0)
+	f.lastPoll = time.Now()
+	return events
+}
+
+func (f *eventFilter) expired() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Since(f.lastPoll) > filterTimeout
+}
+
+// PublicFilterAPI API for querying events
+type PublicFilterAPI struct {
+	events *EventSystem
+}
+
+// NewPublicFilterAPI
+func NewPublicFilterAPI(events *EventSystem) *PublicFilterAPI {
+	return &PublicFilterAPI{events}
+}
+
+// GetEvents returns the events in stable blocks from fromHeight to toHeight. The events are filtered by addresses and topics. Topics is a list of topic choices on each position, an empty choice matches any topic
+func (f *PublicFilterAPI) GetEvents(fromHeight, toHeight uint32, addresses []string, topics [][]common.Hash) ([]*types.Event, error) {
+	criteria, err := newEventCriteria(addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+	if stableHeight := f.events.chain.StableBlock().Height(); toHeight > stableHeight {
+		toHeight = stableHeight
+	}
+	if fromHeight > toHeight {
+		return make([]*types.Event, 0), nil
+	}
+	if toHeight-fromHeight >= maxEventsQueryRange {
+		return nil, ErrEventsQueryRange
+	}
+	return queryStableEvents(f.events.db, fromHeight, toHeight, criteria)
+}
+
+// NewEventFilter installs a filter for the events in new stable blocks. The events could be polled by GetFilterChanges. The filter will be uninstalled if it is not polled for 5 minutes
+func (f *PublicFilterAPI) NewEventFilter(addresses []string, topics [][]common.Hash) (rpc.ID, error) {
+	criteria, err := newEventCriteria(addresses, topics)
+	if err != nil {
+		return "", err
+	}
+	return f.events.installFilter(criteria), nil
+}
+
+// GetFilterChanges returns the events which are matched since last poll
+func (f *PublicFilterAPI) GetFilterChanges(id rpc.ID) ([]*types.Event, error) {
+	return f.events.filterChanges(id)
+}
+
+// UninstallFilter removes the filter
+func (f *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
+	return f.events.uninstallFilter(id)
+}
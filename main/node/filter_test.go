@@ -0,0 +1,125 @@
+package node
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/testchain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var (
+	testEventAddr1  = common.HexToAddress("0x01")
+	testEventAddr2  = common.HexToAddress("0x02")
+	testEventTopic1 = common.HexToHash("0x11")
+	testEventTopic2 = common.HexToHash("0x22")
+	testEventTopic3 = common.HexToHash("0x33")
+)
+
+func TestEventCriteria_Match(t *testing.T) {
+	event := &types.Event{Address: testEventAddr1, Topics: []common.Hash{testEventTopic1, testEventTopic2}}
+	var bloom types.Bloom
+	bloom.Add(event.Address.Bytes())
+	bloom.Add(testEventTopic1[:])
+	bloom.Add(testEventTopic2[:])
+
+	tests := []struct {
+		criteria EventCriteria
+		match    bool
+	}{
+		{EventCriteria{}, true},
+		{EventCriteria{Addresses: []common.Address{testEventAddr1}}, true},
+		{EventCriteria{Addresses: []common.Address{testEventAddr2, testEventAddr1}}, true},
+		{EventCriteria{Addresses: []common.Address{testEventAddr2}}, false},
+		{EventCriteria{Topics: [][]common.Hash{{testEventTopic1}}}, true},
+		{EventCriteria{Topics: [][]common.Hash{{}, {testEventTopic2}}}, true},
+		{EventCriteria{Topics: [][]common.Hash{{testEventTopic3, testEventTopic1}, {testEventTopic2}}}, true},
+		{EventCriteria{Topics: [][]common.Hash{{testEventTopic2}}}, false},
+		{EventCriteria{Topics: [][]common.Hash{{testEventTopic3}}}, false},
+		{EventCriteria{Addresses: []common.Address{testEventAddr2}, Topics: [][]common.Hash{{testEventTopic1}}}, false},
+	}
+	for i, test := range tests {
+		assert.Equal(t, test.match, test.criteria.Match(event), "index=%d", i)
+		// the event is in bloom if it matches
+		if test.match {
+			assert.True(t, test.criteria.MatchBloom(bloom), "index=%d", i)
+		}
+	}
+	// more topic positions than the event has
+	criteria := EventCriteria{Topics: [][]common.Hash{{}, {}, {}}}
+	assert.False(t, criteria.Match(event))
+	assert.False(t, (&EventCriteria{Topics: [][]common.Hash{{testEventTopic3}}}).MatchBloom(bloom))
+}
+
+func TestPublicFilterAPI_GetEvents(t *testing.T) {
+	bc, db := testchain.NewTestChain()
+	defer testchain.CloseTestChain(bc, db)
+
+	events := NewEventSystem(bc, db)
+	events.Start()
+	defer events.Stop()
+	api := NewPublicFilterAPI(events)
+	filterID, err := api.NewEventFilter([]string{testEventAddr1.String()}, nil)
+	assert.NoError(t, err)
+
+	// make block 2 stable with some events
+	block := testchain.LoadDefaultBlock(2)
+	receipts := types.Receipts{
+		{Events: []*types.Event{
+			{Address: testEventAddr1, Topics: []common.Hash{testEventTopic1}, Index: 0},
+			{Address: testEventAddr2, Topics: []common.Hash{testEventTopic1, testEventTopic2}, Index: 1},
+		}},
+	}
+	assert.NoError(t, db.SetReceipts(block.Hash(), receipts))
+	_, err = db.SetStableBlock(block.Hash())
+	assert.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+
+	// all events
+	result, err := api.GetEvents(0, 100, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, block.Hash(), result[0].BlockHash)
+	assert.Equal(t, uint32(2), result[0].BlockHeight)
+	// filter by address
+	result, err = api.GetEvents(0, 2, []string{testEventAddr2.String()}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, uint(1), result[0].Index)
+	// filter by topic
+	result, err = api.GetEvents(0, 2, nil, [][]common.Hash{{}, {testEventTopic2}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, testEventAddr2, result[0].Address)
+	result, err = api.GetEvents(0, 2, nil, [][]common.Hash{{testEventTopic3}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+	// out of range
+	result, err = api.GetEvents(0, 1, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+	result, err = api.GetEvents(3, 100, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+	// invalid address
+	_, err = api.GetEvents(0, 2, []string{"Lemo123"}, nil)
+	assert.Error(t, err)
+
+	// the stable block notification is sent by consensus, so we dispatch it manually
+	events.dispatchStableEvents(block)
+	time.Sleep(100 * time.Millisecond)
+	result, err = api.GetFilterChanges(filterID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, testEventAddr1, result[0].Address)
+	// the changes have been polled
+	result, err = api.GetFilterChanges(filterID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+
+	assert.True(t, api.UninstallFilter(filterID))
+	assert.False(t, api.UninstallFilter(filterID))
+	_, err = api.GetFilterChanges(filterID)
+	assert.Equal(t, ErrFilterNotExist, err)
+}
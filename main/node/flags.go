@@ -2,13 +2,17 @@ package node
 
 import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/flag"
+	"github.com/LemoFoundationLtd/lemochain-core/network"
 	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
 	"gopkg.in/urfave/cli.v1"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func NewApp(usage string) *cli.App {
@@ -31,6 +35,10 @@ var (
 		Usage: "Network listening port",
 		Value: DefaultP2PPort,
 	}
+	NoDiscoveryFlag = cli.BoolFlag{
+		Name:  common.NoDiscovery,
+		Usage: "Disable the UDP node discovery. Only the known nodes are dialed",
+	}
 	AutoMineFlag = cli.BoolFlag{
 		Name:  common.MiningEnabled,
 		Usage: "Enable mining",
@@ -81,12 +89,51 @@ var (
 		Usage: "Output log level",
 		Value: 4,
 	}
+
+	TxPoolMaxTxsFlag = cli.IntFlag{
+		Name:  common.TxPoolMaxTxs,
+		Usage: "Maximum number of transactions in pool. The cheapest one will be evicted if the pool is full",
+		Value: txpool.DefaultConfig.MaxTxs,
+	}
+	TxPoolAccountTxsFlag = cli.IntFlag{
+		Name:  common.TxPoolAccountTxs,
+		Usage: "Maximum number of transactions in pool which are sent from one account",
+		Value: txpool.DefaultConfig.MaxTxsPerAccount,
+	}
+	TxPoolPriceLimitFlag = cli.Uint64Flag{
+		Name:  common.TxPoolPriceLimit,
+		Usage: "Minimum gas price (in mo) of the transaction which could be accepted by pool",
+		Value: txpool.DefaultConfig.MinGasPrice.Uint64(),
+	}
+	SyncModeFlag = cli.StringFlag{
+		Name:  common.SyncMode,
+		Usage: `Blockchain sync mode ("full" or "snapshot"). A new node in snapshot mode downloads the state of a recent stable block instead of all history blocks`,
+		Value: FullSyncMode,
+	}
+	BanDurationFlag = cli.IntFlag{
+		Name:  common.BanDuration,
+		Usage: "Minutes to ban a peer whose reputation score is too low cause of its misbehavior",
+		Value: int(network.DefaultBanDuration / time.Minute),
+	}
+)
+
+const (
+	FullSyncMode     = "full"
+	SnapshotSyncMode = "snapshot"
 )
 
 // setP2PConfig set p2p config
 func setP2PConfig(flags flag.CmdFlags, cfg *p2p.Config) {
 	// set listen port
 	cfg.Port = flags.Int(ListenPortFlag.Name)
+	cfg.NoDiscovery = flags.Bool(NoDiscoveryFlag.Name)
+}
+
+// setTxPoolConfig set the limits of tx pool
+func setTxPoolConfig(flags flag.CmdFlags, cfg *txpool.Config) {
+	cfg.MaxTxs = flags.Int(TxPoolMaxTxsFlag.Name)
+	cfg.MaxTxsPerAccount = flags.Int(TxPoolAccountTxsFlag.Name)
+	cfg.MinGasPrice = new(big.Int).SetUint64(flags.Uint64(TxPoolPriceLimitFlag.Name))
 }
 
 // setHttp set http-rpc
@@ -136,6 +183,7 @@ func getNodeConfig(flags flag.CmdFlags) *Config {
 		}
 	}
 	setP2PConfig(flags, &cfg.P2P)
+	setTxPoolConfig(flags, &cfg.TxPool)
 	setIPC(flags, cfg)
 	setHttp(flags, cfg)
 	setWS(flags, cfg)
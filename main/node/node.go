@@ -6,6 +6,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/keystore"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/miner"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
@@ -22,11 +23,13 @@ import (
 	"github.com/inconshreveable/log15"
 	"math/big"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Node struct {
@@ -35,10 +38,12 @@ type Node struct {
 
 	db       protocol.ChainDB
 	accMan   *account.Manager
+	keystore *keystore.KeyStore
 	txPool   *txpool.TxPool
 	chain    *chain.BlockChain
 	pm       *network.ProtocolManager
 	miner    *miner.Miner
+	events   *EventSystem
 	gasPrice *big.Int
 
 	instanceDirLock flock.Releaser
@@ -80,14 +85,22 @@ func initConfig(flags flag.CmdFlags) (*Config, *config.ConfigFromFile) {
 	}
 	configFromFile.Check()
 	log.Info("Load \"config.json\" success", "ChainID", configFromFile.ChainID, "DeputyCount", configFromFile.DeputyCount)
+	if configFromFile.Forks != nil {
+		if err := params.SetForkConfig(configFromFile.Forks); err != nil {
+			panic(config.ErrForkInConfig)
+		}
+		log.Info("Load fork config success", "forks", configFromFile.Forks)
+	}
 
 	// P2P
 	deputynode.SetSelfNodeKey(cfg.NodeKey())
 	cfg.P2P.PrivateKey = deputynode.GetSelfNodeKey()
 	// BlockChain
 	cfg.Chain = chain.Config{
-		ChainID:     uint16(configFromFile.ChainID),
-		MineTimeout: configFromFile.Timeout,
+		ChainID:         uint16(configFromFile.ChainID),
+		MineTimeout:     configFromFile.Timeout,
+		PruneBlocks:     uint32(configFromFile.PruneBlocks),
+		CompactInterval: time.Duration(configFromFile.CompactInterval) * time.Minute,
 	}
 	// Miner
 	// parentBlock---[sleepTime]---mine window from---[ReservedPropagationTime]---mine window to
@@ -104,9 +117,13 @@ func GetChainDataPath(dataDir string) string {
 	return filepath.Join(dataDir, "chaindata")
 }
 
-func initDb(dataDir string) protocol.ChainDB {
+func initDb(dataDir string, backend string) protocol.ChainDB {
 	dir := GetChainDataPath(dataDir)
-	return store.NewChainDataBase(dir)
+	db, err := store.OpenChainDataBase(dir, backend)
+	if err != nil {
+		log.Critf("Open database failed: %v", err)
+	}
+	return db
 }
 
 func getGenesis(db protocol.ChainDB) *types.Block {
@@ -128,13 +145,13 @@ func getGenesis(db protocol.ChainDB) *types.Block {
 
 func New(flags flag.CmdFlags) *Node {
 	cfg, configFromFile := initConfig(flags)
-	db := initDb(cfg.DataDir)
+	db := initDb(cfg.DataDir, configFromFile.StoreBackend)
 	// read genesis block
 	genesisBlock := getGenesis(db)
 	// read all deputy nodes from snapshot block
 	dm := deputynode.NewManager(int(configFromFile.DeputyCount), db)
 	// tx pool
-	txPool := txpool.NewTxPool()
+	txPool := txpool.NewTxPoolWithConfig(cfg.TxPool)
 	blockChain, err := chain.NewBlockChain(cfg.Chain, dm, db, flags, txPool)
 	if err != nil {
 		panic("new block chain failed!!!")
@@ -145,6 +162,26 @@ func New(flags flag.CmdFlags) *Node {
 	selfNodeID := p2p.NodeID{}
 	copy(selfNodeID[:], deputynode.GetSelfNodeID())
 	pm := network.NewProtocolManager(uint16(configFromFile.ChainID), selfNodeID, blockChain, dm, txPool, blockChain.TxGuard(), discover, int(configFromFile.ConnectionLimit), params.VersionUint(), cfg.DataDir)
+	switch mode := flags.String(SyncModeFlag.Name); mode {
+	case FullSyncMode:
+	case SnapshotSyncMode:
+		pm.SetSnapshotSync(true)
+	default:
+		panic(fmt.Sprintf("invalid sync mode: %s", mode))
+	}
+	if minutes := flags.Int(BanDurationFlag.Name); minutes > 0 {
+		pm.SetBanDuration(time.Duration(minutes) * time.Minute)
+	}
+	if configFromFile.MaxBlocksPerRequest > 0 {
+		pm.SetMaxBlocksServe(uint32(configFromFile.MaxBlocksPerRequest))
+	}
+	for name, limit := range configFromFile.RateLimits {
+		code, ok := p2p.ParseRequestMsgCode(name)
+		if !ok {
+			panic(config.ErrRateLimitInConfig)
+		}
+		pm.SetRateLimit(code, network.RateLimit{Rate: limit.Rate, Burst: int(limit.Burst)})
+	}
 	// p2p server
 	server := p2p.NewServer(cfg.P2P, discover)
 
@@ -156,9 +193,11 @@ func New(flags flag.CmdFlags) *Node {
 		wsEndpoint:   cfg.WSEndpoint(),
 		db:           db,
 		accMan:       blockChain.AccountManager(),
+		keystore:     keystore.NewKeyStore(cfg.KeyStoreDir(), keystore.StandardScryptN, keystore.StandardScryptP),
 		chain:        blockChain,
 		txPool:       txPool,
 		miner:        miner.New(cfg.Miner, blockChain, dm, txPool),
+		events:       NewEventSystem(blockChain, db),
 		pm:           pm,
 		server:       server,
 		genesisBlock: genesisBlock,
@@ -166,6 +205,20 @@ func New(flags flag.CmdFlags) *Node {
 	return n
 }
 
+// OpenChain opens the database and block chain without network. It is used by the commands which process chain data offline.
+// The caller should stop the chain and close the database
+func OpenChain(flags flag.CmdFlags) (*chain.BlockChain, protocol.ChainDB) {
+	cfg, configFromFile := initConfig(flags)
+	db := initDb(cfg.DataDir, configFromFile.StoreBackend)
+	getGenesis(db)
+	dm := deputynode.NewManager(int(configFromFile.DeputyCount), db)
+	blockChain, err := chain.NewBlockChain(cfg.Chain, dm, db, flags, txpool.NewTxPoolWithConfig(cfg.TxPool))
+	if err != nil {
+		panic("new block chain failed!!!")
+	}
+	return blockChain, db
+}
+
 func (n *Node) DataDir() string {
 	return n.config.DataDir
 }
@@ -182,6 +235,10 @@ func (n *Node) AccountManager() *account.Manager {
 	return n.accMan
 }
 
+func (n *Node) KeyStore() *keystore.KeyStore {
+	return n.keystore
+}
+
 func (n *Node) Start() error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -197,6 +254,7 @@ func (n *Node) Start() error {
 		return ErrServerStartFailed
 	}
 	n.pm.Start()
+	n.events.Start()
 	n.stop = make(chan struct{})
 
 	if err := n.startRPC(); err != nil {
@@ -352,11 +410,50 @@ func (n *Node) stopHTTP() {
 }
 
 func (n *Node) startWS(apis []rpc.API) error {
+	// Short circuit if the WS endpoint isn't being exposed
+	if n.wsEndpoint == "" {
+		return nil
+	}
+	// Register all the APIs exposed by the services
+	handler := rpc.NewServer()
+	for _, api := range apis {
+		if api.Public {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				return err
+			}
+			// log.Debug("WebSocket registered", "namespace", api.Namespace)
+		}
+	}
+	// All APIs registered, start the WS listener
+	var (
+		listener net.Listener
+		err      error
+	)
+	if listener, err = net.Listen("tcp", n.wsEndpoint); err != nil {
+		return err
+	}
+	go (&http.Server{Handler: handler.WebsocketHandler(n.config.WSOrigins)}).Serve(listener)
+	log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()), "origins", strings.Join(n.config.WSOrigins, ","))
+	// All listeners booted successfully
+	n.wsListener = listener
+	n.wsHandler = handler
+
 	return nil
 }
 
 func (n *Node) stopWS() {
+	if n.wsListener != nil {
+		if err := n.wsListener.Close(); err != nil {
+			log.Errorf("close wsListener failed: %v", err)
+		}
+		n.wsListener = nil
 
+		log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
+	}
+	if n.wsHandler != nil {
+		n.wsHandler.Stop()
+		n.wsHandler = nil
+	}
 }
 
 func (n *Node) stopRPC() {
@@ -377,6 +474,7 @@ func (n *Node) Stop() error {
 	defer n.lock.Unlock()
 	log.Debug("Start stopping node...")
 	n.stopRPC()
+	n.events.Stop()
 	if n.server == nil {
 		log.Warn("p2p server not started")
 	} else {
@@ -497,13 +595,13 @@ func (n *Node) apis() []rpc.API {
 		{
 			Namespace: "account",
 			Version:   "1.0",
-			Service:   NewPublicAccountAPI(n.accMan),
+			Service:   NewPublicAccountAPI(n.accMan, n.chain, n.db),
 			Public:    true,
 		},
 		{
 			Namespace: "account",
 			Version:   "1.0",
-			Service:   NewPrivateAccountAPI(n.accMan),
+			Service:   NewPrivateAccountAPI(n.accMan, n.keystore),
 			Public:    false,
 		},
 		{
@@ -530,6 +628,36 @@ func (n *Node) apis() []rpc.API {
 			Service:   NewPrivateTxAPI(n),
 			Public:    false,
 		},
+		{
+			Namespace: "chain",
+			Version:   "1.0",
+			Service:   NewPublicChainEventAPI(n.events),
+			Public:    true,
+		},
+		{
+			Namespace: "chain",
+			Version:   "1.0",
+			Service:   NewPublicFilterAPI(n.events),
+			Public:    true,
+		},
+		{
+			Namespace: "chain",
+			Version:   "1.0",
+			Service:   NewPublicProofAPI(n.chain, n.db),
+			Public:    true,
+		},
+		{
+			Namespace: "tx",
+			Version:   "1.0",
+			Service:   NewPublicTxEventAPI(n.events),
+			Public:    true,
+		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateDebugAPI(n),
+			Public:    false,
+		},
 	}
 }
 
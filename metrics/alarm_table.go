@@ -108,6 +108,30 @@ var AlarmRuleTable = map[string]*Condition{
 		AlarmValue:   10,
 		AlarmMsgCode: textMsgCode,
 	},
+	HandleGetHeadersMsg_meterName: {
+		AlarmReason:  "最近一分钟时间内收到其他节点请求拉取区块头消息次数大于600次", // 按照连接10个正在同步的节点计算
+		MetricsType:  TypeRate1,
+		AlarmValue:   10,
+		AlarmMsgCode: textMsgCode,
+	},
+	HandleGetBodiesMsg_meterName: {
+		AlarmReason:  "最近一分钟时间内收到其他节点请求拉取区块体消息次数大于600次", // 同上
+		MetricsType:  TypeRate1,
+		AlarmValue:   10,
+		AlarmMsgCode: textMsgCode,
+	},
+	HandleGetAccountsMsg_meterName: {
+		AlarmReason:  "最近一分钟时间内收到其他节点请求拉取状态快照账户消息次数大于600次", // 同上
+		MetricsType:  TypeRate1,
+		AlarmValue:   10,
+		AlarmMsgCode: textMsgCode,
+	},
+	HandleGetNodeDataMsg_meterName: {
+		AlarmReason:  "最近一分钟时间内收到其他节点请求拉取状态树节点消息次数大于3000次", // 状态树节点数量较多
+		MetricsType:  TypeRate1,
+		AlarmValue:   50,
+		AlarmMsgCode: textMsgCode,
+	},
 	// p2p
 	PeerConnFailed_meterName: {
 		AlarmReason:  "最近一分钟时间内节点连接断开的次数大于5次",
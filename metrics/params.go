@@ -8,6 +8,11 @@ var (
 	InvalidTx_meterName      = "txpool/DelInvalidTxs/invalid"
 	TxpoolNumber_counterName = "txpool/totalTxNumber"
 
+	TxpoolRejectFull_counterName    = "txpool/addTx/rejectFull"    // 交易池已满而被拒绝的交易数量
+	TxpoolRejectAccount_counterName = "txpool/addTx/rejectAccount" // 单个账户交易过多而被拒绝的交易数量
+	TxpoolRejectPrice_counterName   = "txpool/addTx/rejectPrice"   // gas price过低而被拒绝的交易数量
+	TxpoolRejectExist_counterName   = "txpool/addTx/rejectExist"   // 重复而被拒绝的交易数量
+
 	// tx
 	txModule                 = "tx"
 	VerifyFailedTx_meterName = "tx/VerifyTxBody/verifyFailed"
@@ -21,7 +26,12 @@ var (
 	HandleConfirmMsg_meterName                = "network/protocol_manager/handleConfirmMsg"                // 统计调用handleConfirmMsg的频率
 	HandleGetBlocksWithChangeLogMsg_meterName = "network/protocol_manager/handleGetBlocksWithChangeLogMsg" // 统计调用handleGetBlocksWithChangeLogMsg的频率
 	HandleDiscoverReqMsg_meterName            = "network/protocol_manager/handleDiscoverReqMsg"            // 统计调用handleDiscoverReqMsg的频率
+	HandleGetHeadersMsg_meterName             = "network/protocol_manager/handleGetHeadersMsg"             // 统计调用handleGetHeadersMsg的频率
+	HandleGetBodiesMsg_meterName              = "network/protocol_manager/handleGetBodiesMsg"              // 统计调用handleGetBodiesMsg的频率
+	HandleGetAccountsMsg_meterName            = "network/protocol_manager/handleGetAccountsMsg"            // 统计调用handleGetAccountsMsg的频率
+	HandleGetNodeDataMsg_meterName            = "network/protocol_manager/handleGetNodeDataMsg"            // 统计调用handleGetNodeDataMsg的频率
 	HandleDiscoverResMsg_meterName            = "network/protocol_manager/handleDiscoverResMsg"            // 统计调用handleDiscoverResMsg的频率
+	ThrottledMsg_meterName                    = "network/protocol_manager/throttledMsg"                    // 统计因请求过于频繁而被丢弃的消息的频率
 
 	// leveldb
 	leveldbModule               = LevelDBPrefix
@@ -3,6 +3,7 @@ package network
 import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
 )
 
 // BlockChain
@@ -24,6 +25,12 @@ type BlockChain interface {
 	InsertConfirms(height uint32, blockHash common.Hash, sigList []types.SignData)
 	// IsInBlackList
 	IsInBlackList(b *types.Block) bool
+	// InsertSnapshot set the block with synchronized state as the chain base. The state is unverified
+	InsertSnapshot(snapshot *store.Snapshot) error
+	// GetSnapshotAccounts get at most count accounts from the start address in the state of stable block
+	GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error)
+	// GetNodeData get trie node or contract code by hash
+	GetNodeData(hash common.Hash) ([]byte, error)
 }
 
 type TxPool interface {
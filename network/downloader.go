@@ -0,0 +1,559 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/hexutil"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//go:generate gencodec -type SyncStatus --field-override syncStatusMarshaling -out gen_sync_status_json.go
+
+const (
+	headerSegmentSize = 128 // the count of headers between two skeleton headers
+	maxSkeletonSize   = 16  // the max count of segments in one skeleton
+	bodyBatchSize     = 64  // the max count of bodies in one request
+	maxHeadersServe   = 512 // the max count of headers responded to one request
+	maxBodiesServe    = 128 // the max count of bodies responded to one request
+	maxTaskRetry      = 3   // the max count of retries of a fetch task
+)
+
+var (
+	// the time to wait for the response of a fetch request
+	fetchTimeout = 10 * time.Second
+
+	ErrSyncBusy         = errors.New("synchronization is in progress")
+	ErrSyncCanceled     = errors.New("synchronization is canceled")
+	ErrFetchTimeout     = errors.New("fetch data from remote timeout")
+	ErrNoPeersToSync    = errors.New("no peers to synchronize from")
+	ErrInvalidHeaders   = errors.New("invalid headers from remote")
+	ErrInvalidBodies    = errors.New("invalid block bodies from remote")
+	ErrUnknownSyncStart = errors.New("the parent of the first synchronized block is unknown")
+	ErrUnknownTerm      = errors.New("the deputy nodes of synchronized block's term are unknown")
+)
+
+// SyncStatus is the progress of headers-first synchronization or state snapshot synchronization
+type SyncStatus struct {
+	Syncing       bool   `json:"syncing"       gencodec:"required"`
+	StartHeight   uint32 `json:"startHeight"   gencodec:"required"`
+	CurrentHeight uint32 `json:"currentHeight" gencodec:"required"`
+	HighestHeight uint32 `json:"highestHeight" gencodec:"required"`
+	PulledHeaders uint32 `json:"pulledHeaders" gencodec:"required"`
+	PulledBodies  uint32 `json:"pulledBodies"  gencodec:"required"`
+	// the progress of state snapshot synchronization
+	PulledAccounts uint32 `json:"pulledAccounts" gencodec:"required"`
+	PulledNodes    uint32 `json:"pulledNodes"    gencodec:"required"`
+}
+
+type syncStatusMarshaling struct {
+	StartHeight    hexutil.Uint32
+	CurrentHeight  hexutil.Uint32
+	HighestHeight  hexutil.Uint32
+	PulledHeaders  hexutil.Uint32
+	PulledBodies   hexutil.Uint32
+	PulledAccounts hexutil.Uint32
+	PulledNodes    hexutil.Uint32
+}
+
+// fetchRequest is a request which is waiting for the response from a peer
+type fetchRequest struct {
+	code  p2p.MsgCode // the code of response message
+	resCh chan interface{}
+}
+
+// headerSegment is a range of headers which is ended with a skeleton header
+type headerSegment struct {
+	from    uint32
+	to      uint32
+	parent  common.Hash   // the hash of block at height from-1. It is empty if the block should be found in local chain
+	anchor  *types.Header // the skeleton header at height to. It is nil for the tail segment
+	headers []*types.Header
+}
+
+// Downloader synchronizes blocks headers-first. It fetches a header skeleton from one peer, then fills the skeleton and fetches block bodies from several peers in parallel
+type Downloader struct {
+	chain  BlockChain
+	dm     *deputynode.Manager
+	peers  *peerSet
+	insert func(block *types.Block) error
+
+	syncing int32
+	status  SyncStatus
+	pending map[p2p.NodeID]*fetchRequest
+	// the blocks whose signer and confirms are not verified because their terms were unknown when they were fetched
+	deferred map[common.Hash]bool
+
+	lock   sync.Mutex
+	quitCh chan struct{}
+}
+
+// NewDownloader
+func NewDownloader(chain BlockChain, dm *deputynode.Manager, peers *peerSet, insert func(block *types.Block) error, quitCh chan struct{}) *Downloader {
+	return &Downloader{
+		chain:    chain,
+		dm:       dm,
+		peers:    peers,
+		insert:   insert,
+		pending:  make(map[p2p.NodeID]*fetchRequest),
+		deferred: make(map[common.Hash]bool),
+		quitCh:   quitCh,
+	}
+}
+
+// Syncing returns true if the synchronization is in progress
+func (d *Downloader) Syncing() bool {
+	return atomic.LoadInt32(&d.syncing) == 1
+}
+
+// Status returns the progress of synchronization
+func (d *Downloader) Status() *SyncStatus {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	status := d.status
+	status.Syncing = d.Syncing()
+	if !status.Syncing {
+		status.CurrentHeight = d.chain.CurrentBlock().Height()
+	}
+	return &status
+}
+
+func (d *Downloader) updateStatus(fn func(status *SyncStatus)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	fn(&d.status)
+}
+
+// Synchronise downloads the blocks from height from to height to. The skeleton is fetched from the master peer, and the other peers help to fill it
+func (d *Downloader) Synchronise(master *peer, from, to uint32) error {
+	if from > to {
+		return nil
+	}
+	if !atomic.CompareAndSwapInt32(&d.syncing, 0, 1) {
+		return ErrSyncBusy
+	}
+	defer atomic.StoreInt32(&d.syncing, 0)
+
+	d.updateStatus(func(status *SyncStatus) {
+		*status = SyncStatus{
+			StartHeight:   from - 1,
+			CurrentHeight: from - 1,
+			HighestHeight: to,
+		}
+		d.deferred = make(map[common.Hash]bool)
+	})
+	log.Infof("Start headers-first synchronization from %d to %d", from, to)
+
+	var parentHash common.Hash
+	for from <= to {
+		end := to
+		if end-from+1 > headerSegmentSize*maxSkeletonSize {
+			end = from + headerSegmentSize*maxSkeletonSize - 1
+		}
+		blocks, err := d.fetchRound(master, from, end, parentHash)
+		if err != nil {
+			log.Warnf("Synchronization from %d to %d failed: %v", from, end, err)
+			return err
+		}
+		for _, block := range blocks {
+			if !d.chain.HasBlock(block.Hash()) {
+				if err := d.verifyDeferred(block); err != nil {
+					log.Warnf("Verify synchronized block %s failed: %v", block.ShortString(), err)
+					return err
+				}
+				if err := d.insert(block); err != nil {
+					log.Warnf("Insert synchronized block %s failed: %v", block.ShortString(), err)
+					return err
+				}
+			}
+			d.updateStatus(func(status *SyncStatus) {
+				status.CurrentHeight = block.Height()
+			})
+		}
+		parentHash = blocks[len(blocks)-1].Hash()
+		from = end + 1
+	}
+	log.Infof("Headers-first synchronization finished at %d", to)
+	return nil
+}
+
+// fetchRound downloads the blocks from height from to height to. The parentHash is the hash of block at height from-1, or empty if it should be found in local chain
+func (d *Downloader) fetchRound(master *peer, from, to uint32, parentHash common.Hash) (types.Blocks, error) {
+	segments, err := d.fetchSkeleton(master, from, to, parentHash)
+	if err != nil {
+		return nil, err
+	}
+	peers := d.peers.PeersToSync(to)
+	if !containsPeer(peers, master) {
+		peers = append(peers, master)
+	}
+
+	// fill the skeleton in parallel
+	err = d.runTasks(peers, len(segments), func(p *peer, index int) error {
+		return d.fillSegment(p, segments[index])
+	})
+	if err != nil {
+		return nil, err
+	}
+	headers := make([]*types.Header, 0, to-from+1)
+	for _, segment := range segments {
+		headers = append(headers, segment.headers...)
+	}
+
+	// fetch bodies in parallel
+	blocks := make(types.Blocks, len(headers))
+	taskCount := (len(headers) + bodyBatchSize - 1) / bodyBatchSize
+	err = d.runTasks(peers, taskCount, func(p *peer, index int) error {
+		start := index * bodyBatchSize
+		end := start + bodyBatchSize
+		if end > len(headers) {
+			end = len(headers)
+		}
+		return d.fetchBodies(p, headers[start:end], blocks[start:end])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// fetchSkeleton fetches the last header of each full segment from master peer, and splits the range into segments
+func (d *Downloader) fetchSkeleton(master *peer, from, to uint32, parentHash common.Hash) ([]*headerSegment, error) {
+	count := (to - from + 1) / headerSegmentSize
+	segments := make([]*headerSegment, 0, count+1)
+	if count > 0 {
+		skeleton, err := d.requestHeaders(master, from+headerSegmentSize-1, count, headerSegmentSize-1)
+		if err != nil {
+			return nil, err
+		}
+		if uint32(len(skeleton)) != count {
+			return nil, ErrInvalidHeaders
+		}
+		for i, header := range skeleton {
+			segmentFrom := from + uint32(i)*headerSegmentSize
+			if header.Height != segmentFrom+headerSegmentSize-1 {
+				return nil, ErrInvalidHeaders
+			}
+			if err := d.deferUnknownTerm(header.Hash(), d.verifySigner(header)); err != nil {
+				return nil, err
+			}
+			segments = append(segments, &headerSegment{from: segmentFrom, to: header.Height, parent: parentHash, anchor: header})
+			parentHash = header.Hash()
+		}
+	}
+	// the tail segment which is not anchored by skeleton
+	if tailFrom := from + count*headerSegmentSize; tailFrom <= to {
+		segments = append(segments, &headerSegment{from: tailFrom, to: to, parent: parentHash})
+	}
+	return segments, nil
+}
+
+// fillSegment fetches the headers of segment and checks them with the skeleton header
+func (d *Downloader) fillSegment(p *peer, segment *headerSegment) error {
+	count := segment.to - segment.from + 1
+	headers, err := d.requestHeaders(p, segment.from, count, 0)
+	if err != nil {
+		return err
+	}
+	if uint32(len(headers)) != count {
+		return ErrInvalidHeaders
+	}
+	last := headers[len(headers)-1]
+	if segment.anchor != nil && last.Hash() != segment.anchor.Hash() {
+		return ErrInvalidHeaders
+	}
+	if err := d.verifyHeaders(headers, segment.parent); err != nil {
+		return err
+	}
+	segment.headers = headers
+	d.updateStatus(func(status *SyncStatus) {
+		status.PulledHeaders += count
+	})
+	return nil
+}
+
+// verifyHeaders checks the headers are continuous from parentHash and signed by deputy nodes
+func (d *Downloader) verifyHeaders(headers []*types.Header, parentHash common.Hash) error {
+	if len(headers) == 0 {
+		return ErrInvalidHeaders
+	}
+	if parentHash == (common.Hash{}) {
+		if !d.chain.HasBlock(headers[0].ParentHash) {
+			return ErrUnknownSyncStart
+		}
+	} else if headers[0].ParentHash != parentHash {
+		return ErrInvalidHeaders
+	}
+	for i, header := range headers {
+		if i > 0 && (header.Height != headers[i-1].Height+1 || header.ParentHash != headers[i-1].Hash()) {
+			return ErrInvalidHeaders
+		}
+		if err := d.deferUnknownTerm(header.Hash(), d.verifySigner(header)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifySigner checks the header is signed by the deputy node of its miner. It returns ErrUnknownTerm if the term of header is not stable in local
+func (d *Downloader) verifySigner(header *types.Header) error {
+	nodeID, err := header.SignerNodeID()
+	if err != nil {
+		log.Warnf("Invalid sign data of synchronized header. height: %d, err: %v", header.Height, err)
+		return ErrInvalidHeaders
+	}
+	if _, err := d.dm.GetTermByHeight(header.Height, true); err != nil {
+		return ErrUnknownTerm
+	}
+	deputy := d.dm.GetDeputyByNodeID(header.Height, nodeID)
+	if deputy == nil || deputy.MinerAddress != header.MinerAddress {
+		log.Warnf("Synchronized header is not signed by deputy node. height: %d, nodeID: %s", header.Height, common.ToHex(nodeID))
+		return ErrInvalidHeaders
+	}
+	return nil
+}
+
+// verifyConfirms checks the confirms in block like the consensus.Validator.VerifyNewConfirms. Every confirm must be signed by a different deputy node. It returns ErrUnknownTerm if the term of block is not stable in local
+func (d *Downloader) verifyConfirms(block *types.Block) error {
+	if len(block.Confirms) == 0 {
+		return nil
+	}
+	if _, err := d.dm.GetTermByHeight(block.Height(), true); err != nil {
+		return ErrUnknownTerm
+	}
+	hash := block.Hash()
+	signers := make(map[string]bool, len(block.Confirms))
+	for _, sig := range block.Confirms {
+		nodeID, err := sig.RecoverNodeID(hash)
+		if err != nil {
+			log.Warnf("Invalid confirm of synchronized block. height: %d, err: %v", block.Height(), err)
+			return ErrInvalidBodies
+		}
+		key := common.ToHex(nodeID)
+		if d.dm.GetDeputyByNodeID(block.Height(), nodeID) == nil || signers[key] {
+			log.Warnf("Synchronized block is confirmed by invalid signer. height: %d, nodeID: %s", block.Height(), key)
+			return ErrInvalidBodies
+		}
+		signers[key] = true
+	}
+	return nil
+}
+
+// deferUnknownTerm records the block to verify it before insert if the err is ErrUnknownTerm. The blocks in the term may be fetched before the term is stable
+func (d *Downloader) deferUnknownTerm(hash common.Hash, err error) error {
+	if err != ErrUnknownTerm {
+		return err
+	}
+	d.lock.Lock()
+	d.deferred[hash] = true
+	d.lock.Unlock()
+	return nil
+}
+
+// verifyDeferred verifies the signer and confirms of block if they are deferred. The term must be stable now because the blocks before have been inserted
+func (d *Downloader) verifyDeferred(block *types.Block) error {
+	d.lock.Lock()
+	deferred := d.deferred[block.Hash()]
+	delete(d.deferred, block.Hash())
+	d.lock.Unlock()
+	if !deferred {
+		return nil
+	}
+	if err := d.verifySigner(block.Header); err != nil {
+		return err
+	}
+	return d.verifyConfirms(block)
+}
+
+// fetchBodies fetches the bodies of headers, and sets the assembled blocks into result
+func (d *Downloader) fetchBodies(p *peer, headers []*types.Header, result types.Blocks) error {
+	hashes := make([]common.Hash, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	bodies, err := d.requestBodies(p, hashes)
+	if err != nil {
+		return err
+	}
+	if len(bodies) != len(headers) {
+		return ErrInvalidBodies
+	}
+	for i, body := range bodies {
+		header := headers[i]
+		if body.Hash != hashes[i] || body.Txs.MerkleRootSha() != header.TxRoot {
+			return ErrInvalidBodies
+		}
+		if deputynode.IsSnapshotBlock(header.Height) {
+			root := body.DeputyNodes.MerkleRootSha()
+			if !bytes.Equal(root[:], header.DeputyRoot) {
+				return ErrInvalidBodies
+			}
+		}
+		block := &types.Block{
+			Header:      header,
+			Txs:         body.Txs,
+			Confirms:    body.Confirms,
+			DeputyNodes: body.DeputyNodes,
+		}
+		if err := d.deferUnknownTerm(hashes[i], d.verifyConfirms(block)); err != nil {
+			return err
+		}
+		result[i] = block
+	}
+	d.updateStatus(func(status *SyncStatus) {
+		status.PulledBodies += uint32(len(bodies))
+	})
+	return nil
+}
+
+// runTasks runs count tasks on the peers in parallel. Each peer runs one task at a time. The task failed on a peer is retried on other peers, and the failed peer is not used any more
+func (d *Downloader) runTasks(peers []*peer, count int, fetch func(p *peer, index int) error) error {
+	if count == 0 {
+		return nil
+	}
+	if len(peers) == 0 {
+		return ErrNoPeersToSync
+	}
+	tasks := make(chan int, count)
+	for i := 0; i < count; i++ {
+		tasks <- i
+	}
+	var (
+		remaining = int32(count)
+		retries   = make([]int32, count)
+		done      = make(chan struct{})
+		abort     sync.Once
+		wg        sync.WaitGroup
+	)
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p *peer) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case <-d.quitCh:
+					return
+				case index := <-tasks:
+					if err := fetch(p, index); err != nil {
+						log.Debugf("Fetch task %d from peer %s failed: %v", index, p.NodeID().String()[:16], err)
+						p.SyncFailed()
+						if isInvalidSyncData(err) {
+							d.peers.Penalise(p, offenceInvalidBlock)
+						}
+						if atomic.AddInt32(&retries[index], 1) > maxTaskRetry {
+							abort.Do(func() { close(done) })
+						} else {
+							tasks <- index
+						}
+						return
+					}
+					if atomic.AddInt32(&remaining, -1) == 0 {
+						abort.Do(func() { close(done) })
+					}
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	select {
+	case <-d.quitCh:
+		return ErrSyncCanceled
+	default:
+	}
+	if atomic.LoadInt32(&remaining) != 0 {
+		return ErrNoPeersToSync
+	}
+	return nil
+}
+
+// requestHeaders sends headers request to peer and waits for the response
+func (d *Downloader) requestHeaders(p *peer, from, count, skip uint32) ([]*types.Header, error) {
+	res, err := d.request(p, p2p.HeadersMsg, func() error {
+		return p.RequestHeaders(from, count, skip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]*types.Header), nil
+}
+
+// requestBodies sends bodies request to peer and waits for the response
+func (d *Downloader) requestBodies(p *peer, hashes []common.Hash) ([]*BlockBody, error) {
+	res, err := d.request(p, p2p.BodiesMsg, func() error {
+		return p.RequestBodies(hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]*BlockBody), nil
+}
+
+func (d *Downloader) request(p *peer, code p2p.MsgCode, send func() error) (interface{}, error) {
+	req := &fetchRequest{code: code, resCh: make(chan interface{}, 1)}
+	nodeID := *p.NodeID()
+	d.lock.Lock()
+	d.pending[nodeID] = req
+	d.lock.Unlock()
+	defer func() {
+		d.lock.Lock()
+		if d.pending[nodeID] == req {
+			delete(d.pending, nodeID)
+		}
+		d.lock.Unlock()
+	}()
+
+	if err := send(); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(fetchTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-req.resCh:
+		return res, nil
+	case <-timer.C:
+		d.peers.Penalise(p, offenceSlowResponse)
+		return nil, ErrFetchTimeout
+	case <-d.quitCh:
+		return nil, ErrSyncCanceled
+	}
+}
+
+// Deliver passes the response from peer to the waiting request. It returns false if the response is not requested
+func (d *Downloader) Deliver(p *peer, code p2p.MsgCode, data interface{}) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	req, ok := d.pending[*p.NodeID()]
+	if !ok || req.code != code {
+		return false
+	}
+	delete(d.pending, *p.NodeID())
+	req.resCh <- data
+	return true
+}
+
+// isInvalidSyncData returns true if the error is caused by the invalid data from remote
+func isInvalidSyncData(err error) bool {
+	switch err {
+	case ErrInvalidHeaders, ErrInvalidBodies, ErrInvalidState, ErrUnconfirmedStable:
+		return true
+	}
+	return false
+}
+
+func containsPeer(peers []*peer, p *peer) bool {
+	for _, item := range peers {
+		if *item.NodeID() == *p.NodeID() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	syncPeerNormal = iota
+	syncPeerSilent
+	syncPeerBadHeaders
+	syncPeerMissingBodies
+)
+
+// syncTestChain is a chain in memory which contains a single branch
+type syncTestChain struct {
+	testChain
+	blocks []*types.Block
+	hashes map[common.Hash]*types.Block
+	lock   sync.RWMutex
+}
+
+func newSyncTestChain(blocks []*types.Block) *syncTestChain {
+	bc := &syncTestChain{hashes: make(map[common.Hash]*types.Block)}
+	for _, block := range blocks {
+		bc.blocks = append(bc.blocks, block)
+		bc.hashes[block.Hash()] = block
+	}
+	return bc
+}
+
+func (bc *syncTestChain) Genesis() *types.Block { return bc.blocks[0] }
+func (bc *syncTestChain) HasBlock(hash common.Hash) bool {
+	return bc.GetBlockByHash(hash) != nil
+}
+func (bc *syncTestChain) GetBlockByHeight(height uint32) *types.Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+	if int(height) >= len(bc.blocks) {
+		return nil
+	}
+	return bc.blocks[height]
+}
+func (bc *syncTestChain) GetBlockByHash(hash common.Hash) *types.Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+	return bc.hashes[hash]
+}
+func (bc *syncTestChain) CurrentBlock() *types.Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+	return bc.blocks[len(bc.blocks)-1]
+}
+func (bc *syncTestChain) StableBlock() *types.Block { return bc.blocks[0] }
+func (bc *syncTestChain) InsertBlock(block *types.Block) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	if block.ParentHash() != bc.blocks[len(bc.blocks)-1].Hash() {
+		return errors.New("unknown parent")
+	}
+	bc.blocks = append(bc.blocks, block)
+	bc.hashes[block.Hash()] = block
+	return nil
+}
+
+// syncTestConn is one side of a connection. The written message is handled by the protocol manager on the other side
+type syncTestConn struct {
+	testPeer
+	id       p2p.NodeID
+	mode     int
+	remotePm *ProtocolManager
+	remote   *peer
+	requests map[p2p.MsgCode]*int32
+}
+
+func newSyncTestConn(id byte, mode int) *syncTestConn {
+	return &syncTestConn{
+		id:       p2p.NodeID{id},
+		mode:     mode,
+		requests: map[p2p.MsgCode]*int32{p2p.GetHeadersMsg: new(int32), p2p.GetBodiesMsg: new(int32)},
+	}
+}
+
+func (c *syncTestConn) RNodeID() *p2p.NodeID { return &c.id }
+func (c *syncTestConn) WriteMsg(code p2p.MsgCode, buf []byte) error {
+	if counter, ok := c.requests[code]; ok {
+		atomic.AddInt32(counter, 1)
+	}
+	switch {
+	case c.mode == syncPeerSilent && (code == p2p.HeadersMsg || code == p2p.BodiesMsg):
+		return nil
+	case c.mode == syncPeerBadHeaders && code == p2p.HeadersMsg:
+		var headers []*types.Header
+		if err := rlp.DecodeBytes(buf, &headers); err != nil {
+			return err
+		}
+		for _, header := range headers {
+			header.Extra = "bad"
+		}
+		buf, _ = rlp.EncodeToBytes(&headers)
+	case c.mode == syncPeerMissingBodies && code == p2p.BodiesMsg:
+		var bodies []*BlockBody
+		if err := rlp.DecodeBytes(buf, &bodies); err != nil {
+			return err
+		}
+		bodies = bodies[:len(bodies)-1]
+		buf, _ = rlp.EncodeToBytes(&bodies)
+	}
+	msg := &p2p.Msg{Code: code, Content: buf, ReceivedAt: time.Now()}
+	go func() {
+		// network latency
+		time.Sleep(10 * time.Millisecond)
+		c.remotePm.work(msg, c.remote)
+	}()
+	return nil
+}
+
+// connectSyncPeer connects the local protocol manager to the remote one, and returns the local peer
+func connectSyncPeer(local, remote *ProtocolManager, id byte, mode int) (*peer, *syncTestConn) {
+	localConn := newSyncTestConn(id, syncPeerNormal)
+	remoteConn := newSyncTestConn(id+100, mode)
+	localPeer := newPeer(localConn)
+	remotePeer := newPeer(remoteConn)
+	localConn.remotePm, localConn.remote = remote, remotePeer
+	remoteConn.remotePm, remoteConn.remote = local, localPeer
+
+	localPeer.lstStatus.CurHeight = remote.chain.CurrentBlock().Height()
+	localPeer.lstStatus.CurHash = remote.chain.CurrentBlock().Hash()
+	local.peers.Register(localPeer)
+	return localPeer, localConn
+}
+
+func makeSyncBlocks(key *ecdsa.PrivateKey, count int) []*types.Block {
+	miner := crypto.PubkeyToAddress(key.PublicKey)
+	blocks := []*types.Block{{Header: &types.Header{Height: 0}}}
+	for i := 1; i <= count; i++ {
+		txs := types.Transactions{}
+		if i%50 == 0 {
+			txs = append(txs, types.NewTransaction(common.HexToAddress("0x10000"), common.HexToAddress("0x1"), big.NewInt(int64(i)), 21000, params.MinGasPrice, nil, params.OrdinaryTx, 100, 1538210391, "", ""))
+		}
+		header := &types.Header{
+			ParentHash:   blocks[i-1].Hash(),
+			MinerAddress: miner,
+			TxRoot:       txs.MerkleRootSha(),
+			Height:       uint32(i),
+			Time:         uint32(1538210391 + i),
+		}
+		hash := header.Hash()
+		header.SignData, _ = crypto.Sign(hash[:], key)
+		blocks = append(blocks, &types.Block{Header: header, Txs: txs})
+	}
+	return blocks
+}
+
+func newSyncTestPm(key *ecdsa.PrivateKey, blocks []*types.Block) *ProtocolManager {
+	return newSyncTestPmWithChain(key, newSyncTestChain(blocks))
+}
+
+func newSyncTestPmWithChain(key *ecdsa.PrivateKey, bc BlockChain) *ProtocolManager {
+	dm := deputynode.NewManager(5, testBlockLoader{})
+	dm.SaveSnapshot(0, types.DeputyNodes{
+		&types.DeputyNode{
+			MinerAddress: crypto.PubkeyToAddress(key.PublicKey),
+			NodeID:       crypto.PrivateKeyToNodeID(key),
+			Rank:         0,
+			Votes:        big.NewInt(5),
+		},
+	})
+	return NewProtocolManager(1, p2p.NodeID{}, bc, dm, nil, txpool.NewTxGuard(100), new(p2p.DiscoverManager), 1, params.VersionUint(), "")
+}
+
+func TestDownloader_Synchronise(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	blocks := makeSyncBlocks(key, 300)
+	remote := newSyncTestPm(key, blocks)
+	defer remote.Stop()
+	local := newSyncTestPm(key, blocks[:1])
+	defer local.Stop()
+
+	oldTimeout := fetchTimeout
+	fetchTimeout = 500 * time.Millisecond
+	defer func() { fetchTimeout = oldTimeout }()
+
+	master, _ := connectSyncPeer(local, remote, 1, syncPeerNormal)
+	_, conn2 := connectSyncPeer(local, remote, 2, syncPeerNormal)
+	_, conn3 := connectSyncPeer(local, remote, 3, syncPeerNormal)
+	connectSyncPeer(local, remote, 4, syncPeerSilent)
+	connectSyncPeer(local, remote, 5, syncPeerBadHeaders)
+	connectSyncPeer(local, remote, 6, syncPeerMissingBodies)
+
+	assert.NoError(t, local.downloader.Synchronise(master, 1, 300))
+	assert.Equal(t, uint32(300), local.chain.CurrentBlock().Height())
+	for i := 0; i <= 300; i++ {
+		assert.Equal(t, blocks[i].Hash(), local.chain.GetBlockByHeight(uint32(i)).Hash())
+	}
+	// the blocks are fetched from several peers
+	assert.NotEqual(t, int32(0), *conn2.requests[p2p.GetBodiesMsg]+*conn3.requests[p2p.GetBodiesMsg])
+
+	status := local.SyncStatus()
+	assert.False(t, status.Syncing)
+	assert.Equal(t, uint32(0), status.StartHeight)
+	assert.Equal(t, uint32(300), status.CurrentHeight)
+	assert.Equal(t, uint32(300), status.HighestHeight)
+	assert.Equal(t, uint32(300), status.PulledHeaders)
+	assert.Equal(t, uint32(300), status.PulledBodies)
+
+	// nothing to synchronise
+	assert.NoError(t, local.downloader.Synchronise(master, 301, 300))
+}
+
+func TestDownloader_Synchronise_invalid(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	blocks := makeSyncBlocks(key, 20)
+
+	// the blocks are not signed by deputy
+	remote := newSyncTestPm(otherKey, blocks)
+	defer remote.Stop()
+	local := newSyncTestPm(otherKey, blocks[:1])
+	defer local.Stop()
+	master, _ := connectSyncPeer(local, remote, 1, syncPeerNormal)
+	assert.Equal(t, ErrNoPeersToSync, local.downloader.Synchronise(master, 1, 20))
+	assert.Equal(t, uint32(0), local.chain.CurrentBlock().Height())
+
+	// the parent of the first block is unknown
+	local = newSyncTestPm(key, []*types.Block{{Header: &types.Header{Height: 0, Extra: "other"}}})
+	defer local.Stop()
+	master, _ = connectSyncPeer(local, remote, 1, syncPeerNormal)
+	assert.Equal(t, ErrNoPeersToSync, local.downloader.Synchronise(master, 1, 20))
+	err := local.downloader.verifyHeaders([]*types.Header{blocks[1].Header}, common.Hash{})
+	assert.Equal(t, ErrUnknownSyncStart, err)
+
+	// busy
+	atomic.StoreInt32(&local.downloader.syncing, 1)
+	assert.Equal(t, ErrSyncBusy, local.downloader.Synchronise(master, 1, 20))
+	assert.True(t, local.SyncStatus().Syncing)
+}
+
+func signSyncConfirm(key *ecdsa.PrivateKey, hash common.Hash) types.SignData {
+	var confirm types.SignData
+	sig, _ := crypto.Sign(hash[:], key)
+	copy(confirm[:], sig)
+	return confirm
+}
+
+func TestDownloader_verifyConfirms(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	blocks := makeSyncBlocks(key, 1)
+	pm := newSyncTestPm(key, blocks[:1])
+	defer pm.Stop()
+	block := blocks[1]
+
+	confirm := signSyncConfirm(key, block.Hash())
+	block.Confirms = []types.SignData{confirm}
+	assert.NoError(t, pm.downloader.verifyConfirms(block))
+	// duplicate confirm
+	block.Confirms = []types.SignData{confirm, confirm}
+	assert.Equal(t, ErrInvalidBodies, pm.downloader.verifyConfirms(block))
+	// not signed by deputy
+	block.Confirms = []types.SignData{signSyncConfirm(otherKey, block.Hash())}
+	assert.Equal(t, ErrInvalidBodies, pm.downloader.verifyConfirms(block))
+	// bad signature
+	block.Confirms = []types.SignData{{0x1}}
+	assert.Equal(t, ErrInvalidBodies, pm.downloader.verifyConfirms(block))
+}
+
+func TestDownloader_verifyDeferred(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	blocks := makeSyncBlocks(key, 1)
+	pm := newSyncTestPm(key, blocks[:1])
+	defer pm.Stop()
+	d := pm.downloader
+
+	// the term of block is unknown
+	header := &types.Header{MinerAddress: crypto.PubkeyToAddress(key.PublicKey), Height: params.TermDuration * 3}
+	hash := header.Hash()
+	header.SignData, _ = crypto.Sign(hash[:], key)
+	block := &types.Block{Header: header, Confirms: []types.SignData{signSyncConfirm(key, hash)}}
+	assert.Equal(t, ErrUnknownTerm, d.verifySigner(header))
+	assert.Equal(t, ErrUnknownTerm, d.verifyConfirms(block))
+	assert.NoError(t, d.deferUnknownTerm(hash, d.verifySigner(header)))
+	// still unknown before insert
+	assert.Equal(t, ErrUnknownTerm, d.verifyDeferred(block))
+	// not deferred
+	assert.NoError(t, d.verifyDeferred(block))
+	assert.NoError(t, d.verifyDeferred(blocks[1]))
+	assert.Equal(t, ErrInvalidHeaders, d.deferUnknownTerm(hash, ErrInvalidHeaders))
+}
+
+func TestDownloader_Deliver(t *testing.T) {
+	pm := createPm()
+	p := newPeer(&testPeer{})
+	// not requested
+	assert.False(t, pm.downloader.Deliver(p, p2p.HeadersMsg, []*types.Header{}))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		// wrong response type
+		assert.False(t, pm.downloader.Deliver(p, p2p.BodiesMsg, []*BlockBody{}))
+		assert.True(t, pm.downloader.Deliver(p, p2p.HeadersMsg, []*types.Header{{Height: 1}}))
+	}()
+	headers, err := pm.downloader.requestHeaders(p, 1, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(headers))
+
+	// timeout
+	oldTimeout := fetchTimeout
+	fetchTimeout = 50 * time.Millisecond
+	defer func() { fetchTimeout = oldTimeout }()
+	_, err = pm.downloader.requestBodies(p, []common.Hash{{0x01}})
+	assert.Equal(t, ErrFetchTimeout, err)
+	close(pm.quitCh)
+}
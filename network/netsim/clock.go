@@ -0,0 +1,34 @@
+package netsim
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a virtual clock shared by the simulated nodes. It only moves when Advance is called, so the mine slots and block times are deterministic
+type Clock struct {
+	now  time.Time
+	lock sync.RWMutex
+}
+
+// NewClock creates a virtual clock which starts at the given time
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current virtual time
+func (c *Clock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.now
+}
+
+// Advance moves the clock forward
+func (c *Clock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
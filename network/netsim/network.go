@@ -0,0 +1,317 @@
+// Package netsim runs several full nodes in one process. The nodes are connected by in-memory pipes, so the tests can control the latency, partitions and clock skew between them
+package netsim
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/chain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/consensus"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidNodeIndex = errors.New("invalid node index")
+	ErrLinkExist        = errors.New("the nodes are connected already")
+	ErrLinkNotExist     = errors.New("the nodes are not connected")
+	ErrMineFailed       = errors.New("mine block failed")
+)
+
+const (
+	DefaultNodeCount   = 3
+	DefaultMineTimeout = 10000 // milliseconds
+	DefaultChainID     = 200
+
+	txProcessTimeout = 1000 // milliseconds
+)
+
+// Config is the setting of a simulated network
+type Config struct {
+	NodeCount   int           // the count of nodes. All of them are deputy nodes in genesis
+	MineTimeout uint64        // the mine slot of each deputy in milliseconds
+	Latency     time.Duration // the default delay of messages between nodes
+}
+
+// Network is a group of simulated nodes
+type Network struct {
+	config Config
+	clock  *Clock
+	nodes  []*Node
+
+	links     map[[2]int]*link
+	latencies map[[2]int]time.Duration
+	groups    map[int]int // the partition group of each node. The nodes in different groups can't reach each other
+	lock      sync.RWMutex
+
+	wg sync.WaitGroup // the events being sent to nodes
+}
+
+// NewNetwork creates the nodes with a shared genesis block and starts them. The nodes are not connected yet
+func NewNetwork(config Config) (*Network, error) {
+	if config.NodeCount <= 0 {
+		config.NodeCount = DefaultNodeCount
+	}
+	if config.MineTimeout == 0 {
+		config.MineTimeout = DefaultMineTimeout
+	}
+
+	keys := make([]*ecdsa.PrivateKey, config.NodeCount)
+	deputies := make([]*chain.CandidateInfo, config.NodeCount)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		deputies[i] = &chain.CandidateInfo{
+			MinerAddress:  address,
+			IncomeAddress: address,
+			NodeID:        crypto.PrivateKeyToNodeID(key),
+			Host:          "127.0.0.1",
+			Port:          strconv.Itoa(7001 + i),
+			Introduction:  fmt.Sprintf("netsim node %d", i),
+		}
+	}
+	genesis := &chain.Genesis{
+		Time:            uint32(time.Now().Unix()),
+		ExtraData:       "netsim",
+		GasLimit:        params.GenesisGasLimit,
+		Founder:         deputies[0].MinerAddress,
+		DeputyNodesInfo: deputies,
+	}
+
+	n := &Network{
+		config:    config,
+		clock:     NewClock(time.Unix(int64(genesis.Time), 0)),
+		links:     make(map[[2]int]*link),
+		latencies: make(map[[2]int]time.Duration),
+		groups:    make(map[int]int),
+	}
+	for i, key := range keys {
+		node, err := newNode(n, i, key, genesis)
+		if err != nil {
+			n.Stop()
+			return nil, err
+		}
+		n.nodes = append(n.nodes, node)
+	}
+	return n, nil
+}
+
+// Clock returns the virtual clock of network
+func (n *Network) Clock() *Clock {
+	return n.clock
+}
+
+// Node returns the node by index
+func (n *Network) Node(i int) *Node {
+	return n.nodes[i]
+}
+
+// Nodes returns all nodes
+func (n *Network) Nodes() []*Node {
+	return n.nodes
+}
+
+func (n *Network) checkIndex(indexes ...int) error {
+	for _, i := range indexes {
+		if i < 0 || i >= len(n.nodes) {
+			return ErrInvalidNodeIndex
+		}
+	}
+	return nil
+}
+
+// linkKey returns the key of link between node i and node j
+func linkKey(i, j int) [2]int {
+	if i > j {
+		i, j = j, i
+	}
+	return [2]int{i, j}
+}
+
+// Connect creates a link between node i and node j, then the protocol managers of them do handshake
+func (n *Network) Connect(i, j int) error {
+	if err := n.checkIndex(i, j); err != nil || i == j {
+		return ErrInvalidNodeIndex
+	}
+	n.lock.Lock()
+	key := linkKey(i, j)
+	if _, ok := n.links[key]; ok {
+		n.lock.Unlock()
+		return ErrLinkExist
+	}
+	l := newLink(n, n.nodes[i], n.nodes[j])
+	n.links[key] = l
+	n.lock.Unlock()
+
+	n.nodes[i].Route.Send(subscribe.AddNewPeer, l.ends[0])
+	n.nodes[j].Route.Send(subscribe.AddNewPeer, l.ends[1])
+	return nil
+}
+
+// ConnectAll connects every two nodes
+func (n *Network) ConnectAll() {
+	for i := range n.nodes {
+		for j := i + 1; j < len(n.nodes); j++ {
+			_ = n.Connect(i, j)
+		}
+	}
+}
+
+// Disconnect closes the link between node i and node j
+func (n *Network) Disconnect(i, j int) error {
+	n.lock.RLock()
+	l, ok := n.links[linkKey(i, j)]
+	n.lock.RUnlock()
+	if !ok {
+		return ErrLinkNotExist
+	}
+	l.close()
+	n.wg.Wait()
+	return nil
+}
+
+func (n *Network) removeLink(l *link) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.links, linkKey(l.ends[0].local.Index, l.ends[1].local.Index))
+}
+
+// sendAsync tells the node that the peer is dropped. It may be called in the loops of protocol manager, so don't wait for it
+func (n *Network) sendAsync(node *Node, p *pipePeer) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		node.Route.Send(subscribe.DeletePeer, p)
+	}()
+}
+
+// SetLatency sets the delay of messages between node i and node j
+func (n *Network) SetLatency(i, j int, latency time.Duration) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.latencies[linkKey(i, j)] = latency
+}
+
+// Latency returns the delay of messages between node i and node j
+func (n *Network) Latency(i, j int) time.Duration {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if latency, ok := n.latencies[linkKey(i, j)]; ok {
+		return latency
+	}
+	return n.config.Latency
+}
+
+// SetSkew sets the difference between the node's clock and the virtual clock
+func (n *Network) SetSkew(i int, skew time.Duration) {
+	n.nodes[i].setSkew(skew)
+}
+
+// Partition splits the nodes into groups. The messages between different groups are lost. The nodes not in any group are in a group together
+func (n *Network) Partition(groups ...[]int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.groups = make(map[int]int)
+	for g, group := range groups {
+		for _, i := range group {
+			n.groups[i] = g + 1
+		}
+	}
+}
+
+// Heal removes the partitions
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+// reachable returns true if the messages from node a can reach node b
+func (n *Network) reachable(a, b *Node) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return n.groups[a.Index] == n.groups[b.Index]
+}
+
+// AdvanceToSlot moves the virtual clock to the start of the next mine window of node i. The window is calculated on the current block of node i
+func (n *Network) AdvanceToSlot(i int) error {
+	if err := n.checkIndex(i); err != nil {
+		return err
+	}
+	node := n.nodes[i]
+	parent := node.Chain.CurrentBlock().Header
+	nextHeight := parent.Height + 1
+	deputy := node.DM.GetMyDeputyInfo(nextHeight)
+	if deputy == nil {
+		return ErrMineFailed
+	}
+	distance, err := node.DM.GetMinerDistance(nextHeight, parent.MinerAddress, deputy.MinerAddress)
+	if err != nil {
+		return err
+	}
+	now := node.Now().UnixNano() / 1e6
+	windowFrom, _ := consensus.GetNextMineWindow(nextHeight, distance, int64(parent.Time)*1000, now, int64(n.config.MineTimeout), node.DM)
+	n.clock.Advance(time.Duration(windowFrom-now) * time.Millisecond)
+	return nil
+}
+
+// Mine mines a block on node i at the current virtual time, then broadcasts it
+func (n *Network) Mine(i int) (*types.Block, error) {
+	if err := n.checkIndex(i); err != nil {
+		return nil, err
+	}
+	node := n.nodes[i]
+	parent := node.Chain.CurrentBlock()
+	node.Chain.MineBlock(txProcessTimeout)
+	block := node.Chain.CurrentBlock()
+	if block.ParentHash() != parent.Hash() || block.MinerAddress() != node.MinerAddress {
+		return nil, ErrMineFailed
+	}
+	return block, nil
+}
+
+// MineNext waits for the mine window of node i on the virtual clock, then mines a block on it
+func (n *Network) MineNext(i int) (*types.Block, error) {
+	if err := n.AdvanceToSlot(i); err != nil {
+		return nil, err
+	}
+	return n.Mine(i)
+}
+
+// WaitFor polls the condition until it is true. It returns false if the condition is still false after timeout
+func (n *Network) WaitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Stop closes all links and nodes, and removes their data
+func (n *Network) Stop() {
+	n.lock.RLock()
+	links := make([]*link, 0, len(n.links))
+	for _, l := range n.links {
+		links = append(links, l)
+	}
+	n.lock.RUnlock()
+	for _, l := range links {
+		l.close()
+	}
+	n.wg.Wait()
+	for _, node := range n.nodes {
+		node.stop()
+	}
+}
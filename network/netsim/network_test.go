@@ -0,0 +1,173 @@
+package netsim
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const waitTimeout = 20 * time.Second
+
+func newTestNetwork(t *testing.T, config Config) *Network {
+	n, err := NewNetwork(config)
+	assert.NoError(t, err)
+	n.ConnectAll()
+	assert.True(t, n.WaitFor(waitTimeout, func() bool {
+		for _, node := range n.Nodes() {
+			if node.PeerCount() != len(n.Nodes())-1 {
+				return false
+			}
+		}
+		return true
+	}))
+	return n
+}
+
+// sameHead returns true if the current blocks of the nodes are the block with the hash
+func sameHead(n *Network, height uint32, indexes ...int) bool {
+	hash := n.Node(indexes[0]).Chain.CurrentBlock().Hash()
+	for _, i := range indexes {
+		current := n.Node(i).Chain.CurrentBlock()
+		if current.Height() != height || current.Hash() != hash {
+			return false
+		}
+	}
+	return true
+}
+
+func stableAt(n *Network, height uint32, indexes ...int) bool {
+	for _, i := range indexes {
+		if n.Node(i).Chain.StableBlock().Height() < height {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewClock(start)
+	assert.Equal(t, start, c.Now())
+	c.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), c.Now())
+	// can't go back
+	c.Advance(-time.Minute)
+	assert.Equal(t, start.Add(time.Second), c.Now())
+}
+
+func TestNetwork_Connect(t *testing.T) {
+	n := newTestNetwork(t, Config{})
+	defer n.Stop()
+
+	assert.Equal(t, ErrLinkExist, n.Connect(0, 1))
+	assert.Equal(t, ErrInvalidNodeIndex, n.Connect(0, 0))
+	assert.Equal(t, ErrInvalidNodeIndex, n.Connect(0, 3))
+
+	assert.NoError(t, n.Disconnect(0, 1))
+	assert.Equal(t, ErrLinkNotExist, n.Disconnect(0, 1))
+	assert.True(t, n.WaitFor(waitTimeout, func() bool {
+		return n.Node(0).PeerCount() == 1 && n.Node(1).PeerCount() == 1
+	}))
+	assert.NoError(t, n.Connect(1, 0))
+	assert.True(t, n.WaitFor(waitTimeout, func() bool {
+		return n.Node(0).PeerCount() == 2 && n.Node(1).PeerCount() == 2
+	}))
+}
+
+func TestNetwork_confirm(t *testing.T) {
+	n := newTestNetwork(t, Config{Latency: 10 * time.Millisecond})
+	defer n.Stop()
+
+	// every deputy mines a block in turn
+	for height := uint32(1); height <= 3; height++ {
+		miner := int(height-1) % 3
+		block, err := n.MineNext(miner)
+		assert.NoError(t, err)
+		assert.Equal(t, height, block.Height())
+		assert.Equal(t, uint32(n.Node(miner).Now().Unix()), block.Time())
+		assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, height, 0, 1, 2) }))
+	}
+	// the blocks become stable after the confirms from other deputies arrive
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return stableAt(n, 3, 0, 1, 2) }))
+
+	// not in turn
+	_, err := n.Mine(1)
+	assert.Equal(t, ErrMineFailed, err)
+}
+
+func TestNetwork_forkSwitch(t *testing.T) {
+	n := newTestNetwork(t, Config{})
+	defer n.Stop()
+
+	for i := 0; i < 3; i++ {
+		_, err := n.MineNext(i)
+		assert.NoError(t, err)
+		assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, uint32(i+1), 0, 1, 2) }))
+	}
+
+	// node 0 is isolated. It mines a block which the majority never receive
+	n.Partition([]int{0}, []int{1, 2})
+	minority, err := n.MineNext(0)
+	assert.NoError(t, err)
+	// the majority have enough deputies to make their blocks stable
+	_, err = n.MineNext(1)
+	assert.NoError(t, err)
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, 4, 1, 2) }))
+	_, err = n.MineNext(2)
+	assert.NoError(t, err)
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, 5, 1, 2) && stableAt(n, 5, 1, 2) }))
+	assert.Equal(t, minority.Hash(), n.Node(0).Chain.CurrentBlock().Hash())
+	assert.Equal(t, uint32(3), n.Node(0).Chain.StableBlock().Height())
+
+	// node 0 switches to the majority fork after the partition is healed
+	n.Heal()
+	_, err = n.MineNext(1)
+	assert.NoError(t, err)
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, 6, 0, 1, 2) }))
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return stableAt(n, 6, 0, 1, 2) }))
+	assert.False(t, n.Node(0).Chain.HasBlock(minority.Hash()))
+}
+
+func TestNetwork_clockSkew(t *testing.T) {
+	n := newTestNetwork(t, Config{})
+	defer n.Stop()
+
+	// the block from a node whose clock is too fast is rejected
+	n.SetSkew(0, time.Minute)
+	block, err := n.MineNext(0)
+	assert.NoError(t, err)
+	assert.False(t, n.WaitFor(time.Second, func() bool {
+		return n.Node(1).Chain.HasBlock(block.Hash()) || n.Node(2).Chain.HasBlock(block.Hash())
+	}))
+
+	// a small skew is tolerated
+	n.SetSkew(1, time.Second)
+	_, err = n.MineNext(1)
+	assert.NoError(t, err)
+	assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, 1, 1, 2) }))
+}
+
+func TestNetwork_termChange(t *testing.T) {
+	termDuration, interimDuration := params.TermDuration, params.InterimDuration
+	params.TermDuration, params.InterimDuration = 6, 2
+	defer func() {
+		params.TermDuration, params.InterimDuration = termDuration, interimDuration
+	}()
+	n := newTestNetwork(t, Config{})
+	defer n.Stop()
+
+	// the snapshot block at height 6 must be stable before the new term starts at height 9
+	for height := uint32(1); height <= 10; height++ {
+		_, err := n.MineNext(int(height-1) % 3)
+		assert.NoError(t, err)
+		assert.True(t, n.WaitFor(waitTimeout, func() bool { return sameHead(n, height, 0, 1, 2) }))
+	}
+	for _, node := range n.Nodes() {
+		term, err := node.DM.GetTermByHeight(10, true)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), term.TermIndex)
+		assert.Equal(t, 3, len(term.Nodes))
+		assert.NotNil(t, node.DM.GetMyDeputyInfo(10))
+	}
+}
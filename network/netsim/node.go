@@ -0,0 +1,113 @@
+package netsim
+
+import (
+	"crypto/ecdsa"
+	"github.com/LemoFoundationLtd/lemochain-core/chain"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/txpool"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/flag"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
+	"github.com/LemoFoundationLtd/lemochain-core/network"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/protocol"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Node is a full node in the simulated network. Each node has its own database, node key, event bus and clock skew
+type Node struct {
+	Index        int
+	Key          *ecdsa.PrivateKey
+	NodeID       p2p.NodeID
+	MinerAddress common.Address
+
+	DB     protocol.ChainDB
+	DM     *deputynode.Manager
+	TxPool *txpool.TxPool
+	Chain  *chain.BlockChain
+	PM     *network.ProtocolManager
+	Route  *subscribe.CentralRouteSub
+
+	net     *Network
+	skew    int64 // nanoseconds
+	dataDir string
+}
+
+func newNode(net *Network, index int, key *ecdsa.PrivateKey, genesis *chain.Genesis) (*Node, error) {
+	dataDir, err := ioutil.TempDir("", "netsim")
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Index:        index,
+		Key:          key,
+		NodeID:       p2p.PubKeyToNodeID(&key.PublicKey),
+		MinerAddress: crypto.PubkeyToAddress(key.PublicKey),
+		Route:        subscribe.NewCentralRouteSub(),
+		net:          net,
+		dataDir:      dataDir,
+	}
+
+	node.DB = store.NewChainDataBase(dataDir)
+	chain.SetupGenesisBlock(node.DB, genesis)
+	node.DM = deputynode.NewManager(len(genesis.DeputyNodesInfo), node.DB)
+	node.DM.SetSelfNodeKey(key)
+	node.TxPool = txpool.NewTxPool()
+	chainCfg := chain.Config{
+		ChainID:     DefaultChainID,
+		MineTimeout: net.config.MineTimeout,
+		Route:       node.Route,
+		Clock:       node.Now,
+	}
+	node.Chain, err = chain.NewBlockChain(chainCfg, node.DM, node.DB, flag.CmdFlags{}, node.TxPool)
+	if err != nil {
+		node.stop()
+		return nil, err
+	}
+	discover := p2p.NewDiscoverManager(dataDir)
+	node.PM = network.NewProtocolManager(DefaultChainID, node.NodeID, node.Chain, node.DM, node.TxPool, node.Chain.TxGuard(), discover, len(genesis.DeputyNodesInfo), params.VersionUint(), dataDir)
+	node.PM.SetRoute(node.Route)
+	node.PM.Start()
+	return node, nil
+}
+
+// Now returns the time on the node's clock
+func (n *Node) Now() time.Time {
+	return n.net.clock.Now().Add(time.Duration(atomic.LoadInt64(&n.skew)))
+}
+
+func (n *Node) setSkew(skew time.Duration) {
+	atomic.StoreInt64(&n.skew, int64(skew))
+}
+
+// PeerCount returns the count of peers which have finished handshake
+func (n *Node) PeerCount() int {
+	if n.PM == nil {
+		return 0
+	}
+	return len(n.PM.PeerScores())
+}
+
+func (n *Node) stop() {
+	if n.PM != nil {
+		n.PM.Stop()
+	}
+	if n.Chain != nil {
+		n.Chain.Stop()
+	}
+	if n.DB != nil {
+		if err := n.DB.Close(); err != nil {
+			log.Errorf("close db fail: %v", err)
+		}
+	}
+	if err := os.RemoveAll(n.dataDir); err != nil {
+		log.Errorf("remove data fail: %v", err)
+	}
+}
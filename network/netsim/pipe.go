@@ -0,0 +1,190 @@
+package netsim
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrPipeClosed = errors.New("pipe is closed")
+
+// outboxSize is the max count of messages which are written but not delivered yet
+const outboxSize = 1024
+
+// envelope is a message on the way to the remote node
+type envelope struct {
+	msg       *p2p.Msg
+	deliverAt time.Time
+}
+
+// link is an in-memory connection between two nodes
+type link struct {
+	net    *Network
+	ends   [2]*pipePeer
+	closed chan struct{}
+	once   sync.Once
+}
+
+// newLink creates a connection between node a and node b. The two ends of link are the peers for a and b
+func newLink(net *Network, a, b *Node) *link {
+	l := &link{net: net, closed: make(chan struct{})}
+	l.ends[0] = newPipePeer(l, a, b)
+	l.ends[1] = newPipePeer(l, b, a)
+	l.ends[0].other = l.ends[1]
+	l.ends[1].other = l.ends[0]
+	go l.ends[0].deliverLoop()
+	go l.ends[1].deliverLoop()
+	return l
+}
+
+// close shuts down both ends and notifies the protocol managers of both nodes
+func (l *link) close() {
+	l.once.Do(func() {
+		close(l.closed)
+		l.net.removeLink(l)
+		for _, end := range l.ends {
+			l.net.sendAsync(end.local, end)
+		}
+	})
+}
+
+// pipePeer is one end of link. It implements p2p.IPeer
+type pipePeer struct {
+	link   *link
+	local  *Node
+	remote *Node
+	other  *pipePeer
+
+	inbox  chan *p2p.Msg
+	outbox chan envelope
+
+	status int32
+	caps   p2p.Capabilities
+	lock   sync.Mutex
+}
+
+func newPipePeer(l *link, local, remote *Node) *pipePeer {
+	return &pipePeer{
+		link:   l,
+		local:  local,
+		remote: remote,
+		inbox:  make(chan *p2p.Msg),
+		outbox: make(chan envelope, outboxSize),
+	}
+}
+
+// deliverLoop moves the written messages to the remote end after the latency. The messages are delivered in order
+func (p *pipePeer) deliverLoop() {
+	for {
+		select {
+		case <-p.link.closed:
+			return
+		case env := <-p.outbox:
+			if wait := time.Until(env.deliverAt); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-p.link.closed:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			// the message is lost if the nodes are partitioned when it arrives
+			if !p.link.net.reachable(p.local, p.remote) {
+				continue
+			}
+			env.msg.ReceivedAt = time.Now()
+			select {
+			case <-p.link.closed:
+				return
+			case p.other.inbox <- env.msg:
+			}
+		}
+	}
+}
+
+func (p *pipePeer) ReadMsg() (*p2p.Msg, error) {
+	select {
+	case <-p.link.closed:
+		return nil, io.EOF
+	case msg := <-p.inbox:
+		return msg, nil
+	}
+}
+
+// WriteMsg sends the message to the remote node. The message is lost silently if the nodes are partitioned
+func (p *pipePeer) WriteMsg(code p2p.MsgCode, msg []byte) error {
+	select {
+	case <-p.link.closed:
+		return ErrPipeClosed
+	default:
+	}
+	if !p.link.net.reachable(p.local, p.remote) {
+		return nil
+	}
+	env := envelope{
+		msg:       &p2p.Msg{Code: code, Content: append([]byte(nil), msg...)},
+		deliverAt: time.Now().Add(p.link.net.Latency(p.local.Index, p.remote.Index)),
+	}
+	select {
+	case <-p.link.closed:
+		return ErrPipeClosed
+	case p.outbox <- env:
+		return nil
+	}
+}
+
+func (p *pipePeer) SetWriteDeadline(duration time.Duration) {}
+
+func (p *pipePeer) RNodeID() *p2p.NodeID {
+	id := p.remote.NodeID
+	return &id
+}
+
+func (p *pipePeer) RAddress() string {
+	return fmt.Sprintf("netsim-%d", p.remote.Index)
+}
+
+func (p *pipePeer) LAddress() string {
+	return fmt.Sprintf("netsim-%d", p.local.Index)
+}
+
+// DoHandshake does nothing because the node ids are known when the link is created
+func (p *pipePeer) DoHandshake(prv *ecdsa.PrivateKey, nodeID *p2p.NodeID) error {
+	return nil
+}
+
+// Run blocks until the link is closed
+func (p *pipePeer) Run() error {
+	<-p.link.closed
+	return nil
+}
+
+func (p *pipePeer) NeedReConnect() bool {
+	return false
+}
+
+func (p *pipePeer) SetStatus(status int32) {
+	atomic.StoreInt32(&p.status, status)
+}
+
+func (p *pipePeer) SetCapabilities(caps p2p.Capabilities) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.caps = caps
+}
+
+func (p *pipePeer) Capabilities() p2p.Capabilities {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.caps
+}
+
+func (p *pipePeer) Close() {
+	p.link.close()
+}
@@ -0,0 +1,58 @@
+package p2p
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+)
+
+const (
+	ProtocolV1 uint32 = 1 // the protocol of the nodes which don't negotiate capabilities
+	ProtocolV2 uint32 = 2 // add the messages for headers-first synchronization and state snapshot synchronization
+
+	CurrentProtocol = ProtocolV2
+)
+
+// Capabilities are the features of frame layer which are negotiated in protocol handshake
+type Capabilities struct {
+	ProtocolVersion uint32
+	Snappy          bool   // whether the message content could be compressed by snappy
+	MaxMsgSize      uint32 // the max length of frame which could be received
+}
+
+// LocalCapabilities returns the capabilities supported by this node
+func LocalCapabilities() Capabilities {
+	return Capabilities{
+		ProtocolVersion: CurrentProtocol,
+		Snappy:          true,
+		MaxMsgSize:      params.MaxPackageLength,
+	}
+}
+
+// BaseCapabilities returns the capabilities of the nodes which don't negotiate capabilities. It is used before the handshake finished
+func BaseCapabilities() Capabilities {
+	return Capabilities{
+		ProtocolVersion: ProtocolV1,
+		Snappy:          false,
+		MaxMsgSize:      params.MaxPackageLength,
+	}
+}
+
+// Negotiate returns the capabilities supported by both sides
+func (c Capabilities) Negotiate(remote Capabilities) Capabilities {
+	result := c
+	if remote.ProtocolVersion < result.ProtocolVersion {
+		result.ProtocolVersion = remote.ProtocolVersion
+	}
+	if result.ProtocolVersion < ProtocolV1 {
+		result.ProtocolVersion = ProtocolV1
+	}
+	result.Snappy = c.Snappy && remote.Snappy
+	if remote.MaxMsgSize != 0 && remote.MaxMsgSize < result.MaxMsgSize {
+		result.MaxMsgSize = remote.MaxMsgSize
+	}
+	return result
+}
+
+// Supports returns true if the message code could be sent to remote
+func (c Capabilities) Supports(code MsgCode) bool {
+	return code.ProtocolVersion() <= c.ProtocolVersion
+}
@@ -0,0 +1,37 @@
+package p2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCapabilities_Negotiate(t *testing.T) {
+	local := LocalCapabilities()
+	assert.Equal(t, local, local.Negotiate(LocalCapabilities()))
+
+	// old node
+	result := local.Negotiate(BaseCapabilities())
+	assert.Equal(t, ProtocolV1, result.ProtocolVersion)
+	assert.False(t, result.Snappy)
+	assert.Equal(t, local.MaxMsgSize, result.MaxMsgSize)
+
+	// newer node with smaller message size
+	result = local.Negotiate(Capabilities{ProtocolVersion: CurrentProtocol + 1, Snappy: true, MaxMsgSize: 1024})
+	assert.Equal(t, CurrentProtocol, result.ProtocolVersion)
+	assert.True(t, result.Snappy)
+	assert.Equal(t, uint32(1024), result.MaxMsgSize)
+
+	// invalid capabilities
+	result = local.Negotiate(Capabilities{})
+	assert.Equal(t, ProtocolV1, result.ProtocolVersion)
+	assert.Equal(t, local.MaxMsgSize, result.MaxMsgSize)
+}
+
+func TestCapabilities_Supports(t *testing.T) {
+	assert.True(t, BaseCapabilities().Supports(BlocksMsg))
+	assert.True(t, BaseCapabilities().Supports(GetBlocksWithChangeLogMsg))
+	assert.False(t, BaseCapabilities().Supports(GetHeadersMsg))
+	assert.False(t, BaseCapabilities().Supports(NodeDataMsg))
+	assert.True(t, LocalCapabilities().Supports(GetHeadersMsg))
+	assert.True(t, LocalCapabilities().Supports(NodeDataMsg))
+}
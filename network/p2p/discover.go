@@ -15,6 +15,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
@@ -40,6 +41,7 @@ type RawNode struct {
 	IsReconnect bool
 	ConnCounter int8
 	Sequence    int32 // fresh(已连接): >0; stale(连接过并失败): <0; connecting(可以连接): 0
+	BanExpire   int64 // the unix time when the ban of black node expires. 0 means the node is banned forever
 }
 
 func newRawNode(nodeID *NodeID, endpoint string) *RawNode {
@@ -385,11 +387,16 @@ func (m *DiscoverManager) IsBlackNode(nodeID *NodeID) bool {
 	}
 }
 
-// getBlackNode
+// getBlackNode get the black node which is still banned. The expired one is removed
 func (m *DiscoverManager) getBlackNode(key common.Hash) *RawNode {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	return m.blackNodes[key]
+	n, ok := m.blackNodes[key]
+	if ok && n.BanExpire != 0 && n.BanExpire <= time.Now().Unix() {
+		delete(m.blackNodes, key)
+		return nil
+	}
+	return n
 }
 
 // PutBlackNode
@@ -397,12 +404,33 @@ func (m *DiscoverManager) PutBlackNode(nodeID *NodeID, endpoint string) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	key := nodeID.Hash()
-	if _, ok := m.blackNodes[key]; ok {
+	if n, ok := m.blackNodes[key]; ok {
+		// ban forever
+		n.BanExpire = 0
 		return
 	}
 	m.blackNodes[key] = newRawNode(nodeID, endpoint)
 }
 
+// BanNode put a node into black list for a while
+func (m *DiscoverManager) BanNode(nodeID *NodeID, endpoint string, duration time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := nodeID.Hash()
+	expire := time.Now().Add(duration).Unix()
+	if n, ok := m.blackNodes[key]; ok {
+		// don't shorten the ban
+		if n.BanExpire != 0 && n.BanExpire < expire {
+			n.BanExpire = expire
+		}
+		return
+	}
+	n := newRawNode(nodeID, endpoint)
+	n.BanExpire = expire
+	m.blackNodes[key] = n
+	log.Infof("Ban node %s until %s", common.ToHex(nodeID[:4]), time.Unix(expire, 0).Format(time.RFC3339))
+}
+
 // initBlackList set black list nodes
 func (m *DiscoverManager) initBlackList() {
 	path := filepath.Join(m.dataDir, BlackFile)
@@ -420,11 +448,13 @@ func (m *DiscoverManager) initBlackList() {
 	}
 }
 
-// writeBlackListToFile
+// writeBlackListToFile write the nodes which are banned forever to file
 func (m *DiscoverManager) writeBlackListToFile() {
 	list := make([]string, 0, MaxNodeCount)
 	for _, node := range m.blackNodes {
-		list = append(list, node.String())
+		if node.BanExpire == 0 {
+			list = append(list, node.String())
+		}
 	}
 	m.writeToFile(list, BlackFile)
 }
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func writeFile(file string, context string) {
@@ -634,6 +635,33 @@ func TestDiscoverManager_PutBlackNode_IsBlackNode(t *testing.T) {
 	}
 }
 
+func TestDiscoverManager_BanNode(t *testing.T) {
+	dis := newDiscover()
+	dis.BanNode(table[0].n, table[0].v, time.Minute)
+	assert.Equal(t, true, dis.IsBlackNode(table[0].n))
+	// the ban can't be shortened
+	dis.BanNode(table[0].n, table[0].v, -time.Minute)
+	assert.Equal(t, true, dis.IsBlackNode(table[0].n))
+
+	// expired ban
+	dis.BanNode(table[1].n, table[1].v, -time.Minute)
+	assert.Equal(t, false, dis.IsBlackNode(table[1].n))
+	assert.Nil(t, dis.blackNodes[table[1].k])
+
+	// the node in black list file is banned forever
+	dis.BanNode(table[2].n, table[2].v, -time.Minute)
+	dis.PutBlackNode(table[2].n, table[2].v)
+	assert.Equal(t, true, dis.IsBlackNode(table[2].n))
+	dis.BanNode(table[2].n, table[2].v, -time.Minute)
+	assert.Equal(t, true, dis.IsBlackNode(table[2].n))
+
+	// only the nodes banned forever are written to file
+	dis.writeBlackListToFile()
+	list := readFile(filepath.Join(dis.dataDir, BlackFile))
+	assert.Equal(t, []string{table[2].n.String() + "@" + table[2].v}, list)
+	removeFile(BlackFile)
+}
+
 func Test_Start_err(t *testing.T) {
 	dis := newDiscover()
 	assert.NoError(t, dis.Start())
@@ -16,6 +16,7 @@ var (
 	ErrAlreadyRunning     = errors.New("has already running")
 	ErrNilPrvKey          = errors.New("privateKey can't be nil")
 	ErrLengthOverflow     = errors.New("net stream package length too long")
+	ErrUnsupportedMsg     = errors.New("message code is not supported by remote")
 
 	ErrRlpDecode = errors.New("rlp decode failed")
 
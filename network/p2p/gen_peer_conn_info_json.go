@@ -12,11 +12,13 @@ func (p PeerConnInfo) MarshalJSON() ([]byte, error) {
 		LocalAddr  string `json:"localAddress"`
 		RemoteAddr string `json:"remoteAddress"`
 		NodeID     string `json:"remoteNodeID"`
+		Score      int32  `json:"score"`
 	}
 	var enc PeerConnInfo
 	enc.LocalAddr = p.LocalAddr
 	enc.RemoteAddr = p.RemoteAddr
 	enc.NodeID = p.NodeID
+	enc.Score = p.Score
 	return json.Marshal(&enc)
 }
 
@@ -26,6 +28,7 @@ func (p *PeerConnInfo) UnmarshalJSON(input []byte) error {
 		LocalAddr  *string `json:"localAddress"`
 		RemoteAddr *string `json:"remoteAddress"`
 		NodeID     *string `json:"remoteNodeID"`
+		Score      *int32  `json:"score"`
 	}
 	var dec PeerConnInfo
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -40,5 +43,8 @@ func (p *PeerConnInfo) UnmarshalJSON(input []byte) error {
 	if dec.NodeID != nil {
 		p.NodeID = *dec.NodeID
 	}
+	if dec.Score != nil {
+		p.Score = *dec.Score
+	}
 	return nil
 }
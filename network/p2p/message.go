@@ -30,8 +30,47 @@ const (
 
 	// for lemochain-server and light node
 	GetBlocksWithChangeLogMsg MsgCode = 0x0e
+
+	// for headers-first synchronization
+	GetHeadersMsg MsgCode = 0x0f // get block headers message
+	HeadersMsg    MsgCode = 0x10 // block headers message
+	GetBodiesMsg  MsgCode = 0x11 // get block bodies message
+	BodiesMsg     MsgCode = 0x12 // block bodies message
+
+	// for state snapshot synchronization
+	GetAccountsMsg MsgCode = 0x13 // get accounts in state snapshot message
+	AccountsMsg    MsgCode = 0x14 // accounts in state snapshot message
+	GetNodeDataMsg MsgCode = 0x15 // get trie nodes or contract codes message
+	NodeDataMsg    MsgCode = 0x16 // trie nodes or contract codes message
 )
 
+// ProtocolVersion returns the version of protocol which introduces the message code
+func (code MsgCode) ProtocolVersion() uint32 {
+	if code >= GetHeadersMsg {
+		return ProtocolV2
+	}
+	return ProtocolV1
+}
+
+// IsRequest returns true if the message asks the remote peer to respond something
+func (code MsgCode) IsRequest() bool {
+	switch code {
+	case GetLstStatusMsg, GetBlocksMsg, GetConfirmsMsg, DiscoverReqMsg, GetBlocksWithChangeLogMsg, GetHeadersMsg, GetBodiesMsg, GetAccountsMsg, GetNodeDataMsg:
+		return true
+	}
+	return false
+}
+
+// ParseRequestMsgCode returns the request message code by its name, e.g. "GetBlocksMsg"
+func ParseRequestMsgCode(name string) (MsgCode, bool) {
+	for code := HeartbeatMsg; code <= NodeDataMsg; code++ {
+		if code.IsRequest() && code.String() == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
 type Msg struct {
 	Code       MsgCode
 	Content    []byte
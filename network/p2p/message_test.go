@@ -48,3 +48,20 @@ func Test_CheckCode(t *testing.T) {
 	msg.Code = MsgCode(100000)
 	assert.Equal(t, false, msg.CheckCode())
 }
+
+func Test_ParseRequestMsgCode(t *testing.T) {
+	code, ok := ParseRequestMsgCode("GetBlocksMsg")
+	assert.True(t, ok)
+	assert.Equal(t, GetBlocksMsg, code)
+	code, ok = ParseRequestMsgCode("GetNodeDataMsg")
+	assert.True(t, ok)
+	assert.Equal(t, GetNodeDataMsg, code)
+
+	// not request
+	_, ok = ParseRequestMsgCode("BlocksMsg")
+	assert.False(t, ok)
+	_, ok = ParseRequestMsgCode("ConfirmMsg")
+	assert.False(t, ok)
+	_, ok = ParseRequestMsgCode("NotExistMsg")
+	assert.False(t, ok)
+}
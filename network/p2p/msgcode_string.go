@@ -22,11 +22,19 @@ func _() {
 	_ = x[DiscoverReqMsg-12]
 	_ = x[DiscoverResMsg-13]
 	_ = x[GetBlocksWithChangeLogMsg-14]
+	_ = x[GetHeadersMsg-15]
+	_ = x[HeadersMsg-16]
+	_ = x[GetBodiesMsg-17]
+	_ = x[BodiesMsg-18]
+	_ = x[GetAccountsMsg-19]
+	_ = x[AccountsMsg-20]
+	_ = x[GetNodeDataMsg-21]
+	_ = x[NodeDataMsg-22]
 }
 
-const _MsgCode_name = "HeartbeatMsgProHandshakeMsgLstStatusMsgGetLstStatusMsgBlockHashMsgTxsMsgGetBlocksMsgBlocksMsgConfirmMsgGetConfirmsMsgConfirmsMsgDiscoverReqMsgDiscoverResMsgGetBlocksWithChangeLogMsg"
+const _MsgCode_name = "HeartbeatMsgProHandshakeMsgLstStatusMsgGetLstStatusMsgBlockHashMsgTxsMsgGetBlocksMsgBlocksMsgConfirmMsgGetConfirmsMsgConfirmsMsgDiscoverReqMsgDiscoverResMsgGetBlocksWithChangeLogMsgGetHeadersMsgHeadersMsgGetBodiesMsgBodiesMsgGetAccountsMsgAccountsMsgGetNodeDataMsgNodeDataMsg"
 
-var _MsgCode_index = [...]uint8{0, 12, 27, 39, 54, 66, 72, 84, 93, 103, 117, 128, 142, 156, 181}
+var _MsgCode_index = [...]uint16{0, 12, 27, 39, 54, 66, 72, 84, 93, 103, 117, 128, 142, 156, 181, 194, 204, 216, 225, 239, 250, 264, 275}
 
 func (i MsgCode) String() string {
 	i -= 1
@@ -11,6 +11,7 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common/mclock"
 	"github.com/LemoFoundationLtd/lemochain-core/common/subscribe"
 	"github.com/LemoFoundationLtd/lemochain-core/metrics"
+	"github.com/golang/snappy"
 	"io"
 	"net"
 	"sync"
@@ -26,6 +27,11 @@ const (
 	StatusDifferentGenesis
 )
 
+const (
+	compressedFlag    = uint32(1 << 31) // the flag in message code which means the content is compressed
+	compressThreshold = 1024            // the message content shorter than it is not compressed
+)
+
 var (
 	readMsgSuccessTimer  = metrics.NewTimer(metrics.ReadMsgSuccess_timerName)  // 统计成功读取msg的timer
 	readMsgFailedTimer   = metrics.NewTimer(metrics.ReadMsgFailed_timerName)   // 统计读取msg失败的timer
@@ -44,6 +50,8 @@ type IPeer interface {
 	Run() (err error)
 	NeedReConnect() bool
 	SetStatus(status int32)
+	SetCapabilities(caps Capabilities)
+	Capabilities() Capabilities
 	Close()
 }
 
@@ -54,6 +62,7 @@ type Peer struct {
 	aes           []byte // AES key
 	created       mclock.AbsTime
 	writeDeadline time.Duration
+	caps          Capabilities // the capabilities negotiated with remote
 
 	status   int32
 	wmu      sync.Mutex
@@ -68,6 +77,7 @@ func NewPeer(fd net.Conn) IPeer {
 		conn:          fd,
 		created:       mclock.Now(),
 		writeDeadline: frameWriteTimeout,
+		caps:          BaseCapabilities(),
 		// closed:   false,
 		newMsgCh: make(chan *Msg, 10),
 		stopCh:   make(chan struct{}),
@@ -247,11 +257,17 @@ func (p *Peer) WriteMsg(code MsgCode, msg []byte) (err error) {
 		}
 	}()
 
+	if !p.caps.Supports(code) {
+		return ErrUnsupportedMsg
+	}
 	// pack message frame
 	buf, err := p.packFrame(code, msg)
 	if err != nil {
 		return err
 	}
+	if uint32(len(buf)-len(PackagePrefix)-PackageLength) > p.caps.MaxMsgSize {
+		return ErrLengthOverflow
+	}
 	p.conn.SetWriteDeadline(time.Now().Add(p.writeDeadline))
 	_, err = p.conn.Write(buf)
 	p.writeDeadline = frameWriteTimeout
@@ -267,6 +283,20 @@ func (p *Peer) SetWriteDeadline(duration time.Duration) {
 	p.writeDeadline = duration
 }
 
+// SetCapabilities sets the capabilities negotiated with remote. They affect the messages written after it
+func (p *Peer) SetCapabilities(caps Capabilities) {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.caps = caps
+}
+
+// Capabilities returns the capabilities negotiated with remote
+func (p *Peer) Capabilities() Capabilities {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	return p.caps
+}
+
 // RNodeID
 func (p *Peer) RNodeID() *NodeID {
 	return &p.rNodeID
@@ -319,9 +349,17 @@ func (p *Peer) heartbeatLoop() {
 
 // packFrame pack message to net stream
 func (p *Peer) packFrame(code MsgCode, msg []byte) ([]byte, error) {
+	// compress the large message if remote supports. The flag is set in message code, so the receiver doesn't depend on the negotiation
+	flag := uint32(0)
+	if p.caps.Snappy && len(msg) >= compressThreshold {
+		if compressed := snappy.Encode(nil, msg); len(compressed) < len(msg) {
+			msg = compressed
+			flag = compressedFlag
+		}
+	}
 	// message code to bytes
 	buf := make([]byte, 4)
-	binary.BigEndian.PutUint32(buf, uint32(code))
+	binary.BigEndian.PutUint32(buf, uint32(code)|flag)
 	// combine code and message buffer
 	if msg != nil {
 		buf = append(buf, msg...)
@@ -348,11 +386,29 @@ func (p *Peer) unpackFrame(content []byte) (MsgCode, []byte, error) {
 	if err != nil {
 		return 0, nil, err
 	}
+	if len(originData) < 4 {
+		return 0, nil, ErrUnavailablePackage
+	}
 	code := binary.BigEndian.Uint32(originData[:4])
 	if len(originData) == 4 {
-		return MsgCode(code), nil, nil
+		return MsgCode(code &^ compressedFlag), nil, nil
+	}
+	if code&compressedFlag == 0 {
+		return MsgCode(code), originData[4:], nil
+	}
+	// decompress
+	length, err := snappy.DecodedLen(originData[4:])
+	if err != nil {
+		return 0, nil, err
+	}
+	if length > int(params.MaxPackageLength) {
+		return 0, nil, ErrLengthOverflow
+	}
+	buf, err := snappy.Decode(nil, originData[4:])
+	if err != nil {
+		return 0, nil, err
 	}
-	return MsgCode(code), originData[4:], nil
+	return MsgCode(code &^ compressedFlag), buf, nil
 }
 
 // SetStatus set peer's status
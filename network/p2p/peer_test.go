@@ -145,3 +145,39 @@ func Test_Chan(t *testing.T) {
 		fmt.Println("ok")
 	}
 }
+
+func Test_compressMsg(t *testing.T) {
+	pCli, pSrv := newPeers(t)
+	defer pCli.Close()
+	defer pSrv.Close()
+
+	// the new message code is not supported before negotiation
+	assert.Equal(t, ErrUnsupportedMsg, pCli.WriteMsg(GetHeadersMsg, nil))
+
+	pCli.SetCapabilities(LocalCapabilities())
+	small := []byte{0x01, 0x02, 0x03}
+	large := bytes.Repeat([]byte{0x01, 0x02, 0x03}, 1000)
+	for _, buf := range [][]byte{small, large} {
+		go func(buf []byte) {
+			assert.NoError(t, pCli.WriteMsg(GetHeadersMsg, buf))
+		}(buf)
+		msg, err := pSrv.ReadMsg()
+		assert.NoError(t, err)
+		assert.Equal(t, GetHeadersMsg, msg.Code)
+		assert.Equal(t, buf, msg.Content)
+	}
+
+	// the frame is compressed
+	p := pCli.(*Peer)
+	frame, err := p.packFrame(BlocksMsg, large)
+	assert.NoError(t, err)
+	assert.True(t, len(frame) < len(large))
+	code, content, err := p.unpackFrame(frame[len(PackagePrefix)+PackageLength:])
+	assert.NoError(t, err)
+	assert.Equal(t, BlocksMsg, code)
+	assert.Equal(t, large, content)
+
+	// remote can't receive so large message
+	pCli.SetCapabilities(Capabilities{ProtocolVersion: CurrentProtocol, MaxMsgSize: 100})
+	assert.Equal(t, ErrLengthOverflow, pCli.WriteMsg(BlocksMsg, large))
+}
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"net"
+)
+
+var (
+	ErrInvalidRecordSig = errors.New("invalid signature of node record")
+	ErrRecordIPNotMatch = errors.New("the IP of node record doesn't match the packet source")
+)
+
+// NodeRecord is the endpoint information of a node. It is signed by the node key, so the node id is recovered from the signature
+type NodeRecord struct {
+	Seq uint64 // increase it when the record changes. The record with bigger sequence is newer
+	IP  net.IP // an unspecified IP means the IP which the record is received from
+	TCP uint16
+	UDP uint16
+	Sig []byte
+}
+
+// NewNodeRecord creates a node record signed by the private key
+func NewNodeRecord(prv *ecdsa.PrivateKey, seq uint64, ip net.IP, tcp, udp uint16) (*NodeRecord, error) {
+	r := &NodeRecord{Seq: seq, IP: normalizeIP(ip), TCP: tcp, UDP: udp}
+	hash, err := r.sigHash()
+	if err != nil {
+		return nil, err
+	}
+	if r.Sig, err = crypto.Sign(hash, prv); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// sigHash returns the hash of record content for signing
+func (r *NodeRecord) sigHash() ([]byte, error) {
+	content, err := rlp.EncodeToBytes([]interface{}{r.Seq, r.IP, r.TCP, r.UDP})
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(content), nil
+}
+
+// Verify checks the signature of record and returns the id of the node which signs it
+func (r *NodeRecord) Verify() (*NodeID, error) {
+	if len(r.Sig) != sigLen {
+		return nil, ErrInvalidRecordSig
+	}
+	hash, err := r.sigHash()
+	if err != nil {
+		return nil, err
+	}
+	pub, err := crypto.SigToPub(hash, r.Sig)
+	if err != nil {
+		return nil, ErrInvalidRecordSig
+	}
+	id := PubKeyToNodeID(pub)
+	return &id, nil
+}
+
+// resolveIP returns the IP of record. The unspecified IP is replaced by the IP which the record is received from
+func (r *NodeRecord) resolveIP(from net.IP) (net.IP, error) {
+	if len(r.IP) == 0 || r.IP.IsUnspecified() {
+		return normalizeIP(from), nil
+	}
+	if !r.IP.Equal(from) {
+		return nil, ErrRecordIPNotMatch
+	}
+	return r.IP, nil
+}
+
+// String returns the record in "NodeID@IP:Port" format. The node id is empty if the signature is invalid
+func (r *NodeRecord) String() string {
+	id, err := r.Verify()
+	if err != nil {
+		return fmt.Sprintf("@%s:%d", r.IP, r.TCP)
+	}
+	return fmt.Sprintf("%s@%s:%d", id.String(), r.IP, r.TCP)
+}
+
+// normalizeIP uses the 4 bytes form of IPv4 address to shorten the packet
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
@@ -0,0 +1,53 @@
+package p2p
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestNodeRecord_Verify(t *testing.T) {
+	prv, _ := crypto.GenerateKey()
+	r, err := NewNodeRecord(prv, 1, net.ParseIP("127.0.0.1"), 7001, 7002)
+	assert.NoError(t, err)
+	id, err := r.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, PubKeyToNodeID(&prv.PublicKey), *id)
+	assert.Equal(t, id.String()+"@127.0.0.1:7001", r.String())
+
+	// encode and decode
+	buf, err := rlp.EncodeToBytes(r)
+	assert.NoError(t, err)
+	var decoded NodeRecord
+	assert.NoError(t, rlp.DecodeBytes(buf, &decoded))
+	id, err = decoded.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, PubKeyToNodeID(&prv.PublicKey), *id)
+
+	// tampered endpoint
+	decoded.TCP = 8001
+	id, err = decoded.Verify()
+	if err == nil {
+		assert.NotEqual(t, PubKeyToNodeID(&prv.PublicKey), *id)
+	}
+	decoded.Sig = decoded.Sig[1:]
+	_, err = decoded.Verify()
+	assert.Equal(t, ErrInvalidRecordSig, err)
+}
+
+func TestNodeRecord_resolveIP(t *testing.T) {
+	prv, _ := crypto.GenerateKey()
+	from := net.ParseIP("10.0.0.1")
+	r, _ := NewNodeRecord(prv, 1, nil, 7001, 7001)
+	ip, err := r.resolveIP(from)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip.String())
+
+	r, _ = NewNodeRecord(prv, 1, net.ParseIP("10.0.0.1"), 7001, 7001)
+	_, err = r.resolveIP(from)
+	assert.NoError(t, err)
+	_, err = r.resolveIP(net.ParseIP("10.0.0.2"))
+	assert.Equal(t, ErrRecordIPNotMatch, err)
+}
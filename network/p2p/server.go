@@ -30,9 +30,10 @@ var (
 
 // Config holds Server options.
 type Config struct {
-	Name       string            // server's Name
-	PrivateKey *ecdsa.PrivateKey // private key
-	Port       int               // listen port
+	Name        string            // server's Name
+	PrivateKey  *ecdsa.PrivateKey // private key
+	Port        int               // listen port
+	NoDiscovery bool              // disable the UDP node discovery, so that only the known nodes are dialed
 }
 
 // listenAddr fetch listen address
@@ -57,6 +58,7 @@ type Server struct {
 	newPeer func(net.Conn) IPeer
 
 	discover    *DiscoverManager // node discovery
+	udp         *UDPDiscovery    // find nodes by Kademlia protocol over UDP
 	dialManager IDialManager     // node dial
 	wg          sync.WaitGroup
 }
@@ -102,6 +104,9 @@ func (srv *Server) Start() error {
 	if err := srv.discover.Start(); err != nil {
 		log.Warnf("Discover.start: %v", err)
 	}
+	if !srv.NoDiscovery {
+		srv.startDiscovery()
+	}
 
 	// start dial task
 	go func() {
@@ -114,6 +119,22 @@ func (srv *Server) Start() error {
 	return nil
 }
 
+// startDiscovery starts UDP discovery on the same port. The known nodes are used to join the network
+func (srv *Server) startDiscovery() {
+	udp, err := ListenUDP(DiscoveryConfig{
+		PrivateKey: srv.PrivateKey,
+		ListenAddr: srv.listenAddr(),
+		TCPPort:    srv.Port,
+		BootNodes:  srv.discover.getAvailableNodes(),
+		Found:      srv.discover.AddNewList,
+	})
+	if err != nil {
+		log.Warnf("Start UDP discovery failed: %v", err)
+		return
+	}
+	srv.udp = udp
+}
+
 // Stop
 func (srv *Server) Stop() {
 	if !atomic.CompareAndSwapInt32(&srv.running, 1, 0) {
@@ -132,6 +153,9 @@ func (srv *Server) Stop() {
 		p.Close()
 	}
 	// stop discover
+	if srv.udp != nil {
+		srv.udp.Close()
+	}
 	if err := srv.discover.Stop(); err != nil {
 		log.Errorf("Discover stop failed: %v", err)
 	}
@@ -309,6 +333,7 @@ type PeerConnInfo struct {
 	LocalAddr  string `json:"localAddress"`
 	RemoteAddr string `json:"remoteAddress"`
 	NodeID     string `json:"remoteNodeID"`
+	Score      int32  `json:"score"` // reputation score of remote peer. It is filled by protocol manager
 }
 
 // Connections get total connections for api
@@ -318,7 +343,7 @@ func (srv *Server) Connections() []PeerConnInfo {
 
 	result := make([]PeerConnInfo, 0, len(srv.connectedNodes))
 	for _, v := range srv.connectedNodes {
-		info := PeerConnInfo{v.LAddress(), v.RAddress(), v.RNodeID().String()}
+		info := PeerConnInfo{LocalAddr: v.LAddress(), RemoteAddr: v.RAddress(), NodeID: v.RNodeID().String()}
 		result = append(result, info)
 	}
 	return result
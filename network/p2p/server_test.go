@@ -74,6 +74,11 @@ func (p *testPeer) NeedReConnect() bool {
 func (p *testPeer) SetStatus(status int32) {
 	return
 }
+func (p *testPeer) SetCapabilities(caps Capabilities) {
+}
+func (p *testPeer) Capabilities() Capabilities {
+	return LocalCapabilities()
+}
 func (p *testPeer) Close() {
 
 }
@@ -236,3 +241,22 @@ func Test_Start_error(t *testing.T) {
 	srv.PrivateKey = prvSrv
 	assert.Panics(t, func() { srv.Start() })
 }
+
+func Test_Start_noDiscovery(t *testing.T) {
+	server := initServer(7017)
+	server.NoDiscovery = true
+	server.dialManager = &testDialManger{}
+	assert.NoError(t, server.Start())
+	assert.Nil(t, server.udp)
+	// the UDP port is not occupied
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: 7017})
+	assert.NoError(t, err)
+	conn.Close()
+	server.Stop()
+
+	server = initServer(7018)
+	server.dialManager = &testDialManger{}
+	assert.NoError(t, server.Start())
+	assert.NotNil(t, server.udp)
+	server.Stop()
+}
@@ -0,0 +1,227 @@
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"math/bits"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	bucketSize        = 16 // the max count of nodes in one bucket
+	hashBits          = len(common.Hash{}) * 8
+	nBuckets          = hashBits / 15       // the count of buckets
+	bucketMinDistance = hashBits - nBuckets // the nodes whose log distance is not greater than it are in the first bucket
+)
+
+// tableNode is a node whose endpoint has been verified by ping-pong
+type tableNode struct {
+	record  *NodeRecord
+	id      NodeID
+	hash    common.Hash // the hash of node id. It is used to calculate XOR distance
+	ip      net.IP      // the IP which is verified by ping-pong
+	addedAt time.Time
+}
+
+// udpAddr returns the discovery endpoint of node
+func (n *tableNode) udpAddr() *net.UDPAddr {
+	return &net.UDPAddr{IP: n.ip, Port: int(n.record.UDP)}
+}
+
+// String returns the node in "NodeID@IP:Port" format. The port is the TCP port for p2p connection
+func (n *tableNode) String() string {
+	return fmt.Sprintf("%s@%s:%d", n.id.String(), n.ip, n.record.TCP)
+}
+
+// Table is a Kademlia routing table. The nodes are put into buckets by the XOR distance between node id hashes
+type Table struct {
+	self     NodeID
+	selfHash common.Hash
+	buckets  [nBuckets][]*tableNode // the most recently seen node is at the front of bucket
+
+	lock sync.Mutex
+}
+
+// newTable creates an empty table for the self node
+func newTable(self NodeID) *Table {
+	return &Table{
+		self:     self,
+		selfHash: self.Hash(),
+	}
+}
+
+// logDist returns the logarithmic distance between a and b, log2(a ^ b)
+func logDist(a, b common.Hash) int {
+	lz := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			lz += 8
+		} else {
+			lz += bits.LeadingZeros8(x)
+			break
+		}
+	}
+	return len(a)*8 - lz
+}
+
+// distCmp compares the distances a->target and b->target. Returns -1 if a is closer to target, 1 if b is closer to target and 0 if they are equal
+func distCmp(target, a, b common.Hash) int {
+	for i := range target {
+		da := a[i] ^ target[i]
+		db := b[i] ^ target[i]
+		if da > db {
+			return 1
+		} else if da < db {
+			return -1
+		}
+	}
+	return 0
+}
+
+// bucketIndex returns the index of the bucket for the node id hash
+func (t *Table) bucketIndex(hash common.Hash) int {
+	d := logDist(t.selfHash, hash)
+	if d <= bucketMinDistance {
+		return 0
+	}
+	return d - bucketMinDistance - 1
+}
+
+// add puts a verified node at the front of its bucket. It returns false if the bucket is full
+func (t *Table) add(n *tableNode) bool {
+	if n.id == t.self {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	index := t.bucketIndex(n.hash)
+	b := t.buckets[index]
+	for i, item := range b {
+		if item.id == n.id {
+			// keep the newer record
+			if n.record.Seq < item.record.Seq {
+				n.record = item.record
+			}
+			n.addedAt = item.addedAt
+			copy(b[1:i+1], b[:i])
+			b[0] = n
+			return true
+		}
+	}
+	if len(b) >= bucketSize {
+		return false
+	}
+	n.addedAt = time.Now()
+	t.buckets[index] = append([]*tableNode{n}, b...)
+	return true
+}
+
+// remove deletes the node from table
+func (t *Table) remove(id NodeID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	index := t.bucketIndex(id.Hash())
+	b := t.buckets[index]
+	for i, item := range b {
+		if item.id == id {
+			t.buckets[index] = append(b[:i:i], b[i+1:]...)
+			return
+		}
+	}
+}
+
+// get returns the node by id, or nil if it is not in table
+func (t *Table) get(id NodeID) *tableNode {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, item := range t.buckets[t.bucketIndex(id.Hash())] {
+		if item.id == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// getByRecord returns the node which has the same record signature, or nil if it is not in table. It saves the signature recovery for the known records
+func (t *Table) getByRecord(r *NodeRecord) *tableNode {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, b := range t.buckets {
+		for _, item := range b {
+			if bytes.Equal(item.record.Sig, r.Sig) {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// closest returns at most count nodes which are closest to the target
+func (t *Table) closest(target common.Hash, count int) []*tableNode {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := make([]*tableNode, 0, count)
+	for _, b := range t.buckets {
+		result = append(result, b...)
+	}
+	sortByDistance(result, target)
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
+
+// leastRecent returns the least recently seen node in the bucket, or nil if the bucket is empty
+func (t *Table) leastRecent(index int) *tableNode {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b := t.buckets[index]
+	if len(b) == 0 {
+		return nil
+	}
+	return b[len(b)-1]
+}
+
+// Len returns the count of nodes in table
+func (t *Table) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	count := 0
+	for _, b := range t.buckets {
+		count += len(b)
+	}
+	return count
+}
+
+// Nodes returns all nodes in table in "NodeID@IP:Port" format
+func (t *Table) Nodes() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := make([]string, 0, MaxNodeCount)
+	for _, b := range t.buckets {
+		for _, n := range b {
+			result = append(result, n.String())
+		}
+	}
+	return result
+}
+
+// sortByDistance sorts the nodes by their distance to target
+func sortByDistance(nodes []*tableNode, target common.Hash) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return distCmp(target, nodes[i].hash, nodes[j].hash) < 0
+	})
+}
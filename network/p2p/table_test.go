@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func newTestTableNode(t *testing.T) *tableNode {
+	prv, _ := crypto.GenerateKey()
+	r, err := NewNodeRecord(prv, 1, net.ParseIP("127.0.0.1"), 7001, 7001)
+	assert.NoError(t, err)
+	id := PubKeyToNodeID(&prv.PublicKey)
+	return &tableNode{record: r, id: id, hash: id.Hash(), ip: r.IP}
+}
+
+func Test_logDist(t *testing.T) {
+	assert.Equal(t, 0, logDist(common.Hash{}, common.Hash{}))
+	assert.Equal(t, 1, logDist(common.Hash{}, common.Hash{31: 0x01}))
+	assert.Equal(t, 8, logDist(common.Hash{31: 0x80}, common.Hash{31: 0x01}))
+	assert.Equal(t, 256, logDist(common.Hash{0: 0x80}, common.Hash{}))
+
+	target := common.Hash{}
+	assert.Equal(t, -1, distCmp(target, common.Hash{31: 0x01}, common.Hash{31: 0x02}))
+	assert.Equal(t, 1, distCmp(target, common.Hash{0: 0x01}, common.Hash{31: 0x02}))
+	assert.Equal(t, 0, distCmp(target, common.Hash{0: 0x01}, common.Hash{0: 0x01}))
+}
+
+func TestTable_add(t *testing.T) {
+	self := newTestTableNode(t)
+	tab := newTable(self.id)
+	assert.Equal(t, false, tab.add(self))
+
+	n := newTestTableNode(t)
+	assert.Equal(t, true, tab.add(n))
+	assert.Equal(t, 1, tab.Len())
+	assert.Equal(t, n, tab.get(n.id))
+	assert.Equal(t, []string{n.id.String() + "@127.0.0.1:7001"}, tab.Nodes())
+
+	// the older record doesn't replace the newer one
+	newer := *n.record
+	newer.Seq = 2
+	assert.Equal(t, true, tab.add(&tableNode{record: &newer, id: n.id, hash: n.hash, ip: n.ip}))
+	assert.Equal(t, true, tab.add(&tableNode{record: n.record, id: n.id, hash: n.hash, ip: n.ip}))
+	assert.Equal(t, uint64(2), tab.get(n.id).record.Seq)
+	assert.Equal(t, 1, tab.Len())
+
+	tab.remove(n.id)
+	assert.Equal(t, 0, tab.Len())
+	assert.Nil(t, tab.get(n.id))
+}
+
+func TestTable_fullBucket(t *testing.T) {
+	tab := newTable(newTestTableNode(t).id)
+	// half of random nodes are in the last bucket
+	newLastBucketNode := func() *tableNode {
+		for {
+			if n := newTestTableNode(t); tab.bucketIndex(n.hash) == nBuckets-1 {
+				return n
+			}
+		}
+	}
+	for i := 0; i < bucketSize; i++ {
+		assert.Equal(t, true, tab.add(newLastBucketNode()))
+	}
+	first := tab.leastRecent(nBuckets - 1)
+	assert.Equal(t, false, tab.add(newLastBucketNode()))
+	assert.Equal(t, bucketSize, tab.Len())
+	// the seen node is moved to the front
+	assert.Equal(t, true, tab.add(first))
+	assert.Equal(t, first, tab.buckets[nBuckets-1][0])
+	assert.NotEqual(t, first, tab.leastRecent(nBuckets-1))
+}
+
+func TestTable_closest(t *testing.T) {
+	tab := newTable(newTestTableNode(t).id)
+	nodes := make([]*tableNode, 0)
+	for i := 0; i < 30; i++ {
+		n := newTestTableNode(t)
+		if tab.add(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	target := newTestTableNode(t).hash
+	closest := tab.closest(target, 5)
+	assert.Equal(t, 5, len(closest))
+	sortByDistance(nodes, target)
+	assert.Equal(t, nodes[:5], closest)
+}
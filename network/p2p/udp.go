@@ -0,0 +1,724 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"net"
+	"sync"
+	"time"
+)
+
+// the types of discovery packet
+const (
+	pingPacket byte = iota + 1
+	pongPacket
+	findnodePacket
+	neighborsPacket
+)
+
+const (
+	maxPacketSize      = 1280                   // the max size of UDP packet
+	maxNeighbors       = 10                     // the max count of records in one neighbors packet, so that the packet size is less than maxPacketSize
+	lookupAlpha        = 3                      // the count of concurrent findnode requests in one lookup
+	maxFindnodeFails   = 3                      // the node is removed from table if its findnode requests timeout continuously
+	packetExpiration   = 20 * time.Second       // the packet received after this time is dropped
+	respTimeout        = 500 * time.Millisecond // the time to wait for the response
+	bondExpiration     = 24 * time.Hour         // the time to trust the endpoint proof
+	refreshInterval    = 5 * time.Minute        // the time to lookup random nodes
+	revalidateInterval = 10 * time.Second       // the time to check whether the least recently seen node is alive
+)
+
+var (
+	ErrPacketTooSmall   = errors.New("discovery packet is too small")
+	ErrBadPacketSig     = errors.New("invalid signature of discovery packet")
+	ErrPacketExpired    = errors.New("discovery packet is expired")
+	ErrUnknownPacket    = errors.New("unknown discovery packet type")
+	ErrUnsolicitedReply = errors.New("unsolicited discovery reply")
+	ErrRecordNotMatch   = errors.New("the node record is not signed by the packet sender")
+	ErrNotBonded        = errors.New("the endpoint of remote node is not verified")
+	ErrDiscoveryTimeout = errors.New("discovery request timeout")
+	ErrDiscoveryClosed  = errors.New("discovery is closed")
+)
+
+type (
+	ping struct {
+		Record     NodeRecord
+		Expiration uint64
+	}
+	pong struct {
+		ReplyTok   common.Hash // the hash of ping packet
+		Record     NodeRecord
+		Expiration uint64
+	}
+	findnode struct {
+		Target     NodeID
+		Expiration uint64
+	}
+	// neighbor is a record with the IP which is verified by the responder. The receiver should ping it to verify the endpoint too
+	neighbor struct {
+		IP     net.IP
+		Record NodeRecord
+	}
+	neighbors struct {
+		Nodes      []neighbor
+		Total      uint32 // the count of nodes in all neighbors packets of this response
+		Expiration uint64
+	}
+)
+
+// DiscoveryConfig holds UDP discovery options
+type DiscoveryConfig struct {
+	PrivateKey *ecdsa.PrivateKey
+	ListenAddr string   // UDP listen address, e.g. ":7001" or "127.0.0.1:0"
+	IP         net.IP   // the IP in node record. Leave it empty to let remote nodes use the IP they received packets from
+	TCPPort    int      // the port of p2p connection
+	BootNodes  []string // the nodes in "NodeID@IP:Port" format to join the network. The port is UDP port
+	// Found is called with the verified nodes in "NodeID@IP:Port" format. The port is TCP port
+	Found func(nodes []string)
+}
+
+// replyMatcher waits for the reply from a node
+type replyMatcher struct {
+	from    NodeID
+	ptype   byte
+	match   func(packet interface{}) (matched bool, done bool)
+	replyCh chan struct{}
+}
+
+// UDPDiscovery finds nodes by Kademlia protocol over UDP. The endpoint of node is verified by ping-pong, and the node record is signed by node key
+type UDPDiscovery struct {
+	conn   *net.UDPConn
+	prv    *ecdsa.PrivateKey
+	self   *NodeRecord
+	selfID NodeID
+	tab    *Table
+	boot   []string
+	found  func(nodes []string)
+
+	matchers []*replyMatcher
+	lastPong map[NodeID]time.Time // the time when remote node replied our ping
+	lastPing map[NodeID]time.Time // the time when we replied remote node's ping
+	fails    map[NodeID]int       // the count of continuous findnode failures
+	lock     sync.Mutex
+
+	quitCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// ListenUDP starts UDP discovery
+func ListenUDP(config DiscoveryConfig) (*UDPDiscovery, error) {
+	if config.PrivateKey == nil {
+		return nil, ErrNilPrvKey
+	}
+	addr, err := net.ResolveUDPAddr("udp", config.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	localAddr := conn.LocalAddr().(*net.UDPAddr)
+	self, err := NewNodeRecord(config.PrivateKey, uint64(time.Now().Unix()), config.IP, uint16(config.TCPPort), uint16(localAddr.Port))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	d := &UDPDiscovery{
+		conn:     conn,
+		prv:      config.PrivateKey,
+		self:     self,
+		selfID:   PubKeyToNodeID(&config.PrivateKey.PublicKey),
+		boot:     config.BootNodes,
+		found:    config.Found,
+		lastPong: make(map[NodeID]time.Time),
+		lastPing: make(map[NodeID]time.Time),
+		fails:    make(map[NodeID]int),
+		quitCh:   make(chan struct{}),
+	}
+	d.tab = newTable(d.selfID)
+	d.wg.Add(2)
+	go d.readLoop()
+	go d.refreshLoop()
+	log.Infof("UDP discovery is listening on %s", localAddr)
+	return d, nil
+}
+
+// Close stops UDP discovery
+func (d *UDPDiscovery) Close() {
+	select {
+	case <-d.quitCh:
+		return
+	default:
+	}
+	close(d.quitCh)
+	_ = d.conn.Close()
+	d.wg.Wait()
+}
+
+// Self returns the record of local node
+func (d *UDPDiscovery) Self() *NodeRecord {
+	return d.self
+}
+
+// LocalAddr returns the UDP listen address
+func (d *UDPDiscovery) LocalAddr() *net.UDPAddr {
+	return d.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Table returns the routing table
+func (d *UDPDiscovery) Table() *Table {
+	return d.tab
+}
+
+// Bootstrap verifies the nodes in "NodeID@IP:Port" format, and adds them to table. The port is UDP port
+func (d *UDPDiscovery) Bootstrap(nodes []string) {
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		id, endpoint := ParseNodeString(node)
+		if id == nil {
+			log.Debugf("Discovery: invalid boot node: %s", node)
+			continue
+		}
+		addr, err := net.ResolveUDPAddr("udp", endpoint)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(id NodeID) {
+			defer wg.Done()
+			if _, err := d.bond(id, addr); err != nil {
+				log.Debugf("Discovery: bond with boot node %s failed: %v", common.ToHex(id[:4]), err)
+			}
+		}(*id)
+	}
+	wg.Wait()
+}
+
+// Lookup finds the nodes which are closest to the target by asking the nodes in table iteratively. The found nodes are verified and added to table
+func (d *UDPDiscovery) Lookup(target NodeID) []string {
+	// rejoin the network if all nodes in table are lost
+	if d.tab.Len() == 0 {
+		d.Bootstrap(d.boot)
+	}
+	targetHash := target.Hash()
+	asked := map[NodeID]bool{d.selfID: true}
+	seen := map[NodeID]bool{d.selfID: true}
+	result := d.tab.closest(targetHash, bucketSize)
+	for _, n := range result {
+		seen[n.id] = true
+	}
+
+	replyCh := make(chan []*tableNode, lookupAlpha)
+	pending := 0
+	for {
+		// ask the closest nodes which are not asked
+		for i := 0; i < len(result) && pending < lookupAlpha; i++ {
+			n := result[i]
+			if asked[n.id] {
+				continue
+			}
+			asked[n.id] = true
+			pending++
+			go func(n *tableNode) {
+				replyCh <- d.queryNeighbors(n, target)
+			}(n)
+		}
+		if pending == 0 {
+			break
+		}
+		select {
+		case nodes := <-replyCh:
+			pending--
+			for _, n := range nodes {
+				if !seen[n.id] {
+					seen[n.id] = true
+					result = append(result, n)
+				}
+			}
+			sortByDistance(result, targetHash)
+			if len(result) > bucketSize {
+				result = result[:bucketSize]
+			}
+		case <-d.quitCh:
+			return nil
+		}
+	}
+
+	list := make([]string, 0, len(result))
+	for _, n := range result {
+		list = append(list, n.String())
+	}
+	return list
+}
+
+// queryNeighbors asks the node for the nodes closest to target, then verifies them by ping-pong
+func (d *UDPDiscovery) queryNeighbors(n *tableNode, target NodeID) []*tableNode {
+	list, err := d.findnode(n.id, n.udpAddr(), target)
+	if err != nil {
+		log.Debugf("Discovery: findnode from %s failed: %v", common.ToHex(n.id[:4]), err)
+		if err == ErrDiscoveryTimeout && d.findnodeFailed(n.id) >= maxFindnodeFails {
+			d.tab.remove(n.id)
+			d.resetFails(n.id)
+		}
+		return nil
+	}
+	d.resetFails(n.id)
+	result := make([]*tableNode, 0, len(list))
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	for _, item := range list {
+		if bytes.Equal(item.Record.Sig, d.self.Sig) {
+			continue
+		}
+		// the node in table has been verified
+		if exist := d.tab.getByRecord(&item.Record); exist != nil {
+			lock.Lock()
+			result = append(result, exist)
+			lock.Unlock()
+			continue
+		}
+		id, err := item.Record.Verify()
+		if err != nil || *id == d.selfID {
+			continue
+		}
+		ip, err := item.Record.resolveIP(item.IP)
+		if err != nil {
+			continue
+		}
+		// the endpoint has been verified recently
+		if d.isBonded(*id) {
+			record := item.Record
+			node := &tableNode{record: &record, id: *id, hash: id.Hash(), ip: normalizeIP(ip)}
+			d.addNode(node)
+			lock.Lock()
+			result = append(result, node)
+			lock.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(id NodeID, addr *net.UDPAddr) {
+			defer wg.Done()
+			if node, err := d.bond(id, addr); err == nil {
+				lock.Lock()
+				result = append(result, node)
+				lock.Unlock()
+			}
+		}(*id, &net.UDPAddr{IP: ip, Port: int(item.Record.UDP)})
+	}
+	wg.Wait()
+	return result
+}
+
+// findnodeFailed increases the failure count of node, then returns the new count
+func (d *UDPDiscovery) findnodeFailed(id NodeID) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.fails[id]++
+	return d.fails[id]
+}
+
+// resetFails clears the failure count of node
+func (d *UDPDiscovery) resetFails(id NodeID) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.fails, id)
+}
+
+// bond verifies the endpoint of node by ping-pong, and makes sure the remote node has verified our endpoint too. Then the node is added to table
+func (d *UDPDiscovery) bond(id NodeID, addr *net.UDPAddr) (*tableNode, error) {
+	// the remote node will ping us back if it doesn't know us
+	pinged := d.expectReply(id, pingPacket, func(interface{}) (bool, bool) { return true, true })
+	defer d.removeMatcher(pinged)
+
+	record, err := d.ping(id, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !d.isPinged(id) {
+		_ = d.waitReply(pinged)
+	}
+	node := &tableNode{record: record, id: id, hash: id.Hash(), ip: normalizeIP(addr.IP)}
+	d.addNode(node)
+	return node, nil
+}
+
+// addNode adds the verified node to table, and notices the new node
+func (d *UDPDiscovery) addNode(node *tableNode) {
+	isNew := d.tab.get(node.id) == nil
+	if d.tab.add(node) && isNew && d.found != nil {
+		d.found([]string{node.String()})
+	}
+}
+
+// ping sends ping packet and waits for the pong. It returns the record of remote node
+func (d *UDPDiscovery) ping(id NodeID, addr *net.UDPAddr) (*NodeRecord, error) {
+	packet, hash, err := d.encodePacket(pingPacket, &ping{Record: *d.self, Expiration: expirationTime()})
+	if err != nil {
+		return nil, err
+	}
+	var record NodeRecord
+	m := d.expectReply(id, pongPacket, func(p interface{}) (bool, bool) {
+		reply := p.(*pong)
+		if !bytes.Equal(reply.ReplyTok[:], hash) {
+			return false, false
+		}
+		record = reply.Record
+		return true, true
+	})
+	defer d.removeMatcher(m)
+	if err := d.send(addr, packet); err != nil {
+		return nil, err
+	}
+	if err := d.waitReply(m); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// findnode sends findnode packet and waits for all neighbors packets
+func (d *UDPDiscovery) findnode(id NodeID, addr *net.UDPAddr, target NodeID) ([]neighbor, error) {
+	packet, _, err := d.encodePacket(findnodePacket, &findnode{Target: target, Expiration: expirationTime()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]neighbor, 0, bucketSize)
+	m := d.expectReply(id, neighborsPacket, func(p interface{}) (bool, bool) {
+		reply := p.(*neighbors)
+		result = append(result, reply.Nodes...)
+		return true, len(result) >= int(reply.Total) || len(result) >= bucketSize
+	})
+	defer d.removeMatcher(m)
+	if err := d.send(addr, packet); err != nil {
+		return nil, err
+	}
+	if err := d.waitReply(m); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// expectReply registers a matcher for the reply from node
+func (d *UDPDiscovery) expectReply(from NodeID, ptype byte, match func(packet interface{}) (bool, bool)) *replyMatcher {
+	m := &replyMatcher{from: from, ptype: ptype, match: match, replyCh: make(chan struct{})}
+	d.lock.Lock()
+	d.matchers = append(d.matchers, m)
+	d.lock.Unlock()
+	return m
+}
+
+// removeMatcher removes the matcher which is not waiting any more
+func (d *UDPDiscovery) removeMatcher(m *replyMatcher) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, item := range d.matchers {
+		if item == m {
+			d.matchers = append(d.matchers[:i], d.matchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// waitReply waits until the matcher is done
+func (d *UDPDiscovery) waitReply(m *replyMatcher) error {
+	timer := time.NewTimer(respTimeout)
+	defer timer.Stop()
+	select {
+	case <-m.replyCh:
+		return nil
+	case <-timer.C:
+		return ErrDiscoveryTimeout
+	case <-d.quitCh:
+		return ErrDiscoveryClosed
+	}
+}
+
+// handleReply passes the packet to the matchers. It returns false if no one is waiting for it
+func (d *UDPDiscovery) handleReply(from NodeID, ptype byte, packet interface{}) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	matched := false
+	for i := 0; i < len(d.matchers); i++ {
+		m := d.matchers[i]
+		if m.from != from || m.ptype != ptype {
+			continue
+		}
+		ok, done := m.match(packet)
+		if !ok {
+			continue
+		}
+		matched = true
+		if done {
+			close(m.replyCh)
+			d.matchers = append(d.matchers[:i], d.matchers[i+1:]...)
+			i--
+		}
+	}
+	return matched
+}
+
+// isBonded returns true if the remote node has replied our ping recently
+func (d *UDPDiscovery) isBonded(id NodeID) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return time.Since(d.lastPong[id]) < bondExpiration
+}
+
+// isPinged returns true if we have replied the ping from remote node recently
+func (d *UDPDiscovery) isPinged(id NodeID) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return time.Since(d.lastPing[id]) < bondExpiration
+}
+
+// readLoop receives and handles the packets
+func (d *UDPDiscovery) readLoop() {
+	defer d.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-d.quitCh:
+				return
+			default:
+			}
+			log.Debugf("Discovery: read UDP packet failed: %v", err)
+			continue
+		}
+		if err := d.handlePacket(from, buf[:n]); err != nil {
+			log.Debugf("Discovery: handle packet from %s failed: %v", from, err)
+		}
+	}
+}
+
+// handlePacket decodes and handles one packet
+func (d *UDPDiscovery) handlePacket(from *net.UDPAddr, buf []byte) error {
+	ptype, packet, fromID, hash, err := decodePacket(buf)
+	if err != nil {
+		return err
+	}
+	switch p := packet.(type) {
+	case *ping:
+		return d.handlePing(from, fromID, hash, p)
+	case *pong:
+		if isExpired(p.Expiration) {
+			return ErrPacketExpired
+		}
+		if id, err := p.Record.Verify(); err != nil || *id != fromID {
+			return ErrRecordNotMatch
+		}
+		if _, err := p.Record.resolveIP(from.IP); err != nil {
+			return err
+		}
+		if !d.handleReply(fromID, ptype, p) {
+			return ErrUnsolicitedReply
+		}
+		// mark the endpoint verified before handling the next packet, which may be a findnode request from the same node
+		d.lock.Lock()
+		d.lastPong[fromID] = time.Now()
+		d.lock.Unlock()
+	case *findnode:
+		return d.handleFindnode(from, fromID, p)
+	case *neighbors:
+		if isExpired(p.Expiration) {
+			return ErrPacketExpired
+		}
+		if !d.handleReply(fromID, ptype, p) {
+			return ErrUnsolicitedReply
+		}
+	}
+	return nil
+}
+
+// handlePing replies pong, and verifies the remote endpoint if it is unknown
+func (d *UDPDiscovery) handlePing(from *net.UDPAddr, fromID NodeID, hash []byte, p *ping) error {
+	if isExpired(p.Expiration) {
+		return ErrPacketExpired
+	}
+	if id, err := p.Record.Verify(); err != nil || *id != fromID {
+		return ErrRecordNotMatch
+	}
+	ip, err := p.Record.resolveIP(from.IP)
+	if err != nil {
+		return err
+	}
+	reply := &pong{Record: *d.self, Expiration: expirationTime()}
+	copy(reply.ReplyTok[:], hash)
+	packet, _, err := d.encodePacket(pongPacket, reply)
+	if err != nil {
+		return err
+	}
+	if err := d.send(from, packet); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	d.lastPing[fromID] = time.Now()
+	d.lock.Unlock()
+	d.handleReply(fromID, pingPacket, p)
+
+	if d.isBonded(fromID) {
+		d.addNode(&tableNode{record: &p.Record, id: fromID, hash: fromID.Hash(), ip: ip})
+	} else {
+		// verify the endpoint by ping
+		go func() {
+			if _, err := d.bond(fromID, &net.UDPAddr{IP: ip, Port: int(p.Record.UDP)}); err != nil {
+				log.Debugf("Discovery: bond with %s failed: %v", common.ToHex(fromID[:4]), err)
+			}
+		}()
+	}
+	return nil
+}
+
+// handleFindnode replies the nodes closest to target. The request is ignored if the endpoint of remote node is not verified, so that the response can't be sent to a victim
+func (d *UDPDiscovery) handleFindnode(from *net.UDPAddr, fromID NodeID, p *findnode) error {
+	if isExpired(p.Expiration) {
+		return ErrPacketExpired
+	}
+	if !d.isBonded(fromID) {
+		return ErrNotBonded
+	}
+	closest := d.tab.closest(p.Target.Hash(), bucketSize)
+	total := uint32(len(closest))
+	for start := 0; start < len(closest) || start == 0; start += maxNeighbors {
+		end := start + maxNeighbors
+		if end > len(closest) {
+			end = len(closest)
+		}
+		reply := &neighbors{Total: total, Expiration: expirationTime()}
+		for _, n := range closest[start:end] {
+			reply.Nodes = append(reply.Nodes, neighbor{IP: n.ip, Record: *n.record})
+		}
+		packet, _, err := d.encodePacket(neighborsPacket, reply)
+		if err != nil {
+			return err
+		}
+		if err := d.send(from, packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshLoop joins the network by boot nodes, then refreshes and revalidates the table periodically
+func (d *UDPDiscovery) refreshLoop() {
+	defer d.wg.Done()
+	d.Lookup(d.selfID)
+
+	refresh := time.NewTicker(refreshInterval)
+	defer refresh.Stop()
+	revalidate := time.NewTicker(revalidateInterval)
+	defer revalidate.Stop()
+	for {
+		select {
+		case <-d.quitCh:
+			return
+		case <-refresh.C:
+			d.Lookup(d.selfID)
+			d.Lookup(randomNodeID())
+		case <-revalidate.C:
+			// retry to join the network
+			if d.tab.Len() == 0 {
+				d.Lookup(d.selfID)
+			}
+			d.revalidate()
+		}
+	}
+}
+
+// revalidate pings the least recently seen node in a random bucket. It is removed if there is no reply
+func (d *UDPDiscovery) revalidate() {
+	var b [1]byte
+	_, _ = rand.Read(b[:])
+	start := int(b[0]) % nBuckets
+	for i := 0; i < nBuckets; i++ {
+		n := d.tab.leastRecent((start + i) % nBuckets)
+		if n == nil {
+			continue
+		}
+		record, err := d.ping(n.id, n.udpAddr())
+		if err != nil {
+			log.Debugf("Discovery: remove dead node %s: %v", common.ToHex(n.id[:4]), err)
+			d.tab.remove(n.id)
+			return
+		}
+		d.tab.add(&tableNode{record: record, id: n.id, hash: n.hash, ip: n.ip})
+		return
+	}
+}
+
+// send writes packet to remote
+func (d *UDPDiscovery) send(to *net.UDPAddr, packet []byte) error {
+	_, err := d.conn.WriteToUDP(packet, to)
+	return err
+}
+
+// encodePacket encodes packet as signature || type || rlp(packet). It returns the packet and its hash
+func (d *UDPDiscovery) encodePacket(ptype byte, packet interface{}) ([]byte, []byte, error) {
+	content, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		return nil, nil, err
+	}
+	content = append([]byte{ptype}, content...)
+	sig, err := crypto.Sign(crypto.Keccak256(content), d.prv)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := append(sig, content...)
+	return buf, crypto.Keccak256(buf), nil
+}
+
+// decodePacket decodes packet, and recovers the sender from signature
+func decodePacket(buf []byte) (byte, interface{}, NodeID, []byte, error) {
+	if len(buf) < sigLen+2 {
+		return 0, nil, NodeID{}, nil, ErrPacketTooSmall
+	}
+	sig, content := buf[:sigLen], buf[sigLen:]
+	pub, err := crypto.SigToPub(crypto.Keccak256(content), sig)
+	if err != nil {
+		return 0, nil, NodeID{}, nil, ErrBadPacketSig
+	}
+	fromID := PubKeyToNodeID(pub)
+
+	var packet interface{}
+	ptype := content[0]
+	switch ptype {
+	case pingPacket:
+		packet = new(ping)
+	case pongPacket:
+		packet = new(pong)
+	case findnodePacket:
+		packet = new(findnode)
+	case neighborsPacket:
+		packet = new(neighbors)
+	default:
+		return 0, nil, NodeID{}, nil, ErrUnknownPacket
+	}
+	if err := rlp.DecodeBytes(content[1:], packet); err != nil {
+		return 0, nil, NodeID{}, nil, err
+	}
+	return ptype, packet, fromID, crypto.Keccak256(buf), nil
+}
+
+func expirationTime() uint64 {
+	return uint64(time.Now().Add(packetExpiration).Unix())
+}
+
+func isExpired(ts uint64) bool {
+	return time.Unix(int64(ts), 0).Before(time.Now())
+}
+
+// randomNodeID generates a random target for lookup
+func randomNodeID() NodeID {
+	var id NodeID
+	_, _ = rand.Read(id[:])
+	return id
+}
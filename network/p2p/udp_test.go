@@ -0,0 +1,186 @@
+package p2p
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDiscovery(t *testing.T, bootNodes []string, found func([]string)) *UDPDiscovery {
+	prv, _ := crypto.GenerateKey()
+	d, err := ListenUDP(DiscoveryConfig{
+		PrivateKey: prv,
+		ListenAddr: "127.0.0.1:0",
+		TCPPort:    7001,
+		BootNodes:  bootNodes,
+		Found:      found,
+	})
+	assert.NoError(t, err)
+	return d
+}
+
+// udpNode returns the discovery endpoint of node in "NodeID@IP:Port" format
+func udpNode(d *UDPDiscovery) string {
+	return d.selfID.String() + "@" + d.LocalAddr().String()
+}
+
+// waitFor checks the condition until it is true or timeout
+func waitFor(condition func() bool) bool {
+	for i := 0; i < 250; i++ {
+		if condition() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+// tableCapacity returns the count of nodes which could be put into the table of d
+func tableCapacity(d *UDPDiscovery, nodes []*UDPDiscovery) int {
+	var counts [nBuckets]int
+	for _, other := range nodes {
+		if other != d {
+			counts[d.Table().bucketIndex(other.selfID.Hash())]++
+		}
+	}
+	result := 0
+	for _, c := range counts {
+		if c > bucketSize {
+			c = bucketSize
+		}
+		result += c
+	}
+	return result
+}
+
+func TestUDPDiscovery_Bootstrap(t *testing.T) {
+	var (
+		found []string
+		lock  sync.Mutex
+	)
+	boot := newTestDiscovery(t, nil, nil)
+	defer boot.Close()
+	d := newTestDiscovery(t, []string{udpNode(boot)}, func(nodes []string) {
+		lock.Lock()
+		found = append(found, nodes...)
+		lock.Unlock()
+	})
+	defer d.Close()
+
+	// both sides verify the endpoint of each other
+	assert.True(t, waitFor(func() bool { return d.Table().get(boot.selfID) != nil }))
+	assert.True(t, waitFor(func() bool { return boot.Table().get(d.selfID) != nil }))
+	assert.True(t, d.isBonded(boot.selfID))
+	assert.True(t, boot.isBonded(d.selfID))
+
+	// the verified node is fed with TCP port
+	lock.Lock()
+	assert.Equal(t, []string{boot.selfID.String() + "@127.0.0.1:7001"}, found)
+	lock.Unlock()
+	id, endpoint := ParseNodeString(found[0])
+	assert.Equal(t, boot.selfID, *id)
+	assert.Equal(t, "127.0.0.1:7001", endpoint)
+}
+
+func TestUDPDiscovery_Lookup(t *testing.T) {
+	const count = 30
+	boot := newTestDiscovery(t, nil, nil)
+	defer boot.Close()
+	nodes := []*UDPDiscovery{boot}
+	for i := 1; i < count; i++ {
+		d := newTestDiscovery(t, []string{udpNode(boot)}, nil)
+		defer d.Close()
+		nodes = append(nodes, d)
+		// wait for joining the network
+		assert.True(t, waitFor(func() bool { return d.Table().Len() > 0 }))
+	}
+
+	// every node knows enough nodes and is known by others
+	converged := func() bool {
+		known := make(map[NodeID]bool)
+		for _, d := range nodes {
+			if d.Table().Len() < bucketSize/2 {
+				return false
+			}
+			for _, n := range nodes {
+				if d.Table().get(n.selfID) != nil {
+					known[n.selfID] = true
+				}
+			}
+		}
+		return len(known) == count
+	}
+	for round := 0; round < 3 && !converged(); round++ {
+		for _, d := range nodes {
+			d.Lookup(d.selfID)
+		}
+	}
+	assert.True(t, converged())
+	assert.Equal(t, tableCapacity(boot, nodes), boot.Table().Len())
+
+	// find a node which is not in table
+	for _, d := range nodes[1:] {
+		target := nodes[count-1]
+		if d == target || d.Table().get(target.selfID) != nil {
+			continue
+		}
+		result := d.Lookup(target.selfID)
+		assert.Equal(t, target.selfID.String()+"@127.0.0.1:7001", result[0])
+		break
+	}
+}
+
+func TestUDPDiscovery_handlePacket(t *testing.T) {
+	d := newTestDiscovery(t, nil, nil)
+	defer d.Close()
+	remote := newTestDiscovery(t, nil, nil)
+	defer remote.Close()
+	from := remote.LocalAddr()
+
+	// the record is signed by another node
+	prv, _ := crypto.GenerateKey()
+	record, _ := NewNodeRecord(prv, 1, nil, 7001, uint16(from.Port))
+	packet, _, err := remote.encodePacket(pingPacket, &ping{Record: *record, Expiration: expirationTime()})
+	assert.NoError(t, err)
+	assert.Equal(t, ErrRecordNotMatch, d.handlePacket(from, packet))
+
+	// the IP of record doesn't match the source
+	record, _ = NewNodeRecord(remote.prv, 1, net.ParseIP("10.0.0.1"), 7001, uint16(from.Port))
+	packet, _, _ = remote.encodePacket(pingPacket, &ping{Record: *record, Expiration: expirationTime()})
+	assert.Equal(t, ErrRecordIPNotMatch, d.handlePacket(from, packet))
+
+	// expired
+	packet, _, _ = remote.encodePacket(pingPacket, &ping{Record: *remote.Self(), Expiration: uint64(time.Now().Unix() - 1)})
+	assert.Equal(t, ErrPacketExpired, d.handlePacket(from, packet))
+
+	// the endpoint of requester is not verified
+	packet, _, _ = remote.encodePacket(findnodePacket, &findnode{Target: d.selfID, Expiration: expirationTime()})
+	assert.Equal(t, ErrNotBonded, d.handlePacket(from, packet))
+
+	// unsolicited reply
+	packet, _, _ = remote.encodePacket(neighborsPacket, &neighbors{Expiration: expirationTime()})
+	assert.Equal(t, ErrUnsolicitedReply, d.handlePacket(from, packet))
+
+	// tampered packet
+	packet[sigLen+1]++
+	assert.Error(t, d.handlePacket(from, packet))
+	assert.Equal(t, ErrPacketTooSmall, d.handlePacket(from, packet[:sigLen]))
+	assert.Equal(t, 0, d.Table().Len())
+}
+
+func TestUDPDiscovery_neighborsSize(t *testing.T) {
+	d := newTestDiscovery(t, nil, nil)
+	defer d.Close()
+	reply := &neighbors{Total: bucketSize, Expiration: expirationTime()}
+	for i := 0; i < maxNeighbors; i++ {
+		n := newTestTableNode(t)
+		n.record.IP = net.ParseIP("2001:db8::68")
+		reply.Nodes = append(reply.Nodes, neighbor{IP: n.record.IP, Record: *n.record})
+	}
+	packet, _, err := d.encodePacket(neighborsPacket, reply)
+	assert.NoError(t, err)
+	assert.True(t, len(packet) <= maxPacketSize, "packet size %d", len(packet))
+}
@@ -21,6 +21,8 @@ type ProtocolHandshake struct {
 	GenesisHash  common.Hash
 	NodeVersion  uint32
 	LatestStatus LatestStatus
+	// Capabilities is encoded as another rlp item after the handshake, so the old nodes which don't know it can still decode the handshake
+	Capabilities p2p.Capabilities `rlp:"-"`
 }
 
 // Bytes object to bytes
@@ -29,7 +31,25 @@ func (phs *ProtocolHandshake) Bytes() []byte {
 	if err != nil {
 		return nil
 	}
-	return buf
+	caps, err := rlp.EncodeToBytes(&phs.Capabilities)
+	if err != nil {
+		return nil
+	}
+	return append(buf, caps...)
+}
+
+// decodeCapabilities decodes the capabilities after the handshake. The node which doesn't send it only has base capabilities
+func decodeCapabilities(content []byte) p2p.Capabilities {
+	_, _, rest, err := rlp.Split(content)
+	if err != nil || len(rest) == 0 {
+		return p2p.BaseCapabilities()
+	}
+	var caps p2p.Capabilities
+	if err := rlp.DecodeBytes(rest, &caps); err != nil {
+		log.Debugf("Decode capabilities failed: %v", err)
+		return p2p.BaseCapabilities()
+	}
+	return caps
 }
 
 // LatestStatus latest peer's status
@@ -47,6 +67,8 @@ type peer struct {
 	lstStatus       LatestStatus
 	badSyncCounter  uint32
 	discoverCounter uint32
+	score           int32     // reputation score. It is decreased by misbehavior
+	scoreTime       time.Time // the time when score is updated
 
 	lock sync.RWMutex
 }
@@ -117,6 +139,86 @@ func (p *peer) RequestBlocks(from, to uint32) int {
 	return 0
 }
 
+// RequestHeaders request headers from remote
+func (p *peer) RequestHeaders(from, count, skip uint32) error {
+	msg := &GetHeadersData{From: from, Count: count, Skip: skip}
+	buf, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warnf("RequestHeaders: rlp encode failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurShort)
+	if err = p.conn.WriteMsg(p2p.GetHeadersMsg, buf); err != nil {
+		log.Warnf("RequestHeaders: write message failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RequestBodies request block bodies from remote
+func (p *peer) RequestBodies(hashes []common.Hash) error {
+	msg := &GetBodiesData{Hashes: hashes}
+	buf, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warnf("RequestBodies: rlp encode failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurShort)
+	if err = p.conn.WriteMsg(p2p.GetBodiesMsg, buf); err != nil {
+		log.Warnf("RequestBodies: write message failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RequestAccounts request the accounts in the state of stable block from remote
+func (p *peer) RequestAccounts(blockHash common.Hash, start common.Address, count uint32) error {
+	msg := &GetAccountsData{BlockHash: blockHash, Start: start, Count: count}
+	buf, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warnf("RequestAccounts: rlp encode failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurShort)
+	if err = p.conn.WriteMsg(p2p.GetAccountsMsg, buf); err != nil {
+		log.Warnf("RequestAccounts: write message failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RequestNodeData request trie nodes or contract codes from remote
+func (p *peer) RequestNodeData(hashes []common.Hash) error {
+	msg := &GetNodesData{Hashes: hashes}
+	buf, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warnf("RequestNodeData: rlp encode failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurShort)
+	if err = p.conn.WriteMsg(p2p.GetNodeDataMsg, buf); err != nil {
+		log.Warnf("RequestNodeData: write message failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RequestBlocksWithChangeLog request blocks with change logs from remote
+func (p *peer) RequestBlocksWithChangeLog(from, to uint32) error {
+	msg := &GetBlocksData{From: from, To: to}
+	buf, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warnf("RequestBlocksWithChangeLog: rlp encode failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurShort)
+	if err = p.conn.WriteMsg(p2p.GetBlocksWithChangeLogMsg, buf); err != nil {
+		log.Warnf("RequestBlocksWithChangeLog: write message failed: %v", err)
+		return err
+	}
+	return nil
+}
+
 // Handshake protocol handshake
 func (p *peer) Handshake(content []byte) (*ProtocolHandshake, error) {
 	// write to remote
@@ -145,6 +247,7 @@ func (p *peer) Handshake(content []byte) (*ProtocolHandshake, error) {
 		if err := msg.Decode(&phs); err != nil {
 			return nil, err
 		}
+		phs.Capabilities = decodeCapabilities(msg.Content)
 		return &phs, nil
 	}
 }
@@ -154,6 +257,11 @@ func (p *peer) NodeID() *p2p.NodeID {
 	return p.conn.RNodeID()
 }
 
+// Supports returns true if the message code is supported by remote
+func (p *peer) Supports(code p2p.MsgCode) bool {
+	return p.conn.Capabilities().Supports(code)
+}
+
 // ReadMsg read message from net stream
 func (p *peer) ReadMsg() (*p2p.Msg, error) {
 	return p.conn.ReadMsg()
@@ -250,6 +358,70 @@ func (p *peer) SendBlocks(blocks types.Blocks) error {
 	return nil
 }
 
+// SendHeaders send block headers to remote
+func (p *peer) SendHeaders(headers []*types.Header) error {
+	buf, err := rlp.EncodeToBytes(&headers)
+	if err != nil {
+		log.Warnf("SendHeaders: rlp failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurLong)
+	if err := p.conn.WriteMsg(p2p.HeadersMsg, buf); err != nil {
+		log.Warnf("SendHeaders to peer: %s failed. disconnect. %v", p.NodeID().String()[:16], err)
+		p.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// SendBodies send block bodies to remote
+func (p *peer) SendBodies(bodies []*BlockBody) error {
+	buf, err := rlp.EncodeToBytes(&bodies)
+	if err != nil {
+		log.Warnf("SendBodies: rlp failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurLong)
+	if err := p.conn.WriteMsg(p2p.BodiesMsg, buf); err != nil {
+		log.Warnf("SendBodies to peer: %s failed. disconnect. %v", p.NodeID().String()[:16], err)
+		p.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// SendAccounts send the accounts in state snapshot to remote
+func (p *peer) SendAccounts(accounts []*types.AccountData) error {
+	buf, err := rlp.EncodeToBytes(&accounts)
+	if err != nil {
+		log.Warnf("SendAccounts: rlp failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurLong)
+	if err := p.conn.WriteMsg(p2p.AccountsMsg, buf); err != nil {
+		log.Warnf("SendAccounts to peer: %s failed. disconnect. %v", p.NodeID().String()[:16], err)
+		p.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// SendNodeData send trie nodes or contract codes to remote
+func (p *peer) SendNodeData(data [][]byte) error {
+	buf, err := rlp.EncodeToBytes(&data)
+	if err != nil {
+		log.Warnf("SendNodeData: rlp failed: %v", err)
+		return err
+	}
+	p.conn.SetWriteDeadline(DurLong)
+	if err := p.conn.WriteMsg(p2p.NodeDataMsg, buf); err != nil {
+		log.Warnf("SendNodeData to peer: %s failed. disconnect. %v", p.NodeID().String()[:16], err)
+		p.conn.Close()
+		return err
+	}
+	return nil
+}
+
 // SendConfirms send confirms to remote peer
 func (p *peer) SendConfirms(confirms *BlockConfirms) error {
 	buf, err := rlp.EncodeToBytes(confirms)
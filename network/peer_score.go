@@ -0,0 +1,99 @@
+package network
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"time"
+)
+
+const (
+	// DefaultBanDuration is the default time to ban a peer whose score is too low
+	DefaultBanDuration = 30 * time.Minute
+
+	banScore             = -100            // the peer is banned if its score is not greater than it
+	scoreRecoverInterval = 6 * time.Second // the score recovers 1 point in every interval until it reaches 0
+)
+
+// offence is a kind of misbehavior of remote peer
+type offence struct {
+	name    string
+	penalty int32
+}
+
+var (
+	offenceInvalidBlock      = offence{"invalid block", 40}
+	offenceBadConfirm        = offence{"bad confirm", 20}
+	offenceMalformedMsg      = offence{"malformed message", 50}
+	offenceOversizedResponse = offence{"oversized response", 30}
+	offenceSlowResponse      = offence{"slow response", 5}
+)
+
+// Score returns the reputation score of peer. It is 0 for a well behaved peer
+func (p *peer) Score() int32 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.recoverScore(time.Now())
+	return p.score
+}
+
+// penalise decreases the score by penalty, then returns the new score
+func (p *peer) penalise(penalty int32) int32 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.recoverScore(time.Now())
+	p.score -= penalty
+	return p.score
+}
+
+// recoverScore increases the negative score according to the time passed since last update
+func (p *peer) recoverScore(now time.Time) {
+	if p.score >= 0 {
+		p.scoreTime = now
+		return
+	}
+	points := int32(now.Sub(p.scoreTime) / scoreRecoverInterval)
+	p.scoreTime = p.scoreTime.Add(time.Duration(points) * scoreRecoverInterval)
+	p.score += points
+	if p.score >= 0 {
+		p.score = 0
+		p.scoreTime = now
+	}
+}
+
+// Penalise decreases the score of peer for its misbehavior. The peer is disconnected and banned for a while if its score is too low
+func (ps *peerSet) Penalise(p *peer, o offence) {
+	score := p.penalise(o.penalty)
+	log.Debugf("Penalise peer %s for %s. score: %d", p.NodeID().String()[:16], o.name, score)
+	if score > banScore {
+		return
+	}
+	log.Warnf("Ban peer %s for %s cause its score %d is too low", p.NodeID().String()[:16], ps.banDuration, score)
+	ps.discover.BanNode(p.NodeID(), p.conn.RAddress(), ps.banDuration)
+	p.RcvBadDataClose()
+	ps.UnRegister(p)
+}
+
+// Scores returns the scores of connected peers by their node id
+func (ps *peerSet) Scores() map[string]int32 {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	scores := make(map[string]int32, len(ps.peers))
+	for id, p := range ps.peers {
+		scores[id.String()] = p.Score()
+	}
+	return scores
+}
+
+// verifyConfirmSigner checks the confirm is signed by a deputy node of the block. The deputy nodes of a term which is not stable in local can't be checked here, the consensus will check them later
+func (pm *ProtocolManager) verifyConfirmSigner(height uint32, hash common.Hash, sig types.SignData) bool {
+	nodeID, err := sig.RecoverNodeID(hash)
+	if err != nil {
+		return false
+	}
+	if _, err := pm.dm.GetTermByHeight(height, true); err != nil {
+		return true
+	}
+	return pm.dm.GetDeputyByNodeID(height, nodeID) != nil
+}
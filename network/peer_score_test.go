@@ -0,0 +1,40 @@
+package network
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_peerScore(t *testing.T) {
+	p := newPeer(&testPeer{})
+	assert.Equal(t, int32(0), p.Score())
+	assert.Equal(t, int32(-30), p.penalise(30))
+	assert.Equal(t, int32(-30), p.Score())
+
+	// recover as time goes by
+	p.scoreTime = p.scoreTime.Add(-5 * scoreRecoverInterval)
+	assert.Equal(t, int32(-25), p.Score())
+	p.scoreTime = p.scoreTime.Add(-100 * scoreRecoverInterval)
+	assert.Equal(t, int32(0), p.Score())
+	assert.Equal(t, int32(-5), p.penalise(5))
+}
+
+func Test_Penalise(t *testing.T) {
+	ps := newTestPeerSet()
+	ps.banDuration = time.Minute
+	p := newPeer(&testPeer{state: 1})
+	ps.Register(p)
+
+	ps.Penalise(p, offenceMalformedMsg)
+	assert.Equal(t, 1, ps.Size())
+	assert.Equal(t, map[string]int32{p.NodeID().String(): -offenceMalformedMsg.penalty}, ps.Scores())
+	assert.Equal(t, false, ps.discover.IsBlackNode(p.NodeID()))
+
+	// the score is too low
+	ps.Penalise(p, offenceMalformedMsg)
+	assert.Equal(t, 0, ps.Size())
+	assert.Equal(t, true, ps.discover.IsBlackNode(p.NodeID()))
+	assert.Equal(t, p2p.StatusBadData, p.conn.(*testPeer).closeStatus)
+}
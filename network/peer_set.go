@@ -9,21 +9,24 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
 	"math/big"
 	"sync"
+	"time"
 )
 
 type peerSet struct {
-	peers    map[p2p.NodeID]*peer
-	discover *p2p.DiscoverManager
-	dm       *deputynode.Manager
-	lock     sync.RWMutex
+	peers       map[p2p.NodeID]*peer
+	discover    *p2p.DiscoverManager
+	dm          *deputynode.Manager
+	banDuration time.Duration // the time to ban a peer whose score is too low
+	lock        sync.RWMutex
 }
 
 // NewPeerSet
 func NewPeerSet(discover *p2p.DiscoverManager, dm *deputynode.Manager) *peerSet {
 	return &peerSet{
-		peers:    make(map[p2p.NodeID]*peer),
-		discover: discover,
-		dm:       dm,
+		peers:       make(map[p2p.NodeID]*peer),
+		discover:    discover,
+		dm:          dm,
+		banDuration: DefaultBanDuration,
 	}
 }
 
@@ -85,6 +88,34 @@ func (ps *peerSet) BestToSync(height uint32) (p *peer) {
 	return p
 }
 
+// PeersToSync the peers whose current height is not less than height, and support headers-first synchronization
+func (ps *peerSet) PeersToSync(height uint32) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	peers := make([]*peer, 0)
+	for _, p := range ps.peers {
+		if p.lstStatus.CurHeight >= height && p.Supports(p2p.GetHeadersMsg) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// StablePeers the peers whose stable height is not less than height, and support state snapshot synchronization
+func (ps *peerSet) StablePeers(height uint32) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	peers := make([]*peer, 0)
+	for _, p := range ps.peers {
+		if p.lstStatus.StaHeight >= height && p.Supports(p2p.GetAccountsMsg) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
 // BestToDiscover best peer to discovery
 func (ps *peerSet) BestToDiscover() *peer {
 	ps.lock.Lock()
@@ -185,7 +216,7 @@ func (ps *peerSet) appendPeerByDistance(peers, deputyNodePeers []*peer, currentH
 		return nil, err
 	}
 	// 如果下下个即将出块的deputy为自己，则不用再广播出去了,防止nextMineDeputy 和thirdMineDeputy相互转,即使是通过api传过来的交易，交易执行等待时间最多为30s。
-	if distance == 2 && bytes.Compare(deputynode.GetSelfNodeID(), deputy.NodeID) == 0 {
+	if distance == 2 && bytes.Compare(ps.dm.SelfNodeID(), deputy.NodeID) == 0 {
 		return peers, nil
 	}
 	// 4. 通过nodeId判断deputy是否在deputyNodePeers中
@@ -12,7 +12,7 @@ import (
 )
 
 func newTestPeerSet() *peerSet {
-	discover := new(p2p.DiscoverManager)
+	discover := p2p.NewDiscoverManager("")
 	dm := deputynode.NewManager(5, testBlockLoader{})
 	dm.SaveSnapshot(0, deputyNodes)
 	ps := NewPeerSet(discover, dm)
@@ -49,6 +49,24 @@ func Test_BestToSync(t *testing.T) {
 	assert.Nil(t, pSync)
 }
 
+func Test_PeersToSync(t *testing.T) {
+	ps := newTestPeerSet()
+	p := newPeer(&testPeer{})
+	p.lstStatus.CurHeight = 100
+	p.lstStatus.StaHeight = 80
+	ps.Register(p)
+	// the old node which doesn't support the new synchronization messages
+	oldCaps := p2p.BaseCapabilities()
+	oldPeer := newPeer(&testPeer{state: 5, caps: &oldCaps})
+	oldPeer.lstStatus = p.lstStatus
+	ps.Register(oldPeer)
+
+	assert.Equal(t, []*peer{p}, ps.PeersToSync(90))
+	assert.Equal(t, 0, len(ps.PeersToSync(110)))
+	assert.Equal(t, []*peer{p}, ps.StablePeers(80))
+	assert.Equal(t, 0, len(ps.StablePeers(90)))
+}
+
 func Test_BestToDiscover(t *testing.T) {
 	ps := newTestPeerSet()
 
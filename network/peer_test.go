@@ -19,6 +19,7 @@ type testPeer struct {
 	closeStatus int32
 
 	state int
+	caps  *p2p.Capabilities
 }
 
 func (p *testPeer) ReadMsg() (msg *p2p.Msg, err error) {
@@ -84,9 +85,17 @@ func (p *testPeer) Run() (err error)
 func (p *testPeer) NeedReConnect() bool                                         { return true }
 func (p *testPeer) SetStatus(status int32)                                      { p.closeStatus = status }
 func (p *testPeer) Close()                                                      {}
+func (p *testPeer) SetCapabilities(caps p2p.Capabilities)                       { p.caps = &caps }
+func (p *testPeer) Capabilities() p2p.Capabilities {
+	if p.caps == nil {
+		return p2p.LocalCapabilities()
+	}
+	return *p.caps
+}
 
 func Test_Bytes(t *testing.T) {
-	target := common.FromHex("0xf86901a0010203000000000000000000000000000000000000000000000000000000000002f8440aa0010100000000000000000000000000000000000000000000000000000000000009a00202000000000000000000000000000000000000000000000000000000000000")
+	// the capabilities are appended after the handshake list
+	target := common.FromHex("0xf86901a0010203000000000000000000000000000000000000000000000000000000000002f8440aa0010100000000000000000000000000000000000000000000000000000000000009a00202000000000000000000000000000000000000000000000000000000000000c50201820400")
 	shake := &ProtocolHandshake{
 		ChainID:     1,
 		GenesisHash: common.Hash{0x01, 0x02, 0x03},
@@ -97,11 +106,21 @@ func Test_Bytes(t *testing.T) {
 			StaHeight: 9,
 			StaHash:   common.Hash{0x02, 0x02},
 		},
+		Capabilities: p2p.Capabilities{ProtocolVersion: 2, Snappy: true, MaxMsgSize: 1024},
 	}
 	tmp := shake.Bytes()
 	if bytes.Compare(target, tmp) != 0 {
 		t.Error("ProtocolHandshake.Bytes not match")
 	}
+
+	// the old nodes can decode it
+	var decoded ProtocolHandshake
+	assert.NoError(t, (&p2p.Msg{Content: tmp}).Decode(&decoded))
+	assert.Equal(t, shake.LatestStatus, decoded.LatestStatus)
+	assert.Equal(t, shake.Capabilities, decodeCapabilities(tmp))
+	// the handshake from old nodes
+	old, _ := rlp.EncodeToBytes(shake)
+	assert.Equal(t, p2p.BaseCapabilities(), decodeCapabilities(old))
 }
 
 func Test_Close(t *testing.T) {
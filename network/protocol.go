@@ -22,6 +22,38 @@ type GetBlocksData struct {
 	To   uint32
 }
 
+// GetHeadersData request Count headers from height From. The heights of headers are increased by Skip+1
+type GetHeadersData struct {
+	From  uint32
+	Count uint32
+	Skip  uint32
+}
+
+// GetBodiesData request the bodies of blocks by hash
+type GetBodiesData struct {
+	Hashes []common.Hash
+}
+
+// BlockBody the block data except header and change logs
+type BlockBody struct {
+	Hash        common.Hash // block hash
+	Txs         types.Transactions
+	Confirms    []types.SignData
+	DeputyNodes types.DeputyNodes
+}
+
+// GetAccountsData request the accounts in the state of a stable block. The accounts are sorted by address
+type GetAccountsData struct {
+	BlockHash common.Hash
+	Start     common.Address // the first address to fetch
+	Count     uint32
+}
+
+// GetNodesData request the trie nodes or contract codes by hash
+type GetNodesData struct {
+	Hashes []common.Hash
+}
+
 // GetSingleBlockData
 type GetSingleBlockData struct {
 	Hash   common.Hash
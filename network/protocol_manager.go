@@ -34,6 +34,11 @@ var (
 	handleGetBlocksWithChangeLogMsgMeter = metrics.NewMeter(metrics.HandleGetBlocksWithChangeLogMsg_meterName) // 统计调用handleGetBlocksWithChangeLogMsg的频率
 	handleDiscoverReqMsgMeter            = metrics.NewMeter(metrics.HandleDiscoverReqMsg_meterName)            // 统计调用handleDiscoverReqMsg的频率
 	handleDiscoverResMsgMeter            = metrics.NewMeter(metrics.HandleDiscoverResMsg_meterName)            // 统计调用handleDiscoverResMsg的频率
+	handleGetHeadersMsgMeter             = metrics.NewMeter(metrics.HandleGetHeadersMsg_meterName)             // 统计调用handleGetHeadersMsg的频率
+	handleGetBodiesMsgMeter              = metrics.NewMeter(metrics.HandleGetBodiesMsg_meterName)              // 统计调用handleGetBodiesMsg的频率
+	handleGetAccountsMsgMeter            = metrics.NewMeter(metrics.HandleGetAccountsMsg_meterName)            // 统计调用handleGetAccountsMsg的频率
+	handleGetNodeDataMsgMeter            = metrics.NewMeter(metrics.HandleGetNodeDataMsg_meterName)            // 统计调用handleGetNodeDataMsg的频率
+	throttledMsgMeter                    = metrics.NewMeter(metrics.ThrottledMsg_meterName)                    // 统计因请求过于频繁而被丢弃的消息的频率
 )
 
 // just for test
@@ -71,8 +76,13 @@ type ProtocolManager struct {
 	peers             *peerSet      // connected peers
 	confirmsCache     *ConfirmCache // received confirm info before block, cache them
 	blockCache        *BlockCache
+	downloader        *Downloader
+	snapshotSync      bool                      // download the state of a recent stable block instead of replaying all blocks
+	rateLimits        map[p2p.MsgCode]RateLimit // the limits of request messages from each peer
+	maxBlocksServe    uint32                    // the max count of blocks responded to one request
 	dataDir           string
 	oldStableBlock    atomic.Value
+	route             *subscribe.CentralRouteSub // the event bus to receive chain and p2p events
 
 	addPeerCh    chan p2p.IPeer
 	removePeerCh chan p2p.IPeer
@@ -107,7 +117,10 @@ func NewProtocolManager(chainID uint16, nodeID p2p.NodeID, chain BlockChain, dm
 		peers:             NewPeerSet(discover, dm),
 		confirmsCache:     NewConfirmCache(),
 		blockCache:        NewBlockCache(),
+		rateLimits:        DefaultRateLimits(),
+		maxBlocksServe:    DefaultMaxBlocksServe,
 		dataDir:           dataDir,
+		route:             subscribe.DefaultRoute(),
 		addPeerCh:         make(chan p2p.IPeer),
 		removePeerCh:      make(chan p2p.IPeer),
 
@@ -120,10 +133,43 @@ func NewProtocolManager(chainID uint16, nodeID p2p.NodeID, chain BlockChain, dm
 
 		quitCh: make(chan struct{}),
 	}
+	pm.downloader = NewDownloader(chain, dm, pm.peers, pm.insertBlock, pm.quitCh)
 	pm.sub()
 	return pm
 }
 
+// SetSnapshotSync sets whether a new node synchronizes the state snapshot of a recent stable block first
+func (pm *ProtocolManager) SetSnapshotSync(enable bool) {
+	pm.snapshotSync = enable
+}
+
+// SetRoute moves the subscriptions to another event bus, so that several nodes can run in one process
+func (pm *ProtocolManager) SetRoute(route *subscribe.CentralRouteSub) {
+	pm.unSub()
+	pm.route = route
+	pm.sub()
+}
+
+// SetBanDuration sets the time to ban a peer whose score is too low
+func (pm *ProtocolManager) SetBanDuration(duration time.Duration) {
+	pm.peers.banDuration = duration
+}
+
+// SetRateLimit sets the token bucket limit of a kind of request message from each peer
+func (pm *ProtocolManager) SetRateLimit(code p2p.MsgCode, limit RateLimit) {
+	pm.rateLimits[code] = limit
+}
+
+// SetMaxBlocksServe sets the max count of blocks responded to one GetBlocksMsg or GetBlocksWithChangeLogMsg
+func (pm *ProtocolManager) SetMaxBlocksServe(count uint32) {
+	pm.maxBlocksServe = count
+}
+
+// PeerScores returns the reputation scores of connected peers by their node id
+func (pm *ProtocolManager) PeerScores() map[string]int32 {
+	return pm.peers.Scores()
+}
+
 func (pm *ProtocolManager) setTest() {
 	pm.test = true
 	pm.testOutput = make(chan int)
@@ -131,24 +177,24 @@ func (pm *ProtocolManager) setTest() {
 
 // sub subscribe channel
 func (pm *ProtocolManager) sub() {
-	subscribe.Sub(subscribe.AddNewPeer, pm.addPeerCh)
-	subscribe.Sub(subscribe.DeletePeer, pm.removePeerCh)
-	subscribe.Sub(subscribe.NewMinedBlock, pm.newMinedBlockCh)
-	subscribe.Sub(subscribe.NewStableBlock, pm.stableBlockCh)
-	subscribe.Sub(subscribe.NewTx, pm.txCh)
-	subscribe.Sub(subscribe.NewConfirm, pm.confirmCh)
-	subscribe.Sub(subscribe.FetchConfirms, pm.fetchConfirms)
+	pm.route.Sub(subscribe.AddNewPeer, pm.addPeerCh)
+	pm.route.Sub(subscribe.DeletePeer, pm.removePeerCh)
+	pm.route.Sub(subscribe.NewMinedBlock, pm.newMinedBlockCh)
+	pm.route.Sub(subscribe.NewStableBlock, pm.stableBlockCh)
+	pm.route.Sub(subscribe.NewTx, pm.txCh)
+	pm.route.Sub(subscribe.NewConfirm, pm.confirmCh)
+	pm.route.Sub(subscribe.FetchConfirms, pm.fetchConfirms)
 }
 
 // unSub unsubscribe channel
 func (pm *ProtocolManager) unSub() {
-	subscribe.UnSub(subscribe.AddNewPeer, pm.addPeerCh)
-	subscribe.UnSub(subscribe.DeletePeer, pm.removePeerCh)
-	subscribe.UnSub(subscribe.NewMinedBlock, pm.newMinedBlockCh)
-	subscribe.UnSub(subscribe.NewStableBlock, pm.stableBlockCh)
-	subscribe.UnSub(subscribe.NewTx, pm.txCh)
-	subscribe.UnSub(subscribe.NewConfirm, pm.confirmCh)
-	subscribe.UnSub(subscribe.FetchConfirms, pm.fetchConfirms)
+	pm.route.UnSub(subscribe.AddNewPeer, pm.addPeerCh)
+	pm.route.UnSub(subscribe.DeletePeer, pm.removePeerCh)
+	pm.route.UnSub(subscribe.NewMinedBlock, pm.newMinedBlockCh)
+	pm.route.UnSub(subscribe.NewStableBlock, pm.stableBlockCh)
+	pm.route.UnSub(subscribe.NewTx, pm.txCh)
+	pm.route.UnSub(subscribe.NewConfirm, pm.confirmCh)
+	pm.route.UnSub(subscribe.FetchConfirms, pm.fetchConfirms)
 }
 
 // Start
@@ -243,6 +289,7 @@ func (pm *ProtocolManager) rcvBlockLoop() {
 				// 判断收到的区块是否为黑名单区块
 				if bbc.IsBlackBlock(b.Hash(), b.ParentHash()) {
 					log.Warnf("This block is black block. block: %s", b.String())
+					pm.peers.Penalise(rcvMsg.p, offenceInvalidBlock)
 					continue
 				}
 				// update latest status
@@ -265,6 +312,9 @@ func (pm *ProtocolManager) rcvBlockLoop() {
 					log.Infof("Got a block %s from peer: %#x", b.ShortString(), rcvMsg.p.NodeID()[:4])
 					if err := pm.insertBlock(b); err != nil {
 						log.Warnf("block verify failed. ignore the rest %d blocks", len(rcvMsg.blocks)-1-i)
+						if err == types.ErrVerifyBlockFailed {
+							pm.peers.Penalise(rcvMsg.p, offenceInvalidBlock)
+						}
 						break
 					}
 				} else if b.Height() <= 1 && pm.chain.Genesis() != nil {
@@ -557,9 +607,10 @@ func (pm *ProtocolManager) handlePeer(p *peer) {
 // handshake protocol handshake
 func (pm *ProtocolManager) handshake(p *peer) (*ProtocolHandshake, error) {
 	phs := &ProtocolHandshake{
-		ChainID:     pm.chainID,
-		GenesisHash: pm.chain.Genesis().Hash(),
-		NodeVersion: pm.nodeVersion,
+		ChainID:      pm.chainID,
+		GenesisHash:  pm.chain.Genesis().Hash(),
+		NodeVersion:  pm.nodeVersion,
+		Capabilities: p2p.LocalCapabilities(),
 		LatestStatus: LatestStatus{
 			CurHash:   pm.chain.CurrentBlock().Hash(),
 			CurHeight: pm.chain.CurrentBlock().Height(),
@@ -575,6 +626,9 @@ func (pm *ProtocolManager) handshake(p *peer) (*ProtocolHandshake, error) {
 	if err != nil {
 		return nil, err
 	}
+	caps := phs.Capabilities.Negotiate(remoteStatus.Capabilities)
+	p.conn.SetCapabilities(caps)
+	log.Debugf("Negotiated capabilities with peer %s. protocol: %d, snappy: %t", p.NodeID().String()[:16], caps.ProtocolVersion, caps.Snappy)
 	return remoteStatus, nil
 }
 
@@ -625,8 +679,41 @@ func (pm *ProtocolManager) findSyncFrom(rStatus *LatestStatus) (uint32, error) {
 	return from, nil
 }
 
-// syncBlocks sync blocks with throttle algorithm
+// SyncStatus returns the progress of headers-first synchronization
+func (pm *ProtocolManager) SyncStatus() *SyncStatus {
+	return pm.downloader.Status()
+}
+
+// syncBlocks sync blocks with throttle algorithm. The range of blocks is downloaded headers-first from multiple peers
 func (pm *ProtocolManager) syncBlocks(p *peer, from, to uint32) bool {
+	// the old peer can only sync blocks by GetBlocksMsg
+	if from < to && p.Supports(p2p.GetHeadersMsg) {
+		if pm.downloader.Syncing() {
+			log.Debug("stop sync blocks cause the synchronization is in progress")
+			return false
+		}
+		go func() {
+			// a new node skips the history blocks by downloading the state of remote stable block
+			if pm.snapshotSync && pm.chain.StableBlock().Height() == 0 {
+				err := pm.downloader.SyncSnapshot(p)
+				if err != nil && err != ErrUnprovableState {
+					log.Warnf("State snapshot synchronization failed: %v", err)
+					return
+				}
+				if err == ErrUnprovableState {
+					log.Infof("Synchronize all blocks because the state snapshot can't be proved by change logs")
+				}
+				if current := pm.chain.CurrentBlock().Height(); current >= from {
+					from = current + 1
+				}
+			}
+			if err := pm.downloader.Synchronise(p, from, to); err != nil {
+				log.Warnf("Headers-first synchronization failed: %v", err)
+			}
+		}()
+		return true
+	}
+
 	// to avoid sync blocks from different peer at same time
 	var timeDelay int64
 	if from != to {
@@ -668,15 +755,31 @@ func (pm *ProtocolManager) work(msg *p2p.Msg, p *peer) error {
 	case p2p.GetConfirmsMsg:
 		return pm.handleGetConfirmsMsg(msg, p)
 	case p2p.ConfirmsMsg:
-		return pm.handleConfirmsMsg(msg)
+		return pm.handleConfirmsMsg(msg, p)
 	case p2p.ConfirmMsg:
-		return pm.handleConfirmMsg(msg)
+		return pm.handleConfirmMsg(msg, p)
 	case p2p.DiscoverReqMsg:
 		return pm.handleDiscoverReqMsg(msg, p)
 	case p2p.DiscoverResMsg:
-		return pm.handleDiscoverResMsg(msg)
+		return pm.handleDiscoverResMsg(msg, p)
 	case p2p.GetBlocksWithChangeLogMsg:
 		return pm.handleGetBlocksWithChangeLogMsg(msg, p)
+	case p2p.GetHeadersMsg:
+		return pm.handleGetHeadersMsg(msg, p)
+	case p2p.HeadersMsg:
+		return pm.handleHeadersMsg(msg, p)
+	case p2p.GetBodiesMsg:
+		return pm.handleGetBodiesMsg(msg, p)
+	case p2p.BodiesMsg:
+		return pm.handleBodiesMsg(msg, p)
+	case p2p.GetAccountsMsg:
+		return pm.handleGetAccountsMsg(msg, p)
+	case p2p.AccountsMsg:
+		return pm.handleAccountsMsg(msg, p)
+	case p2p.GetNodeDataMsg:
+		return pm.handleGetNodeDataMsg(msg, p)
+	case p2p.NodeDataMsg:
+		return pm.handleNodeDataMsg(msg, p)
 	default:
 		log.Debugf("invalid code: %s, from: %s", msg.Code, common.ToHex(p.NodeID()[:4]))
 		return ErrInvalidCode
@@ -686,6 +789,7 @@ func (pm *ProtocolManager) work(msg *p2p.Msg, p *peer) error {
 // handleMsg handle net received message
 func (pm *ProtocolManager) handleMsg(p *peer) error {
 	msgCache := NewMsgCache()
+	limiter := newMsgLimiter(pm.rateLimits)
 	errCh := make(chan error, 1)   // 返回error的管道
 	closeCh := make(chan struct{}) // 监听退出ReadMsg协程的管道
 	// read msg
@@ -715,8 +819,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 		msg := msgCache.Pop()
+		// drop the requests which are too frequent
+		if !limiter.allow(msg.Code, time.Now()) {
+			throttledMsgMeter.Mark(1)
+			metrics.NewMeter(metrics.ThrottledMsg_meterName + "/" + msg.Code.String()).Mark(1)
+			log.Debugf("Drop message %s from peer %s cause the requests are too frequent", msg.Code, p.NodeID().String()[:16])
+			continue
+		}
 		err := pm.work(msg, p)
 		if err != nil {
+			pm.peers.Penalise(p, offenceMalformedMsg)
 			close(closeCh)
 			return err
 		}
@@ -797,7 +909,7 @@ func (pm *ProtocolManager) handleTxsMsg(msg *p2p.Msg) error {
 			if !isExist {
 				if err := pm.txPool.AddTx(tx); err == nil { // 加入交易池
 					// 广播交易
-					subscribe.Send(subscribe.NewTx, tx)
+					pm.route.Send(subscribe.NewTx, tx)
 				}
 			}
 		}()
@@ -812,6 +924,10 @@ func (pm *ProtocolManager) handleBlocksMsg(msg *p2p.Msg, p *peer) error {
 	if err := msg.Decode(&blocks); err != nil {
 		return fmt.Errorf("handleBlocksMsg error: %v", err)
 	}
+	// the blocks with change logs requested by state synchronization
+	if pm.downloader.Deliver(p, p2p.BlocksMsg, blocks) {
+		return nil
+	}
 	rcvMsg := &rcvBlockObj{
 		p:      p,
 		blocks: blocks,
@@ -833,6 +949,7 @@ func (pm *ProtocolManager) handleGetBlocksMsg(msg *p2p.Msg, p *peer) error {
 	if query.From > pm.chain.CurrentBlock().Height() {
 		return nil
 	}
+	pm.limitBlocksRange(&query)
 	go pm.respBlocks(query.From, query.To, p, false)
 	return nil
 }
@@ -888,12 +1005,24 @@ func (pm *ProtocolManager) respBlocks(from, to uint32, p *peer, hasChangeLog boo
 }
 
 // handleConfirmsMsg handle received block's confirm package message
-func (pm *ProtocolManager) handleConfirmsMsg(msg *p2p.Msg) error {
+func (pm *ProtocolManager) handleConfirmsMsg(msg *p2p.Msg, p *peer) error {
 	var info BlockConfirms
 	if err := msg.Decode(&info); err != nil {
 		return fmt.Errorf("handleConfirmsMsg error: %v", err)
 	}
-	go pm.chain.InsertConfirms(info.Height, info.Hash, info.Pack)
+	pack := make([]types.SignData, 0, len(info.Pack))
+	for _, sig := range info.Pack {
+		if pm.verifyConfirmSigner(info.Height, info.Hash, sig) {
+			pack = append(pack, sig)
+		}
+	}
+	if len(pack) != len(info.Pack) {
+		log.Debugf("Got %d invalid confirms from peer: %s", len(info.Pack)-len(pack), p.NodeID().String()[:16])
+		pm.peers.Penalise(p, offenceBadConfirm)
+	}
+	if len(pack) > 0 {
+		go pm.chain.InsertConfirms(info.Height, info.Hash, pack)
+	}
 	return nil
 }
 
@@ -922,12 +1051,17 @@ func (pm *ProtocolManager) handleGetConfirmsMsg(msg *p2p.Msg, p *peer) error {
 }
 
 // handleConfirmMsg handle confirm broadcast info
-func (pm *ProtocolManager) handleConfirmMsg(msg *p2p.Msg) error {
+func (pm *ProtocolManager) handleConfirmMsg(msg *p2p.Msg, p *peer) error {
 	defer handleConfirmMsgMeter.Mark(1)
 	confirm := new(BlockConfirmData)
 	if err := msg.Decode(confirm); err != nil {
 		return fmt.Errorf("handleConfirmMsg error: %v", err)
 	}
+	if !pm.verifyConfirmSigner(confirm.Height, confirm.Hash, confirm.SignInfo) {
+		log.Debugf("Got an invalid confirm from peer: %s", p.NodeID().String()[:16])
+		pm.peers.Penalise(p, offenceBadConfirm)
+		return nil
+	}
 	if pm.chain.HasBlock(confirm.Hash) {
 		go pm.chain.InsertConfirms(confirm.Height, confirm.Hash, []types.SignData{confirm.SignInfo})
 	} else {
@@ -954,12 +1088,16 @@ func (pm *ProtocolManager) handleDiscoverReqMsg(msg *p2p.Msg, p *peer) error {
 }
 
 // handleDiscoverResMsg handle discover nodes response
-func (pm *ProtocolManager) handleDiscoverResMsg(msg *p2p.Msg) error {
+func (pm *ProtocolManager) handleDiscoverResMsg(msg *p2p.Msg, p *peer) error {
 	defer handleDiscoverResMsgMeter.Mark(1)
 	var disRes DiscoverResData
 	if err := msg.Decode(&disRes); err != nil {
 		return fmt.Errorf("handleDiscoverResMsg error: %v", err)
 	}
+	if len(disRes.Nodes) > p2p.MaxNodeCount {
+		pm.peers.Penalise(p, offenceOversizedResponse)
+		return nil
+	}
 	// verify nodes
 	for _, node := range disRes.Nodes {
 		if err := VerifyNode(node); err != nil {
@@ -984,6 +1122,195 @@ func (pm *ProtocolManager) handleGetBlocksWithChangeLogMsg(msg *p2p.Msg, p *peer
 	if query.From > pm.chain.CurrentBlock().Height() {
 		return nil
 	}
+	pm.limitBlocksRange(&query)
 	go pm.respBlocks(query.From, query.To, p, true)
 	return nil
 }
+
+// limitBlocksRange cuts the range of blocks request to maxBlocksServe. The remote peer will request the rest blocks later
+func (pm *ProtocolManager) limitBlocksRange(query *GetBlocksData) {
+	if pm.maxBlocksServe > 0 && query.To-query.From >= pm.maxBlocksServe {
+		query.To = query.From + pm.maxBlocksServe - 1
+	}
+}
+
+// handleGetHeadersMsg handle get headers message
+func (pm *ProtocolManager) handleGetHeadersMsg(msg *p2p.Msg, p *peer) error {
+	defer handleGetHeadersMsgMeter.Mark(1)
+	var query GetHeadersData
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("handleGetHeadersMsg error: %v", err)
+	}
+	if query.Count > maxHeadersServe {
+		query.Count = maxHeadersServe
+	}
+	go pm.respHeaders(&query, p)
+	return nil
+}
+
+// respHeaders response headers to remote peer. The response stops at the first missing header
+func (pm *ProtocolManager) respHeaders(query *GetHeadersData, p *peer) {
+	headers := make([]*types.Header, 0, query.Count)
+	height := query.From
+	for i := uint32(0); i < query.Count; i++ {
+		b := pm.chain.GetBlockByHeight(height)
+		if b == nil {
+			break
+		}
+		headers = append(headers, b.Header)
+		// avoid overflow
+		next := height + query.Skip + 1
+		if next <= height {
+			break
+		}
+		height = next
+	}
+	p.SendHeaders(headers)
+}
+
+// handleHeadersMsg handle received headers message
+func (pm *ProtocolManager) handleHeadersMsg(msg *p2p.Msg, p *peer) error {
+	var headers []*types.Header
+	if err := msg.Decode(&headers); err != nil {
+		return fmt.Errorf("handleHeadersMsg error: %v", err)
+	}
+	if len(headers) > maxHeadersServe {
+		pm.peers.Penalise(p, offenceOversizedResponse)
+		return nil
+	}
+	if !pm.downloader.Deliver(p, p2p.HeadersMsg, headers) {
+		log.Debugf("Ignore unrequested headers from peer: %s", p.NodeID().String()[:16])
+	}
+	return nil
+}
+
+// handleGetBodiesMsg handle get block bodies message
+func (pm *ProtocolManager) handleGetBodiesMsg(msg *p2p.Msg, p *peer) error {
+	defer handleGetBodiesMsgMeter.Mark(1)
+	var query GetBodiesData
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("handleGetBodiesMsg error: %v", err)
+	}
+	if len(query.Hashes) > maxBodiesServe {
+		query.Hashes = query.Hashes[:maxBodiesServe]
+	}
+	go pm.respBodies(query.Hashes, p)
+	return nil
+}
+
+// respBodies response block bodies to remote peer. The response stops at the first missing block
+func (pm *ProtocolManager) respBodies(hashes []common.Hash, p *peer) {
+	bodies := make([]*BlockBody, 0, len(hashes))
+	for _, hash := range hashes {
+		b := pm.chain.GetBlockByHash(hash)
+		if b == nil {
+			break
+		}
+		bodies = append(bodies, &BlockBody{
+			Hash:        hash,
+			Txs:         b.Txs,
+			Confirms:    b.Confirms,
+			DeputyNodes: b.DeputyNodes,
+		})
+	}
+	p.SendBodies(bodies)
+}
+
+// handleBodiesMsg handle received block bodies message
+func (pm *ProtocolManager) handleBodiesMsg(msg *p2p.Msg, p *peer) error {
+	var bodies []*BlockBody
+	if err := msg.Decode(&bodies); err != nil {
+		return fmt.Errorf("handleBodiesMsg error: %v", err)
+	}
+	if len(bodies) > maxBodiesServe {
+		pm.peers.Penalise(p, offenceOversizedResponse)
+		return nil
+	}
+	if !pm.downloader.Deliver(p, p2p.BodiesMsg, bodies) {
+		log.Debugf("Ignore unrequested bodies from peer: %s", p.NodeID().String()[:16])
+	}
+	return nil
+}
+
+// handleGetAccountsMsg handle get accounts message
+func (pm *ProtocolManager) handleGetAccountsMsg(msg *p2p.Msg, p *peer) error {
+	defer handleGetAccountsMsgMeter.Mark(1)
+	var query GetAccountsData
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("handleGetAccountsMsg error: %v", err)
+	}
+	if query.Count > maxAccountsServe {
+		query.Count = maxAccountsServe
+	}
+	go pm.respAccounts(&query, p)
+	return nil
+}
+
+// respAccounts response the accounts in the state of stable block to remote peer. Nothing is responded if the block is not stable
+func (pm *ProtocolManager) respAccounts(query *GetAccountsData, p *peer) {
+	accounts, err := pm.chain.GetSnapshotAccounts(query.BlockHash, query.Start, int(query.Count))
+	if err != nil {
+		log.Debugf("Can't get accounts of block %s: %v", query.BlockHash.Hex(), err)
+		return
+	}
+	p.SendAccounts(accounts)
+}
+
+// handleAccountsMsg handle received accounts message
+func (pm *ProtocolManager) handleAccountsMsg(msg *p2p.Msg, p *peer) error {
+	var accounts []*types.AccountData
+	if err := msg.Decode(&accounts); err != nil {
+		return fmt.Errorf("handleAccountsMsg error: %v", err)
+	}
+	if len(accounts) > maxAccountsServe {
+		pm.peers.Penalise(p, offenceOversizedResponse)
+		return nil
+	}
+	if !pm.downloader.Deliver(p, p2p.AccountsMsg, accounts) {
+		log.Debugf("Ignore unrequested accounts from peer: %s", p.NodeID().String()[:16])
+	}
+	return nil
+}
+
+// handleGetNodeDataMsg handle get trie nodes message
+func (pm *ProtocolManager) handleGetNodeDataMsg(msg *p2p.Msg, p *peer) error {
+	defer handleGetNodeDataMsgMeter.Mark(1)
+	var query GetNodesData
+	if err := msg.Decode(&query); err != nil {
+		return fmt.Errorf("handleGetNodeDataMsg error: %v", err)
+	}
+	if len(query.Hashes) > maxNodeDataServe {
+		query.Hashes = query.Hashes[:maxNodeDataServe]
+	}
+	go pm.respNodeData(query.Hashes, p)
+	return nil
+}
+
+// respNodeData response trie nodes or contract codes to remote peer. The response stops at the first missing node
+func (pm *ProtocolManager) respNodeData(hashes []common.Hash, p *peer) {
+	data := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		item, err := pm.chain.GetNodeData(hash)
+		if err != nil {
+			break
+		}
+		data = append(data, item)
+	}
+	p.SendNodeData(data)
+}
+
+// handleNodeDataMsg handle received trie nodes message
+func (pm *ProtocolManager) handleNodeDataMsg(msg *p2p.Msg, p *peer) error {
+	var data [][]byte
+	if err := msg.Decode(&data); err != nil {
+		return fmt.Errorf("handleNodeDataMsg error: %v", err)
+	}
+	if len(data) > maxNodeDataServe {
+		pm.peers.Penalise(p, offenceOversizedResponse)
+		return nil
+	}
+	if !pm.downloader.Deliver(p, p2p.NodeDataMsg, data) {
+		log.Debugf("Ignore unrequested node data from peer: %s", p.NodeID().String()[:16])
+	}
+	return nil
+}
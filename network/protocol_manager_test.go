@@ -29,6 +29,18 @@ func (bc *testChain) IsInBlackList(b *types.Block) bool {
 	return false
 }
 
+func (bc *testChain) InsertSnapshot(snapshot *store.Snapshot) error {
+	return nil
+}
+
+func (bc *testChain) GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error) {
+	return nil, store.ErrBlockNotExist
+}
+
+func (bc *testChain) GetNodeData(hash common.Hash) ([]byte, error) {
+	return nil, store.ErrNotExist
+}
+
 func (bc *testChain) Genesis() *types.Block {
 	h := &types.Header{}
 	return &types.Block{
@@ -281,3 +293,19 @@ func Test_peerLoop(t *testing.T) {
 func Test_handleMsg(t *testing.T) {
 
 }
+
+func Test_limitBlocksRange(t *testing.T) {
+	pm := createPm()
+	query := &GetBlocksData{From: 10, To: 10 + DefaultMaxBlocksServe - 1}
+	pm.limitBlocksRange(query)
+	assert.Equal(t, uint32(10+DefaultMaxBlocksServe-1), query.To)
+
+	query = &GetBlocksData{From: 10, To: 100000}
+	pm.limitBlocksRange(query)
+	assert.Equal(t, uint32(10+DefaultMaxBlocksServe-1), query.To)
+
+	pm.SetMaxBlocksServe(5)
+	query = &GetBlocksData{From: 10, To: 100000}
+	pm.limitBlocksRange(query)
+	assert.Equal(t, uint32(14), query.To)
+}
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBlocksServe is the default max count of blocks responded to one GetBlocksMsg or GetBlocksWithChangeLogMsg
+const DefaultMaxBlocksServe = 1000
+
+// RateLimit is the token bucket setting of one kind of request message from one peer
+type RateLimit struct {
+	Rate  float64 // the count of requests allowed per second
+	Burst int     // the max count of requests allowed at once
+}
+
+// DefaultRateLimits returns the default limits of request messages. The messages which are not in the map are not limited
+func DefaultRateLimits() map[p2p.MsgCode]RateLimit {
+	return map[p2p.MsgCode]RateLimit{
+		p2p.GetLstStatusMsg:           {Rate: 1, Burst: 5},
+		p2p.GetBlocksMsg:              {Rate: 5, Burst: 20},
+		p2p.GetBlocksWithChangeLogMsg: {Rate: 5, Burst: 20},
+		p2p.GetConfirmsMsg:            {Rate: 20, Burst: 100},
+		p2p.DiscoverReqMsg:            {Rate: 0.2, Burst: 3},
+		p2p.GetHeadersMsg:             {Rate: 10, Burst: 20},
+		p2p.GetBodiesMsg:              {Rate: 20, Burst: 40},
+		p2p.GetAccountsMsg:            {Rate: 20, Burst: 40},
+		p2p.GetNodeDataMsg:            {Rate: 20, Burst: 40},
+	}
+}
+
+// tokenBucket allows Burst requests at once, and refills Rate tokens per second
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// take consumes a token. It returns false if there is no token left
+func (b *tokenBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// msgLimiter limits the request messages from one peer by message code
+type msgLimiter struct {
+	buckets map[p2p.MsgCode]*tokenBucket
+	lock    sync.Mutex
+}
+
+func newMsgLimiter(limits map[p2p.MsgCode]RateLimit) *msgLimiter {
+	now := time.Now()
+	buckets := make(map[p2p.MsgCode]*tokenBucket, len(limits))
+	for code, limit := range limits {
+		buckets[code] = newTokenBucket(limit, now)
+	}
+	return &msgLimiter{buckets: buckets}
+}
+
+// allow returns false if the message should be dropped
+func (l *msgLimiter) allow(code p2p.MsgCode, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, ok := l.buckets[code]
+	if !ok {
+		return true
+	}
+	return b.take(now)
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_tokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(RateLimit{Rate: 2, Burst: 3}, now)
+
+	// burst
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.take(now))
+	}
+	assert.False(t, b.take(now))
+
+	// refill 2 tokens per second
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, b.take(now))
+	assert.False(t, b.take(now))
+
+	// no more than burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.take(now))
+	}
+	assert.False(t, b.take(now))
+}
+
+func Test_msgLimiter(t *testing.T) {
+	l := newMsgLimiter(map[p2p.MsgCode]RateLimit{p2p.GetBlocksMsg: {Rate: 1, Burst: 1}})
+	now := time.Now()
+	assert.True(t, l.allow(p2p.GetBlocksMsg, now))
+	assert.False(t, l.allow(p2p.GetBlocksMsg, now))
+	assert.True(t, l.allow(p2p.GetBlocksMsg, now.Add(time.Second)))
+
+	// not limited
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.allow(p2p.BlocksMsg, now))
+	}
+}
+
+func Test_DefaultRateLimits(t *testing.T) {
+	limits := DefaultRateLimits()
+	limits[p2p.GetBlocksMsg] = RateLimit{Rate: 100, Burst: 100}
+	// the default limits are not changed
+	assert.NotEqual(t, limits[p2p.GetBlocksMsg], DefaultRateLimits()[p2p.GetBlocksMsg])
+	for code, limit := range limits {
+		assert.True(t, limit.Rate > 0, code.String())
+		assert.True(t, limit.Burst > 0, code.String())
+	}
+}
@@ -0,0 +1,618 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/proof"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/network/p2p"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/trie"
+	"math/big"
+	"sync/atomic"
+)
+
+const (
+	accountBatchSize = 256  // the max count of accounts in one request
+	nodeBatchSize    = 384  // the max count of trie nodes in one request
+	maxAccountsServe = 1024 // the max count of accounts responded to one request
+	maxNodeDataServe = 1024 // the max count of trie nodes responded to one request
+)
+
+var (
+	ErrInvalidState      = errors.New("invalid state data from remote")
+	ErrUnconfirmedStable = errors.New("the stable block from remote is not confirmed by enough deputy nodes")
+	ErrUnprovableState   = errors.New("the state snapshot can't be proved by change logs")
+)
+
+// SyncSnapshot downloads the state of master's stable block, and sets the block as the local stable block. Then the blocks after it can be synchronised as usual
+func (d *Downloader) SyncSnapshot(master *peer) error {
+	status := master.LatestStatus()
+	pivotHeight := status.StaHeight
+	if pivotHeight <= d.chain.StableBlock().Height() {
+		return nil
+	}
+	if !atomic.CompareAndSwapInt32(&d.syncing, 0, 1) {
+		return ErrSyncBusy
+	}
+	defer atomic.StoreInt32(&d.syncing, 0)
+
+	d.updateStatus(func(status *SyncStatus) {
+		*status = SyncStatus{
+			StartHeight:   d.chain.StableBlock().Height(),
+			CurrentHeight: d.chain.StableBlock().Height(),
+			HighestHeight: pivotHeight,
+		}
+	})
+	log.Infof("Start state snapshot synchronization at %d", pivotHeight)
+
+	peers := d.peers.StablePeers(pivotHeight)
+	if !containsPeer(peers, master) {
+		peers = append(peers, master)
+	}
+	snapshot := &store.Snapshot{}
+	terms, err := d.fetchTerms(peers, pivotHeight)
+	if err != nil {
+		log.Warnf("Fetch deputy terms before %d failed: %v", pivotHeight, err)
+		return err
+	}
+	snapshot.Terms = terms
+	snapshot.Block, err = d.fetchStableBlock(peers, pivotHeight)
+	if err != nil {
+		log.Warnf("Fetch snapshot block %d failed: %v", pivotHeight, err)
+		return err
+	}
+	if snapshot.Block.Hash() != status.StaHash {
+		log.Warnf("Snapshot block %s is not the stable block of master", snapshot.Block.ShortString())
+		return ErrInvalidHeaders
+	}
+	if deputynode.IsSnapshotBlock(pivotHeight) {
+		d.dm.SaveSnapshot(pivotHeight, snapshot.Block.DeputyNodes)
+	}
+	if err = d.fetchState(peers, snapshot); err != nil {
+		log.Warnf("Fetch state of block %s failed: %v", snapshot.Block.ShortString(), err)
+		return err
+	}
+
+	if err = d.chain.InsertSnapshot(snapshot); err != nil {
+		log.Warnf("Insert snapshot block %s failed: %v", snapshot.Block.ShortString(), err)
+		return err
+	}
+	d.updateStatus(func(status *SyncStatus) {
+		status.CurrentHeight = pivotHeight
+	})
+	log.Infof("State snapshot synchronization finished at %d", pivotHeight)
+	return nil
+}
+
+// fetchTerms fetches the term snapshot blocks before height, and saves the deputy nodes into deputy manager in order
+func (d *Downloader) fetchTerms(peers []*peer, height uint32) ([]*types.Block, error) {
+	terms := make([]*types.Block, 0)
+	// h < TermDuration means overflow
+	for h := params.TermDuration; h < height && h >= params.TermDuration; h += params.TermDuration {
+		if d.chain.GetBlockByHeight(h) != nil {
+			continue
+		}
+		block, err := d.fetchStableBlock(peers, h)
+		if err != nil {
+			return nil, err
+		}
+		d.dm.SaveSnapshot(h, block.DeputyNodes)
+		terms = append(terms, block)
+	}
+	return terms, nil
+}
+
+// fetchStableBlock fetches the stable block at height, and checks it is confirmed by enough deputy nodes
+func (d *Downloader) fetchStableBlock(peers []*peer, height uint32) (*types.Block, error) {
+	result := make(types.Blocks, 1)
+	err := d.runTasks(peers, 1, func(p *peer, index int) error {
+		headers, err := d.requestHeaders(p, height, 1, 0)
+		if err != nil {
+			return err
+		}
+		if len(headers) != 1 || headers[0].Height != height {
+			return ErrInvalidHeaders
+		}
+		if err := d.fetchBodies(p, headers, result); err != nil {
+			return err
+		}
+		return d.verifyStable(result[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+// verifyStable checks the block is signed and confirmed by enough deputy nodes, so it must be stable
+func (d *Downloader) verifyStable(block *types.Block) error {
+	height := block.Height()
+	if _, err := d.dm.GetTermByHeight(height, true); err != nil {
+		return err
+	}
+	if err := d.verifySigner(block.Header); err != nil {
+		return err
+	}
+	signer, _ := block.Header.SignerNodeID()
+	signers := map[string]bool{common.ToHex(signer): true}
+	hash := block.Hash()
+	for _, sig := range block.Confirms {
+		nodeID, err := sig.RecoverNodeID(hash)
+		if err != nil || d.dm.GetDeputyByNodeID(height, nodeID) == nil {
+			log.Warnf("Invalid confirm of synchronized block %s", block.ShortString())
+			return ErrUnconfirmedStable
+		}
+		signers[common.ToHex(nodeID)] = true
+	}
+	if uint32(len(signers)) < d.dm.TwoThirdDeputyCount(height) {
+		return ErrUnconfirmedStable
+	}
+	return nil
+}
+
+// fetchState fetches the accounts and tries in the state of snapshot block, and verifies them with the version root of block
+func (d *Downloader) fetchState(peers []*peer, snapshot *store.Snapshot) error {
+	db, _ := store.NewMemDatabase()
+	sched := trie.NewTrieSync(snapshot.Block.VersionRoot(), db, nil)
+	if err := d.syncTrieNodes(peers, sched, db); err != nil {
+		return err
+	}
+	var err error
+	snapshot.Accounts, err = d.fetchAccounts(peers, snapshot.Block, db)
+	if err != nil {
+		return err
+	}
+
+	// fetch the tries of accounts
+	codeHashes := make([]common.Hash, 0)
+	for _, data := range snapshot.Accounts {
+		for _, root := range []common.Hash{data.StorageRoot, data.AssetCodeRoot, data.AssetIdRoot, data.EquityRoot} {
+			if root != (common.Hash{}) {
+				sched.AddSubTrie(root, 0, common.Hash{}, nil)
+			}
+		}
+		if data.CodeHash != (common.Hash{}) && data.CodeHash != common.Sha3Nil {
+			codeHashes = append(codeHashes, data.CodeHash)
+		}
+	}
+	if err := d.syncTrieNodes(peers, sched, db); err != nil {
+		return err
+	}
+	if snapshot.Codes, err = d.fetchCodes(peers, codeHashes); err != nil {
+		return err
+	}
+	if err := buildAssetIndexes(store.NewTrieDatabase(db), snapshot); err != nil {
+		return err
+	}
+
+	snapshot.Nodes = make(map[common.Hash][]byte, db.Len())
+	for _, key := range db.Keys() {
+		snapshot.Nodes[common.BytesToHash(key)], _ = db.Get(0, key)
+	}
+	return nil
+}
+
+// syncTrieNodes fetches the missing nodes of the scheduled tries until all of them are stored in db
+func (d *Downloader) syncTrieNodes(peers []*peer, sched *trie.TrieSync, db *store.MemDatabase) error {
+	for sched.Pending() > 0 {
+		hashes := sched.Missing(0)
+		results := make([]trie.SyncResult, len(hashes))
+		taskCount := (len(hashes) + nodeBatchSize - 1) / nodeBatchSize
+		err := d.runTasks(peers, taskCount, func(p *peer, index int) error {
+			start := index * nodeBatchSize
+			end := start + nodeBatchSize
+			if end > len(hashes) {
+				end = len(hashes)
+			}
+			data, err := d.requestNodeData(p, hashes[start:end])
+			if err != nil {
+				return err
+			}
+			if len(data) != end-start {
+				return ErrInvalidState
+			}
+			for i, item := range data {
+				if crypto.Keccak256Hash(item) != hashes[start+i] {
+					return ErrInvalidState
+				}
+				results[start+i] = trie.SyncResult{Hash: hashes[start+i], Data: item}
+			}
+			d.updateStatus(func(status *SyncStatus) {
+				status.PulledNodes += uint32(len(data))
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			log.Warnf("Process trie node %s failed: %v", results[index].Hash.Hex(), err)
+			return ErrInvalidState
+		}
+		if _, err := sched.Commit(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchAccounts fetches all accounts in the state of block page by page. Their versions are checked with the version trie in db, and their fields are proved by the change logs of stable blocks
+func (d *Downloader) fetchAccounts(peers []*peer, block *types.Block, db *store.MemDatabase) ([]*types.AccountData, error) {
+	prover := newStateProver(d, peers, block, db)
+	versionTrie, err := trie.NewSecure(block.VersionRoot(), prover.GetTrieDatabase(), account.MaxTrieCacheGen)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*types.AccountData, 0)
+	start := common.Address{}
+	for {
+		var page []*types.AccountData
+		unprovable := false
+		err := d.runTasks(peers, 1, func(p *peer, index int) error {
+			result, err := d.requestAccounts(p, block.Hash(), start, accountBatchSize)
+			if err != nil {
+				return err
+			}
+			if err := verifyAccounts(result, start, versionTrie); err != nil {
+				return err
+			}
+			// the versions and change logs are verified, so no peer could prove the account
+			if err := prover.prove(result); err == proof.ErrAccountUnprovable {
+				unprovable = true
+			} else if err != nil {
+				return err
+			}
+			page = result
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if unprovable {
+			return nil, ErrUnprovableState
+		}
+		accounts = append(accounts, page...)
+		d.updateStatus(func(status *SyncStatus) {
+			status.PulledAccounts += uint32(len(page))
+		})
+		if len(page) < accountBatchSize {
+			break
+		}
+		last := page[len(page)-1].Address
+		start = common.BigToAddress(new(big.Int).Add(last.Big(), big.NewInt(1)))
+		// the last address has been fetched
+		if start.Big().Cmp(last.Big()) <= 0 {
+			break
+		}
+	}
+
+	// every version in the trie should belong to a fetched account, so that no account is missing
+	recordCount := 0
+	for _, data := range accounts {
+		recordCount += len(data.NewestRecords)
+	}
+	leafCount := 0
+	it := trie.NewIterator(versionTrie.NodeIterator(nil))
+	for it.Next() {
+		leafCount++
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	if leafCount != recordCount {
+		log.Warnf("Some accounts are missing. versions in trie: %d, versions in accounts: %d", leafCount, recordCount)
+		return nil, ErrInvalidState
+	}
+	return accounts, nil
+}
+
+// verifyAccounts checks the accounts are sorted from start address, and their versions are same as the version trie
+func verifyAccounts(accounts []*types.AccountData, start common.Address, versionTrie *trie.SecureTrie) error {
+	if len(accounts) > accountBatchSize {
+		return ErrInvalidState
+	}
+	prev := start.Bytes()
+	for i, data := range accounts {
+		cmp := bytes.Compare(data.Address.Bytes(), prev)
+		if cmp < 0 || (i > 0 && cmp == 0) {
+			return ErrInvalidState
+		}
+		prev = data.Address.Bytes()
+		if len(data.NewestRecords) == 0 {
+			return ErrInvalidState
+		}
+		for logType, record := range data.NewestRecords {
+			val, err := versionTrie.TryGet(account.VersionTrieKey(data.Address, logType))
+			if err != nil {
+				return err
+			}
+			if new(big.Int).SetBytes(val).Cmp(new(big.Int).SetUint64(uint64(record.Version))) != 0 {
+				log.Warnf("The version of account %s is not same as version trie. logType: %s", data.Address.String(), logType)
+				return ErrInvalidState
+			}
+		}
+	}
+	return nil
+}
+
+// stateProver proves the synchronized accounts by the change logs in stable blocks. It implements proof.ChainReader by fetching the blocks and trie nodes from peers
+type stateProver struct {
+	d      *Downloader
+	peers  []*peer
+	pivot  *types.Block
+	blocks map[uint32]*types.Block // the verified stable blocks with change logs
+	trieDB *store.TrieDatabase
+}
+
+func newStateProver(d *Downloader, peers []*peer, pivot *types.Block, db *store.MemDatabase) *stateProver {
+	prover := &stateProver{d: d, peers: peers, pivot: pivot, blocks: make(map[uint32]*types.Block)}
+	cache, _ := store.NewMemDatabase()
+	prover.trieDB = store.NewTrieDatabase(&remoteNodeDB{MemDatabase: cache, synced: db, fetch: prover.fetchNode})
+	return prover
+}
+
+func (s *stateProver) GetBlockByHeight(height uint32) (*types.Block, error) {
+	if block, ok := s.blocks[height]; ok {
+		return block, nil
+	}
+	if err := s.fetchBlocks([]uint32{height}); err != nil {
+		return nil, err
+	}
+	return s.blocks[height], nil
+}
+
+func (s *stateProver) GetTrieDatabase() *store.TrieDatabase {
+	return s.trieDB
+}
+
+// header returns the header of verified stable block in height, or nil if it is not fetched
+func (s *stateProver) header(height uint32) *types.Header {
+	if height == s.pivot.Height() {
+		return s.pivot.Header
+	}
+	if block, ok := s.blocks[height]; ok {
+		return block.Header
+	}
+	return nil
+}
+
+// prove checks the fields of accounts with their newest change logs
+func (s *stateProver) prove(accounts []*types.AccountData) error {
+	heightSet := make(map[uint32]bool)
+	for _, data := range accounts {
+		for _, record := range data.NewestRecords {
+			if record.Height > s.pivot.Height() {
+				return ErrInvalidState
+			}
+			if _, ok := s.blocks[record.Height]; !ok {
+				heightSet[record.Height] = true
+			}
+		}
+	}
+	heights := make([]uint32, 0, len(heightSet))
+	for height := range heightSet {
+		heights = append(heights, height)
+	}
+	if err := s.fetchBlocks(heights); err != nil {
+		return err
+	}
+
+	for _, data := range accounts {
+		accountProof, err := proof.NewAccountProof(s, s.pivot.Header, data.Address, data)
+		if err != nil {
+			return err
+		}
+		err = proof.VerifyAccountProof(accountProof, s.header)
+		if err == proof.ErrAccountUnprovable {
+			return err
+		}
+		if err != nil {
+			log.Warnf("The account %s is not proved by change logs: %v", data.Address.String(), err)
+			return ErrInvalidState
+		}
+	}
+	return nil
+}
+
+// fetchBlocks fetches the stable blocks with change logs in heights
+func (s *stateProver) fetchBlocks(heights []uint32) error {
+	result := make(types.Blocks, len(heights))
+	err := s.d.runTasks(s.peers, len(heights), func(p *peer, index int) error {
+		block, err := s.d.requestBlockWithChangeLog(p, heights[index])
+		if err != nil {
+			return err
+		}
+		if err := s.d.verifyStable(block); err != nil {
+			return err
+		}
+		if block.ChangeLogs.MerkleRootSha() != block.Header.LogRoot {
+			return ErrInvalidState
+		}
+		result[index] = block
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, block := range result {
+		s.blocks[block.Height()] = block
+	}
+	return nil
+}
+
+// fetchNode fetches the trie node which is not synchronized, e.g. the node of version trie in early block
+func (s *stateProver) fetchNode(hash common.Hash) ([]byte, error) {
+	var node []byte
+	err := s.d.runTasks(s.peers, 1, func(p *peer, index int) error {
+		data, err := s.d.requestNodeData(p, []common.Hash{hash})
+		if err != nil {
+			return err
+		}
+		if len(data) != 1 || crypto.Keccak256Hash(data[0]) != hash {
+			return ErrInvalidState
+		}
+		node = data[0]
+		return nil
+	})
+	return node, err
+}
+
+// remoteNodeDB reads the trie nodes from the synchronized nodes, and fetches the missing ones from peers
+type remoteNodeDB struct {
+	*store.MemDatabase // the fetched nodes which are not in synced
+	synced             *store.MemDatabase
+	fetch              func(hash common.Hash) ([]byte, error)
+}
+
+func (db *remoteNodeDB) Get(flag uint32, key []byte) ([]byte, error) {
+	if value, err := db.synced.Get(flag, key); err == nil {
+		return value, nil
+	}
+	if value, err := db.MemDatabase.Get(flag, key); err == nil {
+		return value, nil
+	}
+	value, err := db.fetch(common.BytesToHash(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.MemDatabase.Put(flag, key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (db *remoteNodeDB) Has(flag uint32, key []byte) (bool, error) {
+	if ok, _ := db.synced.Has(flag, key); ok {
+		return true, nil
+	}
+	return db.MemDatabase.Has(flag, key)
+}
+
+// fetchCodes fetches the contract codes by hash
+func (d *Downloader) fetchCodes(peers []*peer, hashes []common.Hash) (map[common.Hash][]byte, error) {
+	codes := make(map[common.Hash][]byte, len(hashes))
+	results := make([][]byte, len(hashes))
+	taskCount := (len(hashes) + nodeBatchSize - 1) / nodeBatchSize
+	err := d.runTasks(peers, taskCount, func(p *peer, index int) error {
+		start := index * nodeBatchSize
+		end := start + nodeBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		data, err := d.requestNodeData(p, hashes[start:end])
+		if err != nil {
+			return err
+		}
+		if len(data) != end-start {
+			return ErrInvalidState
+		}
+		for i, item := range data {
+			if crypto.Keccak256Hash(item) != hashes[start+i] {
+				return ErrInvalidState
+			}
+			results[start+i] = item
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, hash := range hashes {
+		codes[hash] = results[i]
+	}
+	return codes, nil
+}
+
+// buildAssetIndexes rebuilds the indexes of asset code and asset id from the synchronized tries. They are built from transactions in full synchronization
+func buildAssetIndexes(trieDB *store.TrieDatabase, snapshot *store.Snapshot) error {
+	snapshot.AssetCodes = make(map[common.Hash]common.Address)
+	snapshot.AssetIds = make(map[common.Hash]common.Hash)
+	iterate := func(root common.Hash, fn func(value []byte) error) error {
+		if root == (common.Hash{}) {
+			return nil
+		}
+		tr, err := trie.NewSecure(root, trieDB, account.MaxTrieCacheGen)
+		if err != nil {
+			return err
+		}
+		it := trie.NewIterator(tr.NodeIterator(nil))
+		for it.Next() {
+			if err := fn(it.Value); err != nil {
+				return err
+			}
+		}
+		return it.Err
+	}
+	for _, data := range snapshot.Accounts {
+		issuer := data.Address
+		err := iterate(data.AssetCodeRoot, func(value []byte) error {
+			var asset types.Asset
+			if err := rlp.DecodeBytes(value, &asset); err != nil {
+				return err
+			}
+			snapshot.AssetCodes[asset.AssetCode] = issuer
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// the asset id which is not held by any account is useless, so it is ok to lose it
+		err = iterate(data.EquityRoot, func(value []byte) error {
+			var equity types.AssetEquity
+			if err := rlp.DecodeBytes(value, &equity); err != nil {
+				return err
+			}
+			snapshot.AssetIds[equity.AssetId] = equity.AssetCode
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestAccounts sends accounts request to peer and waits for the response
+func (d *Downloader) requestAccounts(p *peer, blockHash common.Hash, start common.Address, count uint32) ([]*types.AccountData, error) {
+	res, err := d.request(p, p2p.AccountsMsg, func() error {
+		return p.RequestAccounts(blockHash, start, count)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]*types.AccountData), nil
+}
+
+// requestBlockWithChangeLog sends the request of block with change logs in height to peer and waits for the response
+func (d *Downloader) requestBlockWithChangeLog(p *peer, height uint32) (*types.Block, error) {
+	res, err := d.request(p, p2p.BlocksMsg, func() error {
+		return p.RequestBlocksWithChangeLog(height, height)
+	})
+	if err != nil {
+		return nil, err
+	}
+	blocks := res.(types.Blocks)
+	if len(blocks) != 1 || blocks[0].Height() != height {
+		return nil, ErrInvalidState
+	}
+	return blocks[0], nil
+}
+
+// requestNodeData sends trie nodes request to peer and waits for the response
+func (d *Downloader) requestNodeData(p *peer, hashes []common.Hash) ([][]byte, error) {
+	res, err := d.request(p, p2p.NodeDataMsg, func() error {
+		return p.RequestNodeData(hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([][]byte), nil
+}
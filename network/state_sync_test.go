@@ -0,0 +1,342 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/account"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/deputynode"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/trie"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// stateTestChain is a syncTestChain which contains the state of stable block
+type stateTestChain struct {
+	*syncTestChain
+	stable   *types.Block
+	accounts []*types.AccountData // sorted by address
+	db       *store.MemDatabase
+	snapshot *store.Snapshot // the inserted snapshot
+}
+
+func (bc *stateTestChain) StableBlock() *types.Block {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+	return bc.stable
+}
+func (bc *stateTestChain) InsertSnapshot(snapshot *store.Snapshot) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	bc.blocks = append(bc.blocks[:1], make([]*types.Block, snapshot.Block.Height())...)
+	for _, block := range append(snapshot.Terms, snapshot.Block) {
+		bc.blocks[block.Height()] = block
+		bc.hashes[block.Hash()] = block
+	}
+	bc.stable = snapshot.Block
+	bc.snapshot = snapshot
+	return nil
+}
+func (bc *stateTestChain) GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error) {
+	if bc.GetBlockByHash(blockHash) == nil {
+		return nil, store.ErrBlockNotExist
+	}
+	result := make([]*types.AccountData, 0, count)
+	for _, data := range bc.accounts {
+		if data.Address.Big().Cmp(start.Big()) >= 0 && len(result) < count {
+			result = append(result, data)
+		}
+	}
+	return result, nil
+}
+func (bc *stateTestChain) GetNodeData(hash common.Hash) ([]byte, error) {
+	val, _ := bc.db.Get(0, hash.Bytes())
+	if val == nil {
+		return nil, store.ErrNotExist
+	}
+	return val, nil
+}
+
+var (
+	testCode      = []byte{0x60, 0x01, 0x60, 0x02}
+	testAssetCode = common.HexToHash("0xa1")
+	testAssetId   = common.HexToHash("0xb1")
+)
+
+// makeSyncState creates some accounts with tries, and returns the accounts, the version root and the change logs which set the accounts in block 1
+func makeSyncState(db *store.MemDatabase, count int) ([]*types.AccountData, common.Hash, types.ChangeLogSlice) {
+	trieDB := store.NewTrieDatabase(db)
+	commit := func(values map[common.Hash][]byte) common.Hash {
+		tr, _ := trie.NewSecure(common.Hash{}, trieDB, account.MaxTrieCacheGen)
+		for key, value := range values {
+			tr.Update(key.Bytes(), value)
+		}
+		root, _ := tr.Commit(nil)
+		trieDB.Commit(root, false)
+		return root
+	}
+	logs := make(types.ChangeLogSlice, 0)
+	addLog := func(data *types.AccountData, logType types.ChangeLogType, newVal, extra interface{}) {
+		version := data.NewestRecords[logType].Version + 1
+		data.NewestRecords[logType] = types.VersionRecord{Version: version, Height: 1}
+		logs = append(logs, &types.ChangeLog{LogType: logType, Address: data.Address, Version: version, NewVal: newVal, Extra: extra})
+	}
+
+	versionTrie, _ := trie.NewSecure(common.Hash{}, trieDB, account.MaxTrieCacheGen)
+	accounts := make([]*types.AccountData, count)
+	for i := range accounts {
+		data := &types.AccountData{
+			Address:       common.BigToAddress(big.NewInt(int64(i + 1))),
+			Balance:       big.NewInt(int64(i * 100)),
+			NewestRecords: make(map[types.ChangeLogType]types.VersionRecord),
+		}
+		addLog(data, account.BalanceLog, *data.Balance, nil)
+		if i == 0 {
+			data.CodeHash = crypto.Keccak256Hash(testCode)
+			addLog(data, account.CodeLog, types.Code(testCode), nil)
+			data.StorageRoot = commit(map[common.Hash][]byte{{0x01}: {0x02}, {0x03}: {0x04}})
+			addLog(data, account.StorageLog, []byte{0x04}, common.Hash{0x03})
+			addLog(data, account.StorageRootLog, data.StorageRoot, nil)
+			asset := &types.Asset{AssetCode: testAssetCode, Issuer: data.Address, TotalSupply: big.NewInt(1)}
+			assetBuf, _ := rlp.EncodeToBytes(asset)
+			data.AssetCodeRoot = commit(map[common.Hash][]byte{testAssetCode: assetBuf})
+			addLog(data, account.AssetCodeLog, asset, testAssetCode)
+			addLog(data, account.AssetCodeRootLog, data.AssetCodeRoot, nil)
+			equity := &types.AssetEquity{AssetCode: testAssetCode, AssetId: testAssetId, Equity: big.NewInt(1)}
+			equityBuf, _ := rlp.EncodeToBytes(equity)
+			data.EquityRoot = commit(map[common.Hash][]byte{testAssetId: equityBuf})
+			addLog(data, account.EquityLog, equity, testAssetId)
+			addLog(data, account.EquityRootLog, data.EquityRoot, nil)
+		}
+		for logType, record := range data.NewestRecords {
+			versionTrie.Update(account.VersionTrieKey(data.Address, logType), big.NewInt(int64(record.Version)).Bytes())
+		}
+		accounts[i] = data
+	}
+	root, _ := versionTrie.Commit(nil)
+	trieDB.Commit(root, false)
+	db.Put(0, crypto.Keccak256(testCode), testCode)
+	return accounts, root, logs
+}
+
+// makeStateBlocks creates blocks with the version root. The change logs are in block 1. The term snapshot blocks contain the deputy node of key
+func makeStateBlocks(key *ecdsa.PrivateKey, count int, versionRoot common.Hash, logs types.ChangeLogSlice) []*types.Block {
+	deputies := types.DeputyNodes{
+		&types.DeputyNode{
+			MinerAddress: crypto.PubkeyToAddress(key.PublicKey),
+			NodeID:       crypto.PrivateKeyToNodeID(key),
+			Rank:         0,
+			Votes:        big.NewInt(5),
+		},
+	}
+	blocks := []*types.Block{{Header: &types.Header{Height: 0}, DeputyNodes: deputies}}
+	for i := 1; i <= count; i++ {
+		header := &types.Header{
+			ParentHash:   blocks[i-1].Hash(),
+			MinerAddress: deputies[0].MinerAddress,
+			VersionRoot:  versionRoot,
+			TxRoot:       types.Transactions{}.MerkleRootSha(),
+			LogRoot:      types.ChangeLogSlice{}.MerkleRootSha(),
+			Height:       uint32(i),
+			Time:         uint32(1538210391 + i),
+		}
+		block := &types.Block{Header: header}
+		if i == 1 {
+			header.LogRoot = logs.MerkleRootSha()
+			block.ChangeLogs = logs
+		}
+		if deputynode.IsSnapshotBlock(header.Height) {
+			root := deputies.MerkleRootSha()
+			header.DeputyRoot = root[:]
+			block.DeputyNodes = deputies
+		}
+		hash := header.Hash()
+		header.SignData, _ = crypto.Sign(hash[:], key)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestDownloader_SyncSnapshot(t *testing.T) {
+	oldTermDuration, oldInterimDuration := params.TermDuration, params.InterimDuration
+	params.TermDuration, params.InterimDuration = 10, 2
+	defer func() { params.TermDuration, params.InterimDuration = oldTermDuration, oldInterimDuration }()
+	oldTimeout := fetchTimeout
+	fetchTimeout = 500 * time.Millisecond
+	defer func() { fetchTimeout = oldTimeout }()
+
+	key, _ := crypto.GenerateKey()
+	remoteDB, _ := store.NewMemDatabase()
+	accounts, versionRoot, logs := makeSyncState(remoteDB, 600)
+	blocks := makeStateBlocks(key, 30, versionRoot, logs)
+	remoteChain := &stateTestChain{syncTestChain: newSyncTestChain(blocks), stable: blocks[25], accounts: accounts, db: remoteDB}
+	remote := newSyncTestPmWithChain(key, remoteChain)
+	defer remote.Stop()
+	localChain := &stateTestChain{syncTestChain: newSyncTestChain(blocks[:1]), stable: blocks[0]}
+	local := newSyncTestPmWithChain(key, localChain)
+	defer local.Stop()
+
+	master, _ := connectSyncPeer(local, remote, 1, syncPeerNormal)
+	other, _ := connectSyncPeer(local, remote, 2, syncPeerNormal)
+	silent, _ := connectSyncPeer(local, remote, 3, syncPeerSilent)
+	another, _ := connectSyncPeer(local, remote, 4, syncPeerNormal)
+	for _, p := range []*peer{master, other, silent, another} {
+		p.lstStatus.StaHeight = 25
+		p.lstStatus.StaHash = blocks[25].Hash()
+	}
+
+	assert.NoError(t, local.downloader.SyncSnapshot(master))
+	snapshot := localChain.snapshot
+	assert.Equal(t, blocks[25].Hash(), snapshot.Block.Hash())
+	assert.Equal(t, 2, len(snapshot.Terms))
+	assert.Equal(t, blocks[10].Hash(), snapshot.Terms[0].Hash())
+	assert.Equal(t, blocks[20].Hash(), snapshot.Terms[1].Hash())
+	term, err := local.dm.GetTermByHeight(20, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), term.TermIndex)
+	assert.Equal(t, len(accounts), len(snapshot.Accounts))
+	for i, data := range snapshot.Accounts {
+		assert.Equal(t, accounts[i].Address, data.Address)
+		assert.Equal(t, accounts[i].Balance, data.Balance)
+		assert.Equal(t, accounts[i].NewestRecords, data.NewestRecords)
+	}
+	assert.Equal(t, testCode, snapshot.Codes[crypto.Keccak256Hash(testCode)])
+	assert.Equal(t, accounts[0].Address, snapshot.AssetCodes[testAssetCode])
+	assert.Equal(t, testAssetCode, snapshot.AssetIds[testAssetId])
+	// all trie nodes are synchronized
+	for hash, node := range snapshot.Nodes {
+		val, err := remoteChain.GetNodeData(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, val, node)
+	}
+
+	status := local.SyncStatus()
+	assert.Equal(t, uint32(600), status.PulledAccounts)
+	assert.Equal(t, uint32(len(snapshot.Nodes)), status.PulledNodes)
+
+	// the blocks after snapshot block can be synchronized
+	assert.NoError(t, local.downloader.Synchronise(master, 26, 30))
+	assert.Equal(t, blocks[30].Hash(), local.chain.CurrentBlock().Hash())
+
+	// nothing to synchronize
+	assert.NoError(t, local.downloader.SyncSnapshot(master))
+}
+
+func TestDownloader_SyncSnapshot_invalid(t *testing.T) {
+	oldTimeout := fetchTimeout
+	fetchTimeout = 200 * time.Millisecond
+	defer func() { fetchTimeout = oldTimeout }()
+
+	key, _ := crypto.GenerateKey()
+	remoteDB, _ := store.NewMemDatabase()
+	accounts, versionRoot, logs := makeSyncState(remoteDB, 3)
+	blocks := makeStateBlocks(key, 5, versionRoot, logs)
+
+	// an account is missing
+	remoteChain := &stateTestChain{syncTestChain: newSyncTestChain(blocks), stable: blocks[5], accounts: accounts[1:], db: remoteDB}
+	remote := newSyncTestPmWithChain(key, remoteChain)
+	defer remote.Stop()
+	localChain := &stateTestChain{syncTestChain: newSyncTestChain(blocks[:1]), stable: blocks[0]}
+	local := newSyncTestPmWithChain(key, localChain)
+	defer local.Stop()
+	master, _ := connectSyncPeer(local, remote, 1, syncPeerNormal)
+	master.lstStatus.StaHeight = 5
+	master.lstStatus.StaHash = blocks[5].Hash()
+	assert.Equal(t, ErrInvalidState, local.downloader.SyncSnapshot(master))
+	assert.Nil(t, localChain.snapshot)
+
+	// the stable block is not the block in status
+	master.lstStatus.StaHash = blocks[4].Hash()
+	assert.Equal(t, ErrInvalidHeaders, local.downloader.SyncSnapshot(master))
+
+	// the block is not signed by deputy node
+	otherKey, _ := crypto.GenerateKey()
+	other := newSyncTestPm(otherKey, blocks[:1])
+	defer other.Stop()
+	assert.Equal(t, ErrInvalidHeaders, other.downloader.verifyStable(blocks[5]))
+
+	// the confirm is not signed by deputy node
+	block := &types.Block{Header: blocks[5].Header}
+	sig, _ := crypto.Sign(block.Hash().Bytes(), otherKey)
+	block.Confirms = []types.SignData{types.BytesToSignData(sig)}
+	assert.Equal(t, ErrUnconfirmedStable, local.downloader.verifyStable(block))
+}
+
+func TestDownloader_fetchAccounts(t *testing.T) {
+	oldTimeout := fetchTimeout
+	fetchTimeout = 200 * time.Millisecond
+	defer func() { fetchTimeout = oldTimeout }()
+
+	key, _ := crypto.GenerateKey()
+	remoteDB, _ := store.NewMemDatabase()
+	accounts, versionRoot, logs := makeSyncState(remoteDB, 3)
+	blocks := makeStateBlocks(key, 5, versionRoot, logs)
+	remote := newSyncTestPmWithChain(key, &stateTestChain{syncTestChain: newSyncTestChain(blocks), stable: blocks[5], accounts: accounts, db: remoteDB})
+	defer remote.Stop()
+	// the balance is forged, but the versions are right
+	forged := make([]*types.AccountData, len(accounts))
+	for i, data := range accounts {
+		fake := *data
+		fake.Balance = big.NewInt(1000000)
+		forged[i] = &fake
+	}
+	evil := newSyncTestPmWithChain(key, &stateTestChain{syncTestChain: newSyncTestChain(blocks), stable: blocks[5], accounts: forged, db: remoteDB})
+	defer evil.Stop()
+	local := newSyncTestPmWithChain(key, &stateTestChain{syncTestChain: newSyncTestChain(blocks[:1]), stable: blocks[0]})
+	defer local.Stop()
+
+	// the trie nodes are fetched from peers if they are not synchronized
+	db, _ := store.NewMemDatabase()
+	p1, _ := connectSyncPeer(local, evil, 1, syncPeerNormal)
+	_, err := local.downloader.fetchAccounts([]*peer{p1}, blocks[5], db)
+	assert.Equal(t, ErrNoPeersToSync, err)
+	assert.Equal(t, -offenceInvalidBlock.penalty, p1.Score())
+
+	p2, _ := connectSyncPeer(local, evil, 2, syncPeerNormal)
+	p3, _ := connectSyncPeer(local, remote, 3, syncPeerNormal)
+	result, err := local.downloader.fetchAccounts([]*peer{p2, p3}, blocks[5], db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(accounts), len(result))
+	for i, data := range result {
+		assert.Equal(t, accounts[i].Balance, data.Balance)
+	}
+
+	// the change logs are not match the LogRoot
+	tampered := append([]*types.Block{}, blocks...)
+	tampered[1] = &types.Block{Header: blocks[1].Header, ChangeLogs: logs[1:]}
+	fake := newSyncTestPmWithChain(key, &stateTestChain{syncTestChain: newSyncTestChain(tampered), stable: tampered[5], accounts: accounts, db: remoteDB})
+	defer fake.Stop()
+	p4, _ := connectSyncPeer(local, fake, 4, syncPeerNormal)
+	_, err = newStateProver(local.downloader, []*peer{p4}, blocks[5], db).GetBlockByHeight(1)
+	assert.Equal(t, ErrNoPeersToSync, err)
+	assert.Equal(t, -offenceInvalidBlock.penalty, p4.Score())
+}
+
+func TestVerifyAccounts(t *testing.T) {
+	db, _ := store.NewMemDatabase()
+	accounts, versionRoot, _ := makeSyncState(db, 3)
+	versionTrie, err := trie.NewSecure(versionRoot, store.NewTrieDatabase(db), account.MaxTrieCacheGen)
+	assert.NoError(t, err)
+
+	assert.NoError(t, verifyAccounts(accounts, common.Address{}, versionTrie))
+	assert.NoError(t, verifyAccounts(accounts[1:], accounts[1].Address, versionTrie))
+	// lower than start
+	assert.Equal(t, ErrInvalidState, verifyAccounts(accounts, accounts[1].Address, versionTrie))
+	// not sorted
+	assert.Equal(t, ErrInvalidState, verifyAccounts([]*types.AccountData{accounts[1], accounts[0]}, common.Address{}, versionTrie))
+	// duplicated
+	assert.Equal(t, ErrInvalidState, verifyAccounts([]*types.AccountData{accounts[1], accounts[1]}, common.Address{}, versionTrie))
+	// wrong version
+	fake := *accounts[2]
+	fake.NewestRecords = map[types.ChangeLogType]types.VersionRecord{account.BalanceLog: {Version: 100, Height: 1}}
+	assert.Equal(t, ErrInvalidState, verifyAccounts([]*types.AccountData{&fake}, common.Address{}, versionTrie))
+	// no version
+	fake.NewestRecords = nil
+	assert.Equal(t, ErrInvalidState, verifyAccounts([]*types.AccountData{&fake}, common.Address{}, versionTrie))
+}
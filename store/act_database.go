@@ -641,6 +641,8 @@ func (trie *PatriciaTrie) all(curNode *PatriciaNode, all []types.NodeData) []typ
 type AccountTrieDB struct {
 	trie    *PatriciaTrie
 	beansdb *BeansDB
+	// the accounts are loaded from history at the height if it is not nil
+	historyHeight *uint32
 }
 
 func NewEmptyAccountTrieDB(beansdb *BeansDB) *AccountTrieDB {
@@ -657,10 +659,20 @@ func NewAccountTrieDB(trie *PatriciaTrie, beansdb *BeansDB) *AccountTrieDB {
 	}
 }
 
+// NewHistoryAccountTrieDB creates an AccountTrieDB which loads the accounts' states at the stable block height
+func NewHistoryAccountTrieDB(beansdb *BeansDB, height uint32) *AccountTrieDB {
+	return &AccountTrieDB{
+		trie:          NewEmptyDatabase(),
+		beansdb:       beansdb,
+		historyHeight: &height,
+	}
+}
+
 func (db *AccountTrieDB) Clone() *AccountTrieDB {
 	return &AccountTrieDB{
-		beansdb: db.beansdb,
-		trie:    NewActDatabase(db.trie),
+		beansdb:       db.beansdb,
+		trie:          NewActDatabase(db.trie),
+		historyHeight: db.historyHeight,
 	}
 }
 
@@ -693,7 +705,13 @@ func (db *AccountTrieDB) Get(address common.Address) (*types.AccountData, error)
 	key := address.Hex()
 	data := db.trie.Find(key)
 	if data == nil {
-		account, err := UtilsGetAccount(db.beansdb, address)
+		var account *types.AccountData
+		var err error
+		if db.historyHeight != nil {
+			account, err = UtilsGetAccountByHeight(db.beansdb, address, *db.historyHeight)
+		} else {
+			account, err = UtilsGetAccount(db.beansdb, address)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,364 @@
+package store
+
+import (
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// compactDeleteBatch is the count of items which are deleted in one batch in compaction
+const compactDeleteBatch = 10000
+
+const (
+	BackendBitCask = "bitcask" // the items are appended to bitcask files, and their positions are indexed in LevelDB
+	BackendLevelDB = "leveldb" // the items are stored in a LevelDB which is separated from the index
+	BackendMemory  = "memory"  // the items are stored in memory. It is only used in tests
+)
+
+// ItemIterator is implemented by the backends which can traverse all stored items
+type ItemIterator interface {
+	Iterate(fn func(flag uint32, key, val []byte) error) error
+}
+
+// CheckBackend returns error if the backend can't store the chain data on disk
+func CheckBackend(backend string) error {
+	if backend == BackendBitCask || backend == BackendLevelDB {
+		return nil
+	}
+	return ErrUnknownBackend
+}
+
+// newBackend creates the database which stores the items in home
+func newBackend(backend string, home string, levelDB *leveldb.LevelDBDatabase) (Database, error) {
+	switch backend {
+	case BackendBitCask:
+		return NewBitCaskDatabase(home, levelDB)
+	case BackendLevelDB:
+		return NewLevelItemDatabase(filepath.Join(home, "items")), nil
+	case BackendMemory:
+		db, _ := NewMemDatabase()
+		return &memItemDatabase{db}, nil
+	default:
+		return nil, ErrUnknownBackend
+	}
+}
+
+// openBackend creates the backend which is recorded in LevelDB. The backend is recorded at the first time. If backend is empty, the recorded one is used
+func openBackend(backend string, home string, levelDB *leveldb.LevelDBDatabase) (Database, string, error) {
+	// the data in memory is lost after closing, so it is not recorded
+	if backend == BackendMemory {
+		db, err := newBackend(backend, home, levelDB)
+		return db, backend, err
+	}
+
+	recorded, err := leveldb.GetStoreBackend(levelDB)
+	if err != nil {
+		return nil, "", err
+	}
+	if recorded == "" {
+		// the database which is created before the backend is recorded is always stored in bitcask
+		if stable, err := leveldb.GetCurrentBlock(levelDB); err == nil && stable != (common.Hash{}) {
+			recorded = BackendBitCask
+		}
+	}
+	if backend == "" {
+		backend = recorded
+	}
+	if backend == "" {
+		backend = BackendBitCask
+	}
+	if recorded != "" && recorded != backend {
+		return nil, "", fmt.Errorf("%v: the database in %s is stored by %s, but %s is required", ErrBackendMismatch, home, recorded, backend)
+	}
+
+	db, err := newBackend(backend, home, levelDB)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := leveldb.SetStoreBackend(levelDB, backend); err != nil {
+		return nil, "", err
+	}
+	return db, backend, nil
+}
+
+// BitCaskDatabase routes the items to bitcasks by the first byte of key
+type BitCaskDatabase struct {
+	Home     string
+	Height   uint // the count of hex digits in key which are used to route items
+	BitCasks []*BitCask
+	LevelDB  *leveldb.LevelDBDatabase
+}
+
+func bitCaskPath(home string, index int) string {
+	dataPathModule := filepath.Join(home, "/%02d/%02d/")
+	return fmt.Sprintf(dataPathModule, index>>4, index&0xf)
+}
+
+func NewBitCaskDatabase(home string, levelDB *leveldb.LevelDBDatabase) (*BitCaskDatabase, error) {
+	db := &BitCaskDatabase{
+		Home:    home,
+		Height:  2,
+		LevelDB: levelDB,
+	}
+	db.BitCasks = make([]*BitCask, 1<<(db.Height*4))
+	for index := range db.BitCasks {
+		bitCask, err := NewBitCask(bitCaskPath(home, index), index, levelDB)
+		if err != nil {
+			return nil, err
+		}
+		db.BitCasks[index] = bitCask
+	}
+	return db, nil
+}
+
+func (db *BitCaskDatabase) route(key []byte) *BitCask {
+	num := Byte2Uint32(key)
+	index := num >> ((8 - db.Height) * 4)
+	return db.BitCasks[index]
+}
+
+func (db *BitCaskDatabase) NewBatch() Batch {
+	return &LmDBBatch{
+		db:    db,
+		items: make([]*BatchItem, 0),
+		size:  0,
+	}
+}
+
+func (db *BitCaskDatabase) Commit(batch Batch) error {
+	for _, item := range batch.Items() {
+		if err := db.Put(item.Flg, item.Key, item.Val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *BitCaskDatabase) Put(flag uint32, key []byte, val []byte) error {
+	return db.route(key).Put(flag, key, val)
+}
+
+func (db *BitCaskDatabase) Get(flag uint32, key []byte) ([]byte, error) {
+	return db.route(key).Get(flag, key)
+}
+
+func (db *BitCaskDatabase) Has(flag uint32, key []byte) (bool, error) {
+	val, err := db.Get(flag, key)
+	return val != nil, err
+}
+
+func (db *BitCaskDatabase) Delete(flag uint32, key []byte) error {
+	return db.route(key).Delete(flag, key)
+}
+
+// Close does nothing, because the LevelDB is closed by its owner
+func (db *BitCaskDatabase) Close() {}
+
+func (db *BitCaskDatabase) Iterate(fn func(flag uint32, key, val []byte) error) error {
+	for _, bitcask := range db.BitCasks {
+		if err := bitcask.Iterate(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LevelItemDatabase stores the items in its own LevelDB with the same keys as the item positions in BitCaskDatabase, so the key scanning works in both backends.
+// The items are not mixed with the index, so the pruned items can be deleted from disk
+type LevelItemDatabase struct {
+	LevelDB *leveldb.LevelDBDatabase
+
+	lock    sync.Mutex
+	written map[string]bool // the keys which are written during compaction
+}
+
+func NewLevelItemDatabase(home string) *LevelItemDatabase {
+	return &LevelItemDatabase{LevelDB: leveldb.NewLevelDBDatabase(home, 16, 16)}
+}
+
+func (db *LevelItemDatabase) NewBatch() Batch {
+	return &LmDBBatch{
+		db:    db,
+		items: make([]*BatchItem, 0),
+		size:  0,
+	}
+}
+
+func (db *LevelItemDatabase) Commit(batch Batch) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	ldbBatch := db.LevelDB.NewBatch()
+	for _, item := range batch.Items() {
+		db.markWritten(item.Flg, item.Key)
+		if err := ldbBatch.Put(leveldb.Key(item.Flg, item.Key), item.Val); err != nil {
+			return err
+		}
+	}
+	return ldbBatch.Write()
+}
+
+func (db *LevelItemDatabase) Put(flag uint32, key []byte, val []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.markWritten(flag, key)
+	return db.LevelDB.Put(leveldb.Key(flag, key), val)
+}
+
+func (db *LevelItemDatabase) Get(flag uint32, key []byte) ([]byte, error) {
+	return db.LevelDB.Get(leveldb.Key(flag, key))
+}
+
+func (db *LevelItemDatabase) Has(flag uint32, key []byte) (bool, error) {
+	return db.LevelDB.Has(leveldb.Key(flag, key))
+}
+
+func (db *LevelItemDatabase) Delete(flag uint32, key []byte) error {
+	return db.LevelDB.Delete(leveldb.Key(flag, key))
+}
+
+func (db *LevelItemDatabase) Close() {
+	db.LevelDB.Close()
+}
+
+func (db *LevelItemDatabase) Iterate(fn func(flag uint32, key, val []byte) error) error {
+	iter := db.LevelDB.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		flag, key, ok := leveldb.ParseKey(iter.Key())
+		if !ok {
+			continue
+		}
+		if err := fn(flag, common.CopyBytes(key), common.CopyBytes(iter.Value())); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// markWritten records the key which is written during compaction. It must be called with lock
+func (db *LevelItemDatabase) markWritten(flag uint32, key []byte) {
+	if db.written != nil {
+		db.written[indexEntryKey(flag, key)] = true
+	}
+}
+
+// startCompact starts recording the written keys. It works as the watermark of bitcask, the items written after it are always kept
+func (db *LevelItemDatabase) startCompact() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.written = make(map[string]bool)
+}
+
+// deleteItems deletes the keys which are not written during compaction
+func (db *LevelItemDatabase) deleteItems(entries []*indexEntry) (int, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	batch := db.LevelDB.NewBatch()
+	count := 0
+	for _, entry := range entries {
+		if db.written[indexEntryKey(entry.flag, entry.key)] {
+			continue
+		}
+		if err := batch.Delete(leveldb.Key(entry.flag, entry.key)); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, batch.Write()
+}
+
+// Compact deletes the items which are refused by keep filter and not written after startCompact, then compacts the LevelDB files to reclaim disk space.
+// If keep is nil, only the overwritten data is reclaimed
+func (db *LevelItemDatabase) Compact(keep CompactFilter, quit <-chan struct{}) (*CompactStats, error) {
+	defer func() {
+		db.lock.Lock()
+		db.written = nil
+		db.lock.Unlock()
+	}()
+
+	stats := &CompactStats{}
+	oldSize, err := dirSize(db.LevelDB.Path())
+	if err != nil {
+		return nil, err
+	}
+	if keep != nil {
+		iter := db.LevelDB.NewIterator()
+		dropped := make([]*indexEntry, 0)
+		for iter.Next() {
+			flag, key, ok := leveldb.ParseKey(iter.Key())
+			if !ok || keep(flag, key) {
+				continue
+			}
+			dropped = append(dropped, &indexEntry{flag: flag, key: common.CopyBytes(key)})
+			if len(dropped) < compactDeleteBatch {
+				continue
+			}
+			select {
+			case <-quit:
+				iter.Release()
+				return stats, ErrCompactAborted
+			default:
+			}
+			count, err := db.deleteItems(dropped)
+			if err != nil {
+				iter.Release()
+				return nil, err
+			}
+			stats.Dropped += count
+			dropped = dropped[:0]
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+		count, err := db.deleteItems(dropped)
+		if err != nil {
+			return nil, err
+		}
+		stats.Dropped += count
+	}
+	if err := db.LevelDB.LDB().CompactRange(util.Range{}); err != nil {
+		return nil, err
+	}
+	newSize, err := dirSize(db.LevelDB.Path())
+	if err != nil {
+		return nil, err
+	}
+	stats.Reclaimed = oldSize - newSize
+	return stats, nil
+}
+
+// dirSize returns the total size of files in the directory
+func dirSize(path string) (int64, error) {
+	size := int64(0)
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// memItemDatabase returns nil for the missing item like other backends
+type memItemDatabase struct {
+	*MemDatabase
+}
+
+func (db *memItemDatabase) Get(flag uint32, key []byte) ([]byte, error) {
+	if ok, _ := db.Has(flag, key); !ok {
+		return nil, nil
+	}
+	return db.MemDatabase.Get(flag, key)
+}
@@ -19,7 +19,9 @@ import (
 type BeansDB struct {
 	Home    string
 	LevelDB *leveldb.LevelDBDatabase
+	Backend Database // stores the items. The bitcask files are used if it is nil
 	Queue   *FileQueue
+	Extend  WriteExtend // notified after the data is written to disk. It can be nil
 }
 
 func NewBeansDB(home string, levelDB *leveldb.LevelDBDatabase) *BeansDB {
@@ -30,11 +32,31 @@ func NewBeansDB(home string, levelDB *leveldb.LevelDBDatabase) *BeansDB {
 }
 
 func (beansdb *BeansDB) Start() {
-	beansdb.Queue = NewFileQueue(beansdb.Home, beansdb.LevelDB, beansdb)
+	if beansdb.Backend == nil {
+		backend, err := NewBitCaskDatabase(beansdb.Home, beansdb.LevelDB)
+		if err != nil {
+			panic("create bit cask err: " + err.Error())
+		}
+		beansdb.Backend = backend
+	}
+	beansdb.Queue = NewFileQueue(beansdb.Home, beansdb.LevelDB, beansdb.Backend, beansdb)
 	beansdb.Queue.Start()
 }
 
 func (beansdb *BeansDB) After(flg uint32, key []byte, val []byte) error {
+	err := beansdb.after(flg, key, val)
+	if err != nil {
+		return err
+	}
+
+	if beansdb.Extend == nil {
+		return nil
+	} else {
+		return beansdb.Extend.After(flg, key, val)
+	}
+}
+
+func (beansdb *BeansDB) after(flg uint32, key []byte, val []byte) error {
 	if flg == leveldb.ItemFlagBlock {
 		log.Debugf("after flag: ItemFlagBlock")
 		return beansdb.afterBlock(key, val)
@@ -62,6 +84,15 @@ func (beansdb *BeansDB) After(flg uint32, key []byte, val []byte) error {
 	} else if flg == leveldb.ItemFlagAssetId {
 		// log.Debugf("after flag: ItemFlagAssetId")
 		return nil
+	} else if flg == leveldb.ItemFlagReceipts {
+		// log.Debugf("after flag: ItemFlagReceipts")
+		return nil
+	} else if flg == leveldb.ItemFlagBloom {
+		// log.Debugf("after flag: ItemFlagBloom")
+		return nil
+	} else if flg == leveldb.ItemFlagActHistory {
+		// log.Debugf("after flag: ItemFlagActHistory")
+		return nil
 	} else {
 		panic("after! unknown flag.flag = " + strconv.Itoa(int(flg)))
 	}
@@ -170,6 +201,7 @@ func (beansdb *BeansDB) Delete(flg uint32, key []byte) error {
 
 func (beansdb *BeansDB) Close() {
 	beansdb.Queue.Close()
+	beansdb.Backend.Close()
 }
 
 // ///////////////////////
@@ -545,20 +577,17 @@ func (context *RunContext) encodeBody() ([]byte, error) {
 	return totalBuf, nil
 }
 
+// flush writes the context into a temp file and renames it, so that the context file is never broken by crash
 func (context *RunContext) flush(headBuf, bodyBuf []byte) error {
-	file, err := os.OpenFile(context.Path, os.O_WRONLY, os.ModePerm)
-	defer file.Close()
-	if err != nil {
-		return err
-	}
-
-	_, err = file.Seek(0, 0)
+	tmpPath := context.Path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
 	n, err := file.Write(headBuf)
 	if err != nil {
+		file.Close()
 		return err
 	}
 
@@ -566,21 +595,28 @@ func (context *RunContext) flush(headBuf, bodyBuf []byte) error {
 		panic("n != len(head data)")
 	}
 
-	_, err = file.Seek(int64(len(headBuf)), 0)
+	n, err = file.Write(bodyBuf)
 	if err != nil {
+		file.Close()
 		return err
 	}
 
-	n, err = file.Write(bodyBuf)
+	if n != len(bodyBuf) {
+		panic("n != len(body data)")
+	}
+
+	err = file.Sync()
 	if err != nil {
+		file.Close()
 		return err
 	}
 
-	if n != len(bodyBuf) {
-		panic("n != len(body data)")
+	err = file.Close()
+	if err != nil {
+		return err
 	}
 
-	return file.Sync()
+	return os.Rename(tmpPath, context.Path)
 }
 
 func (context *RunContext) Flush() error {
@@ -24,14 +24,13 @@ func TestBeansDB_Put(t *testing.T) {
 	val, err := CreateBufWithNumber(512)
 	assert.NoError(t, err)
 
-	err = beansdb.Put(leveldb.ItemFlagBlock, key, val)
+	// the random value is not a block, so it is put as a trie node
+	err = beansdb.Put(leveldb.ItemFlagTrie, key, val)
 	assert.NoError(t, err)
 
-	result, err := beansdb.Get(leveldb.ItemFlagBlock, key)
+	result, err := beansdb.Get(leveldb.ItemFlagTrie, key)
 	assert.NoError(t, err)
 	assert.Equal(t, val, result)
-
-	// time.Sleep(500 * time.Millisecond)
 }
 
 func TestBeansDB_Commit(t *testing.T) {
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"encoding/binary"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
@@ -27,6 +28,47 @@ func UtilsGetBlockByHash(db *BeansDB, hash common.Hash) (*types.Block, error) {
 	}
 }
 
+// receiptsKey returns the key of block's receipts. It must be different from the block's key, because the items in FileQueue are indexed by key only
+func receiptsKey(blockHash common.Hash) []byte {
+	return append([]byte("R"), blockHash.Bytes()...)
+}
+
+func UtilsGetReceipts(db *BeansDB, blockHash common.Hash) (types.Receipts, error) {
+	val, err := db.Get(leveldb.ItemFlagReceipts, receiptsKey(blockHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if val == nil {
+		return nil, ErrReceiptNotExist
+	}
+
+	var receipts types.Receipts
+	err = rlp.DecodeBytes(val, &receipts)
+	if err != nil {
+		return nil, err
+	} else {
+		return receipts, nil
+	}
+}
+
+// bloomKey returns the key of block's events bloom
+func bloomKey(blockHash common.Hash) []byte {
+	return append([]byte("L"), blockHash.Bytes()...)
+}
+
+func UtilsGetBloom(db *BeansDB, blockHash common.Hash) (types.Bloom, error) {
+	val, err := db.Get(leveldb.ItemFlagBloom, bloomKey(blockHash))
+	if err != nil {
+		return types.Bloom{}, err
+	}
+
+	if val == nil {
+		return types.Bloom{}, ErrBloomNotExist
+	}
+	return types.BytesToBloom(val), nil
+}
+
 func UtilsGetBlockByHeight(db *BeansDB, height uint32) (*types.Block, error) {
 	val, err := db.Get(leveldb.ItemFlagBlockHeight, leveldb.EncodeNumber(height))
 	if err != nil {
@@ -94,6 +136,150 @@ func UtilsGetAccount(db *BeansDB, address common.Address) (*types.AccountData, e
 	}
 }
 
+// actHistoryHeadKey returns the key of the height which the account is changed at last time
+func actHistoryHeadKey(address common.Address) []byte {
+	return append([]byte("H"), address.Bytes()...)
+}
+
+// actHistoryKey returns the key of the account's history record at the height
+func actHistoryKey(address common.Address, height uint32) []byte {
+	return append(actHistoryHeadKey(address), leveldb.EncodeNumber(height)...)
+}
+
+// accountHistory is the account data after it is changed in a stable block. The records of an account are linked by PrevHeight
+type accountHistory struct {
+	Account    *types.AccountData
+	PrevHeight uint32
+	HasPrev    bool
+	// Truncated means the account has existed before history is recorded, so the older states are unknown
+	Truncated bool
+}
+
+func utilsGetActHistoryHead(db *BeansDB, address common.Address) (uint32, bool, error) {
+	val, err := db.Get(leveldb.ItemFlagActHistory, actHistoryHeadKey(address))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if val == nil {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint32(val), true, nil
+}
+
+func utilsGetActHistory(db *BeansDB, address common.Address, height uint32) (*accountHistory, error) {
+	val, err := db.Get(leveldb.ItemFlagActHistory, actHistoryKey(address, height))
+	if err != nil {
+		return nil, err
+	}
+
+	if val == nil {
+		return nil, ErrActHistoryNotExist
+	}
+
+	var history accountHistory
+	err = rlp.DecodeBytes(val, &history)
+	if err != nil {
+		return nil, err
+	} else {
+		return &history, nil
+	}
+}
+
+// actLastChangeHeight returns the height of the block which changes the account at last time. It is resolved from the newest change log records
+func actLastChangeHeight(account *types.AccountData) uint32 {
+	height := uint32(0)
+	for _, record := range account.NewestRecords {
+		if record.Height > height {
+			height = record.Height
+		}
+	}
+	return height
+}
+
+// putActHistory appends the account's new state at the height to its history
+func putActHistory(db *BeansDB, batch Batch, account *types.AccountData, height uint32) error {
+	history := &accountHistory{Account: account}
+	prevHeight, ok, err := utilsGetActHistoryHead(db, account.Address)
+	if err != nil {
+		return err
+	}
+	if ok {
+		history.PrevHeight, history.HasPrev = prevHeight, true
+	} else {
+		oldAccount, err := UtilsGetAccount(db, account.Address)
+		if err == nil {
+			// the account is saved before history is recorded. Migrate its old state, which is kept since its last change
+			baseHeight := actLastChangeHeight(oldAccount)
+			if baseHeight < height {
+				buf, err := rlp.EncodeToBytes(&accountHistory{Account: oldAccount, Truncated: true})
+				if err != nil {
+					return err
+				}
+				batch.Put(leveldb.ItemFlagActHistory, actHistoryKey(account.Address, baseHeight), buf)
+				history.PrevHeight, history.HasPrev = baseHeight, true
+			} else {
+				history.Truncated = true
+			}
+		} else if err != ErrAccountNotExist {
+			return err
+		}
+	}
+
+	buf, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		return err
+	}
+	batch.Put(leveldb.ItemFlagActHistory, actHistoryKey(account.Address, height), buf)
+	batch.Put(leveldb.ItemFlagActHistory, actHistoryHeadKey(account.Address), leveldb.EncodeNumber(height))
+	return nil
+}
+
+// UtilsGetAccountByHeight returns the account data after the stable block in height is applied
+func UtilsGetAccountByHeight(db *BeansDB, address common.Address, height uint32) (*types.AccountData, error) {
+	pruneHeight, err := leveldb.GetPruneHeight(db.LevelDB)
+	if err != nil {
+		return nil, err
+	}
+	if isPrunedHeight(height, pruneHeight) {
+		return nil, ErrActHistoryNotExist
+	}
+
+	head, ok, err := utilsGetActHistoryHead(db, address)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// the account is never changed since history is recorded. Its state is kept since its last change
+		account, err := UtilsGetAccount(db, address)
+		if err != nil {
+			return nil, err
+		}
+		if actLastChangeHeight(account) > height {
+			return nil, ErrActHistoryNotExist
+		}
+		return account, nil
+	}
+
+	// walk back from the newest record until we reach the height
+	for {
+		history, err := utilsGetActHistory(db, address, head)
+		if err != nil {
+			return nil, err
+		}
+		if head <= height {
+			return history.Account, nil
+		}
+		if !history.HasPrev {
+			if history.Truncated {
+				return nil, ErrActHistoryNotExist
+			}
+			return nil, ErrAccountNotExist
+		}
+		head = history.PrevHeight
+	}
+}
+
 func UtilsSetAssetCode(db *BeansDB, code common.Hash, address common.Address) error {
 	return db.Put(leveldb.ItemFlagAssetCode, code.Bytes(), address.Bytes())
 }
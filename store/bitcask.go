@@ -40,6 +40,9 @@ type BitCask struct {
 	CurIndex     int
 	CurOffset    int64
 	LevelDB      *leveldb.LevelDBDatabase
+
+	compactLock sync.Mutex      // only one compaction runs at a time
+	dirty       map[string]bool // the keys which are changed during compaction
 }
 
 func (bitcask *BitCask) path(index int) string {
@@ -47,6 +50,23 @@ func (bitcask *BitCask) path(index int) string {
 	return fmt.Sprintf(dataPath, index)
 }
 
+// dataFiles returns the paths of data files in bitcask
+func (bitcask *BitCask) dataFiles() ([]string, error) {
+	paths := make([]string, 0, 1)
+	for index := 0; index <= 0xff; index++ {
+		path := bitcask.path(index)
+		isExist, err := FileUtilsIsExist(path)
+		if err != nil {
+			return nil, err
+		}
+		if !isExist {
+			break
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
 func (bitcask *BitCask) homeIsNotExist(home string) error {
 	err := os.MkdirAll(home, os.ModePerm)
 	if err != nil {
@@ -61,6 +81,10 @@ func (bitcask *BitCask) homeIsNotExist(home string) error {
 }
 
 func (bitcask *BitCask) homeIsExist(home string) error {
+	if err := bitcask.recoverCompact(); err != nil {
+		return err
+	}
+
 	pos, err := leveldb.GetCurrentPos(bitcask.LevelDB, bitcask.BitCaskIndex)
 	if err != nil {
 		return err
@@ -121,6 +145,7 @@ func (bitcask *BitCask) Put(flag uint32, key []byte, val []byte) error {
 	}
 
 	offset := uint32(int(bitcask.CurOffset) | bitcask.CurIndex)
+	bitcask.markDirty(flag, key)
 	err = leveldb.SetPos(bitcask.LevelDB, flag, key, &leveldb.Position{
 		Flag:   flag,
 		Offset: offset,
@@ -157,9 +182,41 @@ func (bitcask *BitCask) Get(flag uint32, key []byte) ([]byte, error) {
 }
 
 func (bitcask *BitCask) Delete(flag uint32, key []byte) error {
+	bitcask.RW.Lock()
+	defer bitcask.RW.Unlock()
+
+	bitcask.markDirty(flag, key)
 	return leveldb.DelPos(bitcask.LevelDB, flag, key)
 }
 
+// Iterate calls fn with every record which is pointed by index
+func (bitcask *BitCask) Iterate(fn func(flag uint32, key, val []byte) error) error {
+	bitcask.RW.RLock()
+	defer bitcask.RW.RUnlock()
+
+	paths, err := bitcask.dataFiles()
+	if err != nil {
+		return err
+	}
+	for fileIndex, path := range paths {
+		_, err = scanDataFile(path, nil, func(head *RecordHead, body *RecordBody, offset int64) error {
+			pos, err := leveldb.GetPos(bitcask.LevelDB, head.Flg, body.Key)
+			if err != nil {
+				return err
+			}
+			// the record is overwritten or deleted
+			if pos == nil || pos.Flag != head.Flg || pos.Offset != uint32(offset)|uint32(fileIndex) {
+				return nil
+			}
+			return fn(head.Flg, body.Key, body.Val)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (bitcask *BitCask) checkSize(size int64) error {
 	if (bitcask.CurOffset + size) <= int64(maxFileSize) {
 		return nil
@@ -1,7 +1,7 @@
 package store
 
 import (
-	"fmt"
+	"encoding/binary"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
@@ -44,12 +44,26 @@ type BizDb interface {
 	GetTxByHash(hash common.Hash) (*VTransactionDetail, error)
 
 	GetTxByAddr(src common.Address, index int, size int) ([]*VTransaction, uint32, error)
+
+	GetReceipt(txHash common.Hash) (*types.Receipt, error)
 }
 
 type Reader interface {
 	GetLastConfirm() *CBlock
 
 	GetBlockByHash(hash common.Hash) (*types.Block, error)
+
+	GetReceipts(blockHash common.Hash) (types.Receipts, error)
+}
+
+// TxIndex is the position of a transaction in the stable chain
+type TxIndex struct {
+	BlockHash   common.Hash
+	Height      uint32
+	Index       uint32      // the position of the tx in block.Txs. It is the position of box tx if the tx is in a box
+	PHash       common.Hash // the hash of box tx which contains the tx. It is empty if the tx is not in a box
+	SubIndex    uint32      // the position of the tx in box
+	PackageTime uint32
 }
 
 type BizDatabase struct {
@@ -64,82 +78,199 @@ func NewBizDatabase(reader Reader, levelDB *leveldb.LevelDBDatabase) *BizDatabas
 	}
 }
 
+func (db *BizDatabase) getTxIndex(hash common.Hash) (*TxIndex, error) {
+	val, err := leveldb.Get(db.LevelDB, leveldb.GetTxIndexKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(val) <= 0 {
+		return nil, ErrTxNotExist
+	}
+
+	var txIndex TxIndex
+	err = rlp.DecodeBytes(val, &txIndex)
+	if err != nil {
+		return nil, err
+	} else {
+		return &txIndex, nil
+	}
+}
+
+func (db *BizDatabase) getAddrTxCount(addr common.Address) (uint32, error) {
+	val, err := leveldb.Get(db.LevelDB, leveldb.GetAddrTxCountKey(addr))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(val) <= 0 {
+		return 0, nil
+	} else {
+		return binary.BigEndian.Uint32(val), nil
+	}
+}
+
+// loadTx find the transaction from block by its index
+func (db *BizDatabase) loadTx(txIndex *TxIndex) (*types.Transaction, error) {
+	block, err := db.Reader.GetBlockByHash(txIndex.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if int(txIndex.Index) >= len(block.Txs) {
+		log.Errorf("tx index is out of block txs range. block: %s, index: %d", txIndex.BlockHash.Hex(), txIndex.Index)
+		return nil, ErrTxNotExist
+	}
+
+	tx := block.Txs[txIndex.Index]
+	if (txIndex.PHash == common.Hash{}) {
+		return tx, nil
+	}
+
+	box, err := types.GetBox(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	if int(txIndex.SubIndex) >= len(box.SubTxList) {
+		log.Errorf("tx index is out of box sub txs range. box: %s, index: %d", txIndex.PHash.Hex(), txIndex.SubIndex)
+		return nil, ErrTxNotExist
+	}
+
+	return box.SubTxList[txIndex.SubIndex], nil
+}
+
+// getTxAsset returns the asset code and asset id which the transaction operates
+func getTxAsset(tx *types.Transaction) (common.Hash, common.Hash) {
+	switch tx.Type() {
+	case params.CreateAssetTx:
+		return tx.Hash(), common.Hash{}
+	case params.IssueAssetTx:
+		issueAsset, err := types.GetIssueAsset(tx.Data())
+		if err != nil {
+			return common.Hash{}, tx.Hash()
+		}
+		return issueAsset.AssetCode, tx.Hash()
+	case params.ReplenishAssetTx:
+		replenishAsset, err := types.GetReplenishAsset(tx.Data())
+		if err != nil {
+			return common.Hash{}, common.Hash{}
+		}
+		return replenishAsset.AssetCode, replenishAsset.AssetId
+	case params.ModifyAssetTx:
+		modifyInfo, err := types.GetModifyAssetInfo(tx.Data())
+		if err != nil {
+			return common.Hash{}, common.Hash{}
+		}
+		return modifyInfo.AssetCode, common.Hash{}
+	case params.TransferAssetTx:
+		transferAsset, err := types.GetTransferAsset(tx.Data())
+		if err != nil {
+			return common.Hash{}, common.Hash{}
+		}
+		return common.Hash{}, transferAsset.AssetId
+	default:
+		return common.Hash{}, common.Hash{}
+	}
+}
+
+// GetTxByHash returns the stable transaction and its position
 func (db *BizDatabase) GetTxByHash(hash common.Hash) (*VTransactionDetail, error) {
-	// blockHash, val, st, err := db.Database.TxGetByHash(hash.Hex())
-	// if err != nil {
-	// 	return nil, err
-	// }
-	//
-	// block, err := db.Reader.GetBlockByHash(common.HexToHash(blockHash))
-	// if err == ErrNotExist {
-	// 	return nil, ErrNotExist
-	// }
-	//
-	// if err != nil {
-	// 	return nil, err
-	// }
-	//
-	// var tx types.Transaction
-	// err = rlp.DecodeBytes(val, &tx)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	//
-	// return &VTransactionDetail{
-	// 	BlockHash: block.Hash(),
-	// 	Height:    block.Height(),
-	// 	Tx:        &tx,
-	// 	St:        st,
-	// }, nil
-	return nil, nil
+	txIndex, err := db.getTxIndex(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.loadTx(txIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	assetCode, assetId := getTxAsset(tx)
+	return &VTransactionDetail{
+		BlockHash:   txIndex.BlockHash,
+		PHash:       txIndex.PHash,
+		Height:      txIndex.Height,
+		Tx:          tx,
+		PackageTime: txIndex.PackageTime,
+		AssetCode:   assetCode,
+		AssetId:     assetId,
+	}, nil
 }
 
+// GetTxByAddr returns the stable transactions which are sent or received by the address, in the order they are packaged. It also returns the total count of the address's transactions
 func (db *BizDatabase) GetTxByAddr(src common.Address, index int, size int) ([]*VTransaction, uint32, error) {
-	// if (index < 0) || (size > 200) || (size <= 0) {
-	// 	return nil, 0, errors.New("argment error.")
-	// }
-	//
-	// confirm := db.Reader.GetLastConfirm()
-	// trieDB := confirm.AccountTrieDB
-	// if trieDB == nil {
-	// 	return make([]*VTransaction, 0), 0, nil
-	// }
-	//
-	// account, err := trieDB.Get(src)
-	// if err != nil {
-	// 	return nil, 0, err
-	// }
-	//
-	// if account == nil {
-	// 	return make([]*VTransaction, 0), 0, nil
-	// }
-	//
-	// txCount := uint32(0)
-	// // txCount := account.TxCount
-	// // if uint32(index) > txCount {
-	// // 	return make([]*VTransaction, 0), txCount, nil
-	// // }
-	//
-	// _, vals, sts, err := db.Database.TxGetByAddr(src.Hex(), index, size)
-	// if err != nil {
-	// 	return nil, 0, err
-	// }
-	//
-	// txs := make([]*VTransaction, len(vals))
-	// for index := 0; index < len(vals); index++ {
-	// 	var tx types.Transaction
-	// 	err = rlp.DecodeBytes(vals[index], &tx)
-	// 	if err != nil {
-	// 		return nil, 0, err
-	// 	}
-	//
-	// 	txs[index] = &VTransaction{
-	// 		Tx: &tx,
-	// 		St: sts[index],
-	// 	}
-	// }
-	// return txs, txCount, nil
-	return nil, 0, nil
+	if (index < 0) || (size > 200) || (size <= 0) {
+		return nil, 0, ErrArgInvalid
+	}
+
+	txCount, err := db.getAddrTxCount(src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if uint32(index) >= txCount {
+		return make([]*VTransaction, 0), txCount, nil
+	}
+
+	end := uint32(index + size)
+	if end > txCount {
+		end = txCount
+	}
+
+	txs := make([]*VTransaction, 0, end-uint32(index))
+	for pos := uint32(index); pos < end; pos++ {
+		val, err := leveldb.Get(db.LevelDB, leveldb.GetAddrTxKey(src, pos))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		txIndex, err := db.getTxIndex(common.BytesToHash(val))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		tx, err := db.loadTx(txIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		assetCode, assetId := getTxAsset(tx)
+		txs = append(txs, &VTransaction{
+			Tx:          tx,
+			PHash:       txIndex.PHash,
+			PackageTime: txIndex.PackageTime,
+			AssetCode:   assetCode,
+			AssetId:     assetId,
+		})
+	}
+	return txs, txCount, nil
+}
+
+// GetReceipt returns the receipt of stable transaction. It returns the receipt of box transaction if the transaction is in a box
+func (db *BizDatabase) GetReceipt(txHash common.Hash) (*types.Receipt, error) {
+	txIndex, err := db.getTxIndex(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts, err := db.Reader.GetReceipts(txIndex.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := txHash
+	if (txIndex.PHash != common.Hash{}) {
+		hash = txIndex.PHash
+	}
+	receipt := receipts.Find(hash)
+	if receipt == nil {
+		return nil, ErrReceiptNotExist
+	}
+	result := *receipt
+	result.BlockHash = txIndex.BlockHash
+	result.Height = txIndex.Height
+	return &result, nil
 }
 
 func (db *BizDatabase) AfterCommit(flag uint32, key []byte, val []byte) error {
@@ -157,16 +288,110 @@ func (db *BizDatabase) AfterCommit(flag uint32, key []byte, val []byte) error {
 		return nil
 	} else if flag == leveldb.ItemFlagKV {
 		return nil
+	} else if flag == leveldb.ItemFlagAssetCode {
+		return nil
+	} else if flag == leveldb.ItemFlagAssetId {
+		return nil
+	} else if flag == leveldb.ItemFlagReceipts {
+		return nil
+	} else if flag == leveldb.ItemFlagBloom {
+		return nil
+	} else if flag == leveldb.ItemFlagActHistory {
+		return nil
 	} else {
 		panic("unknown flag.flag = " + strconv.Itoa(int(flag)))
 	}
 }
 
+// After implements WriteExtend, so that the BizDatabase could be notified after the data is written to disk
+func (db *BizDatabase) After(flag uint32, key []byte, val []byte) error {
+	return db.AfterCommit(flag, key, val)
+}
+
+// txIndexBatch collects the index items of a block's transactions
+type txIndexBatch struct {
+	db      *BizDatabase
+	batch   leveldb.Batch
+	indexed map[common.Hash]bool
+	counts  map[common.Address]uint32
+}
+
+func (b *txIndexBatch) addrTxCount(addr common.Address) (uint32, error) {
+	if count, ok := b.counts[addr]; ok {
+		return count, nil
+	}
+	return b.db.getAddrTxCount(addr)
+}
+
+func (b *txIndexBatch) appendAddrTx(addr common.Address, hash common.Hash) error {
+	count, err := b.addrTxCount(addr)
+	if err != nil {
+		return err
+	}
+
+	err = b.batch.Put(leveldb.GetAddrTxKey(addr, count), hash.Bytes())
+	if err != nil {
+		return err
+	}
+
+	b.counts[addr] = count + 1
+	return nil
+}
+
+func (b *txIndexBatch) put(tx *types.Transaction, txIndex *TxIndex) error {
+	hash := tx.Hash()
+	if b.indexed[hash] {
+		return nil
+	}
+
+	// the block is written again after new confirms are received, so the tx may has been indexed
+	isExist, err := b.db.LevelDB.Has(leveldb.GetTxIndexKey(hash))
+	if err != nil {
+		return err
+	}
+	if isExist {
+		return nil
+	}
+
+	buf, err := rlp.EncodeToBytes(txIndex)
+	if err != nil {
+		return err
+	}
+
+	err = b.batch.Put(leveldb.GetTxIndexKey(hash), buf)
+	if err != nil {
+		return err
+	}
+	b.indexed[hash] = true
+
+	from := tx.From()
+	err = b.appendAddrTx(from, hash)
+	if err != nil {
+		return err
+	}
+
+	to := tx.To()
+	if (to != nil) && (*to != from) {
+		return b.appendAddrTx(*to, hash)
+	} else {
+		return nil
+	}
+}
+
+func (b *txIndexBatch) write() error {
+	for addr, count := range b.counts {
+		err := b.batch.Put(leveldb.GetAddrTxCountKey(addr), leveldb.EncodeNumber(count))
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.batch.Write()
+}
+
 func (db *BizDatabase) afterBlock(key []byte, val []byte) error {
 	var block types.Block
 	err := rlp.DecodeBytes(val, &block)
-	ret := hexutil.Encode(val)
-	fmt.Sprintf(ret)
 	if err != nil {
 		return err
 	}
@@ -176,23 +401,50 @@ func (db *BizDatabase) afterBlock(key []byte, val []byte) error {
 		return nil
 	}
 
-	// ver := time.Now().UnixNano()
+	indexBatch := &txIndexBatch{
+		db:      db,
+		batch:   db.LevelDB.NewBatch(),
+		indexed: make(map[common.Hash]bool),
+		counts:  make(map[common.Address]uint32),
+	}
+	blockHash := block.Hash()
 	for index := 0; index < len(txs); index++ {
 		tx := txs[index]
-		// hash := tx.Hash()
-		from := tx.From()
-
-		if tx.Type() == params.CreateAssetTx {
-			log.Info("insert account code: " + tx.Hash().Hex() + "|addr: " + from.Hex())
-			//return leveldb.Set(db.LevelDB, leveldb.GetAssetCodeKey(tx.Hash()), from.Bytes())
-		} else if tx.Type() == params.IssueAssetTx {
-			log.Info("insert account id: " + tx.Hash().Hex() + "|addr: " + from.Hex())
-			//return leveldb.Set(db.LevelDB, leveldb.GetAssetIdKey(tx.Hash()), from.Bytes())
+		txIndex := &TxIndex{
+			BlockHash:   blockHash,
+			Height:      block.Height(),
+			Index:       uint32(index),
+			PackageTime: block.Time(),
+		}
+		err = indexBatch.put(tx, txIndex)
+		if err != nil {
+			return err
 		}
 
+		if tx.Type() != params.BoxTx {
+			continue
+		}
+
+		// index the sub transactions in box
+		box, err := types.GetBox(tx.Data())
 		if err != nil {
 			return err
 		}
+		for subIndex, subTx := range box.SubTxList {
+			subTxIndex := &TxIndex{
+				BlockHash:   blockHash,
+				Height:      block.Height(),
+				Index:       uint32(index),
+				PHash:       tx.Hash(),
+				SubIndex:    uint32(subIndex),
+				PackageTime: block.Time(),
+			}
+			err = indexBatch.put(subTx, subTxIndex)
+			if err != nil {
+				return err
+			}
+		}
 	}
-	return nil
+
+	return indexBatch.write()
 }
@@ -1 +1,161 @@
 package store
+
+import (
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func createTx(from, to common.Address, amount int64) *types.Transaction {
+	return types.NewTransaction(from, to, big.NewInt(amount), 2000000, big.NewInt(3000000000), nil, params.OrdinaryTx, 100, uint64(time.Now().Unix()+300), "", "")
+}
+
+// reopenChain closes the database so that the queued items are flushed and indexed, then opens it again
+func reopenChain(db *ChainDatabase) *ChainDatabase {
+	db.Close()
+	return NewChainDataBase(GetStorePath())
+}
+
+func TestBizDatabase_GetTx(t *testing.T) {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer func() { cacheChain.Close() }()
+
+	addr1 := common.HexToAddress("0x01")
+	addr2 := common.HexToAddress("0x02")
+	addr3 := common.HexToAddress("0x03")
+	tx1 := createTx(addr1, addr2, 1)
+	tx2 := createTx(addr2, addr3, 2)
+	tx3 := createTx(addr1, addr3, 3)
+
+	block0 := GetBlock0()
+	block0.Header.Time = 123
+	block0.Txs = types.Transactions{tx1, tx2}
+	err := cacheChain.SetBlock(block0.Hash(), block0)
+	assert.NoError(t, err)
+	_, err = cacheChain.SetStableBlock(block0.Hash())
+	assert.NoError(t, err)
+
+	block1 := GetBlock1()
+	block1.Header.ParentHash = block0.Hash()
+	block1.Txs = types.Transactions{tx3}
+	err = cacheChain.SetBlock(block1.Hash(), block1)
+	assert.NoError(t, err)
+
+	// unstable tx is not indexed
+	_, err = cacheChain.GetTxByHash(tx3.Hash())
+	assert.Equal(t, ErrTxNotExist, err)
+
+	_, err = cacheChain.SetStableBlock(block1.Hash())
+	assert.NoError(t, err)
+	cacheChain = reopenChain(cacheChain)
+
+	// by hash
+	detail, err := cacheChain.GetTxByHash(tx2.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, block0.Hash(), detail.BlockHash)
+	assert.Equal(t, uint32(0), detail.Height)
+	assert.Equal(t, uint32(123), detail.PackageTime)
+	assert.Equal(t, tx2.Hash(), detail.Tx.Hash())
+
+	detail, err = cacheChain.GetTxByHash(tx3.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, block1.Hash(), detail.BlockHash)
+	assert.Equal(t, uint32(1), detail.Height)
+
+	// by address
+	txs, total, err := cacheChain.GetTxByAddr(addr1, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), total)
+	assert.Equal(t, tx1.Hash(), txs[0].Tx.Hash())
+	assert.Equal(t, tx3.Hash(), txs[1].Tx.Hash())
+
+	txs, total, err = cacheChain.GetTxByAddr(addr3, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), total)
+	assert.Len(t, txs, 1)
+	assert.Equal(t, tx3.Hash(), txs[0].Tx.Hash())
+
+	txs, total, err = cacheChain.GetTxByAddr(addr2, 5, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), total)
+	assert.Len(t, txs, 0)
+
+	_, _, err = cacheChain.GetTxByAddr(addr2, 0, 0)
+	assert.Equal(t, ErrArgInvalid, err)
+
+	// the block is written again with new confirms, and the txs should not be indexed twice
+	_, err = cacheChain.SetConfirms(block1.Hash(), []types.SignData{{0x12}})
+	assert.NoError(t, err)
+	cacheChain = reopenChain(cacheChain)
+	_, total, err = cacheChain.GetTxByAddr(addr1, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), total)
+}
+
+func TestBizDatabase_GetReceipt(t *testing.T) {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer func() { cacheChain.Close() }()
+
+	addr1 := common.HexToAddress("0x01")
+	addr2 := common.HexToAddress("0x02")
+	tx1 := createTx(addr1, addr2, 1)
+	tx2 := createTx(addr2, addr1, 2)
+
+	block0 := GetBlock0()
+	block0.Txs = types.Transactions{tx1, tx2}
+	err := cacheChain.SetBlock(block0.Hash(), block0)
+	assert.NoError(t, err)
+
+	receipt1 := types.NewReceipt(tx1, 0, 21000, nil)
+	receipt1.Events = []*types.Event{{Address: addr2, Topics: []common.Hash{{0x01}}, Data: []byte{0x12}, TxHash: tx1.Hash()}}
+	receipt2 := types.NewReceipt(tx2, 1, 30000, errors.New("out of gas"))
+	receipts := types.Receipts{receipt1, receipt2}
+
+	// block not exist
+	assert.Equal(t, ErrBlockNotExist, cacheChain.SetReceipts(common.HexToHash("0x1"), receipts))
+	assert.NoError(t, cacheChain.SetReceipts(block0.Hash(), receipts))
+
+	// unstable receipts can be loaded by block hash, but not by tx hash
+	result, err := cacheChain.GetReceipts(block0.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, receipts, result)
+	_, err = cacheChain.GetReceipt(tx1.Hash())
+	assert.Equal(t, ErrTxNotExist, err)
+
+	// bloom is saved when the block become stable
+	_, err = cacheChain.GetBloom(block0.Hash())
+	assert.Equal(t, ErrBloomNotExist, err)
+
+	_, err = cacheChain.SetStableBlock(block0.Hash())
+	assert.NoError(t, err)
+	cacheChain = reopenChain(cacheChain)
+
+	bloom, err := cacheChain.GetBloom(block0.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, types.CreateBloom(receipts), bloom)
+	assert.True(t, bloom.Test(addr2.Bytes()))
+	assert.True(t, bloom.Test(common.Hash{0x01}.Bytes()))
+
+	receipt, err := cacheChain.GetReceipt(tx1.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	assert.Equal(t, uint64(21000), receipt.GasUsed)
+	assert.Equal(t, block0.Hash(), receipt.BlockHash)
+	assert.Equal(t, uint32(0), receipt.Height)
+	assert.Equal(t, 1, len(receipt.Events))
+	assert.Equal(t, tx1.Hash(), receipt.Events[0].TxHash)
+	assert.Equal(t, []byte{0x12}, receipt.Events[0].Data)
+
+	receipt, err = cacheChain.GetReceipt(tx2.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+	assert.Equal(t, "out of gas", receipt.VmErr)
+	assert.Equal(t, uint(1), receipt.TxIndex)
+}
@@ -10,6 +10,7 @@ import (
 
 type CBlock struct {
 	Block           *types.Block
+	Receipts        types.Receipts
 	AccountTrieDB   *AccountTrieDB
 	CandidateTrieDB *CandidateTrieDB
 	Top             *VoteTop
@@ -21,6 +21,7 @@ type ChainDatabase struct {
 	UnConfirmBlocks map[common.Hash]*CBlock // unconfirmed block tree nodes
 	Context         *RunContext
 	LevelDB         *leveldb.LevelDBDatabase
+	Backend         string // the name of backend which stores the items
 	Beansdb         *BeansDB
 	BizDB           *BizDatabase
 	RW              sync.RWMutex
@@ -45,10 +46,24 @@ func checkHome(home string) error {
 	}
 }
 
+// NewChainDataBase opens the database with the backend which it is created by. A new database is stored in bitcask files
 func NewChainDataBase(home string) *ChainDatabase {
-	err := checkHome(home)
+	return NewChainDataBaseWithBackend(home, "")
+}
+
+// NewChainDataBaseWithBackend opens the database with the specific backend. It panics if the database is created by another backend
+func NewChainDataBaseWithBackend(home string, backend string) *ChainDatabase {
+	db, err := OpenChainDataBase(home, backend)
 	if err != nil {
-		panic("check home: " + home + "|error: " + err.Error())
+		panic(err)
+	}
+	return db
+}
+
+// OpenChainDataBase opens the database with the specific backend. It returns error if the database is created by another backend, or it can't be recovered
+func OpenChainDataBase(home string, backend string) (*ChainDatabase, error) {
+	if err := checkHome(home); err != nil {
+		return nil, fmt.Errorf("check home %s error: %v", home, err)
 	}
 
 	db := &ChainDatabase{
@@ -59,13 +74,27 @@ func NewChainDataBase(home string) *ChainDatabase {
 	// 启动leveldb的metrics数据统计功能
 	db.LevelDB.Meter()
 
+	itemDB, backend, err := openBackend(backend, home, db.LevelDB)
+	if err != nil {
+		db.LevelDB.Close()
+		return nil, fmt.Errorf("open store backend err: %v", err)
+	}
+	db.Backend = backend
+
 	db.BizDB = NewBizDatabase(db, db.LevelDB)
 	db.Beansdb = NewBeansDB(home, db.LevelDB)
+	db.Beansdb.Backend = itemDB
+	db.Beansdb.Extend = db.BizDB
 	db.Beansdb.Start()
+	if err := db.recoverCommit(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("recover stable block commit err: %v", err)
+	}
 
 	stableBlock, err := db.GetStableBlock()
 	if err != nil && err != ErrStableBlockNotExist {
-		panic("get stable block err: " + err.Error())
+		db.Close()
+		return nil, fmt.Errorf("get stable block err: %v", err)
 	}
 
 	// if stableBlock == nil {
@@ -77,7 +106,8 @@ func NewChainDataBase(home string) *ChainDatabase {
 	db.LastConfirm = NewGenesisBlock(stableBlock, db.Beansdb)
 	candidates, err := db.Context.Candidates.GetCandidates()
 	if err != nil {
-		panic("get candidates err: " + err.Error())
+		db.Close()
+		return nil, fmt.Errorf("get candidates err: %v", err)
 	} else {
 
 		// 把票数为0的candidate筛选掉，默认票数为0的candidate为注销的candidate
@@ -96,7 +126,7 @@ func NewChainDataBase(home string) *ChainDatabase {
 		}
 		db.LastConfirm.Top.Rank(max_candidate_count, newCandidate)
 	}
-	return db
+	return db, nil
 }
 
 func (database *ChainDatabase) GetStableBlock() (*types.Block, error) {
@@ -251,6 +281,16 @@ func (database *ChainDatabase) blockCommit(hash common.Hash) error {
 	batch.Put(leveldb.ItemFlagBlock, hash.Bytes(), buf)
 	batch.Put(leveldb.ItemFlagBlockHeight, leveldb.EncodeNumber(cItem.Block.Height()), hash.Bytes())
 
+	// store receipts
+	if cItem.Receipts != nil {
+		receiptsBuf, err := rlp.EncodeToBytes(cItem.Receipts)
+		if err != nil {
+			return err
+		}
+		batch.Put(leveldb.ItemFlagReceipts, receiptsKey(hash), receiptsBuf)
+		batch.Put(leveldb.ItemFlagBloom, bloomKey(hash), types.CreateBloom(cItem.Receipts).Bytes())
+	}
+
 	// store account
 	decode := func(account *types.AccountData, batch Batch) error {
 		buf, err = rlp.EncodeToBytes(account)
@@ -258,7 +298,8 @@ func (database *ChainDatabase) blockCommit(hash common.Hash) error {
 			return err
 		} else {
 			batch.Put(leveldb.ItemFlagAct, account.Address.Bytes(), buf)
-			return nil
+			// keep the old states for historical queries
+			return putActHistory(database.Beansdb, batch, account, cItem.Block.Height())
 		}
 	}
 
@@ -272,38 +313,23 @@ func (database *ChainDatabase) blockCommit(hash common.Hash) error {
 		return nil
 	}
 
-	commitContext := func(block *types.Block, candidates []*Candidate) error {
-		err = leveldb.SetCurrentBlock(database.LevelDB, cItem.Block.Hash())
-		if err != nil {
-			return err
-		}
-
-		if len(candidates) <= 0 {
-			return nil
-		}
-
-		err := database.Context.SetCandidates(candidates)
-		if err != nil {
-			return err
-		}
-
-		return database.Context.Flush()
-	}
-
 	accounts := cItem.AccountTrieDB.Collect(cItem.Block.Height())
 	err = decodeBatch(accounts, batch)
 	if err != nil {
 		return err
 	}
 
-	err = database.Beansdb.Commit(batch)
+	// the journal is written before any data, so that the commit can be replayed after crash
+	journal := &commitJournal{
+		Hash:       hash,
+		Items:      batch.Items(),
+		Candidates: cItem.filterCandidates(accounts),
+	}
+	err = writeCommitJournal(database.Beansdb.Home, journal)
 	if err != nil {
 		return err
 	}
-
-	candidates := cItem.filterCandidates(accounts)
-	// 注意这里即使是为注销候选节点不能删除记录，这里保存进去只是修改票数为0，因为在退还候选节点押金的地方要拉取所有的候选节点来判断注销的候选节点是否没有退还押金。
-	return commitContext(cItem.Block, candidates)
+	return database.applyCommitJournal(journal)
 }
 
 func (database *ChainDatabase) getBlock4Cache(hash common.Hash) (*types.Block, error) {
@@ -520,6 +546,38 @@ func (database *ChainDatabase) SetBlock(hash common.Hash, block *types.Block) er
 	return nil
 }
 
+// SetReceipts sets the transaction receipts of an unstable block. They will be written to disk when the block become stable
+func (database *ChainDatabase) SetReceipts(hash common.Hash, receipts types.Receipts) error {
+	database.RW.Lock()
+	defer database.RW.Unlock()
+
+	cItem := database.UnConfirmBlocks[hash]
+	if (cItem == nil) || (cItem.Block == nil) {
+		log.Errorf("set receipts error:the block is not exist. hash:" + hash.Hex())
+		return ErrBlockNotExist
+	}
+	cItem.Receipts = receipts
+	return nil
+}
+
+// GetReceipts returns the transaction receipts of a block
+func (database *ChainDatabase) GetReceipts(hash common.Hash) (types.Receipts, error) {
+	database.RW.RLock()
+	cItem := database.UnConfirmBlocks[hash]
+	if (cItem != nil) && (cItem.Receipts != nil) {
+		database.RW.RUnlock()
+		return cItem.Receipts, nil
+	}
+	database.RW.RUnlock()
+
+	return UtilsGetReceipts(database.Beansdb, hash)
+}
+
+// GetBloom returns the events bloom of a stable block. It is saved with the receipts when the block become stable
+func (database *ChainDatabase) GetBloom(hash common.Hash) (types.Bloom, error) {
+	return UtilsGetBloom(database.Beansdb, hash)
+}
+
 func (database *ChainDatabase) appendConfirm(block *types.Block, confirms []types.SignData) {
 	if (block == nil) || (confirms == nil) {
 		return
@@ -669,6 +727,32 @@ func (database *ChainDatabase) GetActDatabase(hash common.Hash) (*AccountTrieDB,
 	}
 }
 
+// GetHistoryActDatabase returns the accounts' states at the block. Unlike GetActDatabase, the states at the older stable blocks are loaded from account history. It returns ErrActHistoryNotExist if the states at the block are pruned
+func (database *ChainDatabase) GetHistoryActDatabase(hash common.Hash) (*AccountTrieDB, error) {
+	database.RW.RLock()
+	defer database.RW.RUnlock()
+
+	if (hash == common.Hash{}) || (database.UnConfirmBlocks[hash] != nil) {
+		return database.GetActDatabase(hash)
+	}
+
+	block, err := database.getBlock4DB(hash)
+	if err != nil {
+		return nil, err
+	}
+	if (database.LastConfirm.Block == nil) || (database.LastConfirm.Block.Hash() == hash) {
+		return database.LastConfirm.AccountTrieDB, nil
+	}
+	pruneHeight, err := leveldb.GetPruneHeight(database.LevelDB)
+	if err != nil {
+		return nil, err
+	}
+	if isPrunedHeight(block.Height(), pruneHeight) {
+		return nil, ErrActHistoryNotExist
+	}
+	return NewHistoryAccountTrieDB(database.Beansdb, block.Height()), nil
+}
+
 // GetContractCode loads contract's code from db.
 func (database *ChainDatabase) GetContractCode(hash common.Hash) (types.Code, error) {
 	val, err := database.Beansdb.Get(leveldb.ItemFlagCode, hash.Bytes())
@@ -769,6 +853,21 @@ func (database *ChainDatabase) GetAssetID(id common.Hash) (common.Address, error
 	}
 }
 
+// GetTxByHash loads the stable transaction by its hash
+func (database *ChainDatabase) GetTxByHash(hash common.Hash) (*VTransactionDetail, error) {
+	return database.BizDB.GetTxByHash(hash)
+}
+
+// GetTxByAddr loads a page of stable transactions which are sent or received by the address
+func (database *ChainDatabase) GetTxByAddr(src common.Address, index int, size int) ([]*VTransaction, uint32, error) {
+	return database.BizDB.GetTxByAddr(src, index, size)
+}
+
+// GetReceipt returns the receipt of stable transaction
+func (database *ChainDatabase) GetReceipt(txHash common.Hash) (*types.Receipt, error) {
+	return database.BizDB.GetReceipt(txHash)
+}
+
 func (database *ChainDatabase) IterateUnConfirms(fn func(*types.Block)) {
 	database.LastConfirm.Walk(func(block *CBlock) {
 		fn(block.Block)
@@ -5,6 +5,8 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
 	"github.com/stretchr/testify/assert"
 	"math/big"
 	"strconv"
@@ -654,3 +656,97 @@ func TestChainDatabase_CandidatesRanking(t *testing.T) {
 	assert.Equal(t, uint32(count), total)
 	cacheChain.Close()
 }
+
+func TestChainDatabase_GetHistoryActDatabase(t *testing.T) {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer cacheChain.Close()
+
+	// the account is changed at height
+	newAccount := func(address string, balance int64, version uint32, height uint32) *types.AccountData {
+		account := GetAccount(address, balance, version)
+		account.NewestRecords[0] = types.VersionRecord{Version: version, Height: height}
+		return account
+	}
+	// the account is saved without history, like the data from old version node
+	putOldAccount := func(account *types.AccountData) {
+		buf, err := rlp.EncodeToBytes(account)
+		assert.NoError(t, err)
+		assert.NoError(t, cacheChain.Beansdb.Put(leveldb.ItemFlagAct, account.Address.Bytes(), buf))
+	}
+
+	// account 1 is changed in block 0, 1 and 3. account 2 is created in block 2
+	blocks := NewBlockBatch(3)
+	balances := []int64{100, 200, 0, 300}
+	for i, block := range blocks {
+		cacheChain.SetBlock(block.Hash(), block)
+		actDatabase, err := cacheChain.GetActDatabase(block.Hash())
+		assert.NoError(t, err)
+		if balances[i] != 0 {
+			actDatabase.Put(newAccount("0x01", balances[i], uint32(i+1), block.Height()), block.Height())
+		}
+		if i == 2 {
+			actDatabase.Put(newAccount("0x02", 500, 1, block.Height()), block.Height())
+			putOldAccount(newAccount("0x04", 600, 1, 1))
+			putOldAccount(newAccount("0x05", 800, 1, 2))
+		}
+		if i == 3 {
+			actDatabase.Put(newAccount("0x04", 700, 2, block.Height()), block.Height())
+		}
+		_, err = cacheChain.SetStableBlock(block.Hash())
+		assert.NoError(t, err)
+	}
+
+	expects := []int64{100, 200, 200, 300}
+	for i, block := range blocks {
+		actDatabase, err := cacheChain.GetHistoryActDatabase(block.Hash())
+		assert.NoError(t, err)
+		account, err := actDatabase.Get(common.HexToAddress("0x01"))
+		assert.NoError(t, err)
+		assert.Equal(t, expects[i], account.Balance.Int64(), "height=%d", i)
+
+		account, err = actDatabase.Get(common.HexToAddress("0x02"))
+		if i < 2 {
+			assert.Equal(t, ErrAccountNotExist, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, int64(500), account.Balance.Int64())
+		}
+
+		// GetActDatabase always returns the newest stable states
+		actDatabase, err = cacheChain.GetActDatabase(block.Hash())
+		assert.NoError(t, err)
+		account, err = actDatabase.Get(common.HexToAddress("0x01"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(300), account.Balance.Int64(), "height=%d", i)
+	}
+
+	// the account is never changed
+	_, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x03"), 1)
+	assert.Equal(t, ErrAccountNotExist, err)
+	// the state saved before history is kept since its last change
+	expectsOld := []int64{0, 600, 600, 700}
+	for height, expect := range expectsOld {
+		account, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x04"), uint32(height))
+		if expect == 0 {
+			assert.Equal(t, ErrActHistoryNotExist, err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, expect, account.Balance.Int64(), "height=%d", height)
+		}
+	}
+	_, err = UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x05"), 1)
+	assert.Equal(t, ErrActHistoryNotExist, err)
+	account, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x05"), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(800), account.Balance.Int64())
+
+	// the states at pruned height are not available
+	assert.NoError(t, leveldb.SetPruneHeight(cacheChain.LevelDB, 3))
+	_, err = cacheChain.GetHistoryActDatabase(blocks[1].Hash())
+	assert.Equal(t, ErrActHistoryNotExist, err)
+	_, err = cacheChain.GetHistoryActDatabase(blocks[0].Hash())
+	assert.NoError(t, err)
+	_, err = cacheChain.GetHistoryActDatabase(blocks[3].Hash())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// commitJournalName is the write-ahead log of the stable block commit in progress
+	commitJournalName = "commit.journal"
+	// the journal is written into the temp file first, and renamed after it is synced, so that the journal is either complete or absent
+	commitJournalTmpExt = ".tmp"
+)
+
+// the steps of stable block commit. A fault can be injected before every step in tests
+const (
+	commitStepRename      = iota // rename the temp journal. The commit is decided after this step
+	commitStepItems              // write the block and accounts into the write queue
+	commitStepStableBlock        // update the stable block in LevelDB
+	commitStepCandidates         // update the candidates in run context
+	commitStepCleanup            // remove the journal
+)
+
+var ErrJournalBroken = errors.New("commit journal is broken")
+
+// commitFault is called before every commit step. The commit stops at the step if it returns error, as if the process crashes.
+// It is only set in tests
+var commitFault func(step int) error
+
+// commitJournal records all writes of a stable block commit, so that the commit can be replayed after crash
+type commitJournal struct {
+	Hash       common.Hash
+	Items      []*BatchItem
+	Candidates []*Candidate
+}
+
+func journalPath(home string) string {
+	return filepath.Join(home, commitJournalName)
+}
+
+func checkCommitFault(step int) error {
+	if commitFault == nil {
+		return nil
+	}
+	return commitFault(step)
+}
+
+// writeCommitJournal writes the journal into home. The journal format is crc16 (2 bytes, big endian) + rlp(journal)
+func writeCommitJournal(home string, journal *commitJournal) error {
+	body, err := rlp.EncodeToBytes(journal)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 2+len(body))
+	binary.BigEndian.PutUint16(buf, CheckSum(body))
+	copy(buf[2:], body)
+
+	path := journalPath(home)
+	tmpPath := path + commitJournalTmpExt
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := checkCommitFault(commitStepRename); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	// the rename is durable only after the directory is synced
+	return syncDir(home)
+}
+
+// syncDir flushes the directory entries, so that the renamed or removed files are persisted
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// removeCommitJournal removes the journal in home, and syncs the directory so that the journal is not replayed after crash
+func removeCommitJournal(home string) error {
+	if err := os.Remove(journalPath(home)); err != nil {
+		return err
+	}
+	return syncDir(home)
+}
+
+// readCommitJournal returns nil if there is no journal in home
+func readCommitJournal(home string) (*commitJournal, error) {
+	buf, err := ioutil.ReadFile(journalPath(home))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 2 || binary.BigEndian.Uint16(buf) != CheckSum(buf[2:]) {
+		return nil, ErrJournalBroken
+	}
+	var journal commitJournal
+	if err := rlp.DecodeBytes(buf[2:], &journal); err != nil {
+		return nil, ErrJournalBroken
+	}
+	return &journal, nil
+}
+
+// applyCommitJournal writes the items, stable block and candidates in journal, then removes the journal. All the writes are idempotent
+func (database *ChainDatabase) applyCommitJournal(journal *commitJournal) error {
+	if err := checkCommitFault(commitStepItems); err != nil {
+		return err
+	}
+	batch := database.Beansdb.NewBatch()
+	for _, item := range journal.Items {
+		batch.Put(item.Flg, item.Key, item.Val)
+	}
+	if err := database.Beansdb.Commit(batch); err != nil {
+		return err
+	}
+
+	if err := checkCommitFault(commitStepStableBlock); err != nil {
+		return err
+	}
+	if err := leveldb.SetCurrentBlock(database.LevelDB, journal.Hash); err != nil {
+		return err
+	}
+
+	// 注意这里即使是为注销候选节点不能删除记录，这里保存进去只是修改票数为0，因为在退还候选节点押金的地方要拉取所有的候选节点来判断注销的候选节点是否没有退还押金。
+	if len(journal.Candidates) > 0 {
+		if err := checkCommitFault(commitStepCandidates); err != nil {
+			return err
+		}
+		if err := database.Context.SetCandidates(journal.Candidates); err != nil {
+			return err
+		}
+		if err := database.Context.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := checkCommitFault(commitStepCleanup); err != nil {
+		return err
+	}
+	return removeCommitJournal(database.Beansdb.Home)
+}
+
+// recoverCommit replays the stable block commit which is decided before crash, or rolls back the commit which is not decided.
+// It must be called after the write queue is started
+func (database *ChainDatabase) recoverCommit() error {
+	// nothing is written before the journal is renamed
+	if err := os.Remove(journalPath(database.Beansdb.Home) + commitJournalTmpExt); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	journal, err := readCommitJournal(database.Beansdb.Home)
+	if err == ErrJournalBroken {
+		return fmt.Errorf("%v: run \"glemo db repair\" to discard it", err)
+	}
+	if err != nil || journal == nil {
+		return err
+	}
+	log.Infof("Replay the commit of stable block %s, items: %d, candidates: %d", journal.Hash.Hex(), len(journal.Items), len(journal.Candidates))
+	return database.applyCommitJournal(journal)
+}
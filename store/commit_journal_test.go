@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errCrash = errors.New("crash")
+
+// commitWithFault stops the commit of block1 at step, then reopens the database
+func commitWithFault(t *testing.T, step int) *ChainDatabase {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	block0 := GetBlock0()
+	assert.NoError(t, cacheChain.SetBlock(block0.Hash(), block0))
+	actDatabase, err := cacheChain.GetActDatabase(block0.Hash())
+	assert.NoError(t, err)
+	actDatabase.Put(GetAccount("0x01", 100, 1), 0)
+	_, err = cacheChain.SetStableBlock(block0.Hash())
+	assert.NoError(t, err)
+
+	block1 := GetBlock1()
+	assert.NoError(t, cacheChain.SetBlock(block1.Hash(), block1))
+	actDatabase, err = cacheChain.GetActDatabase(block1.Hash())
+	assert.NoError(t, err)
+	actDatabase.Put(GetAccount("0x01", 200, 2), 1)
+	actDatabase.Put(NewCandidateAccountData(2, 10), 1)
+
+	commitFault = func(s int) error {
+		if s == step {
+			return errCrash
+		}
+		return nil
+	}
+	_, err = cacheChain.SetStableBlock(block1.Hash())
+	commitFault = nil
+	assert.Equal(t, errCrash, err)
+	cacheChain.Close()
+
+	if step == commitStepItems {
+		// the last record is written partially
+		file, err := os.OpenFile(filepath.Join(GetStorePath(), "tmp.data"), os.O_WRONLY|os.O_APPEND, os.ModePerm)
+		assert.NoError(t, err)
+		_, err = file.Write(bytes.Repeat([]byte{1}, 64))
+		assert.NoError(t, err)
+		file.Close()
+	}
+	return NewChainDataBase(GetStorePath())
+}
+
+func TestChainDatabase_CommitRollback(t *testing.T) {
+	defer ClearData()
+	cacheChain := commitWithFault(t, commitStepRename)
+	defer cacheChain.Close()
+
+	stable, err := cacheChain.GetStableBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, GetBlock0().Hash(), stable.Hash())
+	account, err := cacheChain.GetAccount(common.HexToAddress("0x01"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), account.Balance.Int64())
+	assert.False(t, cacheChain.Context.CandidateIsExist(NewAccountData(2).Address))
+	_, err = os.Stat(journalPath(GetStorePath()) + commitJournalTmpExt)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestChainDatabase_CommitReplay(t *testing.T) {
+	defer ClearData()
+	for _, step := range []int{commitStepItems, commitStepStableBlock, commitStepCandidates, commitStepCleanup} {
+		cacheChain := commitWithFault(t, step)
+
+		stable, err := cacheChain.GetStableBlock()
+		assert.NoError(t, err, "step=%d", step)
+		assert.Equal(t, GetBlock1().Hash(), stable.Hash(), "step=%d", step)
+		account, err := cacheChain.GetAccount(common.HexToAddress("0x01"))
+		assert.NoError(t, err, "step=%d", step)
+		assert.Equal(t, int64(200), account.Balance.Int64(), "step=%d", step)
+		assert.True(t, cacheChain.Context.CandidateIsExist(NewAccountData(2).Address), "step=%d", step)
+		_, err = os.Stat(journalPath(GetStorePath()))
+		assert.True(t, os.IsNotExist(err), "step=%d", step)
+		cacheChain.Close()
+	}
+}
+
+func TestReadCommitJournal(t *testing.T) {
+	ClearData()
+	defer ClearData()
+	assert.NoError(t, os.MkdirAll(GetStorePath(), os.ModePerm))
+
+	journal, err := readCommitJournal(GetStorePath())
+	assert.NoError(t, err)
+	assert.Nil(t, journal)
+
+	journal = &commitJournal{Hash: common.HexToHash("0x1"), Items: []*BatchItem{{Flg: 1, Key: []byte{1}, Val: []byte{2}}}, Candidates: []*Candidate{}}
+	assert.NoError(t, writeCommitJournal(GetStorePath(), journal))
+	result, err := readCommitJournal(GetStorePath())
+	assert.NoError(t, err)
+	assert.Equal(t, journal.Hash, result.Hash)
+	assert.Equal(t, journal.Items[0].Val, result.Items[0].Val)
+
+	// broken journal
+	buf, err := ioutil.ReadFile(journalPath(GetStorePath()))
+	assert.NoError(t, err)
+	buf[len(buf)-1]++
+	assert.NoError(t, ioutil.WriteFile(journalPath(GetStorePath()), buf, os.ModePerm))
+	_, err = readCommitJournal(GetStorePath())
+	assert.Equal(t, ErrJournalBroken, err)
+}
+
+func TestOpenChainDataBase_brokenJournal(t *testing.T) {
+	cacheChain := commitWithFault(t, commitStepItems)
+	defer ClearData()
+	cacheChain.Close()
+
+	// break the journal of an interrupted commit
+	journal := &commitJournal{Hash: GetBlock1().Hash(), Items: []*BatchItem{}, Candidates: []*Candidate{}}
+	assert.NoError(t, writeCommitJournal(GetStorePath(), journal))
+	buf, err := ioutil.ReadFile(journalPath(GetStorePath()))
+	assert.NoError(t, err)
+	buf[len(buf)-1]++
+	assert.NoError(t, ioutil.WriteFile(journalPath(GetStorePath()), buf, os.ModePerm))
+	_, err = OpenChainDataBase(GetStorePath(), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrJournalBroken.Error())
+	assert.Contains(t, err.Error(), "db repair")
+	report, err := VerifyDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.Contains(t, report.Issues, "commit journal is broken")
+
+	// the journal is discarded by repair
+	_, err = RepairDB(GetStorePath())
+	assert.NoError(t, err)
+	_, err = os.Stat(journalPath(GetStorePath()))
+	assert.True(t, os.IsNotExist(err))
+	cacheChain, err = OpenChainDataBase(GetStorePath(), "")
+	assert.NoError(t, err)
+	stable, err := cacheChain.GetStableBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, GetBlock1().Hash(), stable.Hash())
+	cacheChain.Close()
+}
@@ -0,0 +1,526 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// compactMarkerName is the file which records the generation of the compaction in progress
+	compactMarkerName = "compact.marker"
+	// compactFileExt is the extension of data files which are written by compaction
+	compactFileExt = ".compact"
+	// the bitcask is compacted only if the garbage is more than 1/compactGarbageRatio of the data files
+	compactGarbageRatio = 2
+)
+
+// CompactFilter decides whether to keep a record which is written before the watermark. The records after watermark are always kept
+type CompactFilter func(flag uint32, key []byte) bool
+
+// CompactStats is the result of compaction
+type CompactStats struct {
+	BitCasks  int   // the count of compacted bitcasks
+	Records   int   // the count of records which are moved to new data files
+	Dropped   int   // the count of records which are removed by filter
+	Reclaimed int64 // the reclaimed disk space in bytes
+}
+
+// posBefore returns true if the record at position a is written before the record at position b
+func posBefore(a, b uint32) bool {
+	if a&0xff != b&0xff {
+		return a&0xff < b&0xff
+	}
+	return a&0xffffff00 < b&0xffffff00
+}
+
+func (bitcask *BitCask) compactPath(index int) string {
+	return bitcask.path(index) + compactFileExt
+}
+
+func (bitcask *BitCask) markerPath() string {
+	return filepath.Join(bitcask.Home, compactMarkerName)
+}
+
+// Watermark returns the position which the next record will be written at
+func (bitcask *BitCask) Watermark() uint32 {
+	bitcask.RW.RLock()
+	defer bitcask.RW.RUnlock()
+
+	return uint32(int(bitcask.CurOffset) | bitcask.CurIndex)
+}
+
+// Compact rewrites the records which are pointed by index into new data files, and drops the records before watermark which are refused by keep filter.
+// The records before the snapshot position are scanned and rewritten without lock, because the data files are append only. The lock is only held
+// to copy the records written during compaction and swap the data files. The index is updated in one LevelDB batch, so the new data files take effect atomically
+func (bitcask *BitCask) Compact(watermark uint32, keep CompactFilter) (*CompactStats, error) {
+	bitcask.compactLock.Lock()
+	defer bitcask.compactLock.Unlock()
+
+	snapshot := bitcask.startCompact()
+	defer bitcask.stopCompact()
+
+	stats := &CompactStats{}
+	paths, err := bitcask.dataFiles()
+	if err != nil {
+		return nil, err
+	}
+	paths = paths[:int(snapshot&0xff)+1]
+
+	// find the alive records
+	var (
+		totalSize int64
+		liveSize  int64
+		live      = make(map[uint32]bool)
+		dropped   = make([]*indexEntry, 0)
+	)
+	for fileIndex, path := range paths {
+		if fileIndex == len(paths)-1 {
+			totalSize += int64(snapshot & 0xffffff00)
+		} else {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			totalSize += info.Size()
+		}
+		_, err = scanDataFile(path, nil, func(head *RecordHead, body *RecordBody, offset int64) error {
+			recordPos := uint32(offset) | uint32(fileIndex)
+			if !posBefore(recordPos, snapshot) {
+				return nil
+			}
+			pos, err := leveldb.GetPos(bitcask.LevelDB, head.Flg, body.Key)
+			if err != nil {
+				return err
+			}
+			// the record is overwritten or deleted
+			if pos == nil || pos.Flag != head.Flg || pos.Offset != recordPos {
+				return nil
+			}
+			if keep != nil && posBefore(recordPos, watermark) && !keep(head.Flg, body.Key) {
+				dropped = append(dropped, &indexEntry{flag: head.Flg, key: body.Key, pos: recordPos})
+				return nil
+			}
+			live[recordPos] = true
+			liveSize += int64(FileUtilsAlign(uint32(RecordHeadLength) + head.Len))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// it is not worth rewriting the files if most of the data is alive
+	if totalSize == 0 || liveSize*compactGarbageRatio > totalSize {
+		return stats, nil
+	}
+
+	// copy the alive records to new data files
+	writer := &compactWriter{bitcask: bitcask}
+	moved := make([]*indexEntry, 0, len(live))
+	for fileIndex, path := range paths {
+		_, err := scanDataFile(path, nil, func(head *RecordHead, body *RecordBody, offset int64) error {
+			if !live[uint32(offset)|uint32(fileIndex)] {
+				return nil
+			}
+			pos, err := writer.write(head.Flg, body.Key, body.Val)
+			if err != nil {
+				return err
+			}
+			moved = append(moved, &indexEntry{flag: head.Flg, key: body.Key, pos: pos})
+			return nil
+		})
+		if err != nil {
+			writer.abort()
+			return nil, err
+		}
+	}
+
+	bitcask.RW.Lock()
+	defer bitcask.RW.Unlock()
+	moved, dropped = bitcask.skipDirty(moved), bitcask.skipDirty(dropped)
+	tail, err := bitcask.copyTail(writer, snapshot)
+	if err != nil {
+		writer.abort()
+		return nil, err
+	}
+	moved = append(moved, tail...)
+	oldSize, err := bitcask.dataSize()
+	if err != nil {
+		writer.abort()
+		return nil, err
+	}
+	count, currentPos, err := writer.close()
+	if err != nil {
+		writer.abort()
+		return nil, err
+	}
+
+	if err := bitcask.commitCompact(moved, dropped, count, currentPos); err != nil {
+		return nil, err
+	}
+	stats.BitCasks = 1
+	stats.Records = len(moved)
+	stats.Dropped = len(dropped)
+	stats.Reclaimed = oldSize - writer.size
+	return stats, nil
+}
+
+// startCompact starts recording the keys which are changed during compaction. It returns the position which the next record will be written at
+func (bitcask *BitCask) startCompact() uint32 {
+	bitcask.RW.Lock()
+	defer bitcask.RW.Unlock()
+
+	bitcask.dirty = make(map[string]bool)
+	return uint32(int(bitcask.CurOffset) | bitcask.CurIndex)
+}
+
+func (bitcask *BitCask) stopCompact() {
+	bitcask.RW.Lock()
+	defer bitcask.RW.Unlock()
+
+	bitcask.dirty = nil
+}
+
+// markDirty records the key which is changed during compaction. It must be called with lock
+func (bitcask *BitCask) markDirty(flag uint32, key []byte) {
+	if bitcask.dirty != nil {
+		bitcask.dirty[indexEntryKey(flag, key)] = true
+	}
+}
+
+// skipDirty removes the entries whose keys are changed during compaction, so that their new index is not overwritten. It must be called with lock
+func (bitcask *BitCask) skipDirty(entries []*indexEntry) []*indexEntry {
+	result := entries[:0]
+	for _, entry := range entries {
+		if !bitcask.dirty[indexEntryKey(entry.flag, entry.key)] {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// copyTail copies the alive records which are written after the snapshot position to new data files. It must be called with lock
+func (bitcask *BitCask) copyTail(writer *compactWriter, snapshot uint32) ([]*indexEntry, error) {
+	moved := make([]*indexEntry, 0)
+	for fileIndex := int(snapshot & 0xff); fileIndex <= bitcask.CurIndex; fileIndex++ {
+		start := int64(0)
+		if fileIndex == int(snapshot&0xff) {
+			start = int64(snapshot & 0xffffff00)
+		}
+		_, err := scanDataFileFrom(bitcask.path(fileIndex), start, nil, func(head *RecordHead, body *RecordBody, offset int64) error {
+			recordPos := uint32(offset) | uint32(fileIndex)
+			pos, err := leveldb.GetPos(bitcask.LevelDB, head.Flg, body.Key)
+			if err != nil {
+				return err
+			}
+			if pos == nil || pos.Flag != head.Flg || pos.Offset != recordPos {
+				return nil
+			}
+			newPos, err := writer.write(head.Flg, body.Key, body.Val)
+			if err != nil {
+				return err
+			}
+			moved = append(moved, &indexEntry{flag: head.Flg, key: body.Key, pos: newPos})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// dataSize returns the total size of data files. It must be called with lock
+func (bitcask *BitCask) dataSize() (int64, error) {
+	paths, err := bitcask.dataFiles()
+	if err != nil {
+		return 0, err
+	}
+	size := int64(0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// commitCompact updates the index to the new data files, then replaces the old data files
+func (bitcask *BitCask) commitCompact(moved, dropped []*indexEntry, count int, currentPos uint32) error {
+	gen, err := leveldb.GetCompactGen(bitcask.LevelDB, bitcask.BitCaskIndex)
+	if err != nil {
+		bitcask.removeCompactFiles()
+		return err
+	}
+	gen++
+
+	batch := bitcask.LevelDB.NewBatch()
+	for _, entry := range moved {
+		_ = leveldb.SetPos(batch, entry.flag, entry.key, &leveldb.Position{
+			Flag:   entry.flag,
+			Offset: entry.pos,
+		})
+	}
+	for _, entry := range dropped {
+		_ = leveldb.DelPos(batch, entry.flag, entry.key)
+	}
+	_ = leveldb.SetCurrentPos(batch, bitcask.BitCaskIndex, currentPos)
+	_ = leveldb.SetCompactGen(batch, bitcask.BitCaskIndex, gen)
+
+	// the marker tells the recovery whether the new data files should be used
+	if err := bitcask.writeCompactMarker(gen, count); err != nil {
+		bitcask.removeCompactFiles()
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		bitcask.removeCompactFiles()
+		os.Remove(bitcask.markerPath())
+		return err
+	}
+
+	bitcask.CurIndex = int(currentPos & 0xFF)
+	bitcask.CurOffset = int64(currentPos & 0xFFFFFF00)
+	// the index is updated. If the replacement is interrupted, it will be finished in recoverCompact
+	return bitcask.replaceCompactFiles(count)
+}
+
+func (bitcask *BitCask) writeCompactMarker(gen uint32, count int) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint32(buf[0:4], gen)
+	binary.BigEndian.PutUint32(buf[4:8], uint32(count))
+	file, err := os.Create(bitcask.markerPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(buf); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (bitcask *BitCask) readCompactMarker() (gen uint32, count int, ok bool, err error) {
+	buf, err := ioutil.ReadFile(bitcask.markerPath())
+	if os.IsNotExist(err) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if len(buf) != 8 {
+		// the marker is broken before the index is updated
+		return 0, 0, false, nil
+	}
+	return binary.BigEndian.Uint32(buf[0:4]), int(binary.BigEndian.Uint32(buf[4:8])), true, nil
+}
+
+// replaceCompactFiles renames the new data files to the old ones, and removes the old data files which are not used any more
+func (bitcask *BitCask) replaceCompactFiles(count int) error {
+	for index := 0; index < count; index++ {
+		isExist, err := FileUtilsIsExist(bitcask.compactPath(index))
+		if err != nil {
+			return err
+		}
+		// the file may be renamed before crash
+		if isExist {
+			if err := os.Rename(bitcask.compactPath(index), bitcask.path(index)); err != nil {
+				return err
+			}
+		}
+	}
+	// remove from the last one, so that there is no hole between data files after crash
+	last := count - 1
+	for ; last < 0xff; last++ {
+		isExist, err := FileUtilsIsExist(bitcask.path(last + 1))
+		if err != nil {
+			return err
+		}
+		if !isExist {
+			break
+		}
+	}
+	for index := last; index >= count; index-- {
+		if err := os.Remove(bitcask.path(index)); err != nil {
+			return err
+		}
+	}
+	return os.Remove(bitcask.markerPath())
+}
+
+// removeCompactFiles removes the new data files of the compaction which is not committed
+func (bitcask *BitCask) removeCompactFiles() {
+	for index := 0; index <= 0xff; index++ {
+		path := bitcask.compactPath(index)
+		if isExist, err := FileUtilsIsExist(path); err != nil || !isExist {
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			log.Errorf("remove compact file %s failed: %v", path, err)
+		}
+	}
+}
+
+// recoverCompact finishes the compaction which is interrupted after the index is updated, or rolls it back
+func (bitcask *BitCask) recoverCompact() error {
+	gen, count, ok, err := bitcask.readCompactMarker()
+	if err != nil {
+		return err
+	}
+	if ok {
+		savedGen, err := leveldb.GetCompactGen(bitcask.LevelDB, bitcask.BitCaskIndex)
+		if err != nil {
+			return err
+		}
+		if savedGen == gen {
+			log.Infof("finish the interrupted compaction of bitcask %d", bitcask.BitCaskIndex)
+			return bitcask.replaceCompactFiles(count)
+		}
+	}
+	bitcask.removeCompactFiles()
+	if err := os.Remove(bitcask.markerPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// compactWriter writes records into the new data files of compaction
+type compactWriter struct {
+	bitcask *BitCask
+	file    *os.File
+	buf     *bufio.Writer
+	index   int
+	offset  int64
+	size    int64 // total size of written data
+}
+
+func (w *compactWriter) write(flag uint32, key []byte, val []byte) (uint32, error) {
+	data, err := FileUtilsEncode(flag, key, val)
+	if err != nil {
+		return 0, err
+	}
+	if w.file == nil {
+		if err := w.open(0); err != nil {
+			return 0, err
+		}
+	} else if w.offset+int64(len(data)) > int64(maxFileSize) {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+		if err := w.open(w.index + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	pos := uint32(w.offset) | uint32(w.index)
+	if _, err := w.buf.Write(data); err != nil {
+		return 0, err
+	}
+	w.offset += int64(len(data))
+	w.size += int64(len(data))
+	return pos, nil
+}
+
+func (w *compactWriter) open(index int) error {
+	file, err := os.Create(w.bitcask.compactPath(index))
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.buf = bufio.NewWriterSize(file, 1024*1024)
+	w.index = index
+	w.offset = 0
+	return nil
+}
+
+func (w *compactWriter) flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+// close flushes the data files. It returns the count of new data files and the position for next writing
+func (w *compactWriter) close() (int, uint32, error) {
+	// keep an empty data file for writing if there is no record
+	if w.file == nil {
+		if err := w.open(0); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := w.flush(); err != nil {
+		return 0, 0, err
+	}
+	return w.index + 1, uint32(w.offset) | uint32(w.index), nil
+}
+
+func (w *compactWriter) abort() {
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.bitcask.removeCompactFiles()
+}
+
+// CompactWatermarks returns the positions which the next records will be written at in every bitcask.
+// LevelDB has no write position, so the leveldb backend starts recording the written items instead, and an empty slice is returned
+func (database *ChainDatabase) CompactWatermarks() []uint32 {
+	if itemDB, ok := database.Beansdb.Backend.(*LevelItemDatabase); ok {
+		itemDB.startCompact()
+		return []uint32{}
+	}
+	bitcasks := database.Beansdb.Queue.SyncFileDB.bitCasks()
+	watermarks := make([]uint32, len(bitcasks))
+	for index, bitcask := range bitcasks {
+		watermarks[index] = bitcask.Watermark()
+	}
+	return watermarks
+}
+
+// Compact rewrites the bitcasks which have much garbage. The records which are written before the watermarks and refused by keep filter are removed.
+// If watermarks is nil, all alive records are kept. It stops between bitcasks if quit is closed. In leveldb backend, the refused items are deleted and the LevelDB files are compacted
+func (database *ChainDatabase) Compact(watermarks []uint32, keep CompactFilter, quit <-chan struct{}) (*CompactStats, error) {
+	start := time.Now()
+	if itemDB, ok := database.Beansdb.Backend.(*LevelItemDatabase); ok {
+		if watermarks == nil {
+			keep = nil
+		}
+		stats, err := itemDB.Compact(keep, quit)
+		if err != nil {
+			return stats, err
+		}
+		log.Info("Compact database done", "dropped", stats.Dropped, "reclaimed", stats.Reclaimed, "time", time.Since(start))
+		return stats, nil
+	}
+	total := &CompactStats{}
+	for index, bitcask := range database.Beansdb.Queue.SyncFileDB.bitCasks() {
+		select {
+		case <-quit:
+			return total, ErrCompactAborted
+		default:
+		}
+
+		watermark := uint32(0)
+		if watermarks != nil {
+			watermark = watermarks[index]
+		}
+		stats, err := bitcask.Compact(watermark, keep)
+		if err != nil {
+			return total, err
+		}
+		total.BitCasks += stats.BitCasks
+		total.Records += stats.Records
+		total.Dropped += stats.Dropped
+		total.Reclaimed += stats.Reclaimed
+	}
+	log.Info("Compact database done", "bitcasks", total.BitCasks, "records", total.Records, "dropped", total.Dropped, "reclaimed", total.Reclaimed, "time", time.Since(start))
+	return total, nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newCompactTestBitCask(t *testing.T) (*BitCask, *leveldb.LevelDBDatabase) {
+	ClearData()
+	levelDB := leveldb.NewLevelDBDatabase(filepath.Join(GetStorePath(), "index"), 16, 16)
+	bitcask, err := NewBitCask(filepath.Join(GetStorePath(), "00"), 0, levelDB)
+	assert.NoError(t, err)
+	return bitcask, levelDB
+}
+
+func TestBitCask_Compact(t *testing.T) {
+	bitcask, levelDB := newCompactTestBitCask(t)
+	defer ClearData()
+	defer levelDB.Close()
+
+	key1, key2 := []byte("key1"), []byte("key2")
+	// key1 is overwritten many times
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key1, []byte{byte(i)}))
+	}
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key2, []byte("val2")))
+
+	stats, err := bitcask.Compact(0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.BitCasks)
+	assert.Equal(t, 2, stats.Records)
+	assert.Equal(t, 0, stats.Dropped)
+	assert.Equal(t, int64(9*recordAlign), stats.Reclaimed)
+	info, err := os.Stat(bitcask.path(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2*recordAlign), info.Size())
+	val, err := bitcask.Get(leveldb.ItemFlagKV, key1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{9}, val)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val2"), val)
+	_, err = os.Stat(bitcask.markerPath())
+	assert.True(t, os.IsNotExist(err))
+
+	// most of data is alive
+	stats, err = bitcask.Compact(0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.BitCasks)
+
+	// append after compaction
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key1, []byte("new")))
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("new"), val)
+
+	// drop the records before watermark
+	watermark := bitcask.Watermark()
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, []byte("key3"), []byte("val3")))
+	stats, err = bitcask.Compact(watermark, func(flag uint32, key []byte) bool {
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.BitCasks)
+	assert.Equal(t, 1, stats.Records)
+	assert.Equal(t, 2, stats.Dropped)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key1)
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, []byte("key3"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val3"), val)
+}
+
+func TestBitCask_Compact_concurrentWrite(t *testing.T) {
+	bitcask, levelDB := newCompactTestBitCask(t)
+	defer ClearData()
+	defer levelDB.Close()
+
+	key1, key2, key3, key4 := []byte("key1"), []byte("key2"), []byte("key3"), []byte("key4")
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key1, []byte{byte(i)}))
+	}
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key2, []byte("val2")))
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key3, []byte("val3")))
+
+	// the filter is called while scanning, so the writes would dead lock if the lock were held
+	written := false
+	stats, err := bitcask.Compact(bitcask.Watermark(), func(flag uint32, key []byte) bool {
+		if !written {
+			written = true
+			assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key1, []byte("new")))
+			assert.NoError(t, bitcask.Delete(leveldb.ItemFlagKV, key2))
+			assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, key4, []byte("val4")))
+		}
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.BitCasks)
+	assert.Equal(t, 3, stats.Records)
+	assert.Equal(t, 0, stats.Dropped)
+	info, err := os.Stat(bitcask.path(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4*recordAlign), info.Size())
+
+	val, err := bitcask.Get(leveldb.ItemFlagKV, key1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("new"), val)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key2)
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val3"), val)
+	val, err = bitcask.Get(leveldb.ItemFlagKV, key4)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val4"), val)
+	assert.Nil(t, bitcask.dirty)
+}
+
+func TestBitCask_recoverCompact(t *testing.T) {
+	bitcask, levelDB := newCompactTestBitCask(t)
+	defer ClearData()
+	defer levelDB.Close()
+	assert.NoError(t, bitcask.Put(leveldb.ItemFlagKV, []byte("key"), []byte("val")))
+	data, err := ioutil.ReadFile(bitcask.path(0))
+	assert.NoError(t, err)
+
+	// crash before the index is updated
+	assert.NoError(t, ioutil.WriteFile(bitcask.compactPath(0), []byte("broken"), os.ModePerm))
+	assert.NoError(t, bitcask.writeCompactMarker(1, 1))
+	bitcask, err = NewBitCask(bitcask.Home, 0, levelDB)
+	assert.NoError(t, err)
+	_, err = os.Stat(bitcask.compactPath(0))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(bitcask.markerPath())
+	assert.True(t, os.IsNotExist(err))
+	val, err := bitcask.Get(leveldb.ItemFlagKV, []byte("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val"), val)
+
+	// crash after the index is updated
+	assert.NoError(t, ioutil.WriteFile(bitcask.compactPath(0), data, os.ModePerm))
+	assert.NoError(t, FileUtilsCreateFile(bitcask.path(1)))
+	assert.NoError(t, bitcask.writeCompactMarker(1, 1))
+	assert.NoError(t, leveldb.SetCompactGen(levelDB, 0, 1))
+	bitcask, err = NewBitCask(bitcask.Home, 0, levelDB)
+	assert.NoError(t, err)
+	_, err = os.Stat(bitcask.compactPath(0))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(bitcask.path(1))
+	assert.True(t, os.IsNotExist(err))
+	val, err = bitcask.Get(leveldb.ItemFlagKV, []byte("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("val"), val)
+}
@@ -0,0 +1,465 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"os"
+	"path/filepath"
+)
+
+var ErrDBNotExist = errors.New("database is not exist")
+
+// recordAlign is the alignment of records in data files
+const recordAlign = 256
+
+// DBReport is the result of database checking
+type DBReport struct {
+	Records       int // valid records in bitcask files
+	BrokenRecords int // broken records in bitcask files
+	QueuedRecords int // records in queue file, they will be written to bitcask files when database is opened
+	Blocks        int // stable blocks which are checked
+	Candidates    int // candidates in run context which are checked
+	Issues        []string
+}
+
+// OK returns true if there is no issue found
+func (r *DBReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *DBReport) addIssue(format string, args ...interface{}) {
+	issue := fmt.Sprintf(format, args...)
+	log.Warn("Database issue: " + issue)
+	r.Issues = append(r.Issues, issue)
+}
+
+// indexEntry is the latest record of a key in bitcask
+type indexEntry struct {
+	flag uint32
+	key  []byte
+	pos  uint32
+}
+
+func indexEntryKey(flag uint32, key []byte) string {
+	return string(leveldb.EncodeNumber(flag)) + string(key)
+}
+
+// dbChecker reads the database files without starting the write queue
+type dbChecker struct {
+	home    string
+	levelDB *leveldb.LevelDBDatabase
+	fileDB  *BitCaskDatabase
+	queued  map[string][]byte // records in queue file
+	report  *DBReport
+}
+
+func newDBChecker(home string) (*dbChecker, error) {
+	indexPath := filepath.Join(home, "index")
+	if isExist, err := FileUtilsIsExist(indexPath); err != nil {
+		return nil, err
+	} else if !isExist {
+		return nil, ErrDBNotExist
+	}
+
+	levelDB := leveldb.NewLevelDBDatabase(indexPath, 16, 16)
+	// the records in LevelDB backend are checked by LevelDB itself
+	if backend, err := leveldb.GetStoreBackend(levelDB); err != nil || (backend != "" && backend != BackendBitCask) {
+		levelDB.Close()
+		if err == nil {
+			err = ErrBackendNotSupported
+		}
+		return nil, err
+	}
+	// the bitcasks are not opened, so that the files are not created
+	fileDB := &BitCaskDatabase{Home: home, Height: 2, LevelDB: levelDB}
+	fileDB.BitCasks = make([]*BitCask, 1<<(fileDB.Height*4))
+	for index := range fileDB.BitCasks {
+		fileDB.BitCasks[index] = &BitCask{
+			Home:         bitCaskPath(home, index),
+			BitCaskIndex: index,
+			LevelDB:      levelDB,
+		}
+	}
+	return &dbChecker{
+		home:    home,
+		levelDB: levelDB,
+		fileDB:  fileDB,
+		queued:  make(map[string][]byte),
+		report:  &DBReport{},
+	}, nil
+}
+
+func (c *dbChecker) close() {
+	c.levelDB.Close()
+}
+
+// scanDataFile reads all records in a data file. A record which is broken is reported to onBroken and skipped to the next aligned offset.
+// It returns the end offset of the last valid record
+func scanDataFile(path string, onBroken func(offset int64), fn func(head *RecordHead, body *RecordBody, offset int64) error) (int64, error) {
+	return scanDataFileFrom(path, 0, onBroken, fn)
+}
+
+// scanDataFileFrom reads the records from the start offset in a data file
+func scanDataFileFrom(path string, start int64, onBroken func(offset int64), fn func(head *RecordHead, body *RecordBody, offset int64) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		size       = info.Size()
+		end        = start
+		lastBroken = false
+		headBuf    = make([]byte, RecordHeadLength)
+		emptyHead  = make([]byte, RecordHeadLength)
+	)
+	for offset := start; offset+int64(RecordHeadLength) <= size; {
+		if _, err := file.ReadAt(headBuf, offset); err != nil {
+			return 0, err
+		}
+		head, body := readRecord(file, headBuf, offset, size)
+		if head == nil {
+			// the space which is not written is not broken
+			if !lastBroken && !bytes.Equal(headBuf, emptyHead) {
+				if onBroken != nil {
+					onBroken(offset)
+				}
+				lastBroken = true
+			}
+			offset += recordAlign
+			continue
+		}
+		lastBroken = false
+		if err := fn(head, body, offset); err != nil {
+			return 0, err
+		}
+		offset += int64(FileUtilsAlign(uint32(RecordHeadLength) + head.Len))
+		end = offset
+	}
+	return end, nil
+}
+
+func (c *dbChecker) onBroken(path string) func(offset int64) {
+	return func(offset int64) {
+		c.report.BrokenRecords++
+		c.report.addIssue("broken record in %s at offset %d", path, offset)
+	}
+}
+
+// readRecord decodes the record at offset. It returns nil if the record is broken
+func readRecord(file *os.File, headBuf []byte, offset, size int64) (*RecordHead, *RecordBody) {
+	var head RecordHead
+	if err := binary.Read(bytes.NewBuffer(headBuf), binary.LittleEndian, &head); err != nil {
+		return nil, nil
+	}
+	bodyOffset := offset + int64(RecordHeadLength)
+	if !leveldb.CheckItemFlag(head.Flg) || head.Len == 0 || bodyOffset+int64(head.Len) > size {
+		return nil, nil
+	}
+	bodyBuf := make([]byte, head.Len)
+	if _, err := file.ReadAt(bodyBuf, bodyOffset); err != nil {
+		return nil, nil
+	}
+	if CheckSum(bodyBuf) != head.Crc {
+		return nil, nil
+	}
+	var body RecordBody
+	if err := rlp.DecodeBytes(bodyBuf, &body); err != nil {
+		return nil, nil
+	}
+	return &head, &body
+}
+
+// scanBitCask reads all records in bitcask. It returns the latest records of keys and the current position for next writing
+func (c *dbChecker) scanBitCask(bitcask *BitCask) (map[string]*indexEntry, uint32, error) {
+	paths, err := bitcask.dataFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := make(map[string]*indexEntry)
+	currentPos := uint32(0)
+	for fileIndex, path := range paths {
+		end, err := scanDataFile(path, c.onBroken(path), func(head *RecordHead, body *RecordBody, offset int64) error {
+			c.report.Records++
+			entries[indexEntryKey(head.Flg, body.Key)] = &indexEntry{
+				flag: head.Flg,
+				key:  body.Key,
+				pos:  uint32(offset) | uint32(fileIndex),
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		currentPos = uint32(end) | uint32(fileIndex)
+	}
+	return entries, currentPos, nil
+}
+
+// checkBitCask checks the records in bitcask and their positions in LevelDB index
+func (c *dbChecker) checkBitCask(bitcask *BitCask) error {
+	entries, currentPos, err := c.scanBitCask(bitcask)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		pos, err := leveldb.GetPos(c.levelDB, entry.flag, entry.key)
+		if err != nil {
+			return err
+		}
+		if pos == nil {
+			c.report.addIssue("index of record is missing. flag: %d, key: %s", entry.flag, common.ToHex(entry.key))
+		} else if pos.Flag != entry.flag || pos.Offset != entry.pos {
+			c.report.addIssue("index of record is incorrect. flag: %d, key: %s, position: %d, expected: %d", entry.flag, common.ToHex(entry.key), pos.Offset, entry.pos)
+		}
+	}
+	savedPos, err := leveldb.GetCurrentPos(c.levelDB, bitcask.BitCaskIndex)
+	if err != nil {
+		return err
+	}
+	if savedPos != currentPos {
+		c.report.addIssue("current position of bitcask %d is incorrect. position: %d, expected: %d", bitcask.BitCaskIndex, savedPos, currentPos)
+	}
+	return nil
+}
+
+// loadQueue reads the records in queue file. They are newer than the records in bitcask files
+func (c *dbChecker) loadQueue() error {
+	path := filepath.Join(c.home, "tmp.data")
+	if isExist, err := FileUtilsIsExist(path); err != nil || !isExist {
+		return err
+	}
+	_, err := scanDataFile(path, c.onBroken(path), func(head *RecordHead, body *RecordBody, offset int64) error {
+		c.report.QueuedRecords++
+		c.queued[indexEntryKey(head.Flg, body.Key)] = body.Val
+		return nil
+	})
+	return err
+}
+
+func (c *dbChecker) get(flag uint32, key []byte) ([]byte, error) {
+	if val, ok := c.queued[indexEntryKey(flag, key)]; ok {
+		return val, nil
+	}
+	return c.fileDB.route(key).Get(flag, key)
+}
+
+// checkBlocks re-hashes the stable blocks by height, and checks the links between them
+func (c *dbChecker) checkBlocks() error {
+	stableHash, err := leveldb.GetCurrentBlock(c.levelDB)
+	if err != nil {
+		return err
+	}
+	if stableHash == (common.Hash{}) {
+		return nil
+	}
+	stable, err := c.getBlock(stableHash)
+	if err != nil {
+		return err
+	}
+	if stable == nil {
+		c.report.addIssue("stable block %s is missing", stableHash.Hex())
+		return nil
+	}
+
+	parentHash := common.Hash{}
+	for height := uint32(0); height <= stable.Height(); height++ {
+		val, err := c.get(leveldb.ItemFlagBlockHeight, leveldb.EncodeNumber(height))
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			c.report.addIssue("block hash at height %d is missing", height)
+			parentHash = common.Hash{}
+			continue
+		}
+		hash := common.BytesToHash(val)
+		block, err := c.getBlock(hash)
+		if err != nil {
+			c.report.addIssue("decode block %d failed: %v", height, err)
+			parentHash = common.Hash{}
+			continue
+		}
+		if block == nil {
+			c.report.addIssue("block %d is missing. hash: %s", height, hash.Hex())
+			parentHash = common.Hash{}
+			continue
+		}
+		c.report.Blocks++
+		c.checkBlock(block, hash, height, parentHash)
+		parentHash = hash
+	}
+	if parentHash != stableHash {
+		c.report.addIssue("stable block %s is not at height %d", stableHash.Hex(), stable.Height())
+	}
+	return nil
+}
+
+func (c *dbChecker) getBlock(hash common.Hash) (*types.Block, error) {
+	val, err := c.get(leveldb.ItemFlagBlock, hash.Bytes())
+	if err != nil || val == nil {
+		return nil, err
+	}
+	var block types.Block
+	if err := rlp.DecodeBytes(val, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *dbChecker) checkBlock(block *types.Block, hash common.Hash, height uint32, parentHash common.Hash) {
+	if block.Hash() != hash {
+		c.report.addIssue("hash of block %d is incorrect. hash: %s, expected: %s", height, block.Hash().Hex(), hash.Hex())
+	}
+	if block.Height() != height {
+		c.report.addIssue("height of block %s is incorrect. height: %d, expected: %d", hash.Hex(), block.Height(), height)
+	}
+	if height > 0 && parentHash != (common.Hash{}) && block.ParentHash() != parentHash {
+		c.report.addIssue("parent of block %d is incorrect. parent: %s, expected: %s", height, block.ParentHash().Hex(), parentHash.Hex())
+	}
+	if block.Txs.MerkleRootSha() != block.TxRoot() {
+		c.report.addIssue("transactions of block %d don't match the txRoot", height)
+	}
+	if len(block.ChangeLogs) > 0 && block.ChangeLogs.MerkleRootSha() != block.LogRoot() {
+		c.report.addIssue("change logs of block %d don't match the logRoot", height)
+	}
+	if len(block.DeputyNodes) > 0 {
+		deputyRoot := block.DeputyNodes.MerkleRootSha()
+		if !bytes.Equal(deputyRoot[:], block.DeputyRoot()) {
+			c.report.addIssue("deputy nodes of block %d don't match the deputyRoot", height)
+		}
+	}
+}
+
+// checkCandidates checks the candidates in run context with their accounts
+func (c *dbChecker) checkCandidates() error {
+	context := &RunContext{
+		Path:       filepath.Join(c.home, "context.data"),
+		Candidates: NewCandidateCache(),
+	}
+	if isExist, err := FileUtilsIsExist(context.Path); err != nil || !isExist {
+		return err
+	}
+	// the decoder panics if the data is broken
+	defer func() {
+		if r := recover(); r != nil {
+			c.report.addIssue("run context is broken: %v", r)
+		}
+	}()
+	if err := context.load(); err != nil {
+		c.report.addIssue("load run context failed: %v", err)
+		return nil
+	}
+	candidates, err := context.GetCandidates()
+	if err != nil {
+		c.report.addIssue("decode candidates in run context failed: %v", err)
+		return nil
+	}
+	for _, candidate := range candidates {
+		c.report.Candidates++
+		val, err := c.get(leveldb.ItemFlagAct, candidate.Address.Bytes())
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			c.report.addIssue("account of candidate %s is missing", candidate.Address.String())
+			continue
+		}
+		var account types.AccountData
+		if err := rlp.DecodeBytes(val, &account); err != nil {
+			c.report.addIssue("decode account of candidate %s failed: %v", candidate.Address.String(), err)
+			continue
+		}
+		// the votes of unregistered candidate is not updated
+		if account.Candidate.Profile[types.CandidateKeyIsCandidate] != types.IsCandidateNode {
+			continue
+		}
+		if account.Candidate.Votes == nil || candidate.Total == nil || account.Candidate.Votes.Cmp(candidate.Total) != 0 {
+			c.report.addIssue("votes of candidate %s is incorrect. votes: %v, expected: %v", candidate.Address.String(), candidate.Total, account.Candidate.Votes)
+		}
+	}
+	return nil
+}
+
+// VerifyDB checks the CRC of records in bitcask files, the consistency of LevelDB index, the stable blocks and the candidates in run context.
+// The database must not be opened by others
+func VerifyDB(home string) (*DBReport, error) {
+	checker, err := newDBChecker(home)
+	if err != nil {
+		return nil, err
+	}
+	defer checker.close()
+
+	for _, bitcask := range checker.fileDB.BitCasks {
+		if err := checker.checkBitCask(bitcask); err != nil {
+			return nil, err
+		}
+	}
+	if err := checker.loadQueue(); err != nil {
+		return nil, err
+	}
+	if err := checker.checkBlocks(); err != nil {
+		return nil, err
+	}
+	if err := checker.checkCandidates(); err != nil {
+		return nil, err
+	}
+	if _, err := readCommitJournal(home); err == ErrJournalBroken {
+		checker.report.addIssue("commit journal is broken")
+	}
+	return checker.report, nil
+}
+
+// RepairDB rebuilds the LevelDB index from the records in bitcask files. The broken records are skipped, and the broken commit journal is discarded.
+// The database must not be opened by others
+func RepairDB(home string) (*DBReport, error) {
+	checker, err := newDBChecker(home)
+	if err != nil {
+		return nil, err
+	}
+	defer checker.close()
+
+	for _, bitcask := range checker.fileDB.BitCasks {
+		// finish the compaction before crash, so that the new data files are scanned
+		if err := bitcask.recoverCompact(); err != nil {
+			return nil, err
+		}
+		entries, currentPos, err := checker.scanBitCask(bitcask)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			err = leveldb.SetPos(checker.levelDB, entry.flag, entry.key, &leveldb.Position{
+				Flag:   entry.flag,
+				Offset: entry.pos,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := leveldb.SetCurrentPos(checker.levelDB, bitcask.BitCaskIndex, currentPos); err != nil {
+			return nil, err
+		}
+	}
+	// the broken journal can't be replayed, so the stable block stays at the one before the commit
+	if _, err := readCommitJournal(home); err == ErrJournalBroken {
+		if err := removeCommitJournal(home); err != nil {
+			return nil, err
+		}
+		log.Warn("The broken commit journal is discarded")
+	}
+	log.Infof("Rebuild index done. records: %d, broken: %d", checker.report.Records, checker.report.BrokenRecords)
+	return checker.report, nil
+}
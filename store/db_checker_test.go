@@ -0,0 +1,103 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newCheckerTestDB(t *testing.T) []*types.Block {
+	ClearData()
+	db := NewChainDataBase(GetStorePath())
+	defer db.Close()
+
+	blocks := make([]*types.Block, 3)
+	parentHash := common.Hash{}
+	for i := range blocks {
+		blocks[i] = CreateBlock(common.HexToHash("0x1"), parentHash, uint32(i))
+		blocks[i].Header.TxRoot = blocks[i].Txs.MerkleRootSha()
+		parentHash = blocks[i].Hash()
+		assert.NoError(t, db.SetBlock(blocks[i].Hash(), blocks[i]))
+		// the genesis must be stable before inserting other blocks
+		if i == 0 {
+			_, err := db.SetStableBlock(blocks[0].Hash())
+			assert.NoError(t, err)
+		}
+	}
+	_, err := db.SetStableBlock(blocks[2].Hash())
+	assert.NoError(t, err)
+	return blocks
+}
+
+func TestVerifyDB(t *testing.T) {
+	_, err := VerifyDB(GetStorePath() + "_not_exist")
+	assert.Equal(t, ErrDBNotExist, err)
+
+	newCheckerTestDB(t)
+	defer ClearData()
+	report, err := VerifyDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Issues)
+	assert.Equal(t, 3, report.Blocks)
+	assert.NotZero(t, report.Records)
+	assert.Zero(t, report.BrokenRecords)
+}
+
+func TestRepairDB(t *testing.T) {
+	blocks := newCheckerTestDB(t)
+	defer ClearData()
+
+	// lose the index after crash
+	levelDB := leveldb.NewLevelDBDatabase(filepath.Join(GetStorePath(), "index"), 16, 16)
+	assert.NoError(t, leveldb.DelPos(levelDB, leveldb.ItemFlagBlock, blocks[1].Hash().Bytes()))
+	index := Byte2Uint32(blocks[1].Hash().Bytes()) >> 24
+	assert.NoError(t, leveldb.SetCurrentPos(levelDB, int(index), 0))
+	levelDB.Close()
+
+	report, err := VerifyDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.NotEmpty(t, report.Issues)
+
+	report, err = RepairDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Issues)
+	report, err = VerifyDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Issues)
+	assert.Equal(t, 3, report.Blocks)
+}
+
+func TestVerifyDB_broken(t *testing.T) {
+	newCheckerTestDB(t)
+	defer ClearData()
+
+	// break the first record of first non-empty data file
+	var dataPath string
+	_ = filepath.Walk(GetStorePath(), func(path string, info os.FileInfo, err error) error {
+		if err == nil && dataPath == "" && filepath.Ext(path) == ".data" && filepath.Base(path) != "tmp.data" && filepath.Base(path) != "context.data" && info.Size() > 0 {
+			dataPath = path
+		}
+		return nil
+	})
+	assert.NotEmpty(t, dataPath)
+	file, err := os.OpenFile(dataPath, os.O_WRONLY, os.ModePerm)
+	assert.NoError(t, err)
+	_, err = file.WriteAt([]byte{0xff, 0xff}, int64(RecordHeadLength))
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	report, err := VerifyDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 1, report.BrokenRecords)
+
+	// the broken record can't be repaired
+	report, err = RepairDB(GetStorePath())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.BrokenRecords)
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -38,7 +37,7 @@ func (queue *FileQueue) path() string {
 	return filepath.Join(queue.Home, "tmp.data")
 }
 
-func NewFileQueue(home string, levelDB *leveldb.LevelDBDatabase, extend WriteExtend) *FileQueue {
+func NewFileQueue(home string, levelDB *leveldb.LevelDBDatabase, backend Database, extend WriteExtend) *FileQueue {
 	doneChan := make(chan *Inject, 1024*256)
 	errChan := make(chan *Inject)
 	quit := make(chan struct{})
@@ -47,7 +46,7 @@ func NewFileQueue(home string, levelDB *leveldb.LevelDBDatabase, extend WriteExt
 		Index:      make(map[string]*item),
 		Offset:     0,
 		LevelDB:    levelDB,
-		SyncFileDB: NewSyncFileDB(home, levelDB, doneChan, errChan, quit, extend),
+		SyncFileDB: NewSyncFileDB(home, levelDB, backend, doneChan, errChan, quit, extend),
 		DoneChan:   doneChan,
 		ErrChan:    errChan,
 		Quit:       quit,
@@ -80,8 +79,10 @@ func (queue *FileQueue) start() {
 	}()
 }
 
+// Close stops the queue after the pending items are written into backend
 func (queue *FileQueue) Close() {
 	close(queue.Quit)
+	<-queue.SyncFileDB.Stopped
 }
 
 func (queue *FileQueue) setIndex(item *item) {
@@ -182,35 +183,39 @@ func (queue *FileQueue) emptyFile(path string) {
 	}
 }
 
+// scanFile delivers the records in file from offset. The broken records at the end of file are written partially before crash, so they are truncated
 func (queue *FileQueue) scanFile(filePath string, offset int64) (int64, error) {
-	file, err := os.OpenFile(filePath, os.O_RDONLY, os.ModePerm)
-	defer file.Close()
-
-	if err != nil {
-		return -1, err
-	}
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return -1, err
 	}
 
 	fileSize := fileInfo.Size()
-	queue.Offset = offset
-	for {
-		head, body, err := FileUtilsRead(file, queue.Offset)
-		if err == io.EOF {
-			return queue.Offset, ErrEOF
+	end, err := scanDataFile(filePath, func(brokenOffset int64) {
+		log.Warnf("skip broken record in %s at offset %d", filePath, brokenOffset)
+	}, func(head *RecordHead, body *RecordBody, recordOffset int64) error {
+		if recordOffset < offset {
+			return nil
 		}
+		queue.Offset = recordOffset
+		queue.deliver(head.Flg, body.Key, body.Val)
+		log.Debugf("load file progress: %d/%d", recordOffset, fileSize)
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
 
-		if err != nil {
+	if end < offset {
+		end = offset
+	}
+	if end < fileSize {
+		if err := os.Truncate(filePath, end); err != nil {
 			return -1, err
 		}
-
-		length := FileUtilsAlign(uint32(RecordHeadLength) + uint32(head.Len))
-		queue.deliver(head.Flg, body.Key, body.Val)
-		queue.Offset += int64(length)
-		log.Debugf("load file progress: %d/%d", queue.Offset, fileSize)
 	}
+	queue.Offset = end
+	return end, ErrEOF
 }
 
 func (queue *FileQueue) encodeBatchItems(items []*BatchItem) ([][]byte, error) {
@@ -258,6 +263,20 @@ func (queue *FileQueue) mergeBatchItems(tmpBuf [][]byte) []byte {
 	return totalBuf
 }
 
+// pendingKeys returns the keys of items in flag, which are not written into file database yet
+func (queue *FileQueue) pendingKeys(flag uint32) [][]byte {
+	queue.IndexRW.RLock()
+	defer queue.IndexRW.RUnlock()
+
+	keys := make([][]byte, 0)
+	for _, val := range queue.Index {
+		if val.flg == flag {
+			keys = append(keys, val.key)
+		}
+	}
+	return keys
+}
+
 func (queue *FileQueue) Get(flag uint32, key []byte) ([]byte, error) {
 	val := queue.getIndex(flag, key)
 	if val != nil {
@@ -404,6 +404,7 @@ func (db *LevelDBDatabase) NewBatch() Batch {
 
 type Batch interface {
 	DatabasePutter
+	DatabaseDeleter
 	ValueSize() int // amount of data in the batch
 	Write() error
 	// Reset resets the batch for reuse
@@ -422,6 +423,12 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size += 1
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
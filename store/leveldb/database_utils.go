@@ -31,7 +31,10 @@ var (
 	ItemFlagKV          = uint32(7)
 	ItemFlagAssetCode   = uint32(8)
 	ItemFlagAssetId     = uint32(9)
-	ItemFlagStop        = uint32(10)
+	ItemFlagReceipts    = uint32(10)
+	ItemFlagBloom       = uint32(11)
+	ItemFlagActHistory  = uint32(12)
+	ItemFlagStop        = uint32(13)
 )
 
 var (
@@ -47,12 +50,30 @@ var (
 	TxPrefix = []byte("TX")
 	TxSuffix = []byte("tx")
 
+	TxIndexPrefix = []byte("TI")
+	TxIndexSuffix = []byte("ti") // TxIndexPrefix + tx hash + TxIndexSuffix -> tx position in stable block
+
+	AddrTxPrefix = []byte("TA")
+	AddrTxSuffix = []byte("ta") // AddrTxPrefix + address + index (uint32 big endian) + AddrTxSuffix -> tx hash
+
+	AddrTxCountPrefix = []byte("TC")
+	AddrTxCountSuffix = []byte("tc") // AddrTxCountPrefix + address + AddrTxCountSuffix -> tx count (uint32 big endian)
+
 	AssetCodePrefix = []byte("AC")
 	AssetCodeSuffix = []byte("ac")
 
 	AssetIdPrefix = []byte("AI")
 	AssetIdSuffix = []byte("ai")
 
+	ReceiptsPrefix = []byte("RC")
+	ReceiptsSuffix = []byte("rc") // ReceiptsPrefix + "R" + block hash + ReceiptsSuffix -> position of the block's receipts
+
+	BloomPrefix = []byte("BL")
+	BloomSuffix = []byte("bl") // BloomPrefix + "L" + block hash + BloomSuffix -> position of the block's events bloom
+
+	ActHistoryPrefix = []byte("AH")
+	ActHistorySuffix = []byte("ah") // ActHistoryPrefix + "H" + address + [height (uint32 big endian)] + ActHistorySuffix -> position of the account's history record
+
 	TrieNodePrefix = []byte("TN")
 	TrieNodeSuffix = []byte("tn")
 
@@ -65,7 +86,14 @@ var (
 	BitCaskCurrentOffsetPrefix = []byte("OFFSET")
 	BitCaskCurrentOffsetSuffix = []byte("offset")
 
-	StableBlockKey = []byte("LEMO-CURRENT-BLOCK")
+	BitCaskCompactPrefix = []byte("COMPACT")
+	BitCaskCompactSuffix = []byte("compact") // BitCaskCompactPrefix + bitcask index + BitCaskCompactSuffix -> compaction generation (uint32 big endian)
+
+	StableBlockKey  = []byte("LEMO-CURRENT-BLOCK")
+	PruneHeightKey  = []byte("LEMO-PRUNE-HEIGHT")  // the states before this height are pruned except the term snapshots
+	StoreBackendKey = []byte("LEMO-STORE-BACKEND") // the name of backend which stores the items
+	// the height of snapshot block whose state is synchronized from remote peers. The account fields in the state are not verified
+	UnverifiedSnapshotKey = []byte("LEMO-UNVERIFIED-SNAPSHOT")
 )
 
 func CheckItemFlag(flg uint32) bool {
@@ -105,11 +133,66 @@ func Key(flag uint32, key []byte) []byte {
 		return append(append(AssetCodePrefix, key...), AssetCodeSuffix...)
 	case ItemFlagAssetId:
 		return append(append(AssetIdPrefix, key...), AssetIdSuffix...)
+	case ItemFlagReceipts:
+		return append(append(ReceiptsPrefix, key...), ReceiptsSuffix...)
+	case ItemFlagBloom:
+		return append(append(BloomPrefix, key...), BloomSuffix...)
+	case ItemFlagActHistory:
+		return append(append(ActHistoryPrefix, key...), ActHistorySuffix...)
 	default:
 		return key
 	}
 }
 
+// itemPrefixes returns the prefix and suffix of index keys of item flag
+func itemPrefixes(flag uint32) ([]byte, []byte) {
+	switch flag {
+	case ItemFlagBlock:
+		return BlockPrefix, BlockSuffix
+	case ItemFlagBlockHeight:
+		return BlockHeightPrefix, BlockHeightSuffix
+	case ItemFlagTrie:
+		return TrieNodePrefix, TrieNodeSuffix
+	case ItemFlagAct:
+		return AccountPrefix, AccountSuffix
+	case ItemFlagTxIndex:
+		return TxPrefix, TxSuffix
+	case ItemFlagCode:
+		return CodePrefix, CodeSuffix
+	case ItemFlagKV:
+		return KVPrefix, KVSuffix
+	case ItemFlagAssetCode:
+		return AssetCodePrefix, AssetCodeSuffix
+	case ItemFlagAssetId:
+		return AssetIdPrefix, AssetIdSuffix
+	case ItemFlagReceipts:
+		return ReceiptsPrefix, ReceiptsSuffix
+	case ItemFlagBloom:
+		return BloomPrefix, BloomSuffix
+	case ItemFlagActHistory:
+		return ActHistoryPrefix, ActHistorySuffix
+	default:
+		return nil, nil
+	}
+}
+
+// ParseKey returns the item flag and item key in the index key which is made by Key. The flags with the same first prefix letter have different last suffix letters, so the result is unique
+func ParseKey(key []byte) (uint32, []byte, bool) {
+	for flag := ItemFlagStart + 1; flag < ItemFlagStop; flag++ {
+		prefix, suffix := itemPrefixes(flag)
+		if len(key) > len(prefix)+len(suffix) && bytes.HasPrefix(key, prefix) && bytes.HasSuffix(key, suffix) {
+			return flag, key[len(prefix) : len(key)-len(suffix)], true
+		}
+	}
+	return 0, nil, false
+}
+
+// IsBitCaskKey returns true if the key is the bookkeeping data of bitcask files
+func IsBitCaskKey(key []byte) bool {
+	return (bytes.HasPrefix(key, BitCaskCurrentOffsetPrefix) && bytes.HasSuffix(key, BitCaskCurrentOffsetSuffix)) ||
+		(bytes.HasPrefix(key, BitCaskCompactPrefix) && bytes.HasSuffix(key, BitCaskCompactSuffix))
+}
+
 func toPosition(val []byte) (*Position, error) {
 	if len(val) <= 0 {
 		return nil, nil
@@ -180,6 +263,25 @@ func SetCurrentPos(db DatabasePutter, index int, pos uint32) error {
 	return db.Put(key, EncodeNumber(pos))
 }
 
+// GetCompactGen returns the generation of the bitcask's last compaction
+func GetCompactGen(db DatabaseReader, index int) (uint32, error) {
+	key := append(append(BitCaskCompactPrefix, []byte(strconv.Itoa(index))...), BitCaskCompactSuffix...)
+	data, err := db.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) != 4 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func SetCompactGen(db DatabasePutter, index int, gen uint32) error {
+	key := append(append(BitCaskCompactPrefix, []byte(strconv.Itoa(index))...), BitCaskCompactSuffix...)
+	return db.Put(key, EncodeNumber(gen))
+}
+
 func GetCurrentBlock(db DatabaseReader) (common.Hash, error) {
 	val, err := db.Get(StableBlockKey)
 	if err != nil {
@@ -197,6 +299,75 @@ func SetCurrentBlock(db DatabasePutter, hash common.Hash) error {
 	return db.Put(StableBlockKey, hash.Bytes())
 }
 
+func GetPruneHeight(db DatabaseReader) (uint32, error) {
+	val, err := db.Get(PruneHeightKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(val) != 4 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint32(val), nil
+}
+
+func SetPruneHeight(db DatabasePutter, height uint32) error {
+	return db.Put(PruneHeightKey, EncodeNumber(height))
+}
+
+func GetUnverifiedSnapshot(db DatabaseReader) (uint32, error) {
+	val, err := db.Get(UnverifiedSnapshotKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(val) != 4 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint32(val), nil
+}
+
+func SetUnverifiedSnapshot(db DatabasePutter, height uint32) error {
+	return db.Put(UnverifiedSnapshotKey, EncodeNumber(height))
+}
+
+func GetStoreBackend(db DatabaseReader) (string, error) {
+	val, err := db.Get(StoreBackendKey)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+func SetStoreBackend(db DatabasePutter, backend string) error {
+	return db.Put(StoreBackendKey, []byte(backend))
+}
+
+// joinKey concatenates key parts into a new buffer, so the shared prefix slices are never written by append
+func joinKey(parts ...[]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += len(part)
+	}
+	key := make([]byte, 0, size)
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+func GetTxIndexKey(hash common.Hash) []byte {
+	return joinKey(TxIndexPrefix, hash.Bytes(), TxIndexSuffix)
+}
+
+func GetAddrTxKey(addr common.Address, index uint32) []byte {
+	return joinKey(AddrTxPrefix, addr.Bytes(), EncodeNumber(index), AddrTxSuffix)
+}
+
+func GetAddrTxCountKey(addr common.Address) []byte {
+	return joinKey(AddrTxCountPrefix, addr.Bytes(), AddrTxCountSuffix)
+}
+
 func Set(db DatabasePutter, key []byte, val []byte) error {
 	return db.Put(key, val)
 }
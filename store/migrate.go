@@ -0,0 +1,121 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MigrateStats is the result of migration
+type MigrateStats struct {
+	From    string // the backend of source database
+	Items   int    // the count of items which are copied by backend
+	Indexes int    // the count of LevelDB entries which are copied directly, e.g. stable block, transaction index
+}
+
+// MigrateDB copies the database in srcHome to dstHome which is stored by backend. The dstHome must not exist.
+// The records in write queue file are copied as they are, so they are written into backend when the new database is opened.
+// The databases must not be opened by others
+func MigrateDB(srcHome, dstHome, backend string) (*MigrateStats, error) {
+	if err := CheckBackend(backend); err != nil {
+		return nil, err
+	}
+	srcIndexPath := filepath.Join(srcHome, "index")
+	if isExist, err := FileUtilsIsExist(srcIndexPath); err != nil {
+		return nil, err
+	} else if !isExist {
+		return nil, ErrDBNotExist
+	}
+	if isExist, err := FileUtilsIsExist(dstHome); err != nil {
+		return nil, err
+	} else if isExist {
+		return nil, ErrExist
+	}
+
+	start := time.Now()
+	srcLevelDB := leveldb.NewLevelDBDatabase(srcIndexPath, 16, 16)
+	defer srcLevelDB.Close()
+	srcDB, srcBackend, err := openBackend("", srcHome, srcLevelDB)
+	if err != nil {
+		return nil, err
+	}
+	defer srcDB.Close()
+	if srcBackend == backend {
+		return nil, ErrBackendUnchanged
+	}
+	if err := os.MkdirAll(dstHome, os.ModePerm); err != nil {
+		return nil, err
+	}
+	dstLevelDB := leveldb.NewLevelDBDatabase(filepath.Join(dstHome, "index"), 16, 16)
+	defer dstLevelDB.Close()
+	dstDB, _, err := openBackend(backend, dstHome, dstLevelDB)
+	if err != nil {
+		return nil, err
+	}
+	defer dstDB.Close()
+
+	stats := &MigrateStats{From: srcBackend}
+	if err := migrateIndexes(srcLevelDB, dstLevelDB, stats); err != nil {
+		return nil, err
+	}
+	err = srcDB.(ItemIterator).Iterate(func(flag uint32, key, val []byte) error {
+		stats.Items++
+		if stats.Items%100000 == 0 {
+			log.Infof("Migrating items: %d", stats.Items)
+		}
+		return dstDB.Put(flag, key, val)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"context.data", "tmp.data"} {
+		if err := copyDBFile(filepath.Join(srcHome, name), filepath.Join(dstHome, name)); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("Migrate database done", "from", srcBackend, "to", backend, "items", stats.Items, "indexes", stats.Indexes, "time", time.Since(start))
+	return stats, nil
+}
+
+// migrateIndexes copies the LevelDB entries which are not the items or the bookkeeping data of backend
+func migrateIndexes(src, dst *leveldb.LevelDBDatabase, stats *MigrateStats) error {
+	batch := dst.NewBatch()
+	iter := src.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		if _, _, ok := leveldb.ParseKey(key); ok || leveldb.IsBitCaskKey(key) || string(key) == string(leveldb.StoreBackendKey) {
+			continue
+		}
+		if err := batch.Put(common.CopyBytes(key), common.CopyBytes(iter.Value())); err != nil {
+			return err
+		}
+		stats.Indexes++
+		if batch.ValueSize() >= IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// copyDBFile copies the file if it exists
+func copyDBFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, os.ModePerm)
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseKey(t *testing.T) {
+	hash := common.HexToHash("0x48").Bytes()
+	for flag := leveldb.ItemFlagStart + 1; flag < leveldb.ItemFlagStop; flag++ {
+		parsedFlag, key, ok := leveldb.ParseKey(leveldb.Key(flag, hash))
+		assert.True(t, ok)
+		assert.Equal(t, flag, parsedFlag)
+		assert.Equal(t, hash, key)
+	}
+	_, _, ok := leveldb.ParseKey(leveldb.GetTxIndexKey(common.HexToHash("0x1")))
+	assert.False(t, ok)
+	_, _, ok = leveldb.ParseKey(leveldb.StableBlockKey)
+	assert.False(t, ok)
+}
+
+func TestMigrateDB(t *testing.T) {
+	blocks := newCheckerTestDB(t)
+	defer ClearData()
+	levelPath, bitcaskPath := GetStorePath()+"_leveldb", GetStorePath()+"_bitcask"
+	os.RemoveAll(levelPath)
+	os.RemoveAll(bitcaskPath)
+	defer os.RemoveAll(levelPath)
+	defer os.RemoveAll(bitcaskPath)
+
+	_, err := MigrateDB(GetStorePath(), levelPath, "rocksdb")
+	assert.Equal(t, ErrUnknownBackend, err)
+	_, err = MigrateDB(GetStorePath()+"_not_exist", levelPath, BackendLevelDB)
+	assert.Equal(t, ErrDBNotExist, err)
+	_, err = MigrateDB(GetStorePath(), levelPath, BackendBitCask)
+	assert.Equal(t, ErrBackendUnchanged, err)
+
+	// bitcask to leveldb
+	stats, err := MigrateDB(GetStorePath(), levelPath, BackendLevelDB)
+	assert.NoError(t, err)
+	assert.Equal(t, BackendBitCask, stats.From)
+	assert.NotEqual(t, 0, stats.Items)
+	assert.NotEqual(t, 0, stats.Indexes)
+	_, err = MigrateDB(GetStorePath(), levelPath, BackendLevelDB)
+	assert.Equal(t, ErrExist, err)
+
+	db := NewChainDataBase(levelPath)
+	assert.Equal(t, BackendLevelDB, db.Backend)
+	stable, err := db.GetStableBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[2].Hash(), stable.Hash())
+	block, err := db.GetBlockByHeight(1)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[1].Hash(), block.Hash())
+	db.Close()
+
+	// leveldb to bitcask
+	items := stats.Items
+	stats, err = MigrateDB(levelPath, bitcaskPath, BackendBitCask)
+	assert.NoError(t, err)
+	assert.Equal(t, BackendLevelDB, stats.From)
+	assert.Equal(t, items, stats.Items)
+	report, err := VerifyDB(bitcaskPath)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	_, err = VerifyDB(levelPath)
+	assert.Equal(t, ErrBackendNotSupported, err)
+
+	// the database can't be opened by another backend
+	assert.Panics(t, func() {
+		NewChainDataBaseWithBackend(levelPath, BackendBitCask)
+	})
+}
+
+func TestChainDatabase_MemoryBackend(t *testing.T) {
+	ClearData()
+	defer ClearData()
+	db := NewChainDataBaseWithBackend(GetStorePath(), BackendMemory)
+	defer db.Close()
+
+	block := CreateBlock(common.HexToHash("0x1"), common.Hash{}, 0)
+	assert.NoError(t, db.SetBlock(block.Hash(), block))
+	_, err := db.SetStableBlock(block.Hash())
+	assert.NoError(t, err)
+	// wait for writing to backend
+	time.Sleep(100 * time.Millisecond)
+	result, err := db.GetBlockByHeight(0)
+	assert.NoError(t, err)
+	assert.Equal(t, block.Hash(), result.Hash())
+	_, err = os.Stat(bitCaskPath(GetStorePath(), 0))
+	assert.True(t, os.IsNotExist(err))
+}
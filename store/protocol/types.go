@@ -21,11 +21,15 @@ type ChainDB interface {
 
 	LoadLatestBlock() (*types.Block, error)
 	SetStableBlock(hash common.Hash) ([]*types.Block, error)
+	SetSnapshotBlock(snapshot *store.Snapshot) error
 
 	GetAccount(addr common.Address) (*types.AccountData, error)
 
 	GetTrieDatabase() *store.TrieDatabase
 	GetActDatabase(hash common.Hash) (*store.AccountTrieDB, error)
+	GetHistoryActDatabase(hash common.Hash) (*store.AccountTrieDB, error)
+	GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error)
+	GetNodeData(hash common.Hash) ([]byte, error)
 
 	GetContractCode(hash common.Hash) (types.Code, error)
 	SetContractCode(hash common.Hash, code types.Code) error
@@ -37,6 +41,14 @@ type ChainDB interface {
 	GetAssetID(id common.Hash) (common.Address, error)
 	GetAssetCode(code common.Hash) (common.Address, error)
 
+	GetTxByHash(hash common.Hash) (*store.VTransactionDetail, error)
+	GetTxByAddr(src common.Address, index int, size int) ([]*store.VTransaction, uint32, error)
+
+	SetReceipts(hash common.Hash, receipts types.Receipts) error
+	GetReceipts(hash common.Hash) (types.Receipts, error)
+	GetReceipt(txHash common.Hash) (*types.Receipt, error)
+	GetBloom(hash common.Hash) (types.Bloom, error)
+
 	SerializeForks(currentHash common.Hash) string
 
 	Close() error
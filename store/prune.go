@@ -0,0 +1,238 @@
+package store
+
+import (
+	"bytes"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"sort"
+)
+
+// itemKeys returns the LevelDB which contains the keys of items. The keys are the item positions in bitcask backend, or the items themselves in leveldb backend
+func (database *ChainDatabase) itemKeys() *leveldb.LevelDBDatabase {
+	if itemDB, ok := database.Beansdb.Backend.(*LevelItemDatabase); ok {
+		return itemDB.LevelDB
+	}
+	return database.LevelDB
+}
+
+// indexedAddresses returns the addresses in the keys of items in flag. The index keys are dbPrefix + itemPrefix + address + dbSuffix
+func (database *ChainDatabase) indexedAddresses(flag uint32, dbPrefix, dbSuffix, itemPrefix []byte) []common.Address {
+	prefix := append(common.CopyBytes(dbPrefix), itemPrefix...)
+	keyLen := len(prefix) + common.AddressLength + len(dbSuffix)
+
+	found := make(map[common.Address]bool)
+	addresses := make([]common.Address, 0)
+	add := func(address common.Address) {
+		if !found[address] {
+			found[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	iter := database.itemKeys().NewIteratorWithPrefix(prefix)
+	for iter.Next() {
+		key := iter.Key()
+		// skip the other items with the same prefix, e.g. asset code
+		if len(key) != keyLen || !bytes.HasSuffix(key, dbSuffix) {
+			continue
+		}
+		add(common.BytesToAddress(key[len(prefix) : len(prefix)+common.AddressLength]))
+	}
+	iter.Release()
+
+	// the items in write queue are not indexed by level db yet
+	for _, key := range database.Beansdb.Queue.pendingKeys(flag) {
+		if len(key) == len(itemPrefix)+common.AddressLength && bytes.HasPrefix(key, itemPrefix) {
+			add(common.BytesToAddress(key[len(itemPrefix):]))
+		}
+	}
+	return addresses
+}
+
+// IterateAccounts calls fn with the latest state of every stored account
+func (database *ChainDatabase) IterateAccounts(fn func(account *types.AccountData) error) error {
+	for _, address := range database.indexedAddresses(leveldb.ItemFlagAct, leveldb.AccountPrefix, leveldb.AccountSuffix, nil) {
+		account, err := UtilsGetAccount(database.Beansdb, address)
+		if err == ErrAccountNotExist {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IterateUnconfirmedStates calls fn with every unconfirmed block and the accounts which are changed in it
+func (database *ChainDatabase) IterateUnconfirmedStates(fn func(block *types.Block, accounts []*types.AccountData) error) error {
+	type state struct {
+		block    *types.Block
+		accounts []*types.AccountData
+	}
+	database.RW.RLock()
+	states := make([]*state, 0)
+	database.LastConfirm.Walk(func(cBlock *CBlock) {
+		states = append(states, &state{cBlock.Block, cBlock.AccountTrieDB.Collect(cBlock.Block.Height())})
+	}, nil)
+	database.RW.RUnlock()
+
+	for _, s := range states {
+		if err := fn(s.block, s.accounts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneKeepHeights returns the heights of term snapshot blocks before pruneHeight, and pruneHeight itself. Their states are kept in pruning
+func PruneKeepHeights(pruneHeight uint32) []uint32 {
+	heights := make([]uint32, 0)
+	for height := uint32(0); height <= pruneHeight; height += params.TermDuration {
+		heights = append(heights, height)
+	}
+	if heights[len(heights)-1] != pruneHeight {
+		heights = append(heights, pruneHeight)
+	}
+	return heights
+}
+
+// isPrunedHeight returns true if the states at height are removed by pruning
+func isPrunedHeight(height uint32, pruneHeight uint32) bool {
+	return height < pruneHeight && height%params.TermDuration != 0
+}
+
+// isActHistoryKept returns true if the history record at height is the state at one of keepHeights, or it is after the last keep height.
+// newer is the height of the next record of the account
+func isActHistoryKept(keepHeights []uint32, height uint32, newer uint32) bool {
+	if height > keepHeights[len(keepHeights)-1] {
+		return true
+	}
+	i := sort.Search(len(keepHeights), func(i int) bool {
+		return keepHeights[i] >= height
+	})
+	return i < len(keepHeights) && keepHeights[i] < newer
+}
+
+// PruneActHistory unlinks the account history records which are neither the states at PruneKeepHeights nor the states after pruneHeight.
+// fn is called with the account in every retained record. It returns the keys of the retained records.
+// The unlinked records are still on disk until they are removed in compaction
+func (database *ChainDatabase) PruneActHistory(pruneHeight uint32, fn func(account *types.AccountData) error, quit <-chan struct{}) (map[string]bool, error) {
+	oldPruneHeight, err := leveldb.GetPruneHeight(database.LevelDB)
+	if err != nil {
+		return nil, err
+	}
+	if pruneHeight < oldPruneHeight {
+		return nil, ErrArgInvalid
+	}
+	// the queries for pruned states must fail before the records are unlinked
+	if err := leveldb.SetPruneHeight(database.LevelDB, pruneHeight); err != nil {
+		return nil, err
+	}
+
+	keepHeights := PruneKeepHeights(pruneHeight)
+	retained := make(map[string]bool)
+	pruned := 0
+	for _, address := range database.indexedAddresses(leveldb.ItemFlagActHistory, leveldb.ActHistoryPrefix, leveldb.ActHistorySuffix, []byte("H")) {
+		select {
+		case <-quit:
+			return nil, ErrCompactAborted
+		default:
+		}
+
+		accounts, count, err := database.pruneActHistory(address, keepHeights, retained)
+		if err != nil {
+			return nil, err
+		}
+		pruned += count
+		for _, account := range accounts {
+			if err := fn(account); err != nil {
+				return nil, err
+			}
+		}
+	}
+	log.Info("Prune account history done", "retained", len(retained), "pruned", pruned, "pruneHeight", pruneHeight)
+	return retained, nil
+}
+
+// pruneActHistory unlinks the history records of an account. It returns the accounts in retained records and the count of unlinked records
+func (database *ChainDatabase) pruneActHistory(address common.Address, keepHeights []uint32, retained map[string]bool) ([]*types.AccountData, int, error) {
+	// new stable block appends history records
+	database.RW.Lock()
+	defer database.RW.Unlock()
+
+	head, ok, err := utilsGetActHistoryHead(database.Beansdb, address)
+	if err != nil || !ok {
+		return nil, 0, err
+	}
+	// load the records from newest to oldest
+	heights := make([]uint32, 0)
+	records := make([]*accountHistory, 0)
+	for height := head; ; {
+		history, err := utilsGetActHistory(database.Beansdb, address, height)
+		if err == ErrActHistoryNotExist {
+			log.Warnf("history of account %s at height %d is missing", address.String(), height)
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		heights = append(heights, height)
+		records = append(records, history)
+		if !history.HasPrev {
+			break
+		}
+		height = history.PrevHeight
+	}
+
+	kept := make([]int, 0, len(heights))
+	for i, height := range heights {
+		// the newest record is always kept as the latest state
+		if i == 0 || isActHistoryKept(keepHeights, height, heights[i-1]) {
+			kept = append(kept, i)
+		}
+	}
+
+	// link the retained records
+	batch := database.Beansdb.NewBatch()
+	accounts := make([]*types.AccountData, 0, len(kept))
+	for n, i := range kept {
+		history := records[i]
+		changed := false
+		if n+1 < len(kept) {
+			prevHeight := heights[kept[n+1]]
+			if !history.HasPrev || history.PrevHeight != prevHeight {
+				history.PrevHeight, history.HasPrev = prevHeight, true
+				changed = true
+			}
+		} else if history.HasPrev {
+			// the older states are pruned
+			history.PrevHeight, history.HasPrev, history.Truncated = 0, false, true
+			changed = true
+		}
+		key := actHistoryKey(address, heights[i])
+		if changed {
+			buf, err := rlp.EncodeToBytes(history)
+			if err != nil {
+				return nil, 0, err
+			}
+			batch.Put(leveldb.ItemFlagActHistory, key, buf)
+		}
+		retained[string(key)] = true
+		accounts = append(accounts, history.Account)
+	}
+	if err := database.Beansdb.Commit(batch); err != nil {
+		return nil, 0, err
+	}
+	return accounts, len(heights) - len(kept), nil
+}
+
+// IsActHistoryRecord returns true if the item is an account history record but not the head of records
+func IsActHistoryRecord(flag uint32, key []byte) bool {
+	return flag == leveldb.ItemFlagActHistory && len(key) == len(actHistoryKey(common.Address{}, 0))
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/params"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestPruneKeepHeights(t *testing.T) {
+	oldTermDuration := params.TermDuration
+	params.TermDuration = 10
+	defer func() { params.TermDuration = oldTermDuration }()
+
+	assert.Equal(t, []uint32{0}, PruneKeepHeights(0))
+	assert.Equal(t, []uint32{0, 5}, PruneKeepHeights(5))
+	assert.Equal(t, []uint32{0, 10}, PruneKeepHeights(10))
+	assert.Equal(t, []uint32{0, 10, 20, 25}, PruneKeepHeights(25))
+}
+
+func TestChainDatabase_PruneActHistory(t *testing.T) {
+	oldTermDuration := params.TermDuration
+	params.TermDuration = 2
+	defer func() { params.TermDuration = oldTermDuration }()
+
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer cacheChain.Close()
+
+	// account 1 is changed in every block. account 2 is created in block 3
+	blocks := NewBlockBatch(5)
+	for i, block := range blocks {
+		cacheChain.SetBlock(block.Hash(), block)
+		actDatabase, err := cacheChain.GetActDatabase(block.Hash())
+		assert.NoError(t, err)
+		actDatabase.Put(GetAccount("0x01", int64(i+1)*100, uint32(i)), block.Height())
+		if i == 3 {
+			actDatabase.Put(GetAccount("0x02", 500, 1), block.Height())
+		}
+		_, err = cacheChain.SetStableBlock(block.Hash())
+		assert.NoError(t, err)
+	}
+
+	// keep the states at snapshot 0, 2 and the states since 4
+	accounts := make([]*types.AccountData, 0)
+	retained, err := cacheChain.PruneActHistory(4, func(account *types.AccountData) error {
+		accounts = append(accounts, account)
+		return nil
+	}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, retained, 5)
+	assert.Len(t, accounts, 5)
+	assert.True(t, retained[string(actHistoryKey(common.HexToAddress("0x01"), 2))])
+	assert.False(t, retained[string(actHistoryKey(common.HexToAddress("0x01"), 3))])
+
+	expects := []int64{100, 0, 300, 0, 500, 600}
+	for height, expect := range expects {
+		account, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x01"), uint32(height))
+		if expect == 0 {
+			assert.Equal(t, ErrActHistoryNotExist, err, "height=%d", height)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, expect, account.Balance.Int64(), "height=%d", height)
+		}
+	}
+	history, err := utilsGetActHistory(cacheChain.Beansdb, common.HexToAddress("0x01"), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), history.PrevHeight)
+	account, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x02"), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), account.Balance.Int64())
+
+	// prune height can't go back
+	_, err = cacheChain.PruneActHistory(3, func(account *types.AccountData) error { return nil }, nil)
+	assert.Equal(t, ErrArgInvalid, err)
+
+	// the unlinked records are removed in compaction. Wait for writing to bitcask files
+	time.Sleep(1 * time.Second)
+	stats, err := cacheChain.Compact(cacheChain.CompactWatermarks(), func(flag uint32, key []byte) bool {
+		return !IsActHistoryRecord(flag, key) || retained[string(key)]
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Dropped)
+	_, err = utilsGetActHistory(cacheChain.Beansdb, common.HexToAddress("0x01"), 3)
+	assert.Equal(t, ErrActHistoryNotExist, err)
+	account, err = UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x01"), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), account.Balance.Int64())
+}
+
+func TestChainDatabase_Compact_levelDB(t *testing.T) {
+	oldTermDuration := params.TermDuration
+	params.TermDuration = 10
+	defer func() { params.TermDuration = oldTermDuration }()
+
+	ClearData()
+	defer ClearData()
+	cacheChain := NewChainDataBaseWithBackend(GetStorePath(), BackendLevelDB)
+	// the account is changed in every block, and its history records are large
+	blocks := NewBlockBatch(39)
+	for i, block := range blocks {
+		cacheChain.SetBlock(block.Hash(), block)
+		actDatabase, err := cacheChain.GetActDatabase(block.Hash())
+		assert.NoError(t, err)
+		account := GetAccount("0x01", int64(i+1)*100, uint32(i))
+		account.Candidate.Profile = types.Profile{"padding": GetRandomString(64 * 1024)}
+		account.Candidate.Votes = new(big.Int)
+		actDatabase.Put(account, block.Height())
+		_, err = cacheChain.SetStableBlock(block.Hash())
+		assert.NoError(t, err)
+	}
+	// the queued items are written into backend on close
+	cacheChain.Close()
+	cacheChain = NewChainDataBase(GetStorePath())
+	defer cacheChain.Close()
+	itemDB, ok := cacheChain.Beansdb.Backend.(*LevelItemDatabase)
+	assert.True(t, ok)
+	before, err := dirSize(itemDB.LevelDB.Path())
+	assert.NoError(t, err)
+
+	watermarks := cacheChain.CompactWatermarks()
+	retained, err := cacheChain.PruneActHistory(35, func(account *types.AccountData) error { return nil }, nil)
+	assert.NoError(t, err)
+	assert.Len(t, retained, 9)
+	stats, err := cacheChain.Compact(watermarks, func(flag uint32, key []byte) bool {
+		return !IsActHistoryRecord(flag, key) || retained[string(key)]
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 31, stats.Dropped)
+	_, err = utilsGetActHistory(cacheChain.Beansdb, common.HexToAddress("0x01"), 33)
+	assert.Equal(t, ErrActHistoryNotExist, err)
+	account, err := UtilsGetAccountByHeight(cacheChain.Beansdb, common.HexToAddress("0x01"), 30)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3100), account.Balance.Int64())
+
+	// the disk space of dropped records is reclaimed
+	after, err := dirSize(itemDB.LevelDB.Path())
+	assert.NoError(t, err)
+	assert.True(t, stats.Reclaimed > 0)
+	assert.True(t, after*2 < before, "before=%d after=%d", before, after)
+}
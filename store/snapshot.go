@@ -0,0 +1,212 @@
+package store
+
+import (
+	"bytes"
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/log"
+	"github.com/LemoFoundationLtd/lemochain-core/common/rlp"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"math/big"
+	"sort"
+)
+
+// Snapshot is the state of a stable block which is synchronized from remote peers
+type Snapshot struct {
+	Block    *types.Block
+	Terms    []*types.Block // the deputy term snapshot blocks before Block
+	Accounts []*types.AccountData
+	Nodes    map[common.Hash][]byte // the trie nodes of version trie and accounts' tries
+	Codes    map[common.Hash][]byte // contract codes
+
+	// the asset indexes which are created by the transactions before Block
+	AssetCodes map[common.Hash]common.Address // asset code => issuer
+	AssetIds   map[common.Hash]common.Hash    // asset id => asset code
+}
+
+// snapshotAddresses returns at most count addresses of stored accounts from the start address in ascending order
+func (database *ChainDatabase) snapshotAddresses(start common.Address, count int) []common.Address {
+	addresses := make([]common.Address, 0, count)
+	startKey := leveldb.Key(leveldb.ItemFlagAct, start.Bytes())
+	iter := database.itemKeys().NewIteratorWithPrefix(leveldb.AccountPrefix)
+	for ok := iter.Seek(startKey); ok && len(addresses) < count; ok = iter.Next() {
+		key := iter.Key()
+		// skip the other items with the same prefix, e.g. asset code
+		if len(key) != len(startKey) || !bytes.HasSuffix(key, leveldb.AccountSuffix) {
+			continue
+		}
+		addresses = append(addresses, common.BytesToAddress(key[len(leveldb.AccountPrefix):len(key)-len(leveldb.AccountSuffix)]))
+	}
+	iter.Release()
+
+	// the accounts in write queue are not indexed by level db yet
+	for _, key := range database.Beansdb.Queue.pendingKeys(leveldb.ItemFlagAct) {
+		address := common.BytesToAddress(key)
+		if bytes.Compare(address.Bytes(), start.Bytes()) >= 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+	result := make([]common.Address, 0, len(addresses))
+	for i, address := range addresses {
+		if i == 0 || address != addresses[i-1] {
+			result = append(result, address)
+		}
+	}
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
+
+// GetSnapshotAccounts returns at most count accounts in the state of the stable block, whose address is not less than start. The accounts are sorted by address.
+// The state built on an unverified snapshot is not served, so that the unverified accounts are not spread to other nodes
+func (database *ChainDatabase) GetSnapshotAccounts(blockHash common.Hash, start common.Address, count int) ([]*types.AccountData, error) {
+	unverified, err := leveldb.GetUnverifiedSnapshot(database.LevelDB)
+	if err != nil {
+		return nil, err
+	}
+	if unverified != 0 {
+		return nil, ErrSnapshotUnverified
+	}
+	block, err := database.getBlock4DB(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	stable, err := UtilsGetBlockByHeight(database.Beansdb, block.Height())
+	if err != nil {
+		return nil, err
+	}
+	if stable == nil || stable.Hash() != blockHash {
+		return nil, ErrBlockNotExist
+	}
+
+	accounts := make([]*types.AccountData, 0, count)
+	for len(accounts) < count {
+		want := count - len(accounts)
+		addresses := database.snapshotAddresses(start, want)
+		for _, address := range addresses {
+			account, err := UtilsGetAccountByHeight(database.Beansdb, address, block.Height())
+			if err == ErrAccountNotExist {
+				// the account is created after the block
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, account)
+		}
+		if len(addresses) < want {
+			break
+		}
+		last := addresses[len(addresses)-1]
+		start = common.BigToAddress(new(big.Int).Add(last.Big(), big.NewInt(1)))
+		if start.Big().Cmp(last.Big()) <= 0 {
+			break
+		}
+	}
+	return accounts, nil
+}
+
+// GetNodeData returns the trie node or contract code by its hash
+func (database *ChainDatabase) GetNodeData(hash common.Hash) ([]byte, error) {
+	val, err := database.Beansdb.Get(leveldb.ItemFlagTrie, hash.Bytes())
+	if err != nil || val != nil {
+		return val, err
+	}
+	val, err = database.Beansdb.Get(leveldb.ItemFlagCode, hash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, ErrNotExist
+	}
+	return val, nil
+}
+
+// SetSnapshotBlock sets the block whose state is synchronized from remote as the base of local chain. The unconfirmed blocks are dropped, because they can't be linked to the new base block.
+// Only the versions of accounts are verified by the block, so the state is marked unverified and it is not served as a stable state
+func (database *ChainDatabase) SetSnapshotBlock(snapshot *Snapshot) error {
+	database.RW.Lock()
+	defer database.RW.Unlock()
+
+	block := snapshot.Block
+	if block == nil || (database.LastConfirm.Block != nil && block.Height() <= database.LastConfirm.Block.Height()) {
+		return ErrArgInvalid
+	}
+
+	batch := database.Beansdb.NewBatch()
+	// store blocks
+	for _, item := range append(snapshot.Terms, block) {
+		buf, err := rlp.EncodeToBytes(item)
+		if err != nil {
+			return err
+		}
+		batch.Put(leveldb.ItemFlagBlock, item.Hash().Bytes(), buf)
+		batch.Put(leveldb.ItemFlagBlockHeight, leveldb.EncodeNumber(item.Height()), item.Hash().Bytes())
+	}
+
+	// store state
+	for hash, node := range snapshot.Nodes {
+		batch.Put(leveldb.ItemFlagTrie, hash.Bytes(), node)
+	}
+	for hash, code := range snapshot.Codes {
+		batch.Put(leveldb.ItemFlagCode, hash.Bytes(), code)
+	}
+	for code, issuer := range snapshot.AssetCodes {
+		batch.Put(leveldb.ItemFlagAssetCode, code.Bytes(), issuer.Bytes())
+	}
+	for id, code := range snapshot.AssetIds {
+		batch.Put(leveldb.ItemFlagAssetId, id.Bytes(), code.Bytes())
+	}
+	for _, account := range snapshot.Accounts {
+		buf, err := rlp.EncodeToBytes(account)
+		if err != nil {
+			return err
+		}
+		batch.Put(leveldb.ItemFlagAct, account.Address.Bytes(), buf)
+
+		// the states before the block are unknown
+		history, err := rlp.EncodeToBytes(&accountHistory{Account: account, Truncated: true})
+		if err != nil {
+			return err
+		}
+		batch.Put(leveldb.ItemFlagActHistory, actHistoryKey(account.Address, block.Height()), history)
+		batch.Put(leveldb.ItemFlagActHistory, actHistoryHeadKey(account.Address), leveldb.EncodeNumber(block.Height()))
+	}
+	// mark before the state is written, so that the partial state is never served
+	if err := leveldb.SetUnverifiedSnapshot(database.LevelDB, block.Height()); err != nil {
+		return err
+	}
+	if err := database.Beansdb.Commit(batch); err != nil {
+		return err
+	}
+	if err := leveldb.SetCurrentBlock(database.LevelDB, block.Hash()); err != nil {
+		return err
+	}
+
+	newStable := NewGenesisBlock(block, database.Beansdb)
+	candidates := newStable.filterCandidates(snapshot.Accounts)
+	if len(candidates) > 0 {
+		if err := database.Context.SetCandidates(candidates); err != nil {
+			return err
+		}
+		if err := database.Context.Flush(); err != nil {
+			return err
+		}
+	}
+	registered := make([]*Candidate, 0, len(candidates))
+	for _, account := range snapshot.Accounts {
+		if database.isCandidate(account) {
+			registered = append(registered, &Candidate{Address: account.Address, Total: new(big.Int).Set(account.Candidate.Votes)})
+		}
+	}
+	newStable.Top.Rank(max_candidate_count, registered)
+
+	log.Warnf("Set unverified snapshot block as chain base. height: %d, accounts: %d, nodes: %d", block.Height(), len(snapshot.Accounts), len(snapshot.Nodes))
+	database.UnConfirmBlocks = make(map[common.Hash]*CBlock)
+	database.LastConfirm = newStable
+	return nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"github.com/LemoFoundationLtd/lemochain-core/chain/types"
+	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChainDatabase_GetSnapshotAccounts(t *testing.T) {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer cacheChain.Close()
+
+	// account 1~5 are created in block 1, account 6 is created in block 2
+	blocks := NewBlockBatch(2)
+	for i, block := range blocks {
+		assert.NoError(t, cacheChain.SetBlock(block.Hash(), block))
+		actDatabase, err := cacheChain.GetActDatabase(block.Hash())
+		assert.NoError(t, err)
+		if i == 1 {
+			for _, address := range []string{"0x05", "0x03", "0x01", "0x04", "0x02"} {
+				actDatabase.Put(GetAccount(address, 100, 1), block.Height())
+			}
+		}
+		if i == 2 {
+			actDatabase.Put(GetAccount("0x06", 100, 1), block.Height())
+			actDatabase.Put(GetAccount("0x01", 200, 2), block.Height())
+		}
+		_, err = cacheChain.SetStableBlock(block.Hash())
+		assert.NoError(t, err)
+	}
+
+	getAddresses := func(accounts []*types.AccountData) []common.Address {
+		result := make([]common.Address, len(accounts))
+		for i, account := range accounts {
+			result[i] = account.Address
+		}
+		return result
+	}
+	addresses := func(hexes ...string) []common.Address {
+		result := make([]common.Address, len(hexes))
+		for i, hex := range hexes {
+			result[i] = common.HexToAddress(hex)
+		}
+		return result
+	}
+
+	check := func() {
+		accounts, err := cacheChain.GetSnapshotAccounts(blocks[1].Hash(), common.Address{}, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, addresses("0x01", "0x02", "0x03"), getAddresses(accounts))
+		assert.Equal(t, int64(100), accounts[0].Balance.Int64())
+		// the account 6 is not in block 1
+		accounts, err = cacheChain.GetSnapshotAccounts(blocks[1].Hash(), common.HexToAddress("0x04"), 3)
+		assert.NoError(t, err)
+		assert.Equal(t, addresses("0x04", "0x05"), getAddresses(accounts))
+
+		accounts, err = cacheChain.GetSnapshotAccounts(blocks[2].Hash(), common.HexToAddress("0x01"), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, addresses("0x01", "0x02", "0x03", "0x04", "0x05", "0x06"), getAddresses(accounts))
+		assert.Equal(t, int64(200), accounts[0].Balance.Int64())
+	}
+	// some accounts may be still in write queue
+	check()
+	time.Sleep(500 * time.Millisecond)
+	check()
+
+	// not stable block
+	_, err := cacheChain.GetSnapshotAccounts(common.HexToHash("0x1234"), common.Address{}, 3)
+	assert.Equal(t, ErrBlockNotExist, err)
+}
+
+func TestChainDatabase_SetSnapshotBlock(t *testing.T) {
+	ClearData()
+	cacheChain := NewChainDataBase(GetStorePath())
+	defer func() { cacheChain.Close() }()
+
+	blocks := NewBlockBatch(3)
+	assert.NoError(t, cacheChain.SetBlock(blocks[0].Hash(), blocks[0]))
+	_, err := cacheChain.SetStableBlock(blocks[0].Hash())
+	assert.NoError(t, err)
+	// an unconfirmed block which will be dropped
+	assert.NoError(t, cacheChain.SetBlock(blocks[1].Hash(), blocks[1]))
+
+	account := GetAccount("0x01", 100, 3)
+	node := []byte{0x01, 0x02}
+	code := []byte{0x03, 0x04}
+	snapshot := &Snapshot{
+		Block:      blocks[3],
+		Terms:      []*types.Block{blocks[2]},
+		Accounts:   []*types.AccountData{account},
+		Nodes:      map[common.Hash][]byte{common.HexToHash("0x11"): node},
+		Codes:      map[common.Hash][]byte{common.HexToHash("0x22"): code},
+		AssetCodes: map[common.Hash]common.Address{common.HexToHash("0x33"): account.Address},
+		AssetIds:   map[common.Hash]common.Hash{common.HexToHash("0x44"): common.HexToHash("0x33")},
+	}
+	assert.NoError(t, cacheChain.SetSnapshotBlock(snapshot))
+	// lower than stable block
+	assert.Equal(t, ErrArgInvalid, cacheChain.SetSnapshotBlock(&Snapshot{Block: blocks[2]}))
+
+	stable, err := cacheChain.LoadLatestBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[3].Hash(), stable.Hash())
+	assert.Equal(t, 0, len(cacheChain.UnConfirmBlocks))
+	block, err := cacheChain.GetBlockByHeight(2)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[2].Hash(), block.Hash())
+	block, err = cacheChain.GetBlockByHeight(1)
+	assert.Equal(t, ErrBlockNotExist, err)
+
+	val, err := cacheChain.GetNodeData(common.HexToHash("0x11"))
+	assert.NoError(t, err)
+	assert.Equal(t, node, val)
+	val, err = cacheChain.GetNodeData(common.HexToHash("0x22"))
+	assert.NoError(t, err)
+	assert.Equal(t, code, val)
+	_, err = cacheChain.GetNodeData(common.HexToHash("0x55"))
+	assert.Equal(t, ErrNotExist, err)
+	issuer, err := cacheChain.GetAssetCode(common.HexToHash("0x33"))
+	assert.NoError(t, err)
+	assert.Equal(t, account.Address, issuer)
+
+	actDatabase, err := cacheChain.GetActDatabase(blocks[3].Hash())
+	assert.NoError(t, err)
+	result, err := actDatabase.Get(account.Address)
+	assert.NoError(t, err)
+	assert.Equal(t, account.Balance, result.Balance)
+	// the older states are unknown
+	_, err = UtilsGetAccountByHeight(cacheChain.Beansdb, account.Address, 2)
+	assert.Equal(t, ErrActHistoryNotExist, err)
+	// the unverified state is not served
+	_, err = cacheChain.GetSnapshotAccounts(blocks[3].Hash(), common.Address{}, 10)
+	assert.Equal(t, ErrSnapshotUnverified, err)
+	height, err := leveldb.GetUnverifiedSnapshot(cacheChain.LevelDB)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[3].Height(), height)
+
+	// restart
+	assert.NoError(t, cacheChain.Close())
+	cacheChain = NewChainDataBase(GetStorePath())
+	stable, err = cacheChain.LoadLatestBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[3].Hash(), stable.Hash())
+	_, err = leveldb.GetCurrentBlock(cacheChain.LevelDB)
+	assert.NoError(t, err)
+}
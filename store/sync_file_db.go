@@ -1,11 +1,9 @@
 package store
 
 import (
-	"fmt"
 	"github.com/LemoFoundationLtd/lemochain-core/common"
 	"github.com/LemoFoundationLtd/lemochain-core/common/log"
 	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
-	"path/filepath"
 )
 
 type WriteExtend interface {
@@ -15,8 +13,7 @@ type WriteExtend interface {
 type SyncFileDB struct {
 	Home string
 
-	Height   uint
-	BitCasks []*BitCask
+	Backend Database // the database which the items are written into
 
 	LevelDB *leveldb.LevelDBDatabase
 	Extend  WriteExtend
@@ -25,17 +22,13 @@ type SyncFileDB struct {
 	ErrChan   chan *Inject
 	WriteChan chan *Inject
 	Quit      chan struct{}
+	Stopped   chan struct{} // closed after the writes in WriteChan are flushed on quit
 }
 
-func (db *SyncFileDB) path(index int) string {
-	dataPathModule := filepath.Join(db.Home, "/%02d/%02d/")
-	return fmt.Sprintf(dataPathModule, index>>4, index&0xf)
-}
-
-func NewSyncFileDB(home string, levelDB *leveldb.LevelDBDatabase, doneChan chan *Inject, errChan chan *Inject, quit chan struct{}, extend WriteExtend) *SyncFileDB {
+func NewSyncFileDB(home string, levelDB *leveldb.LevelDBDatabase, backend Database, doneChan chan *Inject, errChan chan *Inject, quit chan struct{}, extend WriteExtend) *SyncFileDB {
 	return &SyncFileDB{
 		Home:    home,
-		Height:  2,
+		Backend: backend,
 		LevelDB: levelDB,
 		Extend:  extend,
 
@@ -43,55 +36,59 @@ func NewSyncFileDB(home string, levelDB *leveldb.LevelDBDatabase, doneChan chan
 		ErrChan:   errChan,
 		WriteChan: make(chan *Inject, 1024*256),
 		Quit:      quit,
-	}
-}
-
-func (db *SyncFileDB) newBitCask(index int) *BitCask {
-	path := db.path(index)
-	bitCask, err := NewBitCask(path, index, db.LevelDB)
-	if err != nil {
-		panic("create bit cask err: " + err.Error())
-	} else {
-		return bitCask
+		Stopped:   make(chan struct{}),
 	}
 }
 
 func (db *SyncFileDB) Open() {
-	count := 1 << (uint(db.Height) * 4)
-	db.BitCasks = make([]*BitCask, count)
-
-	for index := 0; index < count; index++ {
-		db.BitCasks[index] = db.newBitCask(index)
-	}
-
 	go db.start(db.DoneChan, db.ErrChan)
 }
 
 func (db *SyncFileDB) start(Done chan *Inject, Err chan *Inject) {
+	defer close(db.Stopped)
 	for {
 		select {
 		case <-db.Quit:
+			db.flush()
 			return
 		case writeOp := <-db.WriteChan:
-			err := db.put(writeOp.Flg, writeOp.Key, writeOp.Val)
-			if err != nil {
-				log.Errorf("bitcask put data err: %s, flg: %d, key: %s", err.Error(), writeOp.Flg, common.ToHex(writeOp.Key))
-				Err <- writeOp
+			if err := db.write(writeOp); err != nil {
+				select {
+				case Err <- writeOp:
+				case <-db.Quit:
+				}
 			} else {
-				db.afterWriteExtend(writeOp)
 				Done <- writeOp
 			}
 		}
 	}
 }
 
-func (db *SyncFileDB) Get(flag uint32, key []byte) ([]byte, error) {
-	bitcask := db.route(key)
-	if bitcask == nil {
-		panic(fmt.Sprintf("queue get k/v. bitcask is nil.key: %s", common.ToHex(key)))
-	} else {
-		return bitcask.Get(flag, key)
+// write puts the item into backend and writes its index
+func (db *SyncFileDB) write(op *Inject) error {
+	err := db.put(op.Flg, op.Key, op.Val)
+	if err != nil {
+		log.Errorf("bitcask put data err: %s, flg: %d, key: %s", err.Error(), op.Flg, common.ToHex(op.Key))
+		return err
 	}
+	db.afterWriteExtend(op)
+	return nil
+}
+
+// flush writes the items left in WriteChan. They are still in queue file, so the failed ones are written again in next start
+func (db *SyncFileDB) flush() {
+	for {
+		select {
+		case writeOp := <-db.WriteChan:
+			_ = db.write(writeOp)
+		default:
+			return
+		}
+	}
+}
+
+func (db *SyncFileDB) Get(flag uint32, key []byte) ([]byte, error) {
+	return db.Backend.Get(flag, key)
 }
 
 func (db *SyncFileDB) Put(flag uint32, key []byte, val []byte) {
@@ -110,8 +107,7 @@ func (db *SyncFileDB) Put(flag uint32, key []byte, val []byte) {
 }
 
 func (db *SyncFileDB) put(flag uint32, key []byte, val []byte) error {
-	bitcask := db.route(key)
-	return bitcask.Put(flag, key, val)
+	return db.Backend.Put(flag, key, val)
 }
 
 func (db *SyncFileDB) afterWriteExtendSuc(op *Inject) {
@@ -140,8 +136,10 @@ func (db *SyncFileDB) afterWriteExtend(op *Inject) {
 	}
 }
 
-func (db *SyncFileDB) route(key []byte) *BitCask {
-	num := Byte2Uint32(key)
-	index := num >> ((8 - db.Height) * 4)
-	return db.BitCasks[index]
+// bitCasks returns the bitcasks if the items are stored in bitcask files
+func (db *SyncFileDB) bitCasks() []*BitCask {
+	if backend, ok := db.Backend.(*BitCaskDatabase); ok {
+		return backend.BitCasks
+	}
+	return nil
 }
@@ -17,13 +17,16 @@
 package trie
 
 import (
+	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
 	"testing"
 	"time"
 
 	"github.com/LemoFoundationLtd/lemochain-core/common"
+	"github.com/LemoFoundationLtd/lemochain-core/common/crypto"
 	"github.com/LemoFoundationLtd/lemochain-core/store"
+	"github.com/LemoFoundationLtd/lemochain-core/store/leveldb"
 )
 
 func init() {
@@ -31,59 +34,59 @@ func init() {
 }
 
 func TestProof(t *testing.T) {
-	// trie, vals := randomTrie(500)
-	// root := trie.Hash()
-	// for _, kv := range vals {
-	// 	proofs, _ := store.NewMemDatabase()
-	// 	// if trie.Prove(kv.k, 0, proofs) != nil {
-	// 	// 	t.Fatalf("missing key %x while constructing proof", kv.k)
-	// 	// }
-	// 	val, err, _ := VerifyProof(root, kv.k, proofs)
-	// 	if err != nil {
-	// 		t.Fatalf("VerifyProof error for key %x: %v\nraw proof: %v", kv.k, err, proofs)
-	// 	}
-	// 	if !bytes.Equal(val, kv.v) {
-	// 		t.Fatalf("VerifyProof returned wrong value for key %x: got %x, want %x", kv.k, val, kv.v)
-	// 	}
-	// }
+	trie, vals := randomTrie(500)
+	root := trie.Hash()
+	for _, kv := range vals {
+		proofs, _ := store.NewMemDatabase()
+		if trie.Prove(kv.k, 0, proofs) != nil {
+			t.Fatalf("missing key %x while constructing proof", kv.k)
+		}
+		val, err, _ := VerifyProof(root, kv.k, proofs)
+		if err != nil {
+			t.Fatalf("VerifyProof error for key %x: %v\nraw proof: %v", kv.k, err, proofs)
+		}
+		if !bytes.Equal(val, kv.v) {
+			t.Fatalf("VerifyProof returned wrong value for key %x: got %x, want %x", kv.k, val, kv.v)
+		}
+	}
 }
 
 func TestOneElementProof(t *testing.T) {
-	// trie := new(Trie)
-	// updateString(trie, "k", "v")
-	// proofs, _ := store.NewMemDatabase()
-	// // trie.Prove([]byte("k"), 0, proofs)
-	// // if len(proofs.Keys()) != 1 {
-	// // 	t.Error("proof should have one element")
-	// // }
-	// val, err, _ := VerifyProof(trie.Hash(), []byte("k"), proofs)
-	// if err != nil {
-	// 	t.Fatalf("VerifyProof error: %v\nproof hashes: %v", err, proofs.Keys())
-	// }
-	// if !bytes.Equal(val, []byte("v")) {
-	// 	t.Fatalf("VerifyProof returned wrong value: got %x, want 'k'", val)
-	// }
+	trie := new(Trie)
+	updateString(trie, "k", "v")
+	proofs, _ := store.NewMemDatabase()
+	trie.Prove([]byte("k"), 0, proofs)
+	if len(proofs.Keys()) != 1 {
+		t.Error("proof should have one element")
+	}
+	val, err, _ := VerifyProof(trie.Hash(), []byte("k"), proofs)
+	if err != nil {
+		t.Fatalf("VerifyProof error: %v\nproof hashes: %v", err, proofs.Keys())
+	}
+	if !bytes.Equal(val, []byte("v")) {
+		t.Fatalf("VerifyProof returned wrong value: got %x, want 'k'", val)
+	}
 }
 
 func TestVerifyBadProof(t *testing.T) {
-	// trie, vals := randomTrie(800)
-	// root := trie.Hash()
-	// for _, kv := range vals {
-	// 	proofs, _ := store.NewMemDatabase()
-	// 	// trie.Prove(kv.k, 0, proofs)
-	// 	// if len(proofs.Keys()) == 0 {
-	// 	// 	t.Fatal("zero length proof")
-	// 	// }
-	// 	keys := proofs.Keys()
-	// 	key := keys[mrand.Intn(len(keys))]
-	// 	node, _ := proofs.Get(key)
-	// 	proofs.Delete(key)
-	// 	mutateByte(node)
-	// 	proofs.Put(crypto.Keccak256(node), node)
-	// 	if _, err, _ := VerifyProof(root, kv.k, proofs); err == nil {
-	// 		t.Fatalf("expected proof to fail for key %x", kv.k)
-	// 	}
-	// }
+	trie, vals := randomTrie(800)
+	root := trie.Hash()
+	for _, kv := range vals {
+		proofs, _ := store.NewMemDatabase()
+		trie.Prove(kv.k, 0, proofs)
+		if len(proofs.Keys()) == 0 {
+			t.Fatal("zero length proof")
+		}
+		keys := proofs.Keys()
+		key := keys[mrand.Intn(len(keys))]
+		node, _ := proofs.Get(leveldb.ItemFlagTrie, key)
+		proofs.Delete(leveldb.ItemFlagTrie, key)
+		mutateByte(node)
+		proofs.Put(leveldb.ItemFlagTrie, crypto.Keccak256(node), node)
+		if _, err, _ := VerifyProof(root, kv.k, proofs); err == nil {
+			t.Fatalf("expected proof to fail for key %x", kv.k)
+		}
+	}
 }
 
 // mutateByte changes one byte in b.
@@ -98,20 +101,20 @@ func mutateByte(b []byte) {
 }
 
 func BenchmarkProve(b *testing.B) {
-	// trie, vals := randomTrie(100)
-	// var keys []string
-	// for k := range vals {
-	// 	keys = append(keys, k)
-	// }
-	//
-	// b.ResetTimer()
-	// for i := 0; i < b.N; i++ {
-	// 	// kv := vals[keys[i%len(keys)]]
-	// 	// proofs, _ := store.NewMemDatabase()
-	// 	// if trie.Prove(kv.k, 0, proofs); len(proofs.Keys()) == 0 {
-	// 	// 	b.Fatalf("zero length proof for %x", kv.k)
-	// 	// }
-	// }
+	trie, vals := randomTrie(100)
+	var keys []string
+	for k := range vals {
+		keys = append(keys, k)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kv := vals[keys[i%len(keys)]]
+		proofs, _ := store.NewMemDatabase()
+		if trie.Prove(kv.k, 0, proofs); len(proofs.Keys()) == 0 {
+			b.Fatalf("zero length proof for %x", kv.k)
+		}
+	}
 }
 
 func BenchmarkVerifyProof(b *testing.B) {
@@ -122,7 +125,7 @@ func BenchmarkVerifyProof(b *testing.B) {
 	for k := range vals {
 		keys = append(keys, k)
 		proof, _ := store.NewMemDatabase()
-		// trie.Prove([]byte(k), 0, proof)
+		trie.Prove([]byte(k), 0, proof)
 		proofs = append(proofs, proof)
 	}
 
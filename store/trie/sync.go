@@ -217,7 +217,7 @@ func (s *TrieSync) Process(results []SyncResult) (bool, int, error) {
 func (s *TrieSync) Commit(dbw store.Putter) (int, error) {
 	// Dump the membatch into a database dbw
 	for i, key := range s.membatch.order {
-		if err := dbw.Put(0, key[:], s.membatch.batch[key]); err != nil {
+		if err := dbw.Put(leveldb.ItemFlagTrie, key[:], s.membatch.batch[key]); err != nil {
 			return i, err
 		}
 	}
@@ -13,7 +13,17 @@ var (
 	ErrBlockNotExist        = errors.New("block does not exist")
 	ErrStableBlockNotExist  = errors.New("stable block does not exist")
 	ErrAccountNotExist      = errors.New("account does not exist")
+	ErrTxNotExist           = errors.New("transaction does not exist")
+	ErrReceiptNotExist      = errors.New("receipt does not exist")
+	ErrBloomNotExist        = errors.New("bloom does not exist")
+	ErrActHistoryNotExist   = errors.New("account history does not exist")
+	ErrSnapshotUnverified   = errors.New("the state is synchronized from remote and not verified")
 	ErrAncestorsNotExist    = errors.New("the block's ancestors does not exist")
+	ErrCompactAborted       = errors.New("compaction is aborted")
+	ErrUnknownBackend       = errors.New("unknown store backend")
+	ErrBackendMismatch      = errors.New("store backend mismatch")
+	ErrBackendNotSupported  = errors.New("the operation is not supported by store backend")
+	ErrBackendUnchanged     = errors.New("the database is stored by the backend already")
 	ErrEOF                  = errors.New("file EOF")
 	ErrRlpEncode            = errors.New("rlp encode err")
 	ErrOutOfMemory          = errors.New("out of memory")